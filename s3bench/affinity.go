@@ -0,0 +1,123 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	mathrand "math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The "-download-affinity" modes, which objects the download workers read: sequential cycles
+// through the stored objects in the order of the trials, worker has every worker read the objects
+// it uploaded itself, random samples the stored objects uniformly and shuffled cycles through a
+// fixed random permutation of them.
+const (
+	affinitySequential = "sequential"
+	affinityWorker     = "worker"
+	affinityRandom     = "random"
+	affinityShuffled   = "shuffled"
+)
+
+func validateAffinity(mode string) error {
+	switch mode {
+	case affinitySequential, affinityWorker, affinityRandom, affinityShuffled:
+		return nil
+	}
+	return fmt.Errorf(`unsupported "-download-affinity" %q, expected "sequential", "worker", "random" or "shuffled"`, mode)
+}
+
+// downloadAffinity maps the downloads of every worker onto the stored objects. A nil
+// downloadAffinity is the sequential mode.
+type downloadAffinity struct {
+	mode string
+	seed int64
+
+	mu sync.Mutex
+	// owners are the workers which uploaded the trials, of the successful uploads only.
+	owners map[int]int
+	perm   []int
+}
+
+// newDownloadAffinity seeds the random modes with seed, or with the clock when it is 0.
+func newDownloadAffinity(mode string, seed int64) *downloadAffinity {
+	if mode == affinitySequential {
+		return nil
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &downloadAffinity{mode: mode, seed: seed, owners: map[int]int{}}
+}
+
+// wrap notes which worker uploaded which trial, for the worker mode.
+func (a *downloadAffinity) wrap(record func(sample)) func(sample) {
+	if a == nil || a.mode != affinityWorker {
+		return record
+	}
+	return func(s sample) {
+		if s.err == nil && !s.cancelled {
+			a.mu.Lock()
+			a.owners[s.trial] = s.worker
+			a.mu.Unlock()
+		}
+		record(s)
+	}
+}
+
+// picker maps the i-th download of a phase to the trial the worker reads. In the worker mode, a
+// worker which uploaded nothing reads sequentially.
+func (a *downloadAffinity) picker(r runner, worker int) func(i int) int {
+	if a == nil {
+		return r.storedTrial
+	}
+	switch a.mode {
+	case affinityWorker:
+		a.mu.Lock()
+		var owned []int
+		for trial, owner := range a.owners {
+			if owner == worker {
+				owned = append(owned, trial)
+			}
+		}
+		a.mu.Unlock()
+		if len(owned) == 0 {
+			return r.storedTrial
+		}
+		sort.Ints(owned)
+		n := 0
+		return func(int) int {
+			n++
+			return owned[(n-1)%len(owned)]
+		}
+	case affinityRandom:
+		// Every pass of a worker draws the same sequence, e.g. for the cache probe.
+		rng := mathrand.New(mathrand.NewSource(a.seed + int64(worker)))
+		return func(int) int {
+			return r.storedTrial(rng.Intn(r.storedCount()) + 1)
+		}
+	default:
+		perm := a.permutation(r.storedCount())
+		return func(i int) int {
+			return r.storedTrial(perm[(i-1)%len(perm)] + 1)
+		}
+	}
+}
+
+// permutation is drawn once, so that every pass reads the objects in the same order.
+func (a *downloadAffinity) permutation(n int) []int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.perm) != n {
+		a.perm = mathrand.New(mathrand.NewSource(a.seed)).Perm(n)
+	}
+	return a.perm
+}
+
+func (a *downloadAffinity) String() string {
+	if a == nil {
+		return affinitySequential
+	}
+	return a.mode
+}
@@ -0,0 +1,169 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// altPresignExpiry is how long the presigned URLs of "-alt-presign" are valid: the whole phase.
+const altPresignExpiry = time.Hour
+
+// altEndpoint downloads the objects once more from an alternate endpoint, e.g. a CDN in front
+// of the bucket. Such endpoints rarely accept signed requests, so the objects are either
+// fetched anonymously or through URLs presigned for the origin.
+type altEndpoint struct {
+	base    *url.URL
+	presign bool
+	client  *http.Client
+	// conns keeps the alternate endpoint's connections out of the origin's counts.
+	conns *connTracker
+}
+
+func newAltEndpoint(value string, presign bool, resolve resolveFlags, localIP net.IP, ipVersion string, wire *wireCounter) (*altEndpoint, error) {
+	if !strings.Contains(value, "://") {
+		value = "https://" + value
+	}
+	base, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	if base.Host == "" || (base.Scheme != "https" && base.Scheme != "http") {
+		return nil, fmt.Errorf(`%q is neither a host nor an http(s) URL`, value)
+	}
+	transport, err := minio.DefaultTransport(base.Scheme == "https")
+	if err != nil {
+		return nil, err
+	}
+	if needsDialer(resolve, localIP, ipVersion) {
+		transport.DialContext = newDialContext(resolve, localIP, ipVersion)
+	}
+	transport.DialContext = wire.dialContext(transport.DialContext)
+	return &altEndpoint{base: base, presign: presign, client: &http.Client{Transport: transport}, conns: newConnTracker()}, nil
+}
+
+// objectURL is where the alternate endpoint serves the object. Anonymous downloads use the path
+// of the endpoint URL, if any, in place of the bucket; presigned URLs keep the origin's path,
+// which is part of their signature.
+func (a *altEndpoint) objectURL(origin ObjectStore, bucketName, key string) (*url.URL, error) {
+	if a.presign {
+		u, err := origin.PresignedGetObject(context.Background(), bucketName, key, altPresignExpiry, nil)
+		if err != nil {
+			return nil, err
+		}
+		u.Scheme, u.Host = a.base.Scheme, a.base.Host
+		return u, nil
+	}
+	u := *a.base
+	if u.Path == "" {
+		u.Path = "/" + bucketName
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	return &u, nil
+}
+
+// AltEndpointStats compares the downloads from "-alt-endpoint" with those from the origin.
+// Every object is fetched twice: cold, likely a cache miss at the edge, and warm right after.
+type AltEndpointStats struct {
+	Endpoint  string     `json:"endpoint"`
+	Presigned bool       `json:"presigned"`
+	Cold      PhaseStats `json:"cold"`
+	Warm      PhaseStats `json:"warm"`
+}
+
+func (s AltEndpointStats) String(origin PhaseStats) string {
+	var sb strings.Builder
+	mode := "anonymous"
+	if s.Presigned {
+		mode = "presigned"
+	}
+	fmt.Fprintf(&sb, " Alt endpoint: %s (%s, n=%d)\n", s.Endpoint, mode, s.Cold.Count)
+	fmt.Fprintf(&sb, "   %-10s %14s %14s %14s\n", "", "origin", "cold", "warm")
+	for _, row := range []struct {
+		name   string
+		values [3]time.Duration
+	}{
+		{"p90.time", [3]time.Duration{origin.P90Time, s.Cold.P90Time, s.Warm.P90Time}},
+		{"p90.ttfb", [3]time.Duration{origin.P90TTFB, s.Cold.P90TTFB, s.Warm.P90TTFB}},
+		{"avg.ttfb", [3]time.Duration{origin.AvgTTFB, s.Cold.AvgTTFB, s.Warm.AvgTTFB}},
+	} {
+		fmt.Fprintf(&sb, "   %-10s %14v %14v %14v\n", row.name,
+			formatDuration(row.values[0]), formatDuration(row.values[1]), formatDuration(row.values[2]))
+	}
+	fmt.Fprintf(&sb, "   %-10s %9s MB/s %9s MB/s %9s MB/s\n", "avg.speed", formatSpeed(origin.AvgSpeed), formatSpeed(s.Cold.AvgSpeed), formatSpeed(s.Warm.AvgSpeed))
+	return sb.String()
+}
+
+// checkAltEndpoint fetches every uploaded object cold and then warm from the alternate endpoint.
+func (r runner) checkAltEndpoint() *AltEndpointStats {
+	cold, warm := r.newPhaseRecorder(), r.newPhaseRecorder()
+	schedule{workers: r.concurrency, trials: r.uploaded, abort: cold.abort}.run(func(worker int) operation {
+		return func(i int, stage string) sample {
+			trial := r.storedTrial(i)
+			key, size := r.key(trial), r.sizes.size(trial)
+			target, err := r.altEndpoint.objectURL(r.client, r.bucket(trial), key)
+			if err != nil {
+				return r.failed("alt-cold", stage, sample{host: r.altEndpoint.base.Host, trial: i, key: key, start: time.Now()},
+					fmt.Errorf(`Unable to presign %s, %w`, key, err))
+			}
+			s := r.altFetch("alt-cold", stage, worker, i, key, target, size)
+			if s.err == nil {
+				w := r.altFetch("alt-warm", stage, worker, i, key, target, size)
+				w.stage, w.worker = stage, worker
+				warm.record(w)
+			}
+			return s
+		}
+	}, cold.record)
+
+	return &AltEndpointStats{Endpoint: r.altEndpoint.base.Host, Presigned: r.altEndpoint.presign, Cold: cold.stats(), Warm: warm.stats()}
+}
+
+func (r runner) altFetch(phase, stage string, worker, i int, key string, target *url.URL, size int64) sample {
+	host := target.Host
+	ctx, conns := r.altEndpoint.conns.trace(context.Background())
+	failure := sample{host: host, trial: i, key: key, start: time.Now()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return r.failed(phase, stage, failure, fmt.Errorf(`Unable to download %s from %s, %w`, key, host, err))
+	}
+
+	startTime := time.Now()
+	resp, err := r.altEndpoint.client.Do(req)
+	if err != nil {
+		return r.failed(phase, stage, failure, fmt.Errorf(`Unable to download %s from %s, %w`, key, host, err))
+	}
+	payloadSize, err := io.Copy(io.Discard, resp.Body)
+	duration := time.Since(startTime)
+	ttfb := conns.timeToFirstByte(startTime)
+	resp.Body.Close()
+
+	failure.start, failure.duration, failure.bytes = startTime, duration, payloadSize
+	switch {
+	case err != nil:
+		return r.failed(phase, stage, failure, fmt.Errorf(`Unable to receive %s from %s after %d of %d bytes, %w`, key, host, payloadSize, size, err))
+	case resp.StatusCode != http.StatusOK:
+		// An error response is no part of the object.
+		failure.bytes = 0
+		return r.failed(phase, stage, failure, fmt.Errorf(`Unable to download %s from %s, %s`, key, host, resp.Status))
+	case payloadSize != size:
+		return r.failed(phase, stage, failure, fmt.Errorf(`Unmatched sizes of %s from %s: actual=%d, expected=%d`, key, host, payloadSize, size))
+	}
+
+	speed := transferSpeed(payloadSize, duration)
+	r.statsd.timing(phase+".duration", duration)
+	r.events.write(Event{
+		Variant: r.title, Phase: phase, Stage: stage, Worker: worker, Host: host, Trial: i, Key: key, Start: startTime,
+		Duration: duration, Bytes: payloadSize, Speed: speed, TTFB: ttfb,
+	})
+	fmt.Fprintf(r.progress, " - Trial: %d%s,\t%s time=%s, ttfb=%s, speed=%s MB/s\n", i, stageMark(stage), strings.TrimPrefix(phase, "alt-"), formatDuration(duration), formatDuration(ttfb), formatSpeed(speed))
+	return sample{host: host, trial: i, key: key, start: startTime, duration: duration, bytes: payloadSize, speed: speed, ttfb: ttfb}
+}
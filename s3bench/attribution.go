@@ -0,0 +1,98 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// attributionBarWidth is the width of the stacked bar of a phase.
+const attributionBarWidth = 40
+
+// requestTimes are the parts of the requests of a trial as httptrace sees them: getting a
+// connection (DNS, dial and TLS unless reused), writing the request with its body and waiting
+// for the first byte of the response.
+type requestTimes struct {
+	connect, write, wait time.Duration
+}
+
+func (t requestTimes) since(earlier requestTimes) requestTimes {
+	return requestTimes{connect: t.connect - earlier.connect, write: t.write - earlier.write, wait: t.wait - earlier.wait}
+}
+
+// LatencyBudget attributes the summed time of the plateau trials of a phase, client CPU spent
+// outside of the timed operations included, to where it went. Transfer is what is left of the
+// operation times after the request parts, mostly the response body. Overlapping requests of a
+// trial, such as the parts of a multipart upload, count for each of them, which the remainder
+// then misses.
+type LatencyBudget struct {
+	ClientCPU time.Duration `json:"client_cpu"`
+	Connect   time.Duration `json:"connect"`
+	Write     time.Duration `json:"write"`
+	Server    time.Duration `json:"server"`
+	Transfer  time.Duration `json:"transfer"`
+}
+
+// LatencyAttribution is the latency budget of the upload and the download phase with
+// "-attribution".
+type LatencyAttribution struct {
+	Upload   LatencyBudget `json:"upload"`
+	Download LatencyBudget `json:"download"`
+}
+
+// add accounts for a trial which took duration, cpu of it outside of the timing.
+func (b *LatencyBudget) add(cpu, duration time.Duration, requests requestTimes) {
+	b.ClientCPU += cpu
+	b.Connect += requests.connect
+	b.Write += requests.write
+	b.Server += requests.wait
+	if rest := duration - requests.connect - requests.write - requests.wait; rest > 0 {
+		b.Transfer += rest
+	}
+}
+
+func (b LatencyBudget) total() time.Duration {
+	return b.ClientCPU + b.Connect + b.Write + b.Server + b.Transfer
+}
+
+func (b LatencyBudget) String() string {
+	total := b.total()
+	if total == 0 {
+		return "no trials"
+	}
+	parts := []struct {
+		mark  string
+		name  string
+		value time.Duration
+	}{{"c", "cpu", b.ClientCPU}, {"n", "connect", b.Connect}, {"w", "write", b.Write}, {"s", "server", b.Server}, {"t", "transfer", b.Transfer}}
+	var bar strings.Builder
+	shares := make([]string, 0, len(parts))
+	for _, p := range parts {
+		share := float64(p.value) / float64(total)
+		bar.WriteString(strings.Repeat(p.mark, int(share*attributionBarWidth+0.5)))
+		shares = append(shares, fmt.Sprintf("%s=%.1f%%", p.name, share*100))
+	}
+	return fmt.Sprintf("[%-*.*s] %s", attributionBarWidth, attributionBarWidth, bar.String(), strings.Join(shares, " "))
+}
+
+func (a LatencyAttribution) String() string {
+	return fmt.Sprintf(` Attribution : shares of the summed trial times
+  upload     %s
+  download   %s
+  (c)pu: payload generation, digests, compression and block checks outside of the timing;
+  co(n)nect, (w)rite and (s)erver wait until the first response byte per request by httptrace;
+  (t)ransfer: the rest of the operation, mostly the response body. Approximate: overlapping
+  requests of a trial are counted each.
+`, a.Upload, a.Download)
+}
+
+// attribution returns the request times of the trial so far.
+func (c *trialConns) attribution() requestTimes {
+	return requestTimes{
+		connect: time.Duration(atomic.LoadInt64(&c.connectTime)),
+		write:   time.Duration(atomic.LoadInt64(&c.writeTime)),
+		wait:    time.Duration(atomic.LoadInt64(&c.waitTime)),
+	}
+}
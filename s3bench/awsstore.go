@@ -0,0 +1,447 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awscredentials "github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithymiddleware "github.com/aws/smithy-go/middleware"
+	"github.com/minio/minio-go/v7"
+)
+
+// The "-sdk" client libraries.
+const (
+	sdkMinio = "minio"
+	sdkAWS   = "aws"
+)
+
+// The transfer manager uploads in the parts and with the concurrency minio-go uses by default,
+// so that the SDKs differ in their implementation only.
+const (
+	awsPartSize          = 16 * 1024 * 1024
+	awsUploadConcurrency = 4
+	// awsDefaultRegion signs for non-AWS endpoints, which mostly ignore the region.
+	awsDefaultRegion = "us-east-1"
+)
+
+// awsStore adapts the s3.Client of aws-sdk-go-v2 to ObjectStore, its errors turned into the
+// minio.ErrorResponse the rest of the benchmark classifies.
+type awsStore struct {
+	client   *s3.Client
+	uploader *manager.Uploader
+	presign  *s3.PresignClient
+	endpoint *url.URL
+}
+
+func newAWSClient(endpoint string, opts clientOptions) (ObjectStore, error) {
+	if opts.signature != signatureV4 {
+		return nil, fmt.Errorf(`"-sdk aws" only signs with signature v4`)
+	}
+	address, err := parseEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	transport, pathStyle, err := newTransport(endpoint, address, opts)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "http"
+	if address.secure() {
+		scheme = "https"
+	}
+	base := &url.URL{Scheme: scheme, Host: address.host}
+	region := opts.region
+	if region == "" {
+		region = awsDefaultRegion
+	}
+	// Like minio-go, only AWS itself gets virtual-host-style requests.
+	host := base.Hostname()
+	pathStyle = pathStyle || !strings.HasSuffix(host, ".amazonaws.com")
+
+	client := s3.New(s3.Options{
+		BaseEndpoint: aws.String(base.String()),
+		Region:       region,
+		Credentials:  awscredentials.NewStaticCredentialsProvider(opts.accessKey, opts.secretKey, ""),
+		HTTPClient:   &http.Client{Transport: transport},
+		UsePathStyle: pathStyle,
+		APIOptions:   []func(*smithymiddleware.Stack) error{awsmiddleware.AddUserAgentKeyValue(appName, appVersion(opts.userAgentSuffix))},
+	})
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		u.PartSize, u.Concurrency = awsPartSize, awsUploadConcurrency
+	})
+	return awsStore{client: client, uploader: uploader, presign: s3.NewPresignClient(client), endpoint: base}, nil
+}
+
+func (s awsStore) EndpointURL() *url.URL {
+	u := *s.endpoint
+	return &u
+}
+
+func (s awsStore) PutObject(ctx context.Context, bucketName, key string, reader io.Reader, size int64, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	input := &s3.PutObjectInput{Bucket: aws.String(bucketName), Key: aws.String(key), Body: reader, Metadata: map[string]string{}}
+	if opts.ContentEncoding != "" {
+		input.ContentEncoding = aws.String(opts.ContentEncoding)
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	// minio-go sends the "x-amz-" keys of the metadata as headers of their own.
+	for k, v := range opts.UserMetadata {
+		switch name := strings.ToLower(k); {
+		case name == aclHeader:
+			input.ACL = types.ObjectCannedACL(v)
+		case name == "x-amz-checksum-crc32":
+			input.ChecksumCRC32 = aws.String(v)
+		case name == "x-amz-checksum-crc32c":
+			input.ChecksumCRC32C = aws.String(v)
+		case name == "x-amz-checksum-sha1":
+			input.ChecksumSHA1 = aws.String(v)
+		case name == "x-amz-checksum-sha256":
+			input.ChecksumSHA256 = aws.String(v)
+		default:
+			input.Metadata[strings.TrimPrefix(name, "x-amz-meta-")] = v
+		}
+	}
+	// The transfer manager has no Content-MD5 for the parts, only a single PUT gets one.
+	if seeker, ok := reader.(io.ReadSeeker); ok && opts.SendContentMd5 && size < awsPartSize {
+		sum := md5.New()
+		if _, err := io.Copy(sum, seeker); err != nil {
+			return minio.UploadInfo{}, err
+		}
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return minio.UploadInfo{}, err
+		}
+		input.ContentMD5 = aws.String(base64.StdEncoding.EncodeToString(sum.Sum(nil)))
+	}
+	if opts.ServerSideEncryption != nil {
+		header := http.Header{}
+		opts.ServerSideEncryption.Marshal(header)
+		input.ServerSideEncryption = types.ServerSideEncryption(header.Get("X-Amz-Server-Side-Encryption"))
+		if id := header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"); id != "" {
+			input.SSEKMSKeyId = aws.String(id)
+		}
+	}
+
+	var options []func(*manager.Uploader)
+	if opts.DisableContentSha256 {
+		options = append(options, manager.WithUploaderRequestOptions(s3.WithAPIOptions(v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware)))
+	}
+	out, err := s.uploader.Upload(ctx, input, options...)
+	if err != nil {
+		return minio.UploadInfo{}, toErrorResponse(err, bucketName, key)
+	}
+	info := minio.UploadInfo{
+		Bucket: bucketName, Key: key, ETag: strings.Trim(aws.ToString(out.ETag), `"`), Size: size,
+		ChecksumCRC32: aws.ToString(out.ChecksumCRC32), ChecksumCRC32C: aws.ToString(out.ChecksumCRC32C),
+		ChecksumSHA1: aws.ToString(out.ChecksumSHA1), ChecksumSHA256: aws.ToString(out.ChecksumSHA256),
+	}
+	if out.VersionID != nil {
+		info.VersionID = *out.VersionID
+	}
+	return info, nil
+}
+
+func (s awsStore) GetObject(ctx context.Context, bucketName, key string, opts minio.GetObjectOptions) (io.ReadCloser, error) {
+	input := &s3.GetObjectInput{Bucket: aws.String(bucketName), Key: aws.String(key)}
+	if byteRange := opts.Header().Get("Range"); byteRange != "" {
+		input.Range = aws.String(byteRange)
+	}
+	out, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		return nil, toErrorResponse(err, bucketName, key)
+	}
+	info := minio.ObjectInfo{Key: key, Size: aws.ToInt64(out.ContentLength), Metadata: http.Header{}}
+	if encoding := aws.ToString(out.ContentEncoding); encoding != "" {
+		info.Metadata.Set("Content-Encoding", encoding)
+	}
+	return awsObject{ReadCloser: out.Body, info: info}, nil
+}
+
+// awsObject offers the headers of the response, as minio.Object does.
+type awsObject struct {
+	io.ReadCloser
+	info minio.ObjectInfo
+}
+
+func (o awsObject) Stat() (minio.ObjectInfo, error) {
+	return o.info, nil
+}
+
+func (s awsStore) StatObject(ctx context.Context, bucketName, key string, opts minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucketName), Key: aws.String(key)})
+	if err != nil {
+		return minio.ObjectInfo{}, toErrorResponse(err, bucketName, key)
+	}
+	info := minio.ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(out.ContentLength),
+		ETag:         strings.Trim(aws.ToString(out.ETag), `"`),
+		ContentType:  aws.ToString(out.ContentType),
+		LastModified: aws.ToTime(out.LastModified),
+		VersionID:    aws.ToString(out.VersionId),
+		Metadata:     http.Header{},
+		UserMetadata: minio.StringMap{},
+	}
+	for k, v := range out.Metadata {
+		info.Metadata.Set("X-Amz-Meta-"+k, v)
+		info.UserMetadata[http.CanonicalHeaderKey(k)] = v
+	}
+	return info, nil
+}
+
+func (s awsStore) RemoveObject(ctx context.Context, bucketName, key string, opts minio.RemoveObjectOptions) error {
+	input := &s3.DeleteObjectInput{Bucket: aws.String(bucketName), Key: aws.String(key)}
+	if opts.VersionID != "" {
+		input.VersionId = aws.String(opts.VersionID)
+	}
+	_, err := s.client.DeleteObject(ctx, input)
+	return toErrorResponse(err, bucketName, key)
+}
+
+func (s awsStore) CopyObject(ctx context.Context, dst minio.CopyDestOptions, src minio.CopySrcOptions) (minio.UploadInfo, error) {
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(dst.Bucket),
+		Key:        aws.String(dst.Object),
+		CopySource: aws.String(src.Bucket + "/" + url.PathEscape(src.Object)),
+	}
+	if dst.Encryption != nil {
+		header := http.Header{}
+		dst.Encryption.Marshal(header)
+		input.ServerSideEncryption = types.ServerSideEncryption(header.Get("X-Amz-Server-Side-Encryption"))
+		if id := header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"); id != "" {
+			input.SSEKMSKeyId = aws.String(id)
+		}
+	}
+	out, err := s.client.CopyObject(ctx, input)
+	if err != nil {
+		return minio.UploadInfo{}, toErrorResponse(err, dst.Bucket, dst.Object)
+	}
+	info := minio.UploadInfo{Bucket: dst.Bucket, Key: dst.Object, VersionID: aws.ToString(out.VersionId)}
+	if out.CopyObjectResult != nil {
+		info.ETag = strings.Trim(aws.ToString(out.CopyObjectResult.ETag), `"`)
+	}
+	return info, nil
+}
+
+// ListObjects pages through the listing like minio-go, MaxKeys being the size of a page.
+func (s awsStore) ListObjects(ctx context.Context, bucketName string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
+	objects := make(chan minio.ObjectInfo, 1)
+	go func() {
+		defer close(objects)
+		input := &s3.ListObjectsV2Input{Bucket: aws.String(bucketName), Prefix: aws.String(opts.Prefix)}
+		if !opts.Recursive {
+			input.Delimiter = aws.String("/")
+		}
+		if opts.StartAfter != "" {
+			input.StartAfter = aws.String(opts.StartAfter)
+		}
+		if opts.MaxKeys > 0 {
+			input.MaxKeys = aws.Int32(int32(opts.MaxKeys))
+		}
+		send := func(info minio.ObjectInfo) bool {
+			select {
+			case objects <- info:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		pages := s3.NewListObjectsV2Paginator(s.client, input)
+		for pages.HasMorePages() {
+			page, err := pages.NextPage(ctx)
+			if err != nil {
+				send(minio.ObjectInfo{Err: toErrorResponse(err, bucketName, "")})
+				return
+			}
+			for _, object := range page.Contents {
+				info := minio.ObjectInfo{
+					Key:          aws.ToString(object.Key),
+					Size:         aws.ToInt64(object.Size),
+					ETag:         strings.Trim(aws.ToString(object.ETag), `"`),
+					LastModified: aws.ToTime(object.LastModified),
+					StorageClass: string(object.StorageClass),
+				}
+				if !send(info) {
+					return
+				}
+			}
+			for _, prefix := range page.CommonPrefixes {
+				if !send(minio.ObjectInfo{Key: aws.ToString(prefix.Prefix)}) {
+					return
+				}
+			}
+		}
+	}()
+	return objects
+}
+
+func (s awsStore) BucketExists(ctx context.Context, bucketName string) (bool, error) {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucketName)})
+	if err == nil {
+		return true, nil
+	}
+	err = toErrorResponse(err, bucketName, "")
+	if resp := errorResponse(err); resp.Code == "NoSuchBucket" {
+		return false, nil
+	}
+	return false, err
+}
+
+func (s awsStore) MakeBucket(ctx context.Context, bucketName string, opts minio.MakeBucketOptions) error {
+	input := &s3.CreateBucketInput{Bucket: aws.String(bucketName)}
+	if region := s.client.Options().Region; region != awsDefaultRegion {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{LocationConstraint: types.BucketLocationConstraint(region)}
+	}
+	_, err := s.client.CreateBucket(ctx, input)
+	return toErrorResponse(err, bucketName, "")
+}
+
+func (s awsStore) RemoveBucket(ctx context.Context, bucketName string) error {
+	_, err := s.client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(bucketName)})
+	return toErrorResponse(err, bucketName, "")
+}
+
+// ListIncompleteUploads leaves the sizes out, which the callers sum up by listing the parts.
+func (s awsStore) ListIncompleteUploads(ctx context.Context, bucketName, objectPrefix string, recursive bool) <-chan minio.ObjectMultipartInfo {
+	uploads := make(chan minio.ObjectMultipartInfo, 1)
+	go func() {
+		defer close(uploads)
+		input := &s3.ListMultipartUploadsInput{Bucket: aws.String(bucketName), Prefix: aws.String(objectPrefix)}
+		if !recursive {
+			input.Delimiter = aws.String("/")
+		}
+		send := func(info minio.ObjectMultipartInfo) bool {
+			select {
+			case uploads <- info:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for {
+			page, err := s.client.ListMultipartUploads(ctx, input)
+			if err != nil {
+				send(minio.ObjectMultipartInfo{Err: toErrorResponse(err, bucketName, "")})
+				return
+			}
+			for _, upload := range page.Uploads {
+				if !send(minio.ObjectMultipartInfo{Key: aws.ToString(upload.Key), UploadID: aws.ToString(upload.UploadId), Initiated: aws.ToTime(upload.Initiated)}) {
+					return
+				}
+			}
+			if !aws.ToBool(page.IsTruncated) {
+				return
+			}
+			input.KeyMarker, input.UploadIdMarker = page.NextKeyMarker, page.NextUploadIdMarker
+		}
+	}()
+	return uploads
+}
+
+func (s awsStore) RemoveIncompleteUpload(ctx context.Context, bucketName, key string) error {
+	for upload := range s.ListIncompleteUploads(ctx, bucketName, key, true) {
+		if upload.Err != nil {
+			return upload.Err
+		}
+		if upload.Key != key {
+			continue
+		}
+		if err := s.AbortMultipartUpload(ctx, bucketName, key, upload.UploadID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s awsStore) ListObjectParts(ctx context.Context, bucketName, key, uploadID string, partNumberMarker, maxParts int) (minio.ListObjectPartsResult, error) {
+	input := &s3.ListPartsInput{Bucket: aws.String(bucketName), Key: aws.String(key), UploadId: aws.String(uploadID)}
+	if partNumberMarker > 0 {
+		input.PartNumberMarker = aws.String(fmt.Sprint(partNumberMarker))
+	}
+	if maxParts > 0 {
+		input.MaxParts = aws.Int32(int32(maxParts))
+	}
+	out, err := s.client.ListParts(ctx, input)
+	if err != nil {
+		return minio.ListObjectPartsResult{}, toErrorResponse(err, bucketName, key)
+	}
+	result := minio.ListObjectPartsResult{Bucket: bucketName, Key: key, UploadID: uploadID, IsTruncated: aws.ToBool(out.IsTruncated)}
+	fmt.Sscan(aws.ToString(out.NextPartNumberMarker), &result.NextPartNumberMarker)
+	for _, part := range out.Parts {
+		result.ObjectParts = append(result.ObjectParts, minio.ObjectPart{
+			PartNumber:   int(aws.ToInt32(part.PartNumber)),
+			Size:         aws.ToInt64(part.Size),
+			ETag:         strings.Trim(aws.ToString(part.ETag), `"`),
+			LastModified: aws.ToTime(part.LastModified),
+		})
+	}
+	return result, nil
+}
+
+func (s awsStore) AbortMultipartUpload(ctx context.Context, bucketName, key, uploadID string) error {
+	_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{Bucket: aws.String(bucketName), Key: aws.String(key), UploadId: aws.String(uploadID)})
+	return toErrorResponse(err, bucketName, key)
+}
+
+func (s awsStore) PresignedGetObject(ctx context.Context, bucketName, key string, expires time.Duration, params url.Values) (*url.URL, error) {
+	if len(params) > 0 {
+		return nil, fmt.Errorf(`"-sdk aws" presigns no query parameters`)
+	}
+	request, err := s.presign.PresignGetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucketName), Key: aws.String(key)}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return nil, toErrorResponse(err, bucketName, key)
+	}
+	return url.Parse(request.URL)
+}
+
+// toErrorResponse turns an error response of the server into the minio.ErrorResponse minio-go
+// would have returned; any other error, e.g. of the connection, is left as it is. Like minio-go,
+// a 404 without a code is a missing key or bucket.
+func toErrorResponse(err error, bucketName, key string) error {
+	if err == nil {
+		return nil
+	}
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	resp := minio.ErrorResponse{Code: apiErr.ErrorCode(), Message: apiErr.ErrorMessage(), BucketName: bucketName, Key: key}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		resp.StatusCode, resp.RequestID = respErr.HTTPStatusCode(), respErr.ServiceRequestID()
+		if respErr.Response != nil {
+			resp.Region, resp.HostID = respErr.Response.Header.Get("x-amz-bucket-region"), respErr.Response.Header.Get("x-amz-id-2")
+			resp.Server = respErr.Response.Header.Get("Server")
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound && (resp.Code == "NotFound" || resp.Code == "") {
+		resp.Code = "NoSuchKey"
+		if key == "" {
+			resp.Code = "NoSuchBucket"
+		}
+	}
+	if resp.StatusCode == http.StatusMovedPermanently && resp.Code == "" {
+		resp.Code = "PermanentRedirect"
+	}
+	if resp.Message == "" {
+		resp.Message = http.StatusText(resp.StatusCode)
+	}
+	return resp
+}
@@ -0,0 +1,113 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// backlogInterval is how often the backlog of a paced ("-rate") phase is sampled.
+const backlogInterval = time.Second
+
+// backlogGrace is how late a trial has to be to count as backlog, which keeps the scheduling
+// jitter of the worker waking up for it out of the numbers.
+const backlogGrace = 10 * time.Millisecond
+
+// BacklogSample is the number of trials which were due by "-rate" but not started yet, as written
+// to the events output.
+type BacklogSample struct {
+	Record  string    `json:"record"`
+	Variant string    `json:"variant,omitempty"`
+	Phase   string    `json:"phase"`
+	At      time.Time `json:"at"`
+	Backlog int       `json:"backlog"`
+}
+
+// BacklogStats summarizes the backlog of a paced phase. UnsustainedAfter is set when the backlog
+// kept growing from that offset until the last trial was due: the workers could not keep up with
+// the target rate.
+type BacklogStats struct {
+	Rate             float64       `json:"rate"`
+	Samples          int           `json:"samples"`
+	Max              int           `json:"max"`
+	Mean             float64       `json:"mean"`
+	UnsustainedAfter time.Duration `json:"unsustained_after,omitempty"`
+}
+
+func (b BacklogStats) String(phase string) string {
+	s := fmt.Sprintf(" Backlog     : %s max=%d mean=%.1f (rate %g/s, n=%d)\n", phase, b.Max, b.Mean, b.Rate, b.Samples)
+	if b.UnsustainedAfter > 0 {
+		s += fmt.Sprintf("  WARNING: %s target rate not sustained after %s\n", phase, formatDuration(b.UnsustainedAfter))
+	}
+	return s
+}
+
+// backlogRecorder collects the backlog samples of a phase. A nil backlogRecorder does nothing.
+type backlogRecorder struct {
+	mu      sync.Mutex
+	variant string
+	phase   string
+	rate    float64
+	events  *eventWriter
+	start   time.Time
+	samples []BacklogSample
+}
+
+func newBacklogRecorder(variant, phase string, rate float64, events *eventWriter) *backlogRecorder {
+	if rate <= 0 {
+		return nil
+	}
+	return &backlogRecorder{variant: variant, phase: phase, rate: rate, events: events}
+}
+
+func (b *backlogRecorder) begin(start time.Time) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.start = start
+}
+
+func (b *backlogRecorder) sample(at time.Time, backlog int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := BacklogSample{Record: "backlog", Variant: b.variant, Phase: b.phase, At: at, Backlog: backlog}
+	b.samples = append(b.samples, s)
+	b.events.write(s)
+}
+
+func (b *backlogRecorder) stats() *BacklogStats {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := &BacklogStats{Rate: b.rate, Samples: len(b.samples)}
+	if len(b.samples) == 0 {
+		return stats
+	}
+	total := 0
+	for _, s := range b.samples {
+		total += s.Backlog
+		if s.Backlog > stats.Max {
+			stats.Max = s.Backlog
+		}
+	}
+	stats.Mean = float64(total) / float64(len(b.samples))
+
+	// The growth has to span a few samples, a single late trial is not a trend.
+	last := len(b.samples) - 1
+	from := last
+	for from > 0 && b.samples[from-1].Backlog <= b.samples[from].Backlog {
+		from--
+	}
+	if last-from >= 2 && b.samples[last].Backlog > b.samples[from].Backlog {
+		stats.UnsustainedAfter = b.samples[from].At.Sub(b.start)
+	}
+	return stats
+}
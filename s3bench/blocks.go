@@ -0,0 +1,169 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"time"
+)
+
+// verifyBlocks ("-verify blocks") seals every block of the payloads with a header which the
+// downloads check as they stream.
+const verifyBlocks = "blocks"
+
+const (
+	// blockSize is the payload block a header covers; a multiple of it keeps verifyChunkSize
+	// aligned, so that "-verify-sample" regenerates the sealed payloads chunk by chunk.
+	blockSize = verifyChunkSize
+	// A block header is the blockMagic, the trial and the block index (both big endian uint32)
+	// followed by the CRC32 of the rest of the block, the header fields before it included.
+	blockHeaderSize = 16
+	// A tail shorter than a header is zeroed instead.
+	blockMagic = "S3BK"
+)
+
+func validateVerifyMode(mode string) error {
+	if mode != "" && mode != verifyBlocks {
+		return fmt.Errorf(`unsupported verification %q, only %q is`, mode, verifyBlocks)
+	}
+	return nil
+}
+
+// BlockVerification is the outcome of "-verify blocks": how many downloads were checked block
+// by block and the first corrupted block of every download which failed. The verify times are
+// spent on the checks while streaming, they are excluded from the download times.
+type BlockVerification struct {
+	Objects       int              `json:"objects"`
+	Blocks        int64            `json:"blocks"`
+	Corrupted     []CorruptedBlock `json:"corrupted,omitempty"`
+	AvgVerifyTime time.Duration    `json:"avg_verify_time"`
+	P90VerifyTime time.Duration    `json:"p90_verify_time"`
+}
+
+// CorruptedBlock is the first block of a download which did not check out, by byte offset: a
+// flipped bit, another object's data or the end of a truncated download.
+type CorruptedBlock struct {
+	Key    string `json:"key"`
+	Offset int64  `json:"offset"`
+}
+
+func (b BlockVerification) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, " Blocks      : verified=%d objects (%d blocks) corrupted=%d avg.verify=%s p90.verify=%s (excluded from the download times)\n",
+		b.Objects, b.Blocks, len(b.Corrupted), formatDuration(b.AvgVerifyTime), formatDuration(b.P90VerifyTime))
+	for _, c := range b.Corrupted {
+		fmt.Fprintf(&sb, "  %s: first corrupted block at offset %d\n", c.Key, c.Offset)
+	}
+	return sb.String()
+}
+
+// sealBlocks writes the headers into the payload of a trial in place.
+func sealBlocks(data []byte, trial int) {
+	for index := 0; index*blockSize < len(data); index++ {
+		end := (index + 1) * blockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		sealBlock(data[index*blockSize:end], trial, index)
+	}
+}
+
+func sealBlock(block []byte, trial, index int) {
+	if len(block) < blockHeaderSize {
+		for i := range block {
+			block[i] = 0
+		}
+		return
+	}
+	copy(block, blockMagic)
+	binary.BigEndian.PutUint32(block[4:], uint32(trial))
+	binary.BigEndian.PutUint32(block[8:], uint32(index))
+	binary.BigEndian.PutUint32(block[12:], blockChecksum(block))
+}
+
+func blockChecksum(block []byte) uint32 {
+	return crc32.Update(crc32.ChecksumIEEE(block[:12]), crc32.IEEETable, block[blockHeaderSize:])
+}
+
+func blockSealed(block []byte, trial, index int) bool {
+	if len(block) < blockHeaderSize {
+		for _, b := range block {
+			if b != 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return bytes.Equal(block[:4], []byte(blockMagic)) &&
+		binary.BigEndian.Uint32(block[4:]) == uint32(trial) &&
+		binary.BigEndian.Uint32(block[8:]) == uint32(index) &&
+		binary.BigEndian.Uint32(block[12:]) == blockChecksum(block)
+}
+
+// blockVerifier checks a download block by block as it is written, holding one block at most.
+// corruptAt is the offset of the first block which did not check out, -1 while none.
+type blockVerifier struct {
+	trial     int
+	size      int64
+	block     []byte
+	filled    int
+	offset    int64
+	blocks    int64
+	corruptAt int64
+	elapsed   time.Duration
+}
+
+func newBlockVerifier(trial int, size int64) *blockVerifier {
+	return &blockVerifier{trial: trial, size: size, block: make([]byte, blockSize), corruptAt: -1}
+}
+
+func (v *blockVerifier) Write(p []byte) (int, error) {
+	start := time.Now()
+	written := len(p)
+	for len(p) > 0 {
+		n := copy(v.block[v.filled:], p)
+		v.filled, p = v.filled+n, p[n:]
+		if v.filled == blockSize {
+			v.check()
+		}
+	}
+	v.elapsed += time.Since(start)
+	return written, nil
+}
+
+// count is the number of blocks checked, 0 for a nil blockVerifier.
+func (v *blockVerifier) count() int64 {
+	if v == nil {
+		return 0
+	}
+	return v.blocks
+}
+
+// close checks the last block, which does not fill the buffer, and the end of the download.
+func (v *blockVerifier) close() {
+	start := time.Now()
+	if v.filled > 0 {
+		v.check()
+	}
+	// A download truncated at a block boundary leaves nothing to check against.
+	if v.offset < v.size && v.corruptAt < 0 {
+		v.corruptAt = v.offset
+	}
+	v.elapsed += time.Since(start)
+}
+
+func (v *blockVerifier) check() {
+	expected := v.size - v.offset
+	if expected > blockSize {
+		expected = blockSize
+	}
+	if v.corruptAt < 0 && (int64(v.filled) != expected || !blockSealed(v.block[:v.filled], v.trial, int(v.offset/blockSize))) {
+		v.corruptAt = v.offset
+	}
+	v.blocks++
+	v.offset += int64(v.filled)
+	v.filled = 0
+}
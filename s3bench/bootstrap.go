@@ -0,0 +1,146 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// bootstrapConfidence is the level of the intervals of "-bootstrap".
+const bootstrapConfidence = 0.95
+
+// DurationInterval and SpeedInterval are bootstrap confidence intervals of an estimate.
+type DurationInterval struct {
+	Low  time.Duration `json:"low"`
+	High time.Duration `json:"high"`
+}
+
+type SpeedInterval struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+func (i DurationInterval) overlaps(other DurationInterval) bool {
+	return i.Low <= other.High && other.Low <= i.High
+}
+
+func (i SpeedInterval) overlaps(other SpeedInterval) bool {
+	return i.Low <= other.High && other.Low <= i.High
+}
+
+// BootstrapStats are the 95% confidence intervals of the statistics of a phase, from Resamples
+// resamples of its trials with replacement. With a few dozen trials the P90 is far less certain
+// than its digits suggest: two runs whose intervals overlap do not tell a difference.
+type BootstrapStats struct {
+	Resamples int              `json:"resamples"`
+	Seed      int64            `json:"seed"`
+	P90Time   DurationInterval `json:"p90_time"`
+	AvgTime   DurationInterval `json:"avg_time"`
+	P90Speed  SpeedInterval    `json:"p90_speed"`
+	AvgSpeed  SpeedInterval    `json:"avg_speed"`
+}
+
+// bootstrapper resamples the times and the speeds of the plateau trials for "-bootstrap" once
+// the run is over. It draws from the sample sets of the phase, those the estimates come from: the
+// values kept by a reservoir, the bucket values of hdr. A nil bootstrapper resamples nothing.
+type bootstrapper struct {
+	resamples int
+	seed      int64
+}
+
+func newBootstrapper(resamples int, seed int64) *bootstrapper {
+	if resamples <= 0 {
+		return nil
+	}
+	return &bootstrapper{resamples: resamples, seed: seed}
+}
+
+// stats resamples the trials; every phase starts over from the seed, so that the intervals of a
+// phase only depend on its trials.
+func (b *bootstrapper) stats(times, speeds sampleSet) *BootstrapStats {
+	if b == nil || times.count() < 2 {
+		return nil
+	}
+	var (
+		rng                  = rand.New(rand.NewSource(b.seed))
+		p90Times, avgTimes   = make([]float64, b.resamples), make([]float64, b.resamples)
+		p90Speeds, avgSpeeds = make([]float64, b.resamples), make([]float64, b.resamples)
+	)
+	for resample := 0; resample < b.resamples; resample++ {
+		t, s := times.resample(rng), speeds.resample(rng)
+		avgTimes[resample], avgSpeeds[resample] = t.mean(), s.mean()
+		p90Times[resample], p90Speeds[resample] = t.percentile(0.9), s.percentile(0.9)
+	}
+	recentre(avgTimes, times.mean())
+	recentre(avgSpeeds, speeds.mean())
+	return &BootstrapStats{
+		Resamples: b.resamples,
+		Seed:      b.seed,
+		P90Time:   durationInterval(p90Times),
+		AvgTime:   durationInterval(avgTimes),
+		P90Speed:  speedInterval(p90Speeds),
+		AvgSpeed:  speedInterval(avgSpeeds),
+	}
+}
+
+// recentre moves the means of the resamples onto the mean of all the trials, which the sample
+// sets keep exact: the values a reservoir kept or the bucket values of hdr have a mean of their
+// own, only the spread of the resamples tells anything.
+func recentre(means []float64, mean float64) {
+	var sum float64
+	for _, m := range means {
+		sum += m
+	}
+	shift := mean - sum/float64(len(means))
+	for i := range means {
+		means[i] += shift
+	}
+}
+
+// interval is the central bootstrapConfidence of the estimates of the resamples.
+func interval(estimates []float64) (float64, float64) {
+	sort.Float64s(estimates)
+	at := func(i int) float64 { return estimates[i] }
+	tail := (1 - bootstrapConfidence) / 2
+	return percentileLinear.estimate(len(estimates), tail, at), percentileLinear.estimate(len(estimates), 1-tail, at)
+}
+
+func durationInterval(estimates []float64) DurationInterval {
+	low, high := interval(estimates)
+	return DurationInterval{Low: time.Duration(low), High: time.Duration(high)}
+}
+
+func speedInterval(estimates []float64) SpeedInterval {
+	low, high := interval(estimates)
+	return SpeedInterval{Low: low, High: high}
+}
+
+// bootstrapped describes the estimates of the phase with their intervals, e.g.
+// "P90 820ms (CI 740ms–950ms)".
+func (s PhaseStats) bootstrapped() string {
+	b := s.Bootstrap
+	return fmt.Sprintf("P90 %s (CI %s–%s) avg %s (CI %s–%s) P90.speed %s MB/s (CI %s–%s) avg.speed %s MB/s (CI %s–%s)",
+		formatDuration(s.P90Time), formatDuration(b.P90Time.Low), formatDuration(b.P90Time.High),
+		formatDuration(s.AvgTime), formatDuration(b.AvgTime.Low), formatDuration(b.AvgTime.High),
+		formatSpeed(s.P90Speed), formatSpeed(b.P90Speed.Low), formatSpeed(b.P90Speed.High),
+		formatSpeed(s.AvgSpeed), formatSpeed(b.AvgSpeed.Low), formatSpeed(b.AvgSpeed.High))
+}
+
+// formatBootstrap lists the intervals of the phases which have them.
+func formatBootstrap(phases []namedPhase) string {
+	var sb strings.Builder
+	for _, phase := range phases {
+		b := phase.stats.Bootstrap
+		if b == nil {
+			continue
+		}
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, " Bootstrap   : %.0f%% confidence intervals of %d resamples, seed=%d\n", bootstrapConfidence*100, b.Resamples, b.Seed)
+		}
+		fmt.Fprintf(&sb, "  %s: %s\n", phase.name, phase.stats.bootstrapped())
+	}
+	return sb.String()
+}
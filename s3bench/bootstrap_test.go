@@ -0,0 +1,67 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"math"
+	mathrand "math/rand"
+	"testing"
+	"time"
+)
+
+func TestBootstrapOfTheSampleSets(t *testing.T) {
+	// Log-normal times around 100ms, and the speeds of 1 MiB in them.
+	rng := mathrand.New(mathrand.NewSource(1))
+	const n = 5000
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = float64(100*time.Millisecond) * math.Exp(rng.NormFloat64()*0.3)
+	}
+	b := newBootstrapper(500, 1)
+	widths := map[string]float64{}
+	for _, strategy := range []string{"all", "reservoir:100", "hdr"} {
+		newSampleSet, err := parseSampleStrategy(strategy, 1, percentileLinear)
+		if err != nil {
+			t.Fatal(err)
+		}
+		times, speeds := newSampleSet(), newSampleSet()
+		for _, v := range values {
+			times.add(v)
+			speeds.add(transferSpeed(1<<20, time.Duration(v)))
+		}
+		stats := b.stats(times, speeds)
+		if stats == nil || stats.Resamples != 500 || stats.Seed != 1 {
+			t.Fatalf("%s: %+v", strategy, stats)
+		}
+		// The intervals are around the estimates of the very sets: a reservoir has a P90 of its own.
+		p90, avg := time.Duration(times.percentile(0.9)), time.Duration(times.mean())
+		if p90 < stats.P90Time.Low || p90 > stats.P90Time.High || avg < stats.AvgTime.Low || avg > stats.AvgTime.High {
+			t.Errorf("%s: P90 %v and average %v, outside of %+v and %+v", strategy, p90, avg, stats.P90Time, stats.AvgTime)
+		}
+		if p90 := speeds.percentile(0.9); p90 < stats.P90Speed.Low || p90 > stats.P90Speed.High {
+			t.Errorf("%s: P90 speed %v outside of %+v", strategy, p90, stats.P90Speed)
+		}
+		if *b.stats(times, speeds) != *stats {
+			t.Errorf("%s: another interval of the same seed", strategy)
+		}
+		widths[strategy] = float64(stats.P90Time.High-stats.P90Time.Low) / float64(p90)
+	}
+	// The 100 values of the reservoir tell the P90 far less certainly than all the 5000.
+	if widths["reservoir:100"] < 3*widths["all"] {
+		t.Errorf("relative widths of the P90 intervals %v, want the one of the reservoir much wider", widths)
+	}
+	// The hdr buckets are within 1% of the values.
+	if math.Abs(widths["hdr"]-widths["all"]) > 0.02 {
+		t.Errorf("relative widths of the P90 intervals %v, want the one of hdr close to the one of all", widths)
+	}
+
+	one, _ := parseSampleStrategy("all", 1, percentileLinear)
+	times := one()
+	times.add(1)
+	if b.stats(times, one()) != nil {
+		t.Error("the bootstrap of a single trial")
+	}
+	var off *bootstrapper
+	if off.stats(times, times) != nil {
+		t.Error("a nil bootstrapper resampled")
+	}
+}
@@ -0,0 +1,154 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// bucketCountPlaceholder is replaced by the bucket number, from 1, in a "-bucketName" template
+// with "-bucket-count".
+const bucketCountPlaceholder = "{n}"
+
+// PerBucketStats breaks the phases down by the bucket (several "-bucketName") the trials went to.
+type PerBucketStats struct {
+	Upload   []BucketStats `json:"upload"`
+	Download []BucketStats `json:"download"`
+}
+
+type BucketStats struct {
+	Bucket     string        `json:"bucket"`
+	Count      int           `json:"count"`
+	AvgTime    time.Duration `json:"avg_time"`
+	P90Time    time.Duration `json:"p90_time"`
+	Bytes      int64         `json:"bytes"`
+	Throughput float64       `json:"throughput"`
+}
+
+// bucketStats returns the per-bucket breakdown ordered by bucket, or nil when not tracked.
+func (p *phaseRecorder) bucketStats() []BucketStats {
+	if p.buckets == nil {
+		return nil
+	}
+	stats := make([]BucketStats, 0, len(p.buckets))
+	for bucket, b := range p.buckets {
+		stats = append(stats, BucketStats{
+			Bucket:     bucket,
+			Count:      b.times.count(),
+			AvgTime:    time.Duration(b.times.mean()),
+			P90Time:    time.Duration(b.times.percentile(0.9)),
+			Bytes:      b.bytes,
+			Throughput: float64(b.bytes) / b.lastEnd.Sub(b.windowStart).Seconds() / 1024 / 1024, // MB/s
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Bucket < stats[j].Bucket })
+	return stats
+}
+
+func (p PerBucketStats) String() string {
+	var sb strings.Builder
+	sb.WriteString(" Buckets     :\n")
+	fmt.Fprintf(&sb, "  %-9s %-24s %7s %14s %14s %14s\n", "phase", "bucket", "ops", "mean", "p90", "throughput")
+	for _, phase := range []struct {
+		name  string
+		stats []BucketStats
+	}{{"upload", p.Upload}, {"download", p.Download}} {
+		for _, b := range phase.stats {
+			fmt.Fprintf(&sb, "  %-9s %-24s %7d %14v %14v %9s MB/s\n", phase.name, b.Bucket, b.Count, formatDuration(b.AvgTime), formatDuration(b.P90Time), formatSpeed(b.Throughput))
+		}
+	}
+	return sb.String()
+}
+
+// bucket is where the object of a trial goes: trials are spread round-robin over the buckets,
+// so that the download and the cleanup of a trial find the object where its upload put it.
+func (r runner) bucket(trial int) string {
+	if len(r.buckets) < 2 {
+		return r.bucketName
+	}
+	return r.buckets[(trial-1)%len(r.buckets)]
+}
+
+// parseBuckets accepts a comma separated list of buckets or, with count, a template numbered
+// through bucketCountPlaceholder.
+func parseBuckets(value string, count int) ([]string, error) {
+	if count > 0 {
+		if !strings.Contains(value, bucketCountPlaceholder) {
+			return nil, fmt.Errorf(`%q has no %s for the bucket number`, value, bucketCountPlaceholder)
+		}
+		buckets := make([]string, 0, count)
+		for n := 1; n <= count; n++ {
+			buckets = append(buckets, strings.ReplaceAll(value, bucketCountPlaceholder, strconv.Itoa(n)))
+		}
+		return buckets, nil
+	}
+	var buckets []string
+	seen := map[string]bool{}
+	for _, bucket := range strings.Split(value, ",") {
+		if bucket = strings.TrimSpace(bucket); bucket == "" {
+			continue
+		}
+		if seen[bucket] {
+			return nil, fmt.Errorf(`bucket %q is listed twice`, bucket)
+		}
+		seen[bucket] = true
+		buckets = append(buckets, bucket)
+	}
+	if len(buckets) == 0 {
+		return nil, fmt.Errorf(`no buckets in %q`, value)
+	}
+	return buckets, nil
+}
+
+// createBuckets creates those of the buckets which do not exist yet and returns them.
+func createBuckets(client ObjectStore, buckets []string) ([]string, error) {
+	var created []string
+	for _, bucket := range buckets {
+		exists, err := client.BucketExists(context.Background(), bucket)
+		if err != nil {
+			return created, fmt.Errorf(`unable to check whether bucket %q exists, %v`, bucket, err)
+		}
+		if exists {
+			continue
+		}
+		if err := client.MakeBucket(context.Background(), bucket, minio.MakeBucketOptions{}); err != nil {
+			return created, fmt.Errorf(`unable to create bucket %q, %v`, bucket, err)
+		}
+		created = append(created, bucket)
+	}
+	return created, nil
+}
+
+// removeCreatedBuckets removes the buckets "-create-bucket" created, unless something is still
+// in them: kept or failed to be removed objects, or somebody else's.
+func removeCreatedBuckets(client ObjectStore, buckets []string) {
+	for _, bucket := range buckets {
+		if !bucketEmpty(client, bucket) {
+			log.Printf(`WARNING: not removing bucket %s created for the run as it is not empty`, bucket)
+			continue
+		}
+		if err := client.RemoveBucket(context.Background(), bucket); err != nil {
+			log.Printf(`Unable to remove bucket %s, %v`, bucket, err)
+		}
+	}
+}
+
+func bucketEmpty(client ObjectStore, bucket string) bool {
+	// Stops the listing after the first object.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for object := range client.ListObjects(ctx, bucket, minio.ListObjectsOptions{MaxKeys: 1}) {
+		if object.Err != nil {
+			log.Printf(`Unable to list bucket %s before removal, %v`, bucket, object.Err)
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TimeBudgetStats is how a phase with "-upload-time-budget" or "-download-time-budget" ended.
+// Ran is the number of the Requested trials which started before the Budget was spent; once
+// Expired, the phase started no further trials, those in flight finished.
+type TimeBudgetStats struct {
+	Budget    time.Duration `json:"budget"`
+	Requested int           `json:"requested"`
+	Ran       int           `json:"ran"`
+	Expired   bool          `json:"expired"`
+}
+
+func (s TimeBudgetStats) String(phase string) string {
+	if !s.Expired {
+		return fmt.Sprintf(" Time budget : %s ran all %d trials within %s\n", phase, s.Requested, formatDuration(s.Budget))
+	}
+	return fmt.Sprintf(" Time budget : %s spent %s after %d of %d trials, the rest did not run\n", phase, formatDuration(s.Budget), s.Ran, s.Requested)
+}
+
+// timeBudget caps the wall-clock of a schedule. A nil timeBudget never expires.
+type timeBudget struct {
+	budget         time.Duration
+	expired        bool
+	ran, requested int
+}
+
+func newTimeBudget(budget time.Duration) *timeBudget {
+	if budget <= 0 {
+		return nil
+	}
+	return &timeBudget{budget: budget}
+}
+
+// begin derives the abort context of the schedule which is done once the budget is spent.
+func (b *timeBudget) begin(abort context.Context) (context.Context, context.CancelFunc) {
+	if abort == nil {
+		abort = context.Background()
+	}
+	return context.WithTimeout(abort, b.budget)
+}
+
+// end notes whether the budget stopped the schedule before it ran all of its trials, rather than
+// an abort of the phase.
+func (b *timeBudget) end(abort context.Context, ran, trials int) {
+	b.ran, b.requested = ran, trials
+	b.expired = ran < trials && abort.Err() == context.DeadlineExceeded
+}
+
+func (b *timeBudget) stats() *TimeBudgetStats {
+	if b == nil {
+		return nil
+	}
+	return &TimeBudgetStats{Budget: b.budget, Requested: b.requested, Ran: b.ran, Expired: b.expired}
+}
@@ -0,0 +1,155 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stageBurst marks the trials of "-burst", which make it into the headline statistics as the
+// plateau trials of the worker pool do.
+const stageBurst = "burst"
+
+// BurstStats is a burst of a phase: Operations released at once at Release. First and Last are
+// the first and the last completion after the release, failures included, and Spread the time
+// between them. Skew is how far apart the successful operations actually started, the
+// percentiles are those of their durations.
+type BurstStats struct {
+	Record     string        `json:"record"`
+	Variant    string        `json:"variant,omitempty"`
+	Phase      string        `json:"phase"`
+	Burst      int           `json:"burst"`
+	Release    time.Time     `json:"release"`
+	Operations int           `json:"operations"`
+	Failed     int           `json:"failed,omitempty"`
+	Skew       time.Duration `json:"skew"`
+	First      time.Duration `json:"first"`
+	Last       time.Duration `json:"last"`
+	Spread     time.Duration `json:"spread"`
+	P50        time.Duration `json:"p50"`
+	P90        time.Duration `json:"p90"`
+	Max        time.Duration `json:"max"`
+}
+
+// burstSchedule runs a phase as bursts instead of on a pool of workers, for the failures which
+// only show when requests arrive at the same instant: the operations of size workers are
+// prepared, released at once by a barrier and awaited, bursts times with gap in between.
+type burstSchedule struct {
+	variant, phase string
+	size, bursts   int
+	gap            time.Duration
+	// abort, when cancelled, stops further bursts; the burst in flight still completes.
+	abort    context.Context
+	method   percentileMethod
+	events   *eventWriter
+	progress io.Writer
+}
+
+func (r runner) burstSchedule(phase string, p *phaseRecorder) burstSchedule {
+	return burstSchedule{variant: r.title, phase: phase, size: r.burst, bursts: r.trials, gap: r.burstGap, abort: p.abort, method: r.percentileMethod, events: r.events, progress: r.progress}
+}
+
+// run returns the number of trials performed and the statistics of every burst. The trials of
+// burst n are numbered from (n-1)*size+1 on. Every sample is passed to record.
+func (s burstSchedule) run(newOperation operationFactory, record func(sample)) (int, []BurstStats) {
+	ops := make([]operation, s.size)
+	for w := range ops {
+		ops[w] = newOperation(w + 1)
+	}
+	var (
+		performed int
+		bursts    []BurstStats
+	)
+	for n := 1; n <= s.bursts; n++ {
+		if s.abort != nil && s.abort.Err() != nil {
+			break
+		}
+		if n > 1 {
+			time.Sleep(s.gap)
+		}
+		stats := s.burst(n, ops, record)
+		bursts = append(bursts, stats)
+		s.events.write(stats)
+		fmt.Fprintf(s.progress, " - Burst: %d of %d,\toperations=%d failed=%d first=%s last=%s spread=%s\n",
+			n, s.bursts, stats.Operations, stats.Failed, formatDuration(stats.First), formatDuration(stats.Last), formatDuration(stats.Spread))
+		performed += len(ops)
+	}
+	return performed, bursts
+}
+
+// burst releases the operations once every one of them waits at the barrier.
+func (s burstSchedule) burst(n int, ops []operation, record func(sample)) BurstStats {
+	var (
+		ready, done sync.WaitGroup
+		release     = make(chan struct{})
+		releasedAt  time.Time
+		results     = make([]sample, len(ops))
+		completed   = make([]time.Duration, len(ops))
+	)
+	ready.Add(len(ops))
+	done.Add(len(ops))
+	for w, op := range ops {
+		go func(w int, op operation) {
+			defer done.Done()
+			ready.Done()
+			<-release
+			result := op((n-1)*len(ops)+w+1, stageBurst)
+			completed[w] = time.Since(releasedAt)
+			result.stage, result.worker, result.due = stageBurst, w+1, releasedAt
+			results[w] = result
+			record(result)
+		}(w, op)
+	}
+	ready.Wait()
+	releasedAt = time.Now()
+	close(release)
+	done.Wait()
+
+	stats := BurstStats{Record: "burst", Variant: s.variant, Phase: s.phase, Burst: n, Release: releasedAt, Operations: len(ops)}
+	times := &allSamples{method: s.method}
+	var firstStart, lastStart time.Time
+	for w, result := range results {
+		if w == 0 || completed[w] < stats.First {
+			stats.First = completed[w]
+		}
+		if completed[w] > stats.Last {
+			stats.Last = completed[w]
+		}
+		if result.err != nil {
+			stats.Failed++
+			continue
+		}
+		if result.skipped || result.duration <= 0 {
+			continue
+		}
+		times.add(float64(result.duration))
+		if result.duration > stats.Max {
+			stats.Max = result.duration
+		}
+		if firstStart.IsZero() || result.start.Before(firstStart) {
+			firstStart = result.start
+		}
+		if result.start.After(lastStart) {
+			lastStart = result.start
+		}
+	}
+	stats.Spread = stats.Last - stats.First
+	stats.Skew = lastStart.Sub(firstStart)
+	stats.P50, stats.P90 = time.Duration(times.percentile(0.5)), time.Duration(times.percentile(0.9))
+	return stats
+}
+
+func formatBursts(bursts []BurstStats) string {
+	var sb strings.Builder
+	sb.WriteString(" Bursts      :\n")
+	fmt.Fprintf(&sb, "  %-9s %5s %5s %6s %10s %10s %10s %10s %10s %10s %10s\n", "phase", "burst", "ops", "failed", "skew", "first", "last", "spread", "p50", "p90", "max")
+	for _, b := range bursts {
+		fmt.Fprintf(&sb, "  %-9s %5d %5d %6d %10s %10s %10s %10s %10s %10s %10s\n", b.Phase, b.Burst, b.Operations, b.Failed,
+			formatDuration(b.Skew), formatDuration(b.First), formatDuration(b.Last), formatDuration(b.Spread), formatDuration(b.P50), formatDuration(b.P90), formatDuration(b.Max))
+	}
+	return sb.String()
+}
@@ -0,0 +1,53 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"strings"
+)
+
+// cacheSuspectDelta is how much faster, in percent of the P90 time, the repeat pass has to be
+// for a read cache to be suspected.
+const cacheSuspectDelta = -25
+
+// CacheProbe compares the download phase, pass 1, to a repeat of it over the same keys in the
+// same order and at the same concurrency, pass 2, with "-cache-probe". The deltas are in percent
+// of the first pass: a repeat markedly faster than the first read hints at a read cache in front
+// of the storage.
+type CacheProbe struct {
+	Passes    []CacheProbePass `json:"passes"`
+	Deltas    PhaseDeltas      `json:"deltas"`
+	Suspected bool             `json:"suspected,omitempty"`
+}
+
+type CacheProbePass struct {
+	Pass  int        `json:"pass"`
+	Stats PhaseStats `json:"stats"`
+}
+
+func newCacheProbe(first, repeat PhaseStats) *CacheProbe {
+	deltas := comparePhases(first, repeat)
+	return &CacheProbe{
+		Passes:    []CacheProbePass{{Pass: 1, Stats: first}, {Pass: 2, Stats: repeat}},
+		Deltas:    deltas,
+		Suspected: repeat.Count > 0 && deltas.P90Time <= cacheSuspectDelta,
+	}
+}
+
+func (c CacheProbe) repeat() *PhaseStats {
+	return &c.Passes[1].Stats
+}
+
+func (c CacheProbe) String() string {
+	var sb strings.Builder
+	first, repeat := c.Passes[0].Stats, c.Passes[1].Stats
+	fmt.Fprintf(&sb, " Cache probe : the downloads read once more (n=%d)\n", repeat.Count)
+	fmt.Fprintf(&sb, " %-20s %14s %14s %9s\n", "", "pass=1", "pass=2", "delta")
+	writeTimeRow(&sb, "  avg time", first.AvgTime, repeat.AvgTime, c.Deltas.AvgTime, c.Deltas.meaningful("avg_time"))
+	writeTimeRow(&sb, "  P90 time", first.P90Time, repeat.P90Time, c.Deltas.P90Time, c.Deltas.meaningful("p90_time"))
+	writeSpeedRow(&sb, "  avg speed", first.AvgSpeed, repeat.AvgSpeed, c.Deltas.AvgSpeed, c.Deltas.meaningful("avg_speed"))
+	if c.Suspected {
+		fmt.Fprintf(&sb, "  WARNING: the repeat reads are %.1f%% faster at P90, a read cache likely serves them and flatters the download figures\n", -c.Deltas.P90Time)
+	}
+	return sb.String()
+}
@@ -0,0 +1,194 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	mathrand "math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// cancelSeedOffset keeps the cancellations of "-abort-ratio" apart from the sizes and the key
+// directories, which are drawn with the same seed.
+const cancelSeedOffset = 2 << 40
+
+// cancelPlan picks the uploads "-abort-ratio" cancels and the point of the payload at which it
+// does, by the seed: the downloads and the verification know which objects were never stored.
+type cancelPlan struct {
+	ratio float64
+	seed  int64
+}
+
+func newCancelPlan(ratio float64, seed int64) (cancelPlan, error) {
+	if ratio < 0 || ratio >= 1 {
+		return cancelPlan{}, fmt.Errorf(`"-abort-ratio" must be at least 0 and below 1`)
+	}
+	return cancelPlan{ratio: ratio, seed: seed}, nil
+}
+
+// point tells whether the upload of a trial is cancelled and after which fraction of the
+// payload, between 10% and 90% of it.
+func (c cancelPlan) point(trial int) (float64, bool) {
+	if c.ratio == 0 {
+		return 0, false
+	}
+	rng := mathrand.New(mathrand.NewSource(c.seed + cancelSeedOffset + int64(trial)))
+	if rng.Float64() >= c.ratio {
+		return 0, false
+	}
+	return 0.1 + 0.8*rng.Float64(), true
+}
+
+// kept lists the trials whose uploads are not cancelled, nil when none is.
+func (c cancelPlan) kept(uploaded int) []int {
+	if c.ratio == 0 {
+		return nil
+	}
+	kept := make([]int, 0, uploaded)
+	for trial := 1; trial <= uploaded; trial++ {
+		if _, cancelled := c.point(trial); !cancelled {
+			kept = append(kept, trial)
+		}
+	}
+	return kept
+}
+
+// storedTrial maps the i-th read of a phase to an uploaded object, cycling through those whose
+// uploads were not cancelled.
+func (r runner) storedTrial(i int) int {
+	if r.kept == nil {
+		return (i-1)%r.uploaded + 1
+	}
+	return r.kept[(i-1)%len(r.kept)]
+}
+
+// storedCount is the number of objects the upload phase stored, cancelled uploads aside.
+func (r runner) storedCount() int {
+	if r.kept == nil {
+		return r.uploaded
+	}
+	return len(r.kept)
+}
+
+// CancelStats are the uploads "-abort-ratio" cancelled mid-transfer, which are not part of the
+// upload statistics. The time to cancel is from the cancellation until the upload returned,
+// Completed uploads were done before it took effect. LeftBehind counts the incomplete multipart
+// uploads found under the prefix afterwards, Removed the keys whose incomplete uploads were
+// removed.
+type CancelStats struct {
+	Ratio           float64       `json:"ratio"`
+	Cancelled       int           `json:"cancelled"`
+	Completed       int           `json:"completed,omitempty"`
+	AvgTimeToCancel time.Duration `json:"avg_time_to_cancel"`
+	P90TimeToCancel time.Duration `json:"p90_time_to_cancel"`
+	LeftBehind      int           `json:"left_behind"`
+	Removed         int           `json:"removed"`
+}
+
+func (p *phaseRecorder) cancelStats(ratio float64) *CancelStats {
+	return &CancelStats{
+		Ratio:           ratio,
+		Cancelled:       p.cancelTimes.count(),
+		Completed:       p.cancelLate,
+		AvgTimeToCancel: time.Duration(p.cancelTimes.mean()),
+		P90TimeToCancel: time.Duration(p.cancelTimes.percentile(0.9)),
+	}
+}
+
+func (c CancelStats) String() string {
+	return fmt.Sprintf(" Cancelled   : uploads=%d (ratio %g) completed.anyway=%d avg.time-to-cancel=%s p90.time-to-cancel=%s incomplete.left=%d removed=%d\n",
+		c.Cancelled, c.Ratio, c.Completed, formatDuration(c.AvgTimeToCancel), formatDuration(c.P90TimeToCancel), c.LeftBehind, c.Removed)
+}
+
+// cancelUpload uploads like the application which gave up on it: the context is cancelled once
+// after bytes of the payload were read, which a multipart upload does part by part. It returns
+// how long the upload took to return after that, completed when it succeeded nevertheless.
+func (r runner) cancelUpload(ctx context.Context, client ObjectStore, bucket, key string, data []byte, opts minio.PutObjectOptions, after int64) (timeToCancel time.Duration, completed bool, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	start := time.Now()
+	reader := &cancelingReader{Reader: r.payload(data), after: after, cancel: cancel, start: start}
+	_, err = client.PutObject(ctx, bucket, key, reader, int64(len(data)), opts)
+	timeToCancel = time.Since(start) - time.Duration(reader.cancelledAt.Load())
+	if err == nil {
+		return timeToCancel, true, nil
+	}
+	if errors.Is(err, context.Canceled) || ctx.Err() != nil {
+		return timeToCancel, false, nil
+	}
+	return 0, false, err
+}
+
+// cancelingReader cancels once more than after bytes were read, recording when since start.
+// It hides the io.Seeker of the payload, so that it is read front to back.
+type cancelingReader struct {
+	io.Reader
+	after       int64
+	read        int64
+	cancel      context.CancelFunc
+	start       time.Time
+	cancelledAt atomic.Int64
+}
+
+func (c *cancelingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	if c.read < c.after && c.read+int64(n) >= c.after {
+		c.cancelledAt.Store(int64(time.Since(c.start)))
+		c.cancel()
+	}
+	c.read += int64(n)
+	return n, err
+}
+
+func completedMark(completed bool) string {
+	if !completed {
+		return ""
+	}
+	return " (completed anyway)"
+}
+
+// removeIncompleteUploads lists the multipart uploads which were left incomplete under the prefix
+// of every bucket and removes them.
+func (r runner) removeIncompleteUploads(stats *CancelStats) {
+	buckets := r.buckets
+	if len(buckets) == 0 {
+		buckets = []string{r.bucketName}
+	}
+	for _, bucket := range buckets {
+		uploads, keys := r.listIncompleteUploads(bucket)
+		stats.LeftBehind += uploads
+		// Removing the uploads of a key removes all of them.
+		for _, key := range keys {
+			if err := r.client.RemoveIncompleteUpload(context.Background(), bucket, key); err != nil {
+				log.Printf(`Unable to remove the incomplete upload of %s from %s, %v`, key, bucket, err)
+				continue
+			}
+			stats.Removed++
+		}
+	}
+}
+
+func (r runner) listIncompleteUploads(bucket string) (uploads int, keys []string) {
+	// Stops the listing on the first error.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	seen := map[string]bool{}
+	for upload := range r.client.ListIncompleteUploads(ctx, bucket, r.prefix, true) {
+		if upload.Err != nil {
+			log.Printf(`Unable to list the incomplete uploads under %s in %s, %v`, r.prefix, bucket, upload.Err)
+			break
+		}
+		uploads++
+		if !seen[upload.Key] {
+			seen[upload.Key] = true
+			keys = append(keys, upload.Key)
+		}
+	}
+	return uploads, keys
+}
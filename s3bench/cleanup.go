@@ -0,0 +1,217 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// cleanupPollInterval is how often "-cleanup-wait-replicated" re-checks a pending object.
+const cleanupPollInterval = time.Second
+
+// CleanupStats counts what happened to the benchmark objects on removal. On a bucket with
+// replication, a delete issued while the object is still PENDING may leave the replica behind.
+type CleanupStats struct {
+	Removed int `json:"removed"`
+	Failed  int `json:"failed,omitempty"`
+	Skipped int `json:"skipped,omitempty"`
+	// Pending objects were deleted before their replication completed, ReplicationFailed ones
+	// reported a FAILED replication.
+	Pending           int           `json:"replication_pending,omitempty"`
+	ReplicationFailed int           `json:"replication_failed,omitempty"`
+	Waited            time.Duration `json:"waited,omitempty"`
+	// Errors counts the failures by class.
+	Errors errorCounts `json:"errors,omitempty"`
+	// Remaining are the objects of the run the cleanup failed to remove, and Script and List
+	// where the commands to remove them and their list were written.
+	Remaining []RemainingObject `json:"remaining,omitempty"`
+	Script    string            `json:"script,omitempty"`
+	List      string            `json:"list,omitempty"`
+}
+
+// RemainingObject is an object of the run left behind by a failed cleanup.
+type RemainingObject struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+}
+
+// incomplete tells whether the cleanup left objects of the run behind.
+func (c *CleanupStats) incomplete() bool {
+	return c != nil && (c.Failed > 0 || len(c.Remaining) > 0)
+}
+
+// failed counts a failure of removing the key from bucket, which is left behind then.
+func (c *CleanupStats) failed(bucket, key string, err *OpError) {
+	c.Remaining = append(c.Remaining, RemainingObject{Bucket: bucket, Key: key})
+	log.Printf(`%v`, err)
+	c.Failed++
+	if c.Errors == nil {
+		c.Errors = errorCounts{}
+	}
+	c.Errors[classifyError(err)]++
+}
+
+// add sums up the cleanup of several buckets.
+func (c *CleanupStats) add(other CleanupStats) {
+	c.Removed += other.Removed
+	c.Failed += other.Failed
+	c.Skipped += other.Skipped
+	c.Pending += other.Pending
+	c.ReplicationFailed += other.ReplicationFailed
+	c.Waited += other.Waited
+	c.Remaining = append(c.Remaining, other.Remaining...)
+	for class, n := range other.Errors {
+		if c.Errors == nil {
+			c.Errors = errorCounts{}
+		}
+		c.Errors[class] += n
+	}
+}
+
+// eventful tells whether the cleanup is worth a line in the report.
+func (c CleanupStats) eventful() bool {
+	return c.Failed > 0 || c.Skipped > 0 || c.Pending > 0 || c.ReplicationFailed > 0 || c.Waited > 0
+}
+
+func (c CleanupStats) String() string {
+	s := fmt.Sprintf(" Cleanup     : removed=%d failed=%d skipped=%d replication.pending=%d replication.failed=%d",
+		c.Removed, c.Failed, c.Skipped, c.Pending, c.ReplicationFailed)
+	if len(c.Errors) > 0 {
+		s += fmt.Sprintf(" (%s)", c.Errors)
+	}
+	if c.Waited > 0 {
+		s += fmt.Sprintf(" (waited %s for replication)", formatDuration(c.Waited))
+	}
+	s += "\n"
+	if c.Pending > 0 {
+		s += "  WARNING: objects were deleted before their replication completed, replicas may be left behind\n"
+	}
+	if c.Script != "" {
+		s += fmt.Sprintf("  WARNING: %d objects were left behind, %s removes them (listed in %s)\n", len(c.Remaining), c.Script, c.List)
+	}
+	return s
+}
+
+// Replication statuses as reported by X-Amz-Replication-Status; objects of a bucket without
+// replication carry none.
+const (
+	replicationPending   = "PENDING"
+	replicationCompleted = "COMPLETED"
+	replicationFailed    = "FAILED"
+)
+
+func replicationStatus(info minio.ObjectInfo) string {
+	status := strings.ToUpper(info.ReplicationStatus)
+	if status == "COMPLETE" {
+		// AWS S3 reports COMPLETE, MinIO COMPLETED.
+		return replicationCompleted
+	}
+	return status
+}
+
+// awaitReplicated polls a PENDING object until its replication is no longer pending or the
+// deadline passes, and returns its last status.
+func (r runner) awaitReplicated(bucket, key string, info minio.ObjectInfo, deadline time.Time) string {
+	status := replicationStatus(info)
+	for status == replicationPending && time.Now().Before(deadline) {
+		time.Sleep(cleanupPollInterval)
+		info, err := r.client.StatObject(context.Background(), bucket, key, minio.StatObjectOptions{})
+		if err != nil {
+			log.Printf(`WARNING: unable to check the replication status of %s in %s, %v`, key, bucket, err)
+			return status
+		}
+		status = replicationStatus(info)
+	}
+	return status
+}
+
+// confirmRemaining drops the objects the listing of the run prefix no longer shows, a failed
+// stat or delete may still have left nothing behind. Without a listing all are kept.
+func (r runner) confirmRemaining(stats *CleanupStats, buckets []string) {
+	if len(stats.Remaining) == 0 {
+		return
+	}
+	listed := map[RemainingObject]bool{}
+	for _, bucket := range buckets {
+		for object := range r.client.ListObjects(context.Background(), bucket, minio.ListObjectsOptions{Prefix: r.prefix, Recursive: true}) {
+			if object.Err != nil {
+				log.Printf(`WARNING: unable to list %s in %s for the objects left behind, %v`, r.prefix, bucket, object.Err)
+				return
+			}
+			listed[RemainingObject{Bucket: bucket, Key: object.Key}] = true
+		}
+	}
+	remaining := stats.Remaining[:0]
+	for _, object := range stats.Remaining {
+		if listed[object] {
+			remaining = append(remaining, object)
+		}
+	}
+	stats.Remaining = remaining
+}
+
+// writeRemaining writes the commands removing the objects the cleanup left behind, and their
+// list as JSON, to the "-output-dir" or the working directory.
+func (r runner) writeRemaining(stats *CleanupStats) {
+	if len(stats.Remaining) == 0 {
+		return
+	}
+	script, list := "cleanup-remaining.sh", "cleanup-remaining.json"
+	if r.title != "" {
+		script, list = "cleanup-remaining-"+r.title+".sh", "cleanup-remaining-"+r.title+".json"
+	}
+	if r.runDir != nil {
+		script, list = r.runDir.file(script), r.runDir.file(list)
+	}
+
+	content, err := json.MarshalIndent(stats.Remaining, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(list, append(content, '\n'), 0o644)
+	}
+	if err == nil {
+		err = writeFileAtomic(script, []byte(remainingScript(r.runID, r.client.EndpointURL().String(), stats.Remaining)), 0o755)
+	}
+	if err != nil {
+		log.Printf(`WARNING: unable to write the objects left behind by the cleanup, %v`, err)
+		return
+	}
+	stats.Script, stats.List = script, list
+	log.Printf(`WARNING: the cleanup left %d objects behind, %s removes them (listed in %s)`, len(stats.Remaining), script, list)
+}
+
+// remainingScript removes the objects with mc, through the alias $MC_ALIAS, or with aws-cli when
+// $TOOL is "aws".
+func remainingScript(runID, endpoint string, objects []RemainingObject) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `#!/bin/sh
+# Removes the objects of run %s the cleanup left behind at %s.
+# With mc (the default) MC_ALIAS names the alias of the endpoint, "s3bench" unless set;
+# with TOOL=aws aws-cli removes them, taking the credentials from its configuration.
+set -e
+ENDPOINT_URL=${ENDPOINT_URL:-%s}
+
+remove() {
+	case "${TOOL:-mc}" in
+	mc) mc rm "${MC_ALIAS:-s3bench}/$1/$2" ;;
+	aws) aws s3 rm --endpoint-url "$ENDPOINT_URL" "s3://$1/$2" ;;
+	*) echo "unknown TOOL $TOOL, expected mc or aws" >&2; exit 1 ;;
+	esac
+}
+
+`, runID, endpoint, shellQuote(endpoint))
+	for _, object := range objects {
+		fmt.Fprintf(&sb, "remove %s %s\n", shellQuote(object.Bucket), shellQuote(object.Key))
+	}
+	return sb.String()
+}
+
+// shellQuote quotes s for the shell, as a single-quoted word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,2186 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+const (
+	accessKeyEnvVarName = `S3_ACCESS_KEY`
+	secretKeyEnvVarName = `S3_SECRET_KEY`
+
+	targetAccessKeyEnvVarName = `S3_TARGET_ACCESS_KEY`
+	targetSecretKeyEnvVarName = `S3_TARGET_SECRET_KEY`
+)
+
+// Main is the command line tool: it runs args, the program name left out, and returns the exit
+// code, that of an ExitError of Run.
+func Main(args []string) int {
+	return runCLI(context.Background(), args, nil)
+}
+
+// runCLI runs the command line args, the program name left out, and returns the exit code. The
+// phases stop once ctx is done; results, when set, receive the samples and the report.
+func runCLI(ctx context.Context, args []string, results *Results) int {
+	started := time.Now()
+	flags := flag.NewFlagSet(appName, flag.ContinueOnError)
+	// exits close the outputs and remove what the setup created, whichever way runCLI returns.
+	exits := &cleanups{}
+	defer exits.run()
+	// A runner failing halts the run, its code is returned once the phases in flight stopped.
+	ctx, halt := newRunHalt(ctx)
+	defer halt.cancel()
+	var (
+		endpoint, accessKey, secretKey, bucketName string
+		fileSizeMb                                 int
+		trials                                     int
+		prefix                                     string
+		sseMode, sseKMSKeyID                       string
+		compareSSE                                 bool
+		keepObjects                                bool
+		scanPrefix                                 string
+		scanKeysPath                               string
+		interleave                                 bool
+		verifyListing                              bool
+		reportFormat, reportTemplatePath           string
+		reportFields                               bool
+		contentEncoding                            string
+		statePath                                  string
+		autoTrials                                 bool
+		confidenceValue                            string
+		maxTrials                                  int
+		bucketCount                                int
+		createBucket                               bool
+		verifyMode                                 string
+		prices                                     Prices
+		dryRun                                     bool
+		force                                      bool
+		startAtValue                               string
+		startIn                                    time.Duration
+		keyDepth, keyFanout                        int
+		abortRatio                                 float64
+		cleanupAll                                 bool
+		olderThan                                  time.Duration
+		maxClockSkew                               time.Duration
+		presetName                                 string
+		listPresets                                bool
+		profileName                                string
+		listProfiles                               bool
+		region, caCert                             string
+		insecureSkipVerify                         bool
+		scanSample                                 int
+		cleanupWaitReplicated                      time.Duration
+		jsonOutput                                 bool
+		linkSpeedValue                             string
+		pprofListen, cpuProfile, memProfile        string
+		otelEndpoint                               string
+		otelInsecure                               bool
+		label                                      string
+		statsdAddr, statsdPrefix, statsdFormat     string
+		cloudwatchNamespace, cloudwatchRegion      string
+		thresholds                                 thresholdFlags
+		webhookURL, webhookFormat, webhookOn       string
+		seed                                       int64
+		verifySampleValue                          string
+		eventsPath                                 string
+		statBeforeGet                              bool
+		concurrency                                int
+		rampUp, rampDown                           time.Duration
+		rate                                       float64
+		window                                     time.Duration
+		sampleStrategyValue                        string
+		percentileMethodValue                      string
+		perWorkerStats                             bool
+		attribution                                bool
+		verbose                                    bool
+		resolve                                    resolveFlags
+		localAddrValue, interfaceName              string
+		hostsValue                                 string
+		signature                                  string
+		disableContentSHA256                       bool
+		sendContentMD5                             bool
+		checksumAlgorithmValue                     string
+		noMetadata                                 bool
+		overwriteTrials                            int
+		missTrials                                 int
+		cacheProbe                                 bool
+		uploadPaceValue                            string
+		uploadTimeBudget, downloadTimeBudget       time.Duration
+		outputDir                                  string
+		getQuery                                   getQueryFlags
+		sniHost, hostHeader                        string
+		downloadBucket, downloadPrefix, stagingVia string
+		replicationCheck                           bool
+		sourceEndpoint, targetEndpoint             string
+		targetAccessKey, targetSecretKey           string
+		targetBucketName                           string
+		replicationInterval, replicationTimeout    time.Duration
+		junitOutput                                string
+		summaryLine                                bool
+		permissionPreflight                        bool
+		faultInject                                string
+		replayPath                                 string
+		replaySpeed                                float64
+		replayPrepopulate, replayValidate          bool
+		growthBenchmark, growthVerify              bool
+		growthStepValue                            string
+		growthSteps                                int
+		downloadAffinityMode                       string
+		followRegionRedirect                       bool
+		sdk                                        string
+		backend, fsRoot                            string
+		fsDirect                                   bool
+		measureSigningCost                         bool
+		signingRequests                            int
+		exportKeysPath                             string
+		burst                                      int
+		burstGap                                   time.Duration
+		clientEncrypt                              bool
+		clientEncryptPassword, clientEncryptKey    string
+		debugHTTPMode, debugHTTPPath               string
+		bootstrapResamples                         int
+		bootstrapSeed                              int64
+		sizeDistributionValue                      string
+		sizeStddev, sizeMin, sizeMax               string
+		abortThreshold                             int
+		uploadRetries                              int
+		uploadTimeout                              time.Duration
+		sharedClient                               bool
+		clientsPerWorker                           int
+		acl                                        string
+		expectContinue                             time.Duration
+		userAgentSuffix                            string
+		printVersion                               bool
+		altEndpointValue                           string
+		altPresign                                 bool
+		ipVersion                                  string
+		runs                                       int
+		pauseBetweenRuns                           time.Duration
+		phaseGap, quiesceTimeout                   time.Duration
+		waitForQuiesce                             bool
+		formats                                    formatting
+	)
+	flags.StringVar(&endpoint, "endpoint", "", `S3 endpoint, e.g. "minio.local:9000" or "http://[::1]:9000" (TLS unless the scheme is "http")`)
+	flags.StringVar(&accessKey, "accessKey", "", fmt.Sprintf(`S3 access key (or through $%s)`, accessKeyEnvVarName))
+	flags.StringVar(&secretKey, "secretKey", "", fmt.Sprintf(`S3 secret key (or through $%s)`, secretKeyEnvVarName))
+	flags.StringVar(&bucketName, "bucketName", "", `S3 bucket name, or a comma separated list of buckets the trials are spread over round-robin`)
+	flags.IntVar(&fileSizeMb, "fileSize", 10, "Size of random file to generate and upload (Mb)")
+	flags.IntVar(&trials, "trials", 10, "Amount of uploads-downloads")
+	flags.StringVar(&prefix, "prefix", "", "Key prefix for uploaded objects")
+	flags.StringVar(&sseMode, "sse", "", `Server-side encryption for uploads: "s3" or "kms"`)
+	flags.StringVar(&sseKMSKeyID, "sse-kms-key-id", "", `KMS key ID (with "-sse kms")`)
+	flags.BoolVar(&compareSSE, "compare-sse", false, "Run the workload twice, plain and with -sse, and compare the results")
+	flags.BoolVar(&keepObjects, "keep-objects", false, "Do not remove uploaded objects after the run")
+	flags.BoolVar(&jsonOutput, "json", false, "Print the report as JSON")
+	flags.StringVar(&linkSpeedValue, "link-speed", "", `Speed of the network link, e.g. "10Gbps" (auto-detected on Linux if omitted)`)
+	flags.StringVar(&pprofListen, "pprof-listen", "", `Expose net/http/pprof on this address during the run, e.g. ":6060"`)
+	flags.StringVar(&cpuProfile, "cpuprofile", "", "Write a CPU profile of the measurement phases to file")
+	flags.StringVar(&memProfile, "memprofile", "", "Write a heap profile taken at the end of the measurement phases to file")
+	flags.StringVar(&otelEndpoint, "otel-endpoint", "", `Export a trace span per trial to this OTLP/gRPC collector, e.g. "localhost:4317"`)
+	flags.BoolVar(&otelInsecure, "otel-insecure", false, "Connect to the OTLP collector without TLS")
+	flags.StringVar(&label, "label", "", "Label identifying the run in reports and metrics")
+	flags.StringVar(&statsdAddr, "statsd-addr", "", `Emit per-trial metrics to this StatsD agent, e.g. "127.0.0.1:8125"`)
+	flags.StringVar(&statsdPrefix, "statsd-prefix", "s3bench.", "Prefix of StatsD metric names")
+	flags.StringVar(&statsdFormat, "statsd-format", "dogstatsd", `StatsD wire format: "dogstatsd" (with tags) or "statsd"`)
+	flags.StringVar(&cloudwatchNamespace, "cloudwatch-namespace", "", "Publish the summary to CloudWatch under this namespace (AWS credentials from the default chain)")
+	flags.StringVar(&cloudwatchRegion, "cloudwatch-region", "", "CloudWatch region (defaults to the AWS SDK configuration)")
+	flags.Var(&thresholds, "threshold", `Check to evaluate against the report, e.g. "upload.p90_time<2s" or "download.p90_speed>=100" (repeatable)`)
+	flags.StringVar(&webhookURL, "webhook-url", "", "POST the outcome of the run to this URL")
+	flags.StringVar(&webhookFormat, "webhook-format", "generic", `Webhook payload envelope: "slack" or "generic"`)
+	flags.StringVar(&webhookOn, "webhook-on", "always", `When to call the webhook: "failure" or "always"`)
+	flags.Int64Var(&seed, "seed", 0, "Seed of the deterministic payload generator (0 means random payloads)")
+	flags.StringVar(&verifySampleValue, "verify-sample", "", `Re-download this fraction of objects after the download phase and compare them byte by byte, e.g. "10%"`)
+	flags.StringVar(&eventsPath, "events", "", "Write every trial as a JSON line to file")
+	flags.BoolVar(&statBeforeGet, "stat-before-get", false, "Issue a StatObject right before each (separately timed) download")
+	flags.IntVar(&concurrency, "concurrency", 1, "Amount of parallel workers per phase")
+	flags.DurationVar(&rampUp, "ramp-up", 0, "Start the workers one by one over this window; its trials are excluded from the statistics")
+	flags.DurationVar(&rampDown, "ramp-down", 0, "Stop the workers one by one over this window after the measured trials; its trials are excluded from the statistics")
+	flags.DurationVar(&window, "window", 0, "Additionally summarize trials per time window of this size")
+	flags.StringVar(&sampleStrategyValue, "sample-strategy", "all", `How to keep samples for percentiles: "all", "reservoir:N" or "hdr" (1% relative error)`)
+	flags.StringVar(&percentileMethodValue, "percentile-method", string(percentileLinear), `Percentile method: "linear" (interpolated, as numpy) or "nearest-rank"`)
+	flags.BoolVar(&perWorkerStats, "per-worker-stats", false, "Print a per-worker breakdown of the phases")
+	flags.BoolVar(&attribution, "attribution", false, "Print where the time of the upload and the download trials went: client CPU, connection setup, request write, server wait and transfer")
+	flags.BoolVar(&verbose, "verbose", false, "Print more details per trial, e.g. whether a fresh connection was opened")
+	flags.Var(&resolve, "resolve", `Connect to host:port at this address instead of resolving it, e.g. "minio.internal:9000:10.0.0.42" (repeatable)`)
+	flags.StringVar(&localAddrValue, "local-addr", "", "Source address of the connections to the endpoint")
+	flags.StringVar(&interfaceName, "interface", "", `Send through this network interface, e.g. "eth2" (binds to its address)`)
+	flags.StringVar(&hostsValue, "hosts", "", `Spread the workers round-robin across these nodes of one cluster instead of "-endpoint", e.g. "node1:9000,node2:9000"`)
+	flags.StringVar(&signature, "signature", signatureV4, `Request signature version: "v4" or "v2" (legacy gateways)`)
+	flags.BoolVar(&disableContentSHA256, "disable-content-sha256", false, "Send uploads as UNSIGNED-PAYLOAD instead of hashing them (signature v4)")
+	flags.BoolVar(&sendContentMD5, "send-content-md5", false, "Send Content-MD5 with every upload")
+	flags.StringVar(&checksumAlgorithmValue, "checksum-algorithm", "", `Send this checksum with every upload: "CRC32", "CRC32C", "SHA1" or "SHA256"`)
+	flags.BoolVar(&noMetadata, "no-metadata", false, "Do not attach the identifying s3bench-* user metadata to uploaded objects (cleanup then removes objects without checking it)")
+	flags.IntVar(&overwriteTrials, "overwrite-trials", 0, "After the upload phase, overwrite every object with new data this many times and measure those uploads separately")
+	flags.IntVar(&missTrials, "miss-trials", 0, "After the download phase, probe this many objects which do not exist and measure the error responses")
+	flags.BoolVar(&replicationCheck, "replication-check", false, `Measure how long uploaded objects take to show up on "-target-endpoint"`)
+	flags.StringVar(&sourceEndpoint, "source-endpoint", "", `Endpoint the objects are uploaded to with "-replication-check" (instead of "-endpoint")`)
+	flags.StringVar(&targetEndpoint, "target-endpoint", "", "Endpoint the objects are expected to get replicated to")
+	flags.StringVar(&targetAccessKey, "target-access-key", "", fmt.Sprintf(`Access key of the replication target (or through $%s, defaults to the source one)`, targetAccessKeyEnvVarName))
+	flags.StringVar(&targetSecretKey, "target-secret-key", "", fmt.Sprintf(`Secret key of the replication target (or through $%s, defaults to the source one)`, targetSecretKeyEnvVarName))
+	flags.StringVar(&targetBucketName, "target-bucket", "", "Bucket on the replication target (defaults to -bucketName)")
+	flags.DurationVar(&replicationInterval, "replication-interval", 100*time.Millisecond, "How often to poll the replication target")
+	flags.DurationVar(&replicationTimeout, "replication-timeout", time.Minute, "Count an object as never replicated after this long")
+	flags.StringVar(&junitOutput, "junit-output", "", "Write the phases and threshold checks as JUnit XML test cases to file")
+	flags.BoolVar(&summaryLine, "summary-line", false, "Print a single line summary instead of the report (colored on terminals unless $NO_COLOR is set)")
+	flags.BoolVar(&permissionPreflight, "preflight", false, `Before the run, probe which operations the credentials are allowed to perform (as the "check" command) and stop if the workload needs a denied one`)
+	flags.StringVar(&faultInject, "fault-inject", "", `TESTING ONLY: randomly (by -seed) fail or delay operations, e.g. "put:error:0.1,get:latency:500ms:0.2"`)
+	flags.StringVar(&replayPath, "replay", "", `Replay the operations of a trace file (JSON lines of {"offset_ms", "op": "put"|"get", "key", "size"}) instead of the phases`)
+	flags.Float64Var(&replaySpeed, "replay-speed", 1, "Replay the trace this many times faster")
+	flags.BoolVar(&replayPrepopulate, "replay-prepopulate", false, "Upload the objects the trace reads before replaying it")
+	flags.BoolVar(&replayValidate, "replay-validate", false, `Only check the "-replay" trace file without executing it`)
+	flags.BoolVar(&growthBenchmark, "growth-benchmark", false, `Upload a single key "-growth-steps" times instead of the phases, each time "-growth-step" larger, and fit the upload time to the size`)
+	flags.StringVar(&growthStepValue, "growth-step", "16MiB", `How much larger every upload of "-growth-benchmark" gets`)
+	flags.IntVar(&growthSteps, "growth-steps", 32, `How many uploads "-growth-benchmark" does`)
+	flags.BoolVar(&growthVerify, "growth-verify", false, `Download the final object of "-growth-benchmark" and check its content`)
+
+	flags.StringVar(&sizeDistributionValue, "size-distribution", sizeFixed, `Distribution of the object sizes around -fileSize: "fixed", "uniform" or "normal"`)
+	flags.StringVar(&sizeStddev, "size-stddev", "", `Standard deviation of the object sizes, e.g. "512KiB"`)
+	flags.StringVar(&sizeMin, "size-min", "", `Smallest object size (defaults to -fileSize minus -size-stddev for "uniform", 1 byte for "normal")`)
+	flags.StringVar(&sizeMax, "size-max", "", `Largest object size (defaults to -fileSize plus -size-stddev for "uniform", twice -fileSize for "normal")`)
+	flags.IntVar(&abortThreshold, "abort-threshold", 0, "Record failed trials and stop a phase once this many failed, the following phases still run (0 aborts the run on the first failure)")
+	flags.IntVar(&uploadRetries, "upload-retries", 0, "Retry a failed upload this many times; a retry first checks whether the object got stored after all")
+	flags.DurationVar(&uploadTimeout, "upload-timeout", 0, "Fail an upload try which takes longer than this (0 means no limit)")
+	flags.BoolVar(&sharedClient, "shared-client", true, "Share one client, and so its connection pool, between the workers (per host)")
+	flags.IntVar(&clientsPerWorker, "clients-per-worker", 1, `With "-shared-client=false", the number of clients of every worker which take turns on its trials`)
+	flags.StringVar(&acl, "acl", "", `Canned ACL of the uploads, e.g. "private", "public-read" or "bucket-owner-full-control"`)
+	flags.DurationVar(&expectContinue, "expect-continue", 0, `Send uploads with "Expect: 100-continue" and wait this long for the server's go-ahead before the body (0 sends the body right away)`)
+	flags.StringVar(&userAgentSuffix, "user-agent-suffix", "", fmt.Sprintf(`Appended to the User-Agent after "%s/<version>" to tell the benchmark traffic in access logs, e.g. "teamX nightly"`, appName))
+	flags.BoolVar(&printVersion, "version", false, "Print the version and exit")
+	flags.StringVar(&altEndpointValue, "alt-endpoint", "", `After the download phase, fetch every object twice (cold and warm) from an alternate endpoint, e.g. a CDN, and compare it to the origin`)
+	flags.BoolVar(&altPresign, "alt-presign", false, `Fetch from "-alt-endpoint" through URLs presigned for the origin rather than anonymously`)
+	flags.StringVar(&ipVersion, "ip-version", ipAny, `Connect over "4" (IPv4 only), "6" (IPv6 only) or "any"`)
+	flags.IntVar(&runs, "runs", 1, "Repeat the whole benchmark this many times, each under a prefix of its own, and aggregate the runs")
+	flags.DurationVar(&pauseBetweenRuns, "pause-between-runs", 0, `Pause between the "-runs", e.g. to let caches cool down`)
+	flags.DurationVar(&phaseGap, "phase-gap", 0, "Pause this long between the upload and the download phases, e.g. for background healing or replication")
+	flags.BoolVar(&waitForQuiesce, "wait-for-quiesce", false, "Before the download phase, probe the StatObject latency until three probes in a row are within 10% of each other")
+	flags.DurationVar(&quiesceTimeout, "quiesce-timeout", 5*time.Minute, `Start the download phase after this long even if "-wait-for-quiesce" found no stable latency`)
+	flags.IntVar(&formats.durationPrecision, "duration-precision", defaultFormatting.durationPrecision, `Round the durations in the human readable output to this many significant digits, 0 prints them exactly`)
+	flags.BoolVar(&formats.prettyNumbers, "pretty", defaultFormatting.prettyNumbers, `Group the digits of byte counts and speeds in the human readable output, e.g. "1,048,576"`)
+	flags.Float64Var(&rate, "rate", 0, "Start this many upload and download trials per second on whichever worker is free, and report the backlog of trials which are due but not started (0: as fast as the workers go)")
+	flags.DurationVar(&cleanupWaitReplicated, "cleanup-wait-replicated", 0, "Before removing an object whose replication is still PENDING, wait up to this long in total for it to complete, so that no replicas are left behind")
+	flags.StringVar(&scanPrefix, "scan-prefix", "", "Instead of uploading, list the existing objects under this prefix and benchmark downloading a sample of them")
+	flags.StringVar(&scanKeysPath, "scan-keys", "", `Instead of uploading, benchmark downloading the existing objects listed in this file, a key per line optionally followed by its size; the others are sized by a stat each during the setup`)
+	flags.IntVar(&scanSample, "sample", 100, `How many of the objects listed by "-scan-prefix" to download, sampled uniformly`)
+	flags.BoolVar(&interleave, "interleave", false, "Run the upload and download phases at the same time on two worker pools, every download reading an object whose upload completed")
+	flags.BoolVar(&verifyListing, "verify-listing", false, "After the upload phase, list the run prefix and report uploaded objects which are missing from the listing or listed with another size")
+	flags.StringVar(&reportFormat, "format", "full", `Print the report in a built-in format: "full" or "brief"`)
+	flags.StringVar(&reportTemplatePath, "report-template", "", "Print the report through this text/template file, with the report as the dot")
+	flags.BoolVar(&reportFields, "report-fields", false, `Print the fields and functions available to "-report-template" and exit`)
+	flags.StringVar(&contentEncoding, "content-encoding", "", `Upload the payloads compressed with "Content-Encoding: gzip" and decode the downloads, reporting wire and decoded throughput`)
+	flags.StringVar(&statePath, "state-file", "", "Save the completed trials to this file while running, and resume from it when rerun with the same options")
+	flags.BoolVar(&autoTrials, "auto-trials", false, `Instead of "-trials", run the upload and download trials until the P90 estimate of the phase moved less than "-confidence" over the last trials`)
+	flags.StringVar(&confidenceValue, "confidence", "5%", `With "-auto-trials", how far the P90 estimate may still move for a phase to stop, e.g. "5%"`)
+	flags.IntVar(&maxTrials, "max-trials", 1000, `With "-auto-trials", the most trials a phase runs even if P90 is not stable yet`)
+	flags.IntVar(&bucketCount, "bucket-count", 0, `Spread the trials over this many buckets named by "-bucketName" with "{n}" replaced by 1, 2 and so on`)
+	flags.BoolVar(&createBucket, "create-bucket", false, "Create the buckets which do not exist, and remove them after the run unless something was left in them")
+	flags.StringVar(&verifyMode, "verify", "", `Seal every 64 KiB block of the payloads with its CRC32 and check the downloads block by block while streaming: "blocks"`)
+	flags.Float64Var(&prices.EgressPerGB, "price-per-gb-egress", 0, "Estimate the cost of the run with this price per GiB downloaded")
+	flags.Float64Var(&prices.PerThousandPut, "price-per-1k-put", 0, "Estimate the cost of the run with this price per 1000 PUT (and LIST) requests")
+	flags.Float64Var(&prices.PerThousandGet, "price-per-1k-get", 0, "Estimate the cost of the run with this price per 1000 GET (and HEAD) requests")
+	flags.BoolVar(&dryRun, "dry-run", false, `Print the requests and the transfer the options plan for, and their cost with the prices, without sending anything; with "cleanup", only list what would be removed`)
+	flags.BoolVar(&force, "force", false, `Run even if the prefix holds objects the benchmark did not create, which uploads may overwrite; confirms "cleanup -all"`)
+	flags.StringVar(&startAtValue, "start-at", "", `Complete the setup, then wait until this RFC 3339 time, e.g. "2024-06-01T14:00:00Z", before the first measured operation, to start several hosts together`)
+	flags.DurationVar(&startIn, "start-in", 0, `Like "-start-at", but this long after the tool was started, e.g. "30s"`)
+	flags.IntVar(&keyDepth, "key-depth", 0, `Nest the keys this many directories deep, e.g. "a/b/c/d/file-1.dat", spreading the trials over the tree by "-seed" (0: flat keys)`)
+	flags.IntVar(&keyFanout, "key-fanout", 16, `With "-key-depth", the number of directories per level`)
+	flags.Float64Var(&abortRatio, "abort-ratio", 0, `Cancel this fraction of the uploads at a random point (by "-seed") of the transfer, report their times to cancel apart from the upload statistics and the incomplete multipart uploads they left behind, which are removed`)
+	flags.BoolVar(&cleanupAll, "all", false, `With "cleanup", remove the incomplete uploads of the whole bucket instead of "-prefix", which requires "-force"`)
+	flags.DurationVar(&olderThan, "older-than", 0, `With "cleanup", only remove incomplete uploads initiated longer ago than this`)
+	flags.DurationVar(&maxClockSkew, "max-clock-skew", time.Second, `Warn when the Date headers of the first and the last response of a phase put the server clock further off than this (0: do not compare)`)
+	flags.StringVar(&presetName, "preset", "", `Default the workload flags to those of a well-known workload: "backup", "webassets" or "analytics"; explicit flags override them`)
+	flags.BoolVar(&listPresets, "list-presets", false, `Print the flags every "-preset" sets and exit`)
+	flags.StringVar(&profileName, "profile", "", `Default the endpoint, the keys, the region, the TLS options and the bucket to those of this profile in ~/.s3bench/profiles.yaml; explicit flags override them`)
+	flags.BoolVar(&listProfiles, "list-profiles", false, "Print the names and the endpoints of the profiles and exit")
+	flags.StringVar(&region, "region", "", "S3 region of the bucket (looked up by the client when not set)")
+	flags.StringVar(&caCert, "ca-cert", "", "Trust the PEM certificates in this file on top of the system ones")
+	flags.BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, "Do not verify the TLS certificate of the endpoint")
+	flags.BoolVar(&cacheProbe, "cache-probe", false, "After the download phase, download the same keys once more in the same order and at the same concurrency and compare the passes, as a read cache makes the repeat markedly faster")
+	flags.StringVar(&uploadPaceValue, "upload-pace", "", `Produce the payload of every upload no faster than this rate, e.g. "20MB" (per second), to emulate a producer-limited client; the report compares the achieved speed to it`)
+	flags.StringVar(&downloadBucket, "download-bucket", "", `Download from this bucket instead of where the uploads went, after copying them there or waiting for their replication (see "-download-via")`)
+	flags.StringVar(&downloadPrefix, "download-prefix", "", `Download from this key prefix instead of "-prefix", after copying the uploads there or waiting for their replication (see "-download-via")`)
+	flags.StringVar(&stagingVia, "download-via", stagingViaCopy, `How the uploads get to "-download-bucket" and "-download-prefix": "copy" them server-side, or wait up to "-replication-timeout" for the "replication" configured on the server`)
+	flags.DurationVar(&uploadTimeBudget, "upload-time-budget", 0, `Stop starting upload trials once the phase took this long, even if fewer than "-trials" ran; the downloads read what was uploaded`)
+	flags.DurationVar(&downloadTimeBudget, "download-time-budget", 0, `Stop starting download trials once the phase took this long, even if fewer than "-trials" ran`)
+	flags.StringVar(&outputDir, "output-dir", "", `Write the report as report.json and report.md, the trials as samples.jsonl, the effective flags as config.yaml (credentials redacted) and the standard output as stdout.log to this directory along with an index.json of their SHA-256; it is created if missing and must be empty unless "-force" is given`)
+	flags.Var(&getQuery, "get-query", `After the download phase, download the same keys once more with this "param=value" query parameter attached, e.g. a transformation of the gateway; the sizes are only checked to be non-zero and the report compares the passes (repeatable)`)
+	flags.StringVar(&sniHost, "sni-host", "", "Send this server name in the TLS handshake (SNI) and verify the certificate against it, instead of the endpoint host")
+	flags.StringVar(&hostHeader, "host-header", "", "Send this Host header instead of the endpoint host; the requests are still signed for the endpoint host and use path-style bucket addressing")
+	flags.StringVar(&downloadAffinityMode, "download-affinity", affinitySequential, `Which objects the download workers read: "sequential" (by trial), "worker" (each those it uploaded), "random" (uniformly sampled) or "shuffled" (a fixed random permutation); implies "-per-worker-stats" unless "sequential"`)
+	flags.BoolVar(&followRegionRedirect, "follow-region-redirect", true, `When AWS redirects the bucket to another region, switch to the regional endpoint and the region of the bucket; otherwise stop naming them`)
+	flags.StringVar(&sdk, "sdk", sdkMinio, `The client library of the requests: "minio" (minio-go) or "aws" (aws-sdk-go-v2, uploading with its transfer manager in the 16 MiB parts and with the 4 concurrent parts of minio-go)`)
+	flags.StringVar(&backend, "backend", backendS3, `Where the objects go: "s3" (the endpoint) or "fs" (the local files under "-fs-root", a baseline of the client machine without the network)`)
+	flags.StringVar(&fsRoot, "fs-root", "", `The directory of "-backend fs", a bucket being a directory below it`)
+	flags.BoolVar(&fsDirect, "fs-direct", false, `Write and read the files of "-backend fs" with O_DIRECT, bypassing the page cache (Linux only)`)
+	flags.BoolVar(&measureSigningCost, "measure-signing", false, `Before the run, time signing the requests of "-signing-requests" uploads of the object size the way the SDK does, without the network, and report the cost per request and the throughput it caps the workers at`)
+	flags.IntVar(&signingRequests, "signing-requests", 16, `How many uploads "-measure-signing" signs`)
+	flags.StringVar(&exportKeysPath, "export-keys", "", `Write the objects the run stored to this JSON file, as {bucket, key, size, etag, sha256, uploaded_at}, after the upload phase and again after the overwrites and the staging copies; "-scan-keys" reads it back`)
+	flags.IntVar(&burst, "burst", 0, `Run the upload and the download phases as "-trials" bursts of this many operations released at the same instant instead of on the worker pool, and report the spread of their completions per burst`)
+	flags.DurationVar(&burstGap, "burst-gap", time.Second, `With "-burst", the pause between the end of a burst and the release of the next one`)
+	flags.BoolVar(&clientEncrypt, "client-encrypt", false, `Encrypt the uploads client-side with AES-256-GCM before they are timed and decrypt the downloads, timing the crypto apart from the transfer; the key is derived from "-client-encrypt-password" or read from "-client-encrypt-key-file"`)
+	flags.StringVar(&clientEncryptPassword, "client-encrypt-password", "", `With "-client-encrypt", the password the key is derived from with Argon2id`)
+	flags.StringVar(&clientEncryptKey, "client-encrypt-key-file", "", `With "-client-encrypt", a file holding the 256-bit key as 32 bytes or 64 hex digits`)
+	flags.StringVar(&debugHTTPMode, "debug-http", "", `Dump the headers of the requests and their responses, the credentials redacted and the bodies omitted: of the "first" request of every phase, of the failing ones ("errors") or of "all"; the trials are dumped once they are over, outside of their time`)
+	flags.StringVar(&debugHTTPPath, "debug-http-output", "", `Write the dumps of "-debug-http" to this file instead of the standard error`)
+	flags.IntVar(&bootstrapResamples, "bootstrap", 0, `Once the run is over, resample the trials of every phase this many times, e.g. 1000, for the 95% confidence intervals of its P90 and average times and speeds (0: none)`)
+	flags.Int64Var(&bootstrapSeed, "bootstrap-seed", 1, `The seed of the resampling of "-bootstrap", the same trials getting the same intervals`)
+	// "check" only probes the permissions, "cleanup" removes incomplete multipart uploads,
+	// "schema" prints the JSON Schema of the reports, any other invocation runs the benchmark.
+	check, cleanup, schema := false, false, false
+	if len(args) > 0 && args[0] == "check" {
+		args, check = args[1:], true
+	}
+	if len(args) > 0 && args[0] == "cleanup" {
+		args, cleanup = args[1:], true
+	}
+	if len(args) > 0 && args[0] == "schema" {
+		args, schema = args[1:], true
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	sources := configSources{}
+	sources.mark(flags, sourceFlag)
+	if err := applyEnv(flags); err != nil {
+		return fatalf(`Invalid environment: %v`, err)
+	}
+	sources.mark(flags, sourceEnv)
+	if printVersion {
+		fmt.Println(appName, version)
+		return 0
+	}
+	if schema {
+		if err := printJSON(reportSchema()); err != nil {
+			return fatalf(`Unable to encode report: %v`, err)
+		}
+		return 0
+	}
+	if listPresets {
+		writePresets(os.Stdout)
+		return 0
+	}
+	if listProfiles || profileName != "" {
+		path, err := defaultProfilesPath()
+		if err != nil {
+			return fatalf(`%v`, err)
+		}
+		if listProfiles {
+			if err := writeProfiles(os.Stdout, path); err != nil {
+				return fatalf(`Unable to read the profiles: %v`, err)
+			}
+			return 0
+		}
+		if err := applyProfile(flags, path, profileName); err != nil {
+			return fatalf(`Unable to apply "-profile": %v`, err)
+		}
+		sources.mark(flags, sourceProfile)
+	}
+	if presetName != "" {
+		preset, err := findPreset(presetName)
+		if err != nil {
+			return fatalf(`Invalid "-preset": %v`, err)
+		}
+		if err := preset.apply(flags); err != nil {
+			return fatalf(`Invalid "-preset": %v`, err)
+		}
+		sources.mark(flags, sourcePreset)
+	}
+	// Captured before any option is derived from the others or from the environment, so that the
+	// hash of a resumed run matches the one of the run it resumes.
+	config := resolveConfig(flags, sources)
+	if reportFields {
+		writeReportFields(os.Stdout)
+		return 0
+	}
+	reportTemplate, err := newReportTemplate(reportFormat, reportTemplatePath)
+	if err != nil {
+		return fatalf(`Invalid report template: %v`, err)
+	}
+	if reportFormat != "full" || reportTemplatePath != "" {
+		if jsonOutput || summaryLine || runs > 1 || replayPath != "" || growthBenchmark {
+			return fatalf(`"-format" and "-report-template" are mutually exclusive with "-json", "-summary-line", "-runs", "-replay" and "-growth-benchmark"`)
+		}
+	}
+
+	var traceOps []traceOp
+	if replayPath != "" {
+		if replaySpeed <= 0 {
+			return fatalf(`"-replay-speed" must be positive`)
+		}
+		var err error
+		if traceOps, err = readTrace(replayPath); err != nil {
+			return fatalf(`Invalid trace %s: %v`, replayPath, err)
+		}
+		if replayValidate {
+			fmt.Printf("%s: %s\n", replayPath, describeTrace(traceOps, replaySpeed))
+			return 0
+		}
+	}
+
+	// Endpoints get pasted as URLs: the scheme decides on TLS, and a bucket path may stand in for
+	// the bucket name.
+	endpointFlag := "endpoint"
+	if sourceEndpoint != "" {
+		if endpoint != "" {
+			return fatalf(`"-source-endpoint" and "-endpoint" are mutually exclusive`)
+		}
+		endpoint, endpointFlag = sourceEndpoint, "source-endpoint"
+	}
+	if endpoint != "" {
+		var (
+			bucket string
+			err    error
+		)
+		if endpoint, bucket, err = normalizeEndpoint(endpointFlag, endpoint, bucketName); err != nil {
+			return fatalf(`Invalid "-%s": %v`, endpointFlag, err)
+		}
+		if bucket != "" {
+			bucketName = bucket
+		}
+	}
+	if replicationCheck {
+		if targetEndpoint == "" {
+			return fatalf(`"-replication-check" requires "-target-endpoint"`)
+		}
+		var (
+			bucket string
+			err    error
+		)
+		if targetEndpoint, bucket, err = normalizeEndpoint("target-endpoint", targetEndpoint, targetBucketName); err != nil {
+			return fatalf(`Invalid "-target-endpoint": %v`, err)
+		}
+		if bucket != "" {
+			targetBucketName = bucket
+		}
+		if replicationInterval <= 0 || replicationTimeout <= 0 {
+			return fatalf(`Both "-replication-interval" and "-replication-timeout" must be positive`)
+		}
+	}
+
+	var hosts []string
+	if hostsValue != "" {
+		if endpoint != "" {
+			return fatalf(`"-hosts" and "-endpoint" are mutually exclusive`)
+		}
+		var err error
+		if hosts, err = parseHosts(hostsValue); err != nil {
+			return fatalf(`Invalid "-hosts": %v`, err)
+		}
+		for i, host := range hosts {
+			var bucket string
+			if hosts[i], bucket, err = normalizeEndpoint("hosts", host, bucketName); err != nil {
+				return fatalf(`Invalid "-hosts": %v`, err)
+			}
+			if bucket != "" {
+				bucketName = bucket
+			}
+		}
+		// The hosts form one logical endpoint in tags and dimensions.
+		endpoint = strings.Join(hosts, ",")
+	}
+
+	switch backend {
+	case backendS3:
+		if fsRoot != "" || fsDirect {
+			return fatalf(`"-fs-root" and "-fs-direct" require "-backend fs"`)
+		}
+	case backendFS:
+		if fsRoot == "" {
+			return fatalf(`"-backend fs" requires "-fs-root"`)
+		}
+		if endpoint != "" {
+			return fatalf(`"-backend fs" is mutually exclusive with "-endpoint" and "-hosts"`)
+		}
+		if sdk != sdkMinio || replicationCheck || altEndpointValue != "" || sniHost != "" || hostHeader != "" || len(resolve) > 0 || sseMode != "" || len(getQuery) > 0 {
+			return fatalf(`"-backend fs" is mutually exclusive with "-sdk", "-replication-check", "-alt-endpoint", "-sni-host", "-host-header", "-resolve", "-sse" and "-get-query", which need S3`)
+		}
+		// Their reports carry no metadata to label them with.
+		if replayPath != "" || growthBenchmark {
+			return fatalf(`"-backend fs" is mutually exclusive with "-replay" and "-growth-benchmark"`)
+		}
+		if measureSigningCost {
+			return fatalf(`"-measure-signing" is mutually exclusive with "-backend fs", whose requests are not signed`)
+		}
+		abs, err := filepath.Abs(fsRoot)
+		if err != nil {
+			return fatalf(`Invalid "-fs-root": %v`, err)
+		}
+		fsRoot, endpoint = abs, "file://"+filepath.ToSlash(abs)
+	default:
+		return fatalf(`Unsupported "-backend" %q, expected "s3" or "fs"`, backend)
+	}
+
+	if endpoint == "" || bucketName == "" || backend == backendS3 && (accessKey == "" || secretKey == "") {
+		fmt.Printf(`Either endpoint, access key, secret key or bucket name is missing. Run with "-h" to see the usage.`)
+		return 1
+	}
+
+	if trials < 1 || concurrency < 1 {
+		return fatalf(`Both "-trials" and "-concurrency" must be positive`)
+	}
+	if abortThreshold < 0 {
+		return fatalf(`"-abort-threshold" must not be negative`)
+	}
+	if acl != "" {
+		if err := validateACL(acl); err != nil {
+			return fatalf(`Invalid "-acl": %v`, err)
+		}
+	}
+	if clientsPerWorker < 1 {
+		return fatalf(`"-clients-per-worker" must be positive`)
+	}
+	if uploadRetries < 0 || uploadTimeout < 0 {
+		return fatalf(`Neither "-upload-retries" nor "-upload-timeout" may be negative`)
+	}
+	if overwriteTrials < 0 || missTrials < 0 {
+		return fatalf(`Neither "-overwrite-trials" nor "-miss-trials" may be negative`)
+	}
+
+	sse, err := newServerSide(sseMode, sseKMSKeyID)
+	if err != nil {
+		return fatalf(`Invalid server-side encryption options: %v`, err)
+	}
+	if err := validateDurationPrecision(formats.durationPrecision); err != nil {
+		return fatalf(`Invalid duration precision: %v`, err)
+	}
+	currentFormatting = formats
+	defer func() { currentFormatting = defaultFormatting }()
+	if phaseGap < 0 || quiesceTimeout <= 0 {
+		return fatalf(`"-phase-gap" must not be negative and "-quiesce-timeout" must be positive`)
+	}
+	if !waitForQuiesce {
+		quiesceTimeout = 0
+	}
+	var scanKeys []scannedObject
+	if scanKeysPath != "" {
+		if scanPrefix != "" {
+			return fatalf(`"-scan-prefix" and "-scan-keys" are mutually exclusive`)
+		}
+		if scanKeys, err = readScanKeys(scanKeysPath); err != nil {
+			return fatalf(`Invalid "-scan-keys": %v`, err)
+		}
+	}
+	scanning := scanPrefix != "" || scanKeysPath != ""
+	if scanning {
+		if scanSample <= 0 {
+			return fatalf(`"-sample" must be positive`)
+		}
+		if overwriteTrials > 0 || verifySampleValue != "" || replicationCheck || altEndpointValue != "" || compareSSE || replayPath != "" || runs > 1 || phaseGap > 0 || waitForQuiesce || rate > 0 {
+			return fatalf(`"-scan-prefix" and "-scan-keys" only benchmark downloads, they are mutually exclusive with "-overwrite-trials", "-verify-sample", "-replication-check", "-alt-endpoint", "-compare-sse", "-replay", "-runs", "-phase-gap", "-wait-for-quiesce" and "-rate"`)
+		}
+	}
+	if err := validateContentEncoding(contentEncoding); err != nil {
+		return fatalf(`Invalid "-content-encoding": %v`, err)
+	}
+	if contentEncoding != "" && (verifySampleValue != "" || altEndpointValue != "" || replayPath != "" || scanning) {
+		return fatalf(`"-content-encoding" is mutually exclusive with "-verify-sample", "-alt-endpoint", "-replay", "-scan-prefix" and "-scan-keys"`)
+	}
+	if statePath != "" && (runs > 1 || compareSSE || replayPath != "" || interleave || scanning) {
+		return fatalf(`"-state-file" is mutually exclusive with "-runs", "-compare-sse", "-replay", "-interleave", "-scan-prefix" and "-scan-keys"`)
+	}
+	if verifyListing && (interleave || scanning) {
+		return fatalf(`"-verify-listing" is mutually exclusive with "-interleave", "-scan-prefix" and "-scan-keys"`)
+	}
+	if interleave && (rampUp > 0 || rampDown > 0 || overwriteTrials > 0 || phaseGap > 0 || waitForQuiesce || scanning) {
+		return fatalf(`"-interleave" is mutually exclusive with "-ramp-up", "-ramp-down", "-overwrite-trials", "-phase-gap", "-wait-for-quiesce", "-scan-prefix" and "-scan-keys"`)
+	}
+	var confidence float64
+	if autoTrials {
+		if confidence, err = parseFraction(confidenceValue); err != nil || confidence == 0 {
+			return fatalf(`Invalid "-confidence": must be a positive fraction, e.g. "5%%"`)
+		}
+		if maxTrials < minStableTrials {
+			return fatalf(`"-max-trials" must be at least %d`, minStableTrials)
+		}
+		if rampDown > 0 || interleave || scanning || replayPath != "" || statePath != "" {
+			return fatalf(`"-auto-trials" is mutually exclusive with "-ramp-down", "-interleave", "-scan-prefix", "-scan-keys", "-replay" and "-state-file"`)
+		}
+		trials = maxTrials
+	}
+	if err := validateVerifyMode(verifyMode); err != nil {
+		return fatalf(`Invalid "-verify": %v`, err)
+	}
+	if verifyMode != "" && (contentEncoding != "" || scanning || replayPath != "") {
+		return fatalf(`"-verify" is mutually exclusive with "-content-encoding", "-scan-prefix", "-scan-keys" and "-replay"`)
+	}
+	if bucketCount < 0 {
+		return fatalf(`"-bucket-count" must not be negative`)
+	}
+	buckets, err := parseBuckets(bucketName, bucketCount)
+	if err != nil {
+		return fatalf(`Invalid "-bucketName": %v`, err)
+	}
+	bucketName = buckets[0]
+	if len(buckets) > 1 && (scanning || replayPath != "" || replicationCheck) {
+		return fatalf(`Several buckets are mutually exclusive with "-scan-prefix", "-scan-keys", "-replay" and "-replication-check"`)
+	}
+	startAt, err := parseStartTime(startAtValue, startIn, started)
+	if err != nil {
+		return fatalf(`Unable to schedule the start: %v`, err)
+	}
+	if maxClockSkew < 0 {
+		return fatalf(`"-max-clock-skew" must not be negative`)
+	}
+	if cleanupWaitReplicated < 0 {
+		return fatalf(`"-cleanup-wait-replicated" must not be negative`)
+	}
+	if rate < 0 {
+		return fatalf(`"-rate" must not be negative`)
+	}
+	if rate > 0 && (rampUp > 0 || rampDown > 0) {
+		return fatalf(`"-rate" is mutually exclusive with "-ramp-up" and "-ramp-down"`)
+	}
+	if runs < 1 || pauseBetweenRuns < 0 {
+		return fatalf(`"-runs" must be positive and "-pause-between-runs" must not be negative`)
+	}
+	if runs > 1 && (compareSSE || replayPath != "") {
+		return fatalf(`"-runs" is mutually exclusive with "-compare-sse" and "-replay"`)
+	}
+	if compareSSE && sse == nil {
+		return fatalf(`"-compare-sse" requires "-sse" to be set`)
+	}
+
+	var linkSpeed float64
+	if linkSpeedValue != "" {
+		if linkSpeed, err = parseLinkSpeed(linkSpeedValue); err != nil {
+			return fatalf(`Invalid "-link-speed": %v`, err)
+		}
+	}
+
+	state, err := openStateFile(statePath, config.Hash)
+	if err != nil {
+		return fatalf(`Unable to resume from %s: %v`, statePath, err)
+	}
+	var verifySample float64
+	if verifySampleValue != "" {
+		if verifySample, err = parseFraction(verifySampleValue); err != nil {
+			return fatalf(`Invalid "-verify-sample": %v`, err)
+		}
+		// Expected payloads are regenerated, so verification requires the deterministic generator;
+		// a resumed run regenerates those of the uploads it restores.
+		if seed == 0 {
+			seed = state.sizeSeed(time.Now().UnixNano())
+		}
+	}
+
+	method, err := parsePercentileMethod(percentileMethodValue)
+	if err != nil {
+		return fatalf(`Invalid "-percentile-method": %v`, err)
+	}
+	newSampleSet, err := parseSampleStrategy(sampleStrategyValue, seed, method)
+	if err != nil {
+		return fatalf(`Invalid "-sample-strategy": %v`, err)
+	}
+
+	var notifier *webhook
+	if webhookURL != "" {
+		if notifier, err = newWebhook(webhookURL, webhookFormat, webhookOn, label); err != nil {
+			return fatalf(`Invalid webhook options: %v`, err)
+		}
+	}
+
+	fileSizeMb *= 1024 * 1024
+	sizeSeed := seed
+	if sizeSeed == 0 {
+		sizeSeed = state.sizeSeed(time.Now().UnixNano())
+	}
+	sizes, err := newSizeDistribution(sizeDistributionValue, int64(fileSizeMb), sizeStddev, sizeMin, sizeMax, sizeSeed)
+	if err != nil {
+		return fatalf(`Invalid "-size-distribution": %v`, err)
+	}
+	// The tree is drawn with the seed of the sizes, which a state file keeps as well.
+	keys, err := newKeyspace(keyDepth, keyFanout, sizeSeed)
+	if err != nil {
+		return fatalf(`Invalid keyspace: %v`, err)
+	}
+	if keys.nested() && (scanning || replayPath != "") {
+		return fatalf(`"-key-depth" is mutually exclusive with "-scan-prefix", "-scan-keys" and "-replay"`)
+	}
+	cancels, err := newCancelPlan(abortRatio, sizeSeed)
+	if err != nil {
+		return fatalf(`Invalid "-abort-ratio": %v`, err)
+	}
+	var uploadPace int64
+	if uploadPaceValue != "" {
+		if uploadPace, err = parseRate(uploadPaceValue); err != nil || uploadPace == 0 {
+			return fatalf(`Invalid "-upload-pace" %q, expected a rate like "20MB"`, uploadPaceValue)
+		}
+	}
+	if uploadTimeBudget < 0 || downloadTimeBudget < 0 {
+		return fatalf(`"-upload-time-budget" and "-download-time-budget" must not be negative`)
+	}
+	if uploadTimeBudget > 0 && scanning {
+		return fatalf(`"-upload-time-budget" is mutually exclusive with "-scan-prefix" and "-scan-keys", which upload nothing`)
+	}
+	if (uploadTimeBudget > 0 || downloadTimeBudget > 0) && replayPath != "" {
+		return fatalf(`"-upload-time-budget" and "-download-time-budget" are mutually exclusive with "-replay"`)
+	}
+	var readFrom *readLocation
+	if downloadBucket != "" || downloadPrefix != "" {
+		if stagingVia != stagingViaCopy && stagingVia != stagingViaReplication {
+			return fatalf(`Unsupported "-download-via" %q, expected "copy" or "replication"`, stagingVia)
+		}
+		if scanning || interleave || replayPath != "" || statePath != "" || runs > 1 || compareSSE {
+			return fatalf(`"-download-bucket" and "-download-prefix" are mutually exclusive with "-scan-prefix", "-scan-keys", "-interleave", "-replay", "-state-file", "-runs" and "-compare-sse"`)
+		}
+		readFrom = &readLocation{bucketName: downloadBucket, prefix: downloadPrefix, via: stagingVia, interval: replicationInterval, timeout: replicationTimeout}
+		if readFrom.bucketName == "" {
+			readFrom.bucketName = bucketName
+		}
+		if readFrom.prefix == "" {
+			readFrom.prefix = prefix
+		}
+		if readFrom.bucketName == bucketName && readFrom.prefix == prefix && len(buckets) < 2 {
+			return fatalf(`The download location is where the uploads go already, set another "-download-bucket" or "-download-prefix"`)
+		}
+	}
+	if cacheProbe && (interleave || autoTrials || statePath != "" || replayPath != "") {
+		return fatalf(`"-cache-probe" is mutually exclusive with "-interleave", "-auto-trials", "-state-file" and "-replay"`)
+	}
+	if len(getQuery) > 0 && replayPath != "" {
+		return fatalf(`"-get-query" is mutually exclusive with "-replay"`)
+	}
+	var growthStep int64
+	if growthBenchmark {
+		if growthStep, err = parseByteSize(growthStepValue); err != nil || growthStep <= 0 {
+			return fatalf(`Invalid "-growth-step" %q, expected a positive size like "16MiB"`, growthStepValue)
+		}
+		if growthSteps < 1 {
+			return fatalf(`"-growth-steps" must be at least 1`)
+		}
+		if replayPath != "" || scanning || interleave || statePath != "" || runs > 1 || compareSSE || dryRun {
+			return fatalf(`"-growth-benchmark" is mutually exclusive with "-replay", "-scan-prefix", "-scan-keys", "-interleave", "-state-file", "-runs", "-compare-sse" and "-dry-run"`)
+		}
+	}
+	switch sdk {
+	case sdkMinio:
+	case sdkAWS:
+		if signature != signatureV4 {
+			return fatalf(`"-sdk aws" only signs with "-signature v4"`)
+		}
+		if len(getQuery) > 0 {
+			return fatalf(`"-get-query" is mutually exclusive with "-sdk aws", whose requests carry no extra parameters`)
+		}
+	default:
+		return fatalf(`Unsupported "-sdk" %q, expected "minio" or "aws"`, sdk)
+	}
+	if err := validateAffinity(downloadAffinityMode); err != nil {
+		return fatalf(`Invalid "-download-affinity": %v`, err)
+	}
+	if downloadAffinityMode != affinitySequential {
+		if interleave || replayPath != "" || statePath != "" {
+			return fatalf(`"-download-affinity" is mutually exclusive with "-interleave", "-replay" and "-state-file"`)
+		}
+		if downloadAffinityMode == affinityWorker && scanning {
+			return fatalf(`"-download-affinity worker" is mutually exclusive with "-scan-prefix" and "-scan-keys", which upload nothing`)
+		}
+		perWorkerStats = true
+	}
+	if exportKeysPath != "" {
+		if scanning || interleave || replayPath != "" || growthBenchmark {
+			return fatalf(`"-export-keys" is mutually exclusive with "-scan-prefix", "-scan-keys", "-interleave", "-replay" and "-growth-benchmark"`)
+		}
+		if !keepObjects {
+			log.Printf(`WARNING: without "-keep-objects" the cleanup removes the objects "-export-keys" lists`)
+		}
+	}
+	if abortRatio > 0 && (interleave || scanning || replayPath != "" || statePath != "") {
+		return fatalf(`"-abort-ratio" is mutually exclusive with "-interleave", "-scan-prefix", "-scan-keys", "-replay" and "-state-file"`)
+	}
+	if burst < 0 || burstGap < 0 {
+		return fatalf(`Neither "-burst" nor "-burst-gap" may be negative`)
+	}
+	if burst > 0 {
+		if sources["concurrency"] == sourceFlag {
+			return fatalf(`"-burst" is the concurrency of the bursts, it is mutually exclusive with "-concurrency"`)
+		}
+		if interleave || rampUp > 0 || rampDown > 0 || rate > 0 || autoTrials || statePath != "" || scanning || replayPath != "" || growthBenchmark ||
+			cacheProbe || len(getQuery) > 0 || abortRatio > 0 || uploadTimeBudget > 0 || downloadTimeBudget > 0 {
+			return fatalf(`"-burst" is mutually exclusive with "-interleave", "-ramp-up", "-ramp-down", "-rate", "-auto-trials", "-state-file", "-scan-prefix", "-scan-keys", "-replay", "-growth-benchmark", "-cache-probe", "-get-query", "-abort-ratio" and the "-*-time-budget"`)
+		}
+		// Every operation of a burst is a worker of its own.
+		concurrency = burst
+	}
+	var clientCrypter *clientCrypt
+	if clientEncrypt {
+		if (clientEncryptPassword == "") == (clientEncryptKey == "") {
+			return fatalf(`"-client-encrypt" needs either "-client-encrypt-password" or "-client-encrypt-key-file"`)
+		}
+		if contentEncoding != "" || verifyMode != "" || len(getQuery) > 0 || altEndpointValue != "" || scanning || replayPath != "" || growthBenchmark {
+			return fatalf(`"-client-encrypt" is mutually exclusive with "-content-encoding", "-verify", "-get-query", "-alt-endpoint", "-scan-prefix", "-scan-keys", "-replay" and "-growth-benchmark"`)
+		}
+		var err error
+		if clientCrypter, err = newClientCrypt(clientEncryptPassword, clientEncryptKey); err != nil {
+			return fatalf(`Invalid "-client-encrypt-key-file": %v`, err)
+		}
+	} else if clientEncryptPassword != "" || clientEncryptKey != "" {
+		return fatalf(`"-client-encrypt-password" and "-client-encrypt-key-file" need "-client-encrypt"`)
+	}
+
+	if bootstrapResamples < 0 {
+		return fatalf(`"-bootstrap" must not be negative`)
+	}
+	if err := validateDebugHTTP(debugHTTPMode); err != nil {
+		return fatalf(`Invalid "-debug-http": %v`, err)
+	}
+	if debugHTTPPath != "" && debugHTTPMode == "" {
+		return fatalf(`"-debug-http-output" needs "-debug-http"`)
+	}
+	var dumper *httpDumper
+	if debugHTTPMode != "" {
+		output, closeOutput, err := debugHTTPOutput(debugHTTPPath)
+		if err != nil {
+			return fatalf(`Unable to create "-debug-http-output": %v`, err)
+		}
+		exits.add(func() {
+			if err := closeOutput(); err != nil {
+				log.Printf(`Unable to write "-debug-http-output": %v`, err)
+			}
+		})
+		dumper = newHTTPDumper(debugHTTPMode, output)
+	}
+
+	if prices.EgressPerGB < 0 || prices.PerThousandPut < 0 || prices.PerThousandGet < 0 {
+		return fatalf(`None of the "-price-*" may be negative`)
+	}
+	if cleanup {
+		if prefix == "" && !cleanupAll {
+			return fatalf(`"cleanup" needs a "-prefix", or "-all" for the whole bucket`)
+		}
+		if cleanupAll && !force {
+			return fatalf(`"cleanup -all" removes the incomplete uploads of everybody in the bucket, pass "-force" as well`)
+		}
+		if olderThan < 0 {
+			return fatalf(`"-older-than" must not be negative`)
+		}
+	}
+	if dryRun && !cleanup {
+		plan := workloadPlan{
+			runs: runs, trials: trials, overwriteTrials: overwriteTrials, missTrials: missTrials, sizes: sizes,
+			statBeforeGet: statBeforeGet, cacheProbe: cacheProbe, transformed: len(getQuery) > 0, keepObjects: keepObjects, verifyListing: verifyListing,
+			verified: int(float64(trials)*verifySample + 0.5),
+		}
+		if compareSSE {
+			plan.runs *= 2
+		}
+		cost := newCostReport(plan.requests(), prices)
+		cost.Planned = true
+		if jsonOutput {
+			if err := printJSON(cost); err != nil {
+				return fatalf(`Unable to encode report: %v`, err)
+			}
+		} else {
+			fmt.Printf("Dry run, nothing was sent:\n%s", cost)
+		}
+		return 0
+	}
+
+	if signature != signatureV2 && signature != signatureV4 {
+		return fatalf(`Invalid "-signature": %q is neither %q nor %q`, signature, signatureV4, signatureV2)
+	}
+	if disableContentSHA256 && signature != signatureV4 {
+		return fatalf(`"-disable-content-sha256" requires "-signature %s"`, signatureV4)
+	}
+
+	checksum, err := parseChecksumAlgorithm(checksumAlgorithmValue)
+	if err != nil {
+		return fatalf(`Invalid "-checksum-algorithm": %v`, err)
+	}
+
+	var injector *faultInjector
+	faultSeed := seed
+	if faultInject != "" {
+		faults, err := parseFaults(faultInject)
+		if err != nil {
+			return fatalf(`Invalid "-fault-inject": %v`, err)
+		}
+		if faultSeed == 0 {
+			faultSeed = time.Now().UnixNano()
+		}
+		injector = newFaultInjector(faults, faultSeed)
+	}
+
+	var localIP net.IP
+	switch {
+	case localAddrValue != "" && interfaceName != "":
+		return fatalf(`"-local-addr" and "-interface" are mutually exclusive`)
+	case localAddrValue != "":
+		if localIP = net.ParseIP(localAddrValue); localIP == nil {
+			return fatalf(`Invalid "-local-addr": %q is not an IP address`, localAddrValue)
+		}
+	case interfaceName != "":
+		if localIP, err = interfaceAddress(interfaceName); err != nil {
+			return fatalf(`Invalid "-interface": %v`, err)
+		}
+	}
+	if localIP != nil {
+		if err := checkBindable(localIP); err != nil {
+			return fatalf(`Unable to bind to %s: %v`, localIP, err)
+		}
+	}
+	if err := validateIPVersion(ipVersion, localIP); err != nil {
+		return fatalf(`Invalid "-ip-version": %v`, err)
+	}
+
+	var tracing *tracing
+	if otelEndpoint != "" {
+		if tracing, err = newTracing(otelEndpoint, otelInsecure); err != nil {
+			return fatalf(`Unable to set up OpenTelemetry exporter: %v`, err)
+		}
+		exits.add(func() {
+			if err := tracing.shutdown(); err != nil {
+				log.Printf(`Unable to flush traces: %v`, err)
+			}
+		})
+	}
+
+	if sniHost != "" {
+		if err := validateHostOverride(sniHost, false); err != nil {
+			return fatalf(`Invalid "-sni-host": %v`, err)
+		}
+	}
+	if hostHeader != "" {
+		if err := validateHostOverride(hostHeader, true); err != nil {
+			return fatalf(`Invalid "-host-header": %v`, err)
+		}
+	}
+
+	var targetReplication *replication
+	clientOpts := clientOptions{
+		accessKey: accessKey, secretKey: secretKey, signature: signature,
+		tracing: tracing, resolve: resolve, localIP: localIP, expectContinue: expectContinue,
+		userAgentSuffix: userAgentSuffix, ipVersion: ipVersion, requests: &requestCounter{},
+		wire: newWireCounter(), debugHTTP: dumper,
+	}
+	if maxClockSkew > 0 {
+		clientOpts.clock = &clockSkewTracker{}
+	}
+	clientOpts.region, clientOpts.insecureSkipVerify = region, insecureSkipVerify
+	clientOpts.sniHost, clientOpts.hostHeader = sniHost, hostHeader
+	clientOpts.sdk = sdk
+	if backend == backendFS {
+		if clientOpts.fs, err = newFSStore(fsRoot, bucketName, fsDirect, clientOpts.requests); err != nil {
+			return fatalf(`Unable to set up "-backend fs" under %s: %v`, fsRoot, err)
+		}
+		exits.add(func() {
+			if left := clientOpts.fs.removeCreated(); len(left) > 0 {
+				log.Printf(`WARNING: keeping the directories %s, which still hold files`, strings.Join(left, ", "))
+			}
+		})
+	}
+	if caCert != "" {
+		if clientOpts.rootCAs, err = loadCACert(caCert); err != nil {
+			return fatalf(`Invalid "-ca-cert": %v`, err)
+		}
+	}
+	var minioClient ObjectStore
+	var hostClients []ObjectStore
+	var regionRedirect *RegionRedirect
+	if hosts == nil {
+		if minioClient, err = newObjectStore(endpoint, clientOpts); err != nil {
+			return fatalf(`Error creating MinIO client: %v`, err)
+		}
+		if endpoint, region, regionRedirect, err = probeRegion(minioClient, bucketName, endpoint, region, followRegionRedirect, sniHost != "" || hostHeader != ""); err != nil {
+			return fatalf(`%v`, err)
+		}
+		if regionRedirect != nil {
+			clientOpts.region = region
+			if minioClient, err = newObjectStore(endpoint, clientOpts); err != nil {
+				return fatalf(`Error creating MinIO client for %s: %v`, endpoint, err)
+			}
+		}
+		minioClient = injector.wrap(minioClient)
+	} else {
+		for _, host := range hosts {
+			client, err := newObjectStore(host, clientOpts)
+			if err != nil {
+				return fatalf(`Error creating MinIO client for %s: %v`, host, err)
+			}
+			hostClients = append(hostClients, injector.wrap(client))
+		}
+		// Verification and cleanup are served by any node.
+		minioClient = hostClients[0]
+	}
+
+	if replicationCheck {
+		// The overrides are those of the endpoint's TLS terminator.
+		targetOpts := clientOpts
+		targetOpts.sniHost, targetOpts.hostHeader = "", ""
+		if targetAccessKey != "" || targetSecretKey != "" {
+			targetOpts.accessKey, targetOpts.secretKey = targetAccessKey, targetSecretKey
+		}
+		if targetBucketName == "" {
+			targetBucketName = bucketName
+		}
+		target, err := newObjectStore(targetEndpoint, targetOpts)
+		if err != nil {
+			return fatalf(`Error creating MinIO client for the replication target: %v`, err)
+		}
+		targetReplication = &replication{target: target, bucketName: targetBucketName, interval: replicationInterval, timeout: replicationTimeout}
+	}
+
+	var alt *altEndpoint
+	if altEndpointValue != "" {
+		if alt, err = newAltEndpoint(altEndpointValue, altPresign, resolve, localIP, ipVersion, clientOpts.wire); err != nil {
+			return fatalf(`Invalid "-alt-endpoint": %v`, err)
+		}
+	}
+
+	var metrics *statsd
+	if statsdAddr != "" {
+		if metrics, err = newStatsd(statsdAddr, statsdPrefix, statsdFormat, "endpoint", endpoint, "bucket", bucketName, "label", label); err != nil {
+			return fatalf(`Unable to set up StatsD: %v`, err)
+		}
+		exits.add(metrics.close)
+	}
+
+	if pprofListen != "" {
+		stopPprof, err := startPprofServer(pprofListen)
+		if err != nil {
+			return fatalf(`Unable to start pprof server: %v`, err)
+		}
+		exits.add(stopPprof)
+	}
+
+	var runDir *runDirectory
+	if outputDir != "" {
+		if check || cleanup || eventsPath != "" {
+			return fatalf(`"-output-dir" is mutually exclusive with "check", "cleanup" and "-events", it holds the events as %s`, runSamples)
+		}
+		if runDir, err = newRunDirectory(outputDir, force); err != nil {
+			return fatalf(`Invalid "-output-dir": %v`, err)
+		}
+		if err := runDir.writeConfig(config); err != nil {
+			return fatalf(`Unable to write the configuration to "-output-dir": %v`, err)
+		}
+		if err := runDir.captureStdout(); err != nil {
+			return fatalf(`Unable to capture the standard output to "-output-dir": %v`, err)
+		}
+		// Added first, it closes after the events.
+		exits.add(func() {
+			if err := runDir.close(); err != nil {
+				log.Printf(`Unable to write the index of "-output-dir": %v`, err)
+			}
+		})
+		eventsPath = runDir.file(runSamples)
+	}
+
+	var events *eventWriter
+	if eventsPath != "" {
+		if events, err = newEventWriter(eventsPath); err != nil {
+			return fatalf(`Unable to create events output: %v`, err)
+		}
+		exits.add(func() {
+			if err := events.close(); err != nil {
+				log.Printf(`Unable to write events output: %v`, err)
+			}
+		})
+		events.write(ConfigRecord{Record: "config", Config: config})
+	}
+
+	// Trial lines must not get mixed into the JSON document on stdout.
+	var progress io.Writer = os.Stdout
+	if jsonOutput {
+		progress = os.Stderr
+	}
+	fmt.Fprint(progress, config)
+	if backend == backendFS {
+		fmt.Fprintf(progress, "%s\n", fsBackendWarning(fsRoot, fsDirect))
+	}
+	var signing *SigningCost
+	if measureSigningCost {
+		if signingRequests < 1 {
+			return fatalf(`"-signing-requests" must be positive`)
+		}
+		method := signingMethod(sdk, signature, minioClient.EndpointURL().Scheme == "https", disableContentSHA256)
+		fmt.Fprintf(progress, "Measuring signing: %d requests of %s (%s)\n", signingRequests, formatBytes(int64(fileSizeMb)), method)
+		cost := measureSigning(method, signingRequests, int64(fileSizeMb), clientOpts, concurrency)
+		signing = &cost
+	}
+
+	// Sized along with the setup, the keys of "-scan-keys" replace the uploads from then on.
+	var keysScan *ScanStats
+	if scanKeys != nil {
+		keysScan = &ScanStats{KeysFile: scanKeysPath, Listed: len(scanKeys)}
+	}
+	bench := runner{
+		client:                minioClient,
+		hosts:                 hostClients,
+		bucketName:            bucketName,
+		buckets:               buckets,
+		prefix:                prefix,
+		sizes:                 sizes,
+		trials:                trials,
+		keepObjects:           keepObjects,
+		cleanupWaitReplicated: cleanupWaitReplicated,
+		scanPrefix:            scanPrefix,
+		keysScan:              keysScan,
+		scanSample:            scanSample,
+		interleave:            interleave,
+		verifyListing:         verifyListing,
+		contentEncoding:       contentEncoding,
+		progress:              progress,
+		profiler:              newProfiler(cpuProfile, memProfile),
+		tracing:               tracing,
+		statsd:                metrics,
+		label:                 label,
+		webhook:               notifier,
+		seed:                  seed,
+		keys:                  keys,
+		cancels:               cancels,
+		preset:                presetName,
+		verifySample:          verifySample,
+		verifyMode:            verifyMode,
+		requests:              clientOpts.requests,
+		clock:                 clientOpts.clock,
+		wire:                  clientOpts.wire,
+		debugHTTP:             clientOpts.debugHTTP,
+		maxClockSkew:          maxClockSkew,
+		prices:                prices,
+		events:                events,
+		statBeforeGet:         statBeforeGet,
+		concurrency:           concurrency,
+		rampUp:                rampUp,
+		rampDown:              rampDown,
+		rate:                  rate,
+		autoTrials:            autoTrials,
+		confidence:            confidence,
+		window:                window,
+		newSampleSet:          newSampleSet,
+		sampleStrategy:        sampleStrategyValue,
+		percentileMethod:      method,
+		bootstrapResamples:    bootstrapResamples,
+		bootstrapSeed:         bootstrapSeed,
+		perWorkerStats:        perWorkerStats,
+		attribution:           attribution,
+		verbose:               verbose,
+
+		signature:            signature,
+		disableContentSHA256: disableContentSHA256,
+		sendContentMD5:       sendContentMD5,
+		checksum:             checksum,
+		runID:                state.runID(newRunID()),
+		state:                state,
+		overwriteTrials:      overwriteTrials,
+		missTrials:           missTrials,
+		cacheProbe:           cacheProbe,
+		getQuery:             getQuery,
+		affinity:             newDownloadAffinity(downloadAffinityMode, seed),
+		exported:             newKeyExporter(exportKeysPath),
+		runDir:               runDir,
+		config:               config,
+		burst:                burst,
+		burstGap:             burstGap,
+		clientCrypt:          clientCrypter,
+		readFrom:             readFrom,
+		uploadPace:           uploadPace,
+		timeBudgets:          map[string]time.Duration{"upload": uploadTimeBudget, "download": downloadTimeBudget},
+		abortThreshold:       abortThreshold,
+		uploadRetries:        uploadRetries,
+		uploadTimeout:        uploadTimeout,
+		clientMode:           clientModeShared,
+		acl:                  acl,
+		expectContinue:       expectContinue,
+		userAgent:            userAgent(userAgentSuffix),
+		ipVersion:            ipVersion,
+		phaseGap:             phaseGap,
+		quiesceTimeout:       quiesceTimeout,
+		replication:          targetReplication,
+		altEndpoint:          alt,
+		junitOutput:          junitOutput,
+		halt:                 halt,
+		ctx:                  ctx,
+		results:              results,
+	}
+	if results != nil {
+		results.method = method
+	}
+	if injector != nil {
+		bench.faultInject, bench.faultSeed = faultInject, faultSeed
+	}
+	if !noMetadata {
+		bench.metadata = objectMetadata(bench.runID, label, time.Now())
+	}
+
+	if createBucket {
+		created, err := createBuckets(minioClient, buckets)
+		exits.add(func() { removeCreatedBuckets(minioClient, created) })
+		if err != nil {
+			return bench.fail(1, `Unable to create the buckets: %v`, withSignature(err, signature))
+		}
+		for _, bucket := range created {
+			fmt.Fprintf(progress, "Created bucket %s\n", bucket)
+		}
+	}
+
+	preflighted := hostClients
+	if preflighted == nil {
+		preflighted = []ObjectStore{minioClient}
+	}
+	for _, client := range preflighted {
+		for _, bucket := range buckets {
+			if err := preflight(client, bucket, resolve, progress); err != nil {
+				return bench.fail(failureExitCode(err), `Preflight check of %s failed: %v`, client.EndpointURL().Host, withSignature(err, signature))
+			}
+		}
+	}
+	if targetReplication != nil {
+		if err := preflight(targetReplication.target, targetReplication.bucketName, resolve, progress); err != nil {
+			return bench.fail(failureExitCode(err), `Preflight check of the replication target %s failed: %v`, targetEndpoint, withSignature(err, signature))
+		}
+	}
+
+	if check || permissionPreflight {
+		checks := bench.checkPermissions()
+		fmt.Fprintf(progress, "Permissions:\n%s", formatPermissions(checks))
+		if !permissionsPassed(checks) {
+			if check {
+				return 1
+			}
+			return bench.fail(1, `Permission preflight failed: the workload needs a denied operation`)
+		}
+		if check {
+			return 0
+		}
+	}
+
+	if cleanup {
+		removed := bench.cleanupIncompleteUploads(cleanupAll, olderThan, dryRun, progress)
+		if code := halt.exitCode(); code != 0 {
+			return code
+		}
+		if jsonOutput {
+			if err := printJSON(removed); err != nil {
+				return fatalf(`Unable to encode report: %v`, err)
+			}
+		} else {
+			fmt.Printf("\nCleanup:\n%s", removed)
+		}
+		return exitCode(removed.Failed == 0)
+	}
+
+	// Scanned objects are only read.
+	if !scanning {
+		if err := bench.checkPrefixes(force); err != nil {
+			return bench.fail(1, `%v`, withSignature(err, signature))
+		}
+	}
+
+	if !sharedClient {
+		endpoints := hosts
+		if endpoints == nil {
+			endpoints = []string{endpoint}
+		}
+		if bench.workerClients, err = newWorkerClients(endpoints, concurrency, clientsPerWorker, clientOpts, bucketName, injector); err != nil {
+			return bench.fail(1, `Error creating MinIO clients: %v`, withSignature(err, signature))
+		}
+		bench.clientMode = clientModePerWorker
+	}
+
+	if scanKeys != nil {
+		bench.scanned, bench.keysScan = bench.sizeScanKeys(scanKeysPath, scanKeys)
+		if code := halt.exitCode(); code != 0 {
+			return code
+		}
+		bench.uploaded, bench.trials = len(bench.scanned), len(bench.scanned)
+	}
+
+	if traceOps != nil {
+		bench.sse = sse
+		if !startAt.IsZero() {
+			bench.start = waitForStart(progress, startAt)
+		}
+		report := bench.replay(replayPath, traceOps, replaySpeed, replayPrepopulate)
+		if code := halt.exitCode(); code != 0 {
+			return code
+		}
+		writeRunReport(runDir, report, report.String())
+		if jsonOutput {
+			if err := printJSON(report); err != nil {
+				return fatalf(`Unable to encode report: %v`, err)
+			}
+		} else {
+			fmt.Printf("\nReport:\n%s\n", report)
+		}
+		return 0
+	}
+
+	if growthBenchmark {
+		bench.sse = sse
+		if !startAt.IsZero() {
+			bench.start = waitForStart(progress, startAt)
+		}
+		report := bench.growth(growthStep, growthSteps, growthVerify)
+		if code := halt.exitCode(); code != 0 {
+			return code
+		}
+		writeRunReport(runDir, report, report.String())
+		if jsonOutput {
+			if err := printJSON(report); err != nil {
+				return fatalf(`Unable to encode report: %v`, err)
+			}
+		} else {
+			fmt.Printf("\nReport:\n%s\n", report)
+		}
+		return exitCode(report.Passed())
+	}
+
+	// Auto-detection is best effort: the utilization line is simply omitted when it fails.
+	// A bound source address determines the interface, otherwise the route to the endpoint does.
+	var linkInterface string
+	if linkSpeed == 0 && backend == backendS3 {
+		var (
+			iface = interfaceName
+			err   error
+		)
+		switch {
+		case iface != "":
+		case localIP != nil:
+			iface, err = interfaceByAddress(localIP)
+		default:
+			hostport := endpointHostPort(minioClient)
+			if address, ok := resolve.lookup(hostport); ok {
+				_, port, _ := net.SplitHostPort(hostport)
+				hostport = net.JoinHostPort(address, port)
+			}
+			iface, err = egressInterface(hostport)
+		}
+		if err == nil {
+			if speed, err := detectLinkSpeed(iface); err == nil {
+				linkInterface, linkSpeed = iface, speed
+			}
+		}
+	}
+
+	if !startAt.IsZero() {
+		// The wait is no part of the setup.
+		waitStarted := time.Now()
+		bench.start = waitForStart(progress, startAt)
+		started = started.Add(time.Since(waitStarted))
+	}
+
+	// finish attaches the derived figures to a report and publishes it to the configured sinks.
+	finish := func(report *Report, variant string) {
+		if linkSpeed > 0 {
+			report.Link = newLinkUtilization(linkInterface, linkSpeed, *report)
+		}
+		report.Metadata.Resolve = resolve.strings()
+		report.Metadata.SNIHost, report.Metadata.HostHeader = sniHost, hostHeader
+		report.Metadata.RegionRedirect = regionRedirect
+		report.Metadata.SDK = sdk
+		report.Metadata.Backend = backend
+		if signing != nil {
+			cost := *signing
+			cost.observe(report.Upload)
+			report.Signing = &cost
+		}
+		if backend == backendFS {
+			report.Metadata.FSRoot, report.Metadata.FSDirect = fsRoot, fsDirect
+		}
+		if localIP != nil {
+			report.Metadata.LocalAddr = localIP.String()
+		}
+		report.Thresholds = evaluateThresholds(*report, thresholds)
+		events.write(SummaryRecord{Record: "summary", Variant: variant, Line: report.summaryLine(styler{})})
+		if cloudwatchNamespace != "" {
+			if err := publishToCloudWatch(cloudwatchNamespace, cloudwatchRegion, endpoint, bucketName, variant, *report); err != nil {
+				log.Printf(`WARNING: unable to publish metrics to CloudWatch: %v`, err)
+			}
+		}
+	}
+
+	if runs > 1 {
+		bench.sse = sse
+		reports := make([]Report, 0, runs)
+		pooled := newResults(method)
+		for i := 1; i <= runs; i++ {
+			if i > 1 && pauseBetweenRuns > 0 {
+				fmt.Fprintf(progress, "Pausing for %v\n", pauseBetweenRuns)
+				time.Sleep(pauseBetweenRuns)
+			}
+			run := bench
+			run.prefix, run.title = fmt.Sprintf("%srun-%d/", prefix, i), fmt.Sprintf("run-%d", i)
+			runResults := newResults(method)
+			run.results = &runResults
+			if i == 1 {
+				run.setup = time.Since(started)
+			} else {
+				// Only the first run starts at "-start-at".
+				run.start = nil
+			}
+			report := run.run()
+			if code := halt.exitCode(); code != 0 {
+				return code
+			}
+			finish(&report, "")
+			reports = append(reports, report)
+			pooled = pooled.Merge(runResults)
+		}
+		if results != nil {
+			results.Samples = pooled.Samples
+		}
+		multi := aggregateRuns(reports, pooled)
+		writeRunReport(runDir, multi, multi.String())
+		switch {
+		case jsonOutput:
+			if err := printJSON(multi); err != nil {
+				return fatalf(`Unable to encode report: %v`, err)
+			}
+		case summaryLine:
+			fmt.Println(multi.summaryLine(newStyler(os.Stdout)))
+		default:
+			fmt.Printf("\n%s\n", multi)
+		}
+		notifier.notify(multi, multi.Passed(), nil)
+		if junitOutput != "" {
+			suites := make([]junitTestSuite, 0, runs)
+			for i, report := range reports {
+				suites = append(suites, junitReportSuite(fmt.Sprintf("run-%d", i+1), report))
+			}
+			if err := writeJUnit(junitOutput, suites...); err != nil {
+				log.Printf(`Unable to write JUnit output: %v`, err)
+			}
+		}
+		cleanups := make([]*CleanupStats, 0, len(reports))
+		for _, report := range reports {
+			cleanups = append(cleanups, report.Cleanup)
+		}
+		return exitCode(multi.Passed(), cleanups...)
+	}
+
+	if !compareSSE {
+		bench.sse, bench.setup = sse, time.Since(started)
+		state.keep(func(sig os.Signal) {
+			bench.exitf(exitInterrupted, `Interrupted by %v, rerun with the same options to resume from %s`, sig, statePath)
+		})
+		report := bench.run()
+		if code := halt.exitCode(); code != 0 {
+			state.halt()
+			return code
+		}
+		state.finish()
+		finish(&report, "")
+		if results != nil {
+			results.Report = &report
+		}
+		writeRunReport(runDir, report, renderReport(reportTemplate, report))
+		switch {
+		case jsonOutput:
+			if err := printJSON(report); err != nil {
+				return fatalf(`Unable to encode report: %v`, err)
+			}
+		case summaryLine:
+			fmt.Println(report.summaryLine(newStyler(os.Stdout)))
+		default:
+			fmt.Printf("\nReport:\n%s\n", renderReport(reportTemplate, report))
+		}
+		notifier.notify(report, report.Passed(), nil)
+		if junitOutput != "" {
+			if err := writeJUnit(junitOutput, junitReportSuite("", report)); err != nil {
+				log.Printf(`Unable to write JUnit output: %v`, err)
+			}
+		}
+		return exitCode(report.Passed(), report.Cleanup)
+	}
+
+	plain, encrypted := bench, bench
+	plain.prefix, plain.title = prefix+"plain/", "plain"
+	encrypted.prefix, encrypted.title, encrypted.sse = prefix+"sse/", "sse-"+sseMode, sse
+	plain.statsd, encrypted.statsd = metrics.withTags("variant", plain.title), metrics.withTags("variant", encrypted.title)
+
+	plain.setup = time.Since(started)
+	plainReport, encryptedReport := plain.run(), encrypted.run()
+	if code := halt.exitCode(); code != 0 {
+		return code
+	}
+	finish(&plainReport, plain.title)
+	finish(&encryptedReport, encrypted.title)
+	comparison := compareReports(plainReport, encryptedReport)
+	text := fmt.Sprintf("Report (plain):\n%s\nReport (sse-%s):\n%s\nComparison:\n%s",
+		renderReport(reportTemplate, comparison.Plain), sseMode, renderReport(reportTemplate, comparison.Encrypted), comparison)
+	writeRunReport(runDir, comparison, text)
+	switch {
+	case jsonOutput:
+		if err := printJSON(comparison); err != nil {
+			return fatalf(`Unable to encode report: %v`, err)
+		}
+	case summaryLine:
+		fmt.Println(comparison.summaryLine(newStyler(os.Stdout)))
+	default:
+		fmt.Printf("\n%s\n", text)
+	}
+	passed := plainReport.Passed() && encryptedReport.Passed()
+	notifier.notify(comparison, passed, nil)
+	if junitOutput != "" {
+		if err := writeJUnit(junitOutput, junitReportSuite(plain.title, plainReport), junitReportSuite(encrypted.title, encryptedReport)); err != nil {
+			log.Printf(`Unable to write JUnit output: %v`, err)
+		}
+	}
+	return exitCode(passed, plainReport.Cleanup, encryptedReport.Cleanup)
+}
+
+// exitCode is the exit code of a run, failed thresholds taking precedence over the cleanups
+// which left objects behind.
+func exitCode(passed bool, cleanups ...*CleanupStats) int {
+	if !passed {
+		return exitThresholdsFailed
+	}
+	for _, cleanup := range cleanups {
+		if cleanup.incomplete() {
+			return exitCleanupIncomplete
+		}
+	}
+	return 0
+}
+
+// writeRunReport adds the report to the "-output-dir", if any; a failure leaves the console
+// report intact.
+func writeRunReport(dir *runDirectory, report any, text string) {
+	if err := dir.writeReport(report, text); err != nil {
+		log.Printf(`Unable to write the report to "-output-dir": %v`, err)
+	}
+}
+
+// fatalf logs why the run cannot go on and is the exit code of runCLI, which returns it so that
+// the cleanups run.
+func fatalf(format string, args ...any) int {
+	log.Printf(format, args...)
+	return 1
+}
+
+// cleanups are run once, the last added first.
+type cleanups struct {
+	funcs []func()
+}
+
+func (c *cleanups) add(f func()) {
+	c.funcs = append(c.funcs, f)
+}
+
+func (c *cleanups) run() {
+	for i := len(c.funcs) - 1; i >= 0; i-- {
+		c.funcs[i]()
+	}
+	c.funcs = nil
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+// Report holds per-phase statistics. Times are serialized as nanoseconds, speeds as MB/s, both in
+// full precision whatever the human readable output is rounded to.
+type Report struct {
+	// SchemaVersion is the reportSchemaVersion the report conforms to, see the "schema" command.
+	SchemaVersion string      `json:"schema_version"`
+	Label         string      `json:"label,omitempty"`
+	Metadata      RunMetadata `json:"metadata"`
+	Upload        PhaseStats  `json:"upload"`
+	Download      PhaseStats  `json:"download"`
+	Stat          *PhaseStats `json:"stat,omitempty"`
+	Digest        *PhaseStats `json:"digest,omitempty"`
+	// Continue holds the waits of the uploads for "100 Continue" with "-expect-continue".
+	Continue *PhaseStats `json:"continue,omitempty"`
+	// Pace compares the uploads to "-upload-pace".
+	Pace *UploadPace `json:"pace,omitempty"`
+	// Overwrite holds the re-uploads to existing keys with "-overwrite-trials".
+	Overwrite *PhaseStats `json:"overwrite,omitempty"`
+	// Sizes summarizes the uploaded sizes with a "-size-distribution" other than "fixed".
+	Sizes *SizeStats `json:"sizes,omitempty"`
+	// SizeCurve fits the duration of the trials to their sizes along with Sizes.
+	SizeCurve *SizeCurve `json:"size_curve,omitempty"`
+	// Miss holds the probes for missing objects with "-miss-trials".
+	Miss *PhaseStats `json:"miss,omitempty"`
+	// CacheProbe compares the download phase to a repeat of it with "-cache-probe".
+	CacheProbe *CacheProbe `json:"cache_probe,omitempty"`
+	// Transformed compares the download phase to a pass with the "-get-query" parameters.
+	Transformed *TransformedGET `json:"transformed,omitempty"`
+	// Staging makes the uploads readable at "-download-bucket" and "-download-prefix".
+	Staging *StagingStats `json:"staging,omitempty"`
+	// Gap is the pause before the download phase with "-phase-gap" or "-wait-for-quiesce".
+	Gap *GapStats `json:"gap,omitempty"`
+	// AltEndpoint compares the downloads from "-alt-endpoint" to the download phase.
+	AltEndpoint *AltEndpointStats `json:"alt_endpoint,omitempty"`
+	// Resumed is set when samples were restored from "-state-file".
+	Resumed *ResumeStats `json:"resumed,omitempty"`
+	Cost    *CostReport  `json:"cost,omitempty"`
+	// Encoding compares wire and decoded bytes with "-content-encoding".
+	Encoding *EncodingStats `json:"encoding,omitempty"`
+	// ClientEncryption is only set with "-client-encrypt".
+	ClientEncryption *ClientEncryptionStats `json:"client_encryption,omitempty"`
+	// Interleaved is the combined throughput of the overlapping phases of "-interleave".
+	Interleaved *InterleaveStats `json:"interleaved,omitempty"`
+	// Scan replaces the uploads with "-scan-prefix".
+	Scan *ScanStats `json:"scan,omitempty"`
+	// Cancelled holds the uploads given up on with "-abort-ratio".
+	Cancelled *CancelStats `json:"cancelled,omitempty"`
+	// Cleanup is missing with "-keep-objects".
+	Cleanup *CleanupStats `json:"cleanup,omitempty"`
+	// Replication holds the delays until objects appeared on the target with "-replication-check".
+	Replication *ReplicationStats   `json:"replication,omitempty"`
+	Attribution *LatencyAttribution `json:"attribution,omitempty"`
+	Workers     *PerWorkerStats     `json:"workers,omitempty"`
+	Hosts       *PerHostStats       `json:"hosts,omitempty"`
+	Buckets     *PerBucketStats     `json:"buckets,omitempty"`
+
+	// Connections is the number of distinct connections opened during the phases.
+	Connections int              `json:"connections"`
+	Link        *LinkUtilization `json:"link,omitempty"`
+	// ClockSkew is missing with "-max-clock-skew 0".
+	ClockSkew *ClockSkew `json:"clock_skew,omitempty"`
+	// Wire is missing with "-backend fs".
+	Wire []PhaseWire `json:"wire,omitempty"`
+
+	Windows      []WindowStats     `json:"windows,omitempty"`
+	Bursts       []BurstStats      `json:"bursts,omitempty"`
+	Thresholds   []ThresholdResult `json:"thresholds,omitempty"`
+	Verification *Verification     `json:"verification,omitempty"`
+	// Blocks is only checked with "-verify blocks".
+	Blocks *BlockVerification `json:"blocks,omitempty"`
+	// Listing checks the uploads against a listing with "-verify-listing".
+	Listing *ListingCheck `json:"listing,omitempty"`
+
+	ClientResources *ClientResources `json:"client_resources,omitempty"`
+	// Signing is the cost of signing the uploads with "-measure-signing".
+	Signing *SigningCost `json:"signing,omitempty"`
+	Timing  Timing       `json:"timing"`
+}
+
+// RunMetadata records the options which change what the numbers mean.
+type RunMetadata struct {
+	StatBeforeGet bool          `json:"stat_before_get,omitempty"`
+	Concurrency   int           `json:"concurrency"`
+	RampUp        time.Duration `json:"ramp_up,omitempty"`
+	RampDown      time.Duration `json:"ramp_down,omitempty"`
+	Rate          float64       `json:"rate,omitempty"`
+
+	SampleStrategy   string           `json:"sample_strategy"`
+	PercentileMethod percentileMethod `json:"percentile_method"`
+
+	Signature            string `json:"signature"`
+	DisableContentSHA256 bool   `json:"disable_content_sha256,omitempty"`
+	SendContentMD5       bool   `json:"send_content_md5,omitempty"`
+	ChecksumAlgorithm    string `json:"checksum_algorithm,omitempty"`
+	RunID                string `json:"run_id"`
+	OverwriteTrials      int    `json:"overwrite_trials,omitempty"`
+	// FaultInject marks runs whose failures and latencies were partly injected on purpose.
+	FaultInject     string        `json:"fault_inject,omitempty"`
+	FaultInjectSeed int64         `json:"fault_inject_seed,omitempty"`
+	AbortThreshold  int           `json:"abort_threshold,omitempty"`
+	UploadRetries   int           `json:"upload_retries,omitempty"`
+	UploadTimeout   time.Duration `json:"upload_timeout,omitempty"`
+	// ClientMode is whether the workers share clients or each has ClientsPerWorker of its own.
+	ClientMode       string        `json:"client_mode"`
+	ClientsPerWorker int           `json:"clients_per_worker,omitempty"`
+	ACL              string        `json:"acl,omitempty"`
+	ExpectContinue   time.Duration `json:"expect_continue,omitempty"`
+	// UserAgent is what the requests carry after minio-go's own User-Agent.
+	UserAgent  string `json:"user_agent"`
+	Version    string `json:"version"`
+	MissTrials int    `json:"miss_trials,omitempty"`
+	CacheProbe bool   `json:"cache_probe,omitempty"`
+	// DownloadAffinity is which objects the download workers read, the "-download-affinity".
+	DownloadAffinity string `json:"download_affinity"`
+	// UploadPace is in bytes per second.
+	UploadPace int64 `json:"upload_pace,omitempty"`
+
+	Resolve   []string `json:"resolve,omitempty"`
+	LocalAddr string   `json:"local_addr,omitempty"`
+	// RemoteIP is where the first connection went to, given the "-ip-version".
+	IPVersion string `json:"ip_version"`
+	// SNIHost and HostHeader are the "-sni-host" and "-host-header" overrides of the endpoint host.
+	SNIHost    string `json:"sni_host,omitempty"`
+	HostHeader string `json:"host_header,omitempty"`
+	// RegionRedirect is the redirect to the region of the bucket the setup followed.
+	RegionRedirect *RegionRedirect `json:"region_redirect,omitempty"`
+	// SDK is the client library which produced the numbers, "-sdk".
+	SDK string `json:"sdk"`
+	// Backend is "fs" when the numbers are those of the local files under FSRoot, not of S3.
+	Backend  string `json:"backend"`
+	FSRoot   string `json:"fs_root,omitempty"`
+	FSDirect bool   `json:"fs_direct,omitempty"`
+	RemoteIP string `json:"remote_ip,omitempty"`
+
+	PhaseGap       time.Duration `json:"phase_gap,omitempty"`
+	QuiesceTimeout time.Duration `json:"quiesce_timeout,omitempty"`
+	// Start is set with "-start-at" or "-start-in".
+	Start  *SyncedStart `json:"start,omitempty"`
+	Preset string       `json:"preset,omitempty"`
+	// Keyspace is set with "-key-depth".
+	Keyspace *KeyspaceShape `json:"keyspace,omitempty"`
+	// Config is every option of the run, the credentials fingerprinted.
+	Config *EffectiveConfig `json:"config,omitempty"`
+	// Burst is the size of the bursts of "-burst", BurstGap the time between them.
+	Burst    int           `json:"burst,omitempty"`
+	BurstGap time.Duration `json:"burst_gap,omitempty"`
+	// ClientEncrypt is the cipher of "-client-encrypt": the phases are those of the ciphertext,
+	// not comparable with a plain run.
+	ClientEncrypt string `json:"client_encrypt,omitempty"`
+}
+
+type PhaseStats struct {
+	Count    int           `json:"count"`
+	AvgTime  time.Duration `json:"avg_time"`
+	AvgSpeed float64       `json:"avg_speed"`
+	P90Time  time.Duration `json:"p90_time"`
+	P90Speed float64       `json:"p90_speed"`
+
+	// Throughput is the aggregate speed over the phase wall-clock time (the plateau with ramps).
+	Bytes      int64         `json:"bytes"`
+	Elapsed    time.Duration `json:"elapsed"`
+	Throughput float64       `json:"throughput"`
+	OpsPerSec  float64       `json:"ops_per_sec"`
+	Start      time.Time     `json:"start"`
+
+	Connections ConnectionStats `json:"connections"`
+	// TTFB is the time to the first byte of the downloads.
+	AvgTTFB time.Duration `json:"avg_ttfb,omitempty"`
+	P90TTFB time.Duration `json:"p90_ttfb,omitempty"`
+
+	// Failed trials are not part of the statistics above, what they transferred before failing
+	// is WastedBytes. With "-abort-threshold" the phase is stopped after AbortedAfter
+	// operations, failed ones included.
+	Failed      int   `json:"failed,omitempty"`
+	WastedBytes int64 `json:"wasted_bytes,omitempty"`
+	// Errors counts the failed trials by class: throttle, timeout, auth, not-found or other.
+	Errors errorCounts `json:"errors,omitempty"`
+	// Recovered uploads were found stored by a retry, which hints at too short client timeouts
+	// rather than at server failures.
+	Recovered int `json:"recovered,omitempty"`
+	// ClockAnomalies are trials which measured a non-positive duration, which only a clock
+	// anomaly produces; they are left out of the statistics.
+	ClockAnomalies int  `json:"clock_anomalies,omitempty"`
+	Aborted        bool `json:"aborted,omitempty"`
+	AbortedAfter   int  `json:"aborted_after,omitempty"`
+	// DecodedBytes are the payload bytes before "-content-encoding", Bytes those on the wire.
+	DecodedBytes      int64   `json:"decoded_bytes,omitempty"`
+	DecodedThroughput float64 `json:"decoded_throughput,omitempty"`
+	// Trend is missing for phases of fewer than minTrendTrials trials.
+	Trend *TrendStats `json:"trend,omitempty"`
+	// Backlog is only tracked for phases paced by "-rate".
+	Backlog *BacklogStats `json:"backlog,omitempty"`
+	// Stability is only tracked with "-auto-trials".
+	Stability *StabilityStats `json:"stability,omitempty"`
+	// TimeBudget is only tracked with "-upload-time-budget" and "-download-time-budget".
+	TimeBudget *TimeBudgetStats `json:"time_budget,omitempty"`
+	// ClientDelay is missing for phases whose trials sent no request.
+	ClientDelay *ClientDelay `json:"client_delay,omitempty"`
+	// Bootstrap is only computed with "-bootstrap".
+	Bootstrap *BootstrapStats `json:"bootstrap,omitempty"`
+}
+
+func (s PhaseStats) withThroughput(bytes int64, elapsed time.Duration) PhaseStats {
+	s.Bytes, s.Elapsed = bytes, elapsed
+	// A skipped phase, e.g. the upload of a scan, took no time and has no throughput.
+	if elapsed > 0 {
+		s.Throughput = float64(bytes) / elapsed.Seconds() / 1024 / 1024 // MB/s
+		s.OpsPerSec = float64(s.Count) / elapsed.Seconds()
+	}
+	return s
+}
+
+func (r Report) String() string {
+	s := ""
+	if r.Metadata.Backend == backendFS {
+		s += fmt.Sprintf(" %s\n", fsBackendWarning(r.Metadata.FSRoot, r.Metadata.FSDirect))
+	}
+	if r.Metadata.ClientEncrypt != "" {
+		s += fmt.Sprintf(" %s\n", clientEncryptionNote(r.Metadata.ClientEncrypt))
+	}
+	if r.Metadata.FaultInject != "" {
+		s += fmt.Sprintf(" %s\n", faultInjectionWarning(r.Metadata.FaultInject, r.Metadata.FaultInjectSeed))
+	}
+	if r.Scan != nil {
+		// Nothing was uploaded.
+		s += fmt.Sprintf(` Download P90: time=%s speed=%s MB/s (%s, n=%d)
+ Average     : download.time=%s
+`,
+			formatDuration(r.Download.P90Time), formatSpeed(r.Download.P90Speed), r.Metadata.PercentileMethod, r.Download.Count,
+			formatDuration(r.Download.AvgTime))
+	} else {
+		s += fmt.Sprintf(` Upload P90  : time=%s speed=%s MB/s (%s, n=%d)
+ Download P90: time=%s speed=%s MB/s (%s, n=%d)
+ Average     : upload.time=%s download.time=%s
+`,
+			formatDuration(r.Upload.P90Time), formatSpeed(r.Upload.P90Speed), r.Metadata.PercentileMethod, r.Upload.Count,
+			formatDuration(r.Download.P90Time), formatSpeed(r.Download.P90Speed), r.Metadata.PercentileMethod, r.Download.Count,
+			formatDuration(r.Upload.AvgTime), formatDuration(r.Download.AvgTime))
+	}
+	if (r.Scan == nil && r.Upload.Count < minSamplesForP90) || r.Download.Count < minSamplesForP90 {
+		s += fmt.Sprintf("  WARNING: with fewer than %d samples P90 is essentially the maximum\n", minSamplesForP90)
+	}
+	s += formatBootstrap(r.phases())
+	if r.Resumed != nil {
+		s += r.Resumed.String()
+	}
+	if r.Scan != nil {
+		s += r.Scan.String()
+	}
+	if r.Interleaved != nil {
+		s += r.Interleaved.String()
+	}
+	if r.Encoding != nil {
+		s += r.Encoding.String(r.Upload, r.Download)
+	}
+	if r.ClientEncryption != nil {
+		s += r.ClientEncryption.String()
+	}
+	if failures := r.failures(); failures != "" {
+		s += fmt.Sprintf(" Failures    : %s\n", failures)
+	}
+	if anomalies := r.clockAnomalies(); anomalies != "" {
+		s += fmt.Sprintf(" Anomalies   : %s (trials of a non-positive duration, left out of the statistics; check the clock of the client)\n", anomalies)
+	}
+	if recovered := r.recovered(); recovered != "" {
+		s += fmt.Sprintf(" Recovered   : %s (stored although the client gave up, check the timeouts)\n", recovered)
+	}
+	if r.Metadata.RampUp > 0 || r.Metadata.RampDown > 0 {
+		s += fmt.Sprintf(" Plateau     : workers=%d upload=%s download=%s\n", r.Metadata.Concurrency, formatDuration(r.Upload.Elapsed), formatDuration(r.Download.Elapsed))
+	}
+	for _, phase := range []struct {
+		name  string
+		stats PhaseStats
+	}{{"upload", r.Upload}, {"download", r.Download}} {
+		if phase.stats.Backlog != nil {
+			s += phase.stats.Backlog.String(phase.name)
+		}
+		if phase.stats.Stability != nil {
+			s += phase.stats.Stability.String(phase.name)
+		}
+		if phase.stats.TimeBudget != nil {
+			s += phase.stats.TimeBudget.String(phase.name)
+		}
+		if phase.stats.Trend != nil && phase.stats.Trend.Significant {
+			s += phase.stats.Trend.String(phase.name)
+		}
+	}
+	if r.Sizes != nil {
+		s += r.Sizes.String()
+	}
+	if r.SizeCurve != nil {
+		s += r.SizeCurve.String()
+	}
+	if r.Overwrite != nil {
+		s += fmt.Sprintf(" Overwrite   : p90.time=%s p90.speed=%s MB/s avg.time=%s (n=%d)\n",
+			formatDuration(r.Overwrite.P90Time), formatSpeed(r.Overwrite.P90Speed), formatDuration(r.Overwrite.AvgTime), r.Overwrite.Count)
+	}
+	if r.Miss != nil {
+		s += fmt.Sprintf(" Miss        : p90.time=%s avg.time=%s ops=%.1f/s (n=%d)\n",
+			formatDuration(r.Miss.P90Time), formatDuration(r.Miss.AvgTime), r.Miss.OpsPerSec, r.Miss.Count)
+	}
+	if r.Staging != nil {
+		s += r.Staging.String()
+	}
+	if r.Gap != nil {
+		s += r.Gap.String()
+	}
+	if r.CacheProbe != nil {
+		s += r.CacheProbe.String()
+	}
+	if r.Transformed != nil {
+		s += r.Transformed.String(r.Download)
+	}
+	if r.AltEndpoint != nil {
+		s += r.AltEndpoint.String(r.Download)
+	}
+	if r.Replication != nil {
+		s += r.Replication.String()
+	}
+	if r.Stat != nil {
+		s += fmt.Sprintf(" Stat        : p90.time=%s avg.time=%s (before each download)\n", formatDuration(r.Stat.P90Time), formatDuration(r.Stat.AvgTime))
+	}
+	if r.Digest != nil {
+		s += fmt.Sprintf(" Digest      : p90.time=%s avg.time=%s (before each upload)\n", formatDuration(r.Digest.P90Time), formatDuration(r.Digest.AvgTime))
+	}
+	if r.Continue != nil {
+		s += fmt.Sprintf(" Continue    : p90.time=%s avg.time=%s (uploads waiting for \"100 Continue\", timeout %v)\n",
+			formatDuration(r.Continue.P90Time), formatDuration(r.Continue.AvgTime), r.Metadata.ExpectContinue)
+	}
+	if r.Pace != nil {
+		s += r.Pace.String()
+	}
+	if r.Metadata.Start != nil {
+		s += r.Metadata.Start.String()
+	}
+	if r.Metadata.Keyspace != nil {
+		s += r.Metadata.Keyspace.String()
+	}
+	clients := r.Metadata.ClientMode
+	if r.Metadata.ClientsPerWorker > 0 {
+		clients += fmt.Sprintf("(%d)", r.Metadata.ClientsPerWorker)
+	}
+	// Numbers of another SDK must not pass for those of minio-go.
+	if r.Metadata.SDK == sdkAWS {
+		clients += " sdk=aws"
+	}
+	s += fmt.Sprintf(" Connections : opened=%d clients=%s upload.fresh=%d upload.reused=%d download.fresh=%d download.reused=%d\n",
+		r.Connections, clients, r.Upload.Connections.Fresh, r.Upload.Connections.Reused, r.Download.Connections.Fresh, r.Download.Connections.Reused)
+	if r.Attribution != nil {
+		s += r.Attribution.String()
+	}
+	if r.Workers != nil {
+		s += r.Workers.String()
+	}
+	if r.Hosts != nil {
+		s += r.Hosts.String()
+	}
+	if r.Buckets != nil {
+		s += r.Buckets.String()
+	}
+	if r.Link != nil {
+		s += r.Link.String()
+	}
+	if r.ClockSkew != nil {
+		s += r.ClockSkew.String()
+	}
+	if delays := r.clientDelays(); delays != "" {
+		s += fmt.Sprintf(" Delay       : %s (due to the first request on the client, GC pauses included)\n", delays)
+	}
+	if len(r.Wire) > 0 {
+		s += formatWire(r.Wire)
+	}
+	if r.ClientResources != nil {
+		s += r.ClientResources.String()
+	}
+	if r.Signing != nil {
+		s += r.Signing.String()
+	}
+	if len(r.Bursts) > 0 {
+		s += formatBursts(r.Bursts)
+	}
+	if len(r.Windows) > 0 {
+		s += formatWindows(r.Windows)
+	}
+	if r.Listing != nil {
+		s += r.Listing.String()
+	}
+	if r.Verification != nil {
+		s += r.Verification.String()
+	}
+	if r.Blocks != nil {
+		s += r.Blocks.String()
+	}
+	if len(r.Thresholds) > 0 {
+		s += " Thresholds  :\n"
+		for _, result := range r.Thresholds {
+			s += result.String()
+		}
+	}
+	if r.Cancelled != nil {
+		s += r.Cancelled.String()
+	}
+	if r.Cleanup != nil && r.Cleanup.eventful() {
+		s += r.Cleanup.String()
+	}
+	if r.Cost != nil {
+		s += r.Cost.String()
+	}
+	if r.Metadata.Config != nil {
+		s += fmt.Sprintf(" Config      : sha256=%s\n", r.Metadata.Config.Hash)
+	}
+	s += r.Timing.String()
+	return s
+}
+
+func summarize(times, speeds sampleSet) PhaseStats {
+	return PhaseStats{
+		Count:    times.count(),
+		AvgTime:  time.Duration(times.mean()),
+		AvgSpeed: speeds.mean(),
+		P90Time:  time.Duration(times.percentile(0.9)),
+		P90Speed: speeds.percentile(0.9),
+	}
+}
+
+func newServerSide(mode, kmsKeyID string) (encrypt.ServerSide, error) {
+	switch mode {
+	case "":
+		return nil, nil
+	case "s3":
+		return encrypt.NewSSE(), nil
+	case "kms":
+		return encrypt.NewSSEKMS(kmsKeyID, nil)
+	default:
+		return nil, fmt.Errorf(`unsupported mode %q`, mode)
+	}
+}
+
+// endpointHostPort returns the endpoint address with the scheme's default port filled in.
+func endpointHostPort(client ObjectStore) string {
+	u := client.EndpointURL()
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "443"
+	if u.Scheme == "http" {
+		port = "80"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+const (
+	signatureV2 = "v2"
+	signatureV4 = "v4"
+)
+
+// clientOptions are shared by the clients of all hosts.
+type clientOptions struct {
+	accessKey, secretKey string
+	signature            string
+	tracing              *tracing
+	resolve              resolveFlags
+	localIP              net.IP
+	expectContinue       time.Duration
+	userAgentSuffix      string
+	ipVersion            string
+	requests             *requestCounter
+	clock                *clockSkewTracker
+	region               string
+	rootCAs              *x509.CertPool
+	insecureSkipVerify   bool
+	// sniHost and hostHeader override the endpoint host in the TLS handshake and the Host header.
+	sniHost, hostHeader string
+	// sdk is the client library, "-sdk".
+	sdk string
+	// fs serves every client with "-backend fs".
+	fs *fsStore
+	// wire counts the bytes of the connections of all the clients per phase.
+	wire *wireCounter
+	// debugHTTP dumps the requests with "-debug-http".
+	debugHTTP *httpDumper
+}
+
+// newObjectStore creates the client of the endpoint with the "-sdk".
+func newObjectStore(endpoint string, opts clientOptions) (ObjectStore, error) {
+	if opts.fs != nil {
+		return opts.fs, nil
+	}
+	if opts.sdk == sdkAWS {
+		return newAWSClient(endpoint, opts)
+	}
+	return newMinioClient(endpoint, opts)
+}
+
+// newTransport is the transport of a client of the endpoint with the connection, TLS and Host
+// header options applied, and traced and counted. pathStyle tells that the bucket must not be
+// addressed by the host.
+func newTransport(endpoint string, address endpointAddress, opts clientOptions) (transport http.RoundTripper, pathStyle bool, err error) {
+	base, err := minio.DefaultTransport(address.secure())
+	if err != nil {
+		return nil, false, err
+	}
+	if needsDialer(opts.resolve, opts.localIP, opts.ipVersion) {
+		base.DialContext = newDialContext(opts.resolve, opts.localIP, opts.ipVersion)
+	}
+	base.DialContext = opts.wire.dialContext(base.DialContext)
+	// Plain HTTP has no TLS configuration.
+	if tlsConfig := base.TLSClientConfig; tlsConfig != nil {
+		if opts.rootCAs != nil {
+			tlsConfig.RootCAs = opts.rootCAs
+		}
+		tlsConfig.InsecureSkipVerify = opts.insecureSkipVerify
+		if opts.sniHost != "" {
+			tlsConfig.ServerName = opts.sniHost
+		}
+	} else if opts.sniHost != "" {
+		return nil, false, fmt.Errorf(`"-sni-host" needs a TLS endpoint, %s is plain HTTP`, endpoint)
+	}
+
+	transport = base
+	if opts.expectContinue > 0 {
+		transport = withExpectContinue(base, opts.expectContinue)
+	}
+	if opts.hostHeader != "" {
+		// Virtual-host-style requests would go to, and be signed for, another host.
+		host, _, err := net.SplitHostPort(address.host)
+		if err != nil {
+			host = address.host
+		}
+		transport, pathStyle = withHostHeader(transport, opts.hostHeader, strings.Trim(host, "[]")), true
+	}
+	// The dumps show the requests as they go out, with the headers of the wrappers included.
+	transport = opts.debugHTTP.transport(transport)
+	return opts.tracing.transport(opts.requests.transport(opts.clock.transport(transport))), pathStyle, nil
+}
+
+func newMinioClient(endpoint string, opts clientOptions) (ObjectStore, error) {
+	address, err := parseEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	transport, pathStyle, err := newTransport(endpoint, address, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var creds *credentials.Credentials
+	switch opts.signature {
+	case signatureV2:
+		creds = credentials.NewStaticV2(opts.accessKey, opts.secretKey, "")
+	case signatureV4:
+		creds = credentials.NewStaticV4(opts.accessKey, opts.secretKey, "")
+	default:
+		return nil, fmt.Errorf(`unknown signature version %q`, opts.signature)
+	}
+	lookup := minio.BucketLookupAuto
+	if pathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(address.host, &minio.Options{
+		Creds:        creds,
+		Secure:       address.secure(),
+		Region:       opts.region,
+		Transport:    transport,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, err
+	}
+	client.SetAppInfo(appName, appVersion(opts.userAgentSuffix))
+	return minioStore{client}, nil
+}
+
+// withSignature points at the signature version when the server rejected the credentials,
+// as legacy gateways often only accept one of them.
+func withSignature(err error, signature string) error {
+	if isAuthError(err) {
+		return fmt.Errorf(`%w (signature %s)`, err, signature)
+	}
+	return err
+}
+
+func isAuthError(err error) bool {
+	switch resp := errorResponse(err); resp.Code {
+	case "SignatureDoesNotMatch", "AccessDenied", "InvalidAccessKeyId", "AuthorizationHeaderMalformed":
+		return true
+	case "InvalidRequest":
+		// A generic code of malformed requests, which AWS also answers an unsupported signature
+		// version or a missing payload hash with.
+		message := strings.ToLower(resp.Message)
+		return strings.Contains(message, "authorization mechanism") || strings.Contains(message, "signature") ||
+			strings.Contains(message, "aws4-hmac-sha256") || strings.Contains(message, "x-amz-content-sha256")
+	}
+	return false
+}
@@ -0,0 +1,294 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// The format of "-client-encrypt", modelled on the DARE format of minio/sio: a header of a magic,
+// the version and a random nonce, followed by the payload sealed in AES-256-GCM packages of
+// clientCryptPackageSize bytes with the header as their additional data. The nonce of a package
+// is the one of the header followed by its number, that of the last one flagged, so that neither
+// reordered nor truncated packages go unnoticed.
+const (
+	clientCryptCipher      = "AES-256-GCM"
+	clientCryptVersion     = 1
+	clientCryptHeaderSize  = 16
+	clientCryptPackageSize = 64 * 1024
+	clientCryptTagSize     = 16
+	clientCryptFinal       = 1 << 31
+)
+
+var (
+	clientCryptMagic = []byte("s3be")
+	// clientCryptSalt is fixed, so that a password decrypts the objects kept by earlier runs; the
+	// same password thus gives the same key to everyone, which "-client-encrypt-key-file" avoids.
+	clientCryptSalt = []byte("github.com/thekondor/s3-simple-benchmarker")
+
+	errClientCryptInvalid = fmt.Errorf(`not a "-client-encrypt" object, or modified (%w)`, errorIntegrity)
+)
+
+// clientCrypt encrypts the uploads and decrypts the downloads of "-client-encrypt". A nil
+// clientCrypt leaves them alone.
+type clientCrypt struct {
+	key []byte
+}
+
+// newClientCrypt derives the key from the password with Argon2id, or reads it from keyFile as
+// 32 bytes or 64 hex digits.
+func newClientCrypt(password, keyFile string) (*clientCrypt, error) {
+	if keyFile == "" {
+		return &clientCrypt{key: argon2.IDKey([]byte(password), clientCryptSalt, 1, 64*1024, 4, 32)}, nil
+	}
+	content, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) == 32 {
+		return &clientCrypt{key: content}, nil
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf(`%s holds neither 32 bytes nor 64 hex digits`, keyFile)
+	}
+	return &clientCrypt{key: key}, nil
+}
+
+// aead is a cipher of the key of its own, for a worker.
+func (c *clientCrypt) aead() cipher.AEAD {
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// cipher names the cipher in the metadata, nothing without encryption.
+func (c *clientCrypt) cipher() string {
+	if c == nil {
+		return ""
+	}
+	return clientCryptCipher
+}
+
+// plaintextSize is the size of the payload a sealer encrypted, 0 without encryption.
+func plaintextSize(sealer *clientSealer, data []byte) int64 {
+	if sealer == nil {
+		return 0
+	}
+	return int64(len(data))
+}
+
+// encryptedSize is the size of the object of a payload of size bytes.
+func encryptedSize(size int64) int64 {
+	packages := max64(1, (size+clientCryptPackageSize-1)/clientCryptPackageSize)
+	return clientCryptHeaderSize + size + packages*clientCryptTagSize
+}
+
+// clientSealer encrypts the payloads of a worker into a buffer of its own.
+type clientSealer struct {
+	aead cipher.AEAD
+	buf  []byte
+}
+
+func (c *clientCrypt) sealer(largest int64) *clientSealer {
+	if c == nil {
+		return nil
+	}
+	return &clientSealer{aead: c.aead(), buf: make([]byte, 0, encryptedSize(largest))}
+}
+
+// seal encrypts plaintext under a random nonce, an error if none could be drawn: a nonce used
+// twice would give the key away.
+func (s *clientSealer) seal(plaintext []byte) ([]byte, time.Duration, error) {
+	start := time.Now()
+	header := make([]byte, clientCryptHeaderSize)
+	copy(header, clientCryptMagic)
+	header[len(clientCryptMagic)] = clientCryptVersion
+	if _, err := rand.Read(header[8:]); err != nil {
+		return nil, 0, fmt.Errorf(`unable to draw a nonce, %w`, err)
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	copy(nonce, header[8:])
+	out := append(s.buf[:0], header...)
+	for n := uint32(0); ; n++ {
+		chunk := plaintext[:min64(int64(len(plaintext)), clientCryptPackageSize)]
+		plaintext = plaintext[len(chunk):]
+		seq := n
+		if len(plaintext) == 0 {
+			seq |= clientCryptFinal
+		}
+		binary.BigEndian.PutUint32(nonce[8:], seq)
+		out = s.aead.Seal(out, nonce, chunk, header)
+		if len(plaintext) == 0 {
+			break
+		}
+	}
+	s.buf = out
+	return out, time.Since(start), nil
+}
+
+// clientOpener reads the plaintext of an object from r. The decryption is timed apart from the
+// reading, as elapsed.
+type clientOpener struct {
+	aead    cipher.AEAD
+	r       io.Reader
+	header  []byte
+	nonce   []byte
+	seq     uint32
+	done    bool
+	elapsed time.Duration
+
+	// sealed holds a package and one byte beyond, which tells whether it is the last one; carry
+	// is that byte having been read already.
+	sealed  []byte
+	carry   bool
+	plain   []byte
+	pending []byte
+}
+
+func (c *clientCrypt) opener(r io.Reader) *clientOpener {
+	aead := c.aead()
+	return &clientOpener{aead: aead, r: r, nonce: make([]byte, aead.NonceSize()), sealed: make([]byte, clientCryptPackageSize+clientCryptTagSize+1)}
+}
+
+func (o *clientOpener) Read(p []byte) (int, error) {
+	for len(o.pending) == 0 {
+		if o.done {
+			return 0, io.EOF
+		}
+		if err := o.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, o.pending)
+	o.pending = o.pending[n:]
+	return n, nil
+}
+
+func (o *clientOpener) next() error {
+	if o.header == nil {
+		header := make([]byte, clientCryptHeaderSize)
+		if _, err := io.ReadFull(o.r, header); err != nil {
+			return noEOF(err)
+		}
+		if !bytes.Equal(header[:len(clientCryptMagic)], clientCryptMagic) || header[len(clientCryptMagic)] != clientCryptVersion {
+			return errClientCryptInvalid
+		}
+		o.header = header
+		copy(o.nonce, header[8:])
+	}
+
+	offset := 0
+	if o.carry {
+		offset = 1
+	}
+	n, err := io.ReadFull(o.r, o.sealed[offset:])
+	size := offset + n
+	switch {
+	case err == nil:
+		size--
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		o.done = true
+	default:
+		return err
+	}
+	seq := o.seq
+	if o.done {
+		seq |= clientCryptFinal
+	}
+	binary.BigEndian.PutUint32(o.nonce[8:], seq)
+
+	start := time.Now()
+	plain, err := o.aead.Open(o.plain[:0], o.nonce, o.sealed[:size], o.header)
+	o.elapsed += time.Since(start)
+	if err != nil {
+		return errClientCryptInvalid
+	}
+	o.plain, o.pending = plain, plain
+	o.seq++
+	if !o.done {
+		o.sealed[0], o.carry = o.sealed[size], true
+	}
+	return nil
+}
+
+// noEOF is an object which ended early, also when it ended before one byte.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ClientEncryptionStats is the cost of "-client-encrypt". The phases time the transfer of the
+// ciphertext only: the payload is encrypted before an upload is timed, the decryption is taken
+// out of the download time. Overhead is the share of the ciphertext over the plaintext in
+// percent, the speeds are those of a single worker, the end-to-end ones of the plaintext over
+// the transfer and the crypto together.
+type ClientEncryptionStats struct {
+	Cipher         string        `json:"cipher"`
+	PackageSize    int           `json:"package_size"`
+	Overhead       float64       `json:"overhead"`
+	AvgEncryptTime time.Duration `json:"avg_encrypt_time"`
+	P90EncryptTime time.Duration `json:"p90_encrypt_time"`
+	EncryptSpeed   float64       `json:"encrypt_speed"`
+	AvgDecryptTime time.Duration `json:"avg_decrypt_time"`
+	P90DecryptTime time.Duration `json:"p90_decrypt_time"`
+	DecryptSpeed   float64       `json:"decrypt_speed"`
+	UploadSpeed    float64       `json:"upload_speed"`
+	DownloadSpeed  float64       `json:"download_speed"`
+}
+
+func newClientEncryptionStats(uploads, downloads *phaseRecorder) *ClientEncryptionStats {
+	s := &ClientEncryptionStats{
+		Cipher:         clientCryptCipher,
+		PackageSize:    clientCryptPackageSize,
+		AvgEncryptTime: time.Duration(uploads.cryptTimes.mean()),
+		P90EncryptTime: time.Duration(uploads.cryptTimes.percentile(0.9)),
+		EncryptSpeed:   transferSpeed(uploads.plaintext, uploads.crypt),
+		AvgDecryptTime: time.Duration(downloads.cryptTimes.mean()),
+		P90DecryptTime: time.Duration(downloads.cryptTimes.percentile(0.9)),
+		DecryptSpeed:   transferSpeed(downloads.plaintext, downloads.crypt),
+		UploadSpeed:    transferSpeed(uploads.plaintext, uploads.endToEnd),
+		DownloadSpeed:  transferSpeed(downloads.plaintext, downloads.endToEnd),
+	}
+	if uploads.plaintext > 0 {
+		s.Overhead = float64(uploads.bytes-uploads.plaintext) / float64(uploads.plaintext) * 100
+	}
+	return s
+}
+
+func (s ClientEncryptionStats) String() string {
+	return fmt.Sprintf(` Encryption  : client-side %s in %s packages, overhead=%.2f%%
+  encrypt.p90=%s encrypt.avg=%s (%s MB/s), decrypt.p90=%s decrypt.avg=%s (%s MB/s)
+  end-to-end: upload=%s MB/s download=%s MB/s (the crypto included)
+`,
+		s.Cipher, formatBytes(int64(s.PackageSize)), s.Overhead,
+		formatDuration(s.P90EncryptTime), formatDuration(s.AvgEncryptTime), formatSpeed(s.EncryptSpeed),
+		formatDuration(s.P90DecryptTime), formatDuration(s.AvgDecryptTime), formatSpeed(s.DecryptSpeed),
+		formatSpeed(s.UploadSpeed), formatSpeed(s.DownloadSpeed))
+}
+
+// clientEncryptionNote marks the reports of client-encrypted runs, whose phases are not those of
+// a plain run.
+func clientEncryptionNote(cipher string) string {
+	return fmt.Sprintf("NOTE: client-side encrypted with %s, the phases time the transfer of the ciphertext; compare with client-encrypted runs only", cipher)
+}
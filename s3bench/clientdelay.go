@@ -0,0 +1,137 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// clientDelayWorst is the number of the most delayed trials of a phase kept for "-verbose".
+const clientDelayWorst = 5
+
+// ClientDelay is how long the trials of a phase were held up on the client: from when a trial
+// was due, at the end of the previous trial of its worker or at its "-rate" slot if that is
+// later, until its first request reached the transport, the payload preparation timed apart
+// left out. The scheduler, the runtime and the SDK are all there is in between, so spikes here
+// with normal server latencies point at the client. GCPause is the garbage collection pause
+// of the trials of the phase, as far as the runtime still remembers the pauses.
+type ClientDelay struct {
+	Count   int            `json:"count"`
+	P50     time.Duration  `json:"p50"`
+	P90     time.Duration  `json:"p90"`
+	P99     time.Duration  `json:"p99"`
+	Max     time.Duration  `json:"max"`
+	GCPause time.Duration  `json:"gc_pause"`
+	Worst   []DelayedTrial `json:"worst,omitempty"`
+}
+
+// DelayedTrial is one of the most delayed trials, with the GC pauses overlapping its delay.
+type DelayedTrial struct {
+	Trial   int           `json:"trial"`
+	Worker  int           `json:"worker"`
+	Due     time.Time     `json:"due"`
+	Delay   time.Duration `json:"delay"`
+	GCPause time.Duration `json:"gc_pause,omitempty"`
+	GCCount int           `json:"gc_count,omitempty"`
+}
+
+// clientDelay is the delay of a trial, false for a trial whose request never reached the
+// transport or which was not scheduled.
+func (s sample) clientDelay() (time.Duration, bool) {
+	if s.due.IsZero() || s.handedOver.IsZero() {
+		return 0, false
+	}
+	return time.Duration(max64(0, int64(s.handedOver.Sub(s.due)-s.prepared))), true
+}
+
+// delayRecorder accumulates the delays of the trials of a phase.
+type delayRecorder struct {
+	delays   sampleSet
+	max      time.Duration
+	from, to time.Time
+	worst    []DelayedTrial
+}
+
+func (d *delayRecorder) add(s sample, delay time.Duration) {
+	d.delays.add(float64(delay))
+	if delay > d.max {
+		d.max = delay
+	}
+	if d.from.IsZero() || s.due.Before(d.from) {
+		d.from = s.due
+	}
+	if s.handedOver.After(d.to) {
+		d.to = s.handedOver
+	}
+	if len(d.worst) == clientDelayWorst && delay <= d.worst[len(d.worst)-1].Delay {
+		return
+	}
+	d.worst = append(d.worst, DelayedTrial{Trial: s.trial, Worker: s.worker, Due: s.due, Delay: delay})
+	sort.SliceStable(d.worst, func(i, j int) bool { return d.worst[i].Delay > d.worst[j].Delay })
+	if len(d.worst) > clientDelayWorst {
+		d.worst = d.worst[:clientDelayWorst]
+	}
+}
+
+// stats reads the GC pauses of the runtime, which stops the world for a moment: only to be
+// called once the phase is over.
+func (d *delayRecorder) stats() *ClientDelay {
+	if d.delays.count() == 0 {
+		return nil
+	}
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	c := &ClientDelay{
+		Count: d.delays.count(),
+		P50:   time.Duration(d.delays.percentile(0.5)),
+		P90:   time.Duration(d.delays.percentile(0.9)),
+		P99:   time.Duration(d.delays.percentile(0.99)),
+		Max:   d.max,
+	}
+	c.GCPause, _ = gcPauses(&mem, d.from, d.to)
+	for _, trial := range d.worst {
+		trial.GCPause, trial.GCCount = gcPauses(&mem, trial.Due, trial.Due.Add(trial.Delay))
+		c.Worst = append(c.Worst, trial)
+	}
+	return c
+}
+
+// gcPauses sums up the GC pauses overlapping from to to among the last ones the runtime keeps.
+func gcPauses(mem *runtime.MemStats, from, to time.Time) (time.Duration, int) {
+	var (
+		total time.Duration
+		count int
+	)
+	for i := 0; i < len(mem.PauseEnd) && uint32(i) < mem.NumGC; i++ {
+		end := time.Unix(0, int64(mem.PauseEnd[i]))
+		start := end.Add(-time.Duration(mem.PauseNs[i]))
+		if end.Before(from) || start.After(to) {
+			continue
+		}
+		total += time.Duration(mem.PauseNs[i])
+		count++
+	}
+	return total, count
+}
+
+func (c ClientDelay) String() string {
+	return fmt.Sprintf("p50=%s p90=%s p99=%s max=%s gc.pause=%s", formatDuration(c.P50), formatDuration(c.P90), formatDuration(c.P99), formatDuration(c.Max), formatDuration(c.GCPause))
+}
+
+// printWorstDelays lists the most delayed trials of a phase for "-verbose".
+func printWorstDelays(w io.Writer, phase string, c *ClientDelay) {
+	if c == nil {
+		return
+	}
+	fmt.Fprintf(w, "Client delay (%s): %s\n", phase, c)
+	for _, trial := range c.Worst {
+		gc := ""
+		if trial.GCCount > 0 {
+			gc = fmt.Sprintf(", gc.pause=%s in %d pauses", formatDuration(trial.GCPause), trial.GCCount)
+		}
+		fmt.Fprintf(w, " - Trial: %d,\tworker=%d delay=%s%s\n", trial.Trial, trial.Worker, formatDuration(trial.Delay), gc)
+	}
+}
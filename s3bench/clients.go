@@ -0,0 +1,33 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	clientModeShared    = "shared"
+	clientModePerWorker = "per-worker"
+)
+
+// newWorkerClients creates perWorker clients, each with its own transport and thus connection
+// pool, for every worker; workers are assigned round-robin to the endpoints. Every client looks
+// up the bucket region right away, so that the lookup does not end up in a timed trial.
+func newWorkerClients(endpoints []string, workers, perWorker int, opts clientOptions, bucketName string, injector *faultInjector) ([][]ObjectStore, error) {
+	workerClients := make([][]ObjectStore, workers)
+	for w := range workerClients {
+		endpoint := endpoints[w%len(endpoints)]
+		for c := 0; c < perWorker; c++ {
+			client, err := newObjectStore(endpoint, opts)
+			if err != nil {
+				return nil, fmt.Errorf(`worker %d: %w`, w+1, err)
+			}
+			if _, err := client.BucketExists(context.Background(), bucketName); err != nil {
+				return nil, fmt.Errorf(`worker %d: %w`, w+1, err)
+			}
+			workerClients[w] = append(workerClients[w], injector.wrap(client))
+		}
+	}
+	return workerClients, nil
+}
@@ -0,0 +1,129 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dateGranularity is the precision of the HTTP Date header: the server clock is known to within
+// half of it around the middle of the second it names.
+const dateGranularity = time.Second
+
+// ClockSkew compares the Date headers of the first and the last response of every phase to the
+// local clock. A skew is how far the server clock is ahead, give or take Uncertainty; beyond the
+// threshold the events of several hosts no longer line up.
+type ClockSkew struct {
+	Threshold   time.Duration    `json:"threshold"`
+	Uncertainty time.Duration    `json:"uncertainty"`
+	Phases      []PhaseClockSkew `json:"phases"`
+	Exceeded    bool             `json:"exceeded,omitempty"`
+}
+
+type PhaseClockSkew struct {
+	Phase string        `json:"phase"`
+	First time.Duration `json:"first"`
+	Last  time.Duration `json:"last"`
+}
+
+func (c ClockSkew) String() string {
+	phases := make([]string, 0, len(c.Phases))
+	for _, p := range c.Phases {
+		phases = append(phases, fmt.Sprintf("%s first=%s last=%s", strings.ToLower(p.Phase), formatSkew(p.First), formatSkew(p.Last)))
+	}
+	s := fmt.Sprintf(" Clock skew  : %s (server Date vs the local clock, ±%s)\n", strings.Join(phases, ", "), formatDuration(c.Uncertainty))
+	if c.Exceeded {
+		s += fmt.Sprintf("  WARNING: the server clock is off by more than %s, the events of several hosts cannot be correlated by time\n", formatDuration(c.Threshold))
+	}
+	return s
+}
+
+func formatSkew(skew time.Duration) string {
+	if skew < 0 {
+		return "-" + formatDuration(-skew)
+	}
+	return "+" + formatDuration(skew)
+}
+
+// clockSkewTracker remembers the first and the last dated response since begin across all the
+// clients. A nil clockSkewTracker tracks nothing.
+type clockSkewTracker struct {
+	mu          sync.Mutex
+	first, last *time.Duration
+}
+
+func (c *clockSkewTracker) transport(base http.RoundTripper) http.RoundTripper {
+	if c == nil {
+		return base
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sent := time.Now()
+		resp, err := base.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+		received := time.Now()
+		if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+			// The server dated the response somewhere between sending and receiving, within the
+			// second the header names.
+			local := sent.Add(received.Sub(sent) / 2)
+			c.observe(date.Add(dateGranularity / 2).Sub(local))
+		}
+		return resp, err
+	})
+}
+
+func (c *clockSkewTracker) observe(skew time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.first == nil {
+		c.first = &skew
+	}
+	c.last = &skew
+}
+
+// begin starts a new phase.
+func (c *clockSkewTracker) begin() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.first, c.last = nil, nil
+}
+
+// end returns the skews of the phase since begin, false when none of its responses was dated.
+func (c *clockSkewTracker) end(phase string) (PhaseClockSkew, bool) {
+	if c == nil {
+		return PhaseClockSkew{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.first == nil {
+		return PhaseClockSkew{}, false
+	}
+	return PhaseClockSkew{Phase: phase, First: *c.first, Last: *c.last}, true
+}
+
+// newClockSkew checks the skews against threshold, counting the doubt of the Date granularity in
+// favour of the clocks.
+func newClockSkew(phases []PhaseClockSkew, threshold time.Duration) *ClockSkew {
+	if len(phases) == 0 {
+		return nil
+	}
+	c := &ClockSkew{Threshold: threshold, Uncertainty: dateGranularity / 2, Phases: phases}
+	for _, p := range phases {
+		for _, skew := range []time.Duration{p.First, p.Last} {
+			if skew < 0 {
+				skew = -skew
+			}
+			if skew-c.Uncertainty > threshold {
+				c.Exceeded = true
+			}
+		}
+	}
+	return c
+}
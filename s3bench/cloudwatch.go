@@ -0,0 +1,70 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// publishToCloudWatch sends the summary figures of a report as custom metrics. Credentials
+// come from the default AWS chain, independently of the S3 credentials of the benchmark.
+func publishToCloudWatch(namespace, region, endpoint, bucketName, variant string, report Report) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var options []func(*awsconfig.LoadOptions) error
+	if region != "" {
+		options = append(options, awsconfig.WithRegion(region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return err
+	}
+
+	dimensions := []types.Dimension{
+		{Name: aws.String("Endpoint"), Value: aws.String(endpoint)},
+		{Name: aws.String("Bucket"), Value: aws.String(bucketName)},
+	}
+	if report.Label != "" {
+		dimensions = append(dimensions, types.Dimension{Name: aws.String("Label"), Value: aws.String(report.Label)})
+	}
+	if variant != "" {
+		dimensions = append(dimensions, types.Dimension{Name: aws.String("Variant"), Value: aws.String(variant)})
+	}
+
+	now := time.Now()
+	datum := func(name string, value float64, unit types.StandardUnit) types.MetricDatum {
+		return types.MetricDatum{
+			MetricName: aws.String(name),
+			Value:      aws.Float64(value),
+			Unit:       unit,
+			Timestamp:  aws.Time(now),
+			Dimensions: dimensions,
+		}
+	}
+
+	var data []types.MetricDatum
+	for _, phase := range []struct {
+		name  string
+		stats PhaseStats
+	}{{"Upload", report.Upload}, {"Download", report.Download}} {
+		data = append(data,
+			datum(phase.name+"AvgTime", phase.stats.AvgTime.Seconds(), types.StandardUnitSeconds),
+			datum(phase.name+"P90Time", phase.stats.P90Time.Seconds(), types.StandardUnitSeconds),
+			datum(phase.name+"AvgSpeed", phase.stats.AvgSpeed*1024*1024, types.StandardUnitBytesSecond),
+			datum(phase.name+"P90Speed", phase.stats.P90Speed*1024*1024, types.StandardUnitBytesSecond),
+			datum(phase.name+"Throughput", phase.stats.Throughput*1024*1024, types.StandardUnitBytesSecond),
+		)
+	}
+
+	_, err = cloudwatch.NewFromConfig(cfg).PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+		Namespace:  aws.String(namespace),
+		MetricData: data,
+	})
+	return err
+}
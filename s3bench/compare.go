@@ -0,0 +1,113 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Comparison holds a plain and an SSE run of the same workload along with relative
+// differences (in percent, positive means the encrypted run has a higher value).
+type Comparison struct {
+	Plain     Report `json:"plain"`
+	Encrypted Report `json:"encrypted"`
+	Deltas    struct {
+		Upload   PhaseDeltas `json:"upload"`
+		Download PhaseDeltas `json:"download"`
+	} `json:"deltas"`
+}
+
+type PhaseDeltas struct {
+	AvgTime  float64 `json:"avg_time_pct"`
+	AvgSpeed float64 `json:"avg_speed_pct"`
+	P90Time  float64 `json:"p90_time_pct"`
+	P90Speed float64 `json:"p90_speed_pct"`
+	// NotMeaningful names the deltas, e.g. "p90_time", whose "-bootstrap" confidence intervals
+	// overlap: the runs do not tell a difference there.
+	NotMeaningful []string `json:"not_meaningful,omitempty"`
+}
+
+// meaningful tells whether the delta of the JSON name is not marked as NotMeaningful.
+func (d PhaseDeltas) meaningful(name string) bool {
+	for _, n := range d.NotMeaningful {
+		if n == name {
+			return false
+		}
+	}
+	return true
+}
+
+func compareReports(plain, encrypted Report) Comparison {
+	c := Comparison{Plain: plain, Encrypted: encrypted}
+	c.Deltas.Upload = comparePhases(plain.Upload, encrypted.Upload)
+	c.Deltas.Download = comparePhases(plain.Download, encrypted.Download)
+	return c
+}
+
+func comparePhases(plain, encrypted PhaseStats) PhaseDeltas {
+	d := PhaseDeltas{
+		AvgTime:  percentDelta(float64(plain.AvgTime), float64(encrypted.AvgTime)),
+		AvgSpeed: percentDelta(plain.AvgSpeed, encrypted.AvgSpeed),
+		P90Time:  percentDelta(float64(plain.P90Time), float64(encrypted.P90Time)),
+		P90Speed: percentDelta(plain.P90Speed, encrypted.P90Speed),
+	}
+	if p, e := plain.Bootstrap, encrypted.Bootstrap; p != nil && e != nil {
+		for _, overlap := range []struct {
+			name     string
+			overlaps bool
+		}{
+			{"avg_time", p.AvgTime.overlaps(e.AvgTime)},
+			{"avg_speed", p.AvgSpeed.overlaps(e.AvgSpeed)},
+			{"p90_time", p.P90Time.overlaps(e.P90Time)},
+			{"p90_speed", p.P90Speed.overlaps(e.P90Speed)},
+		} {
+			if overlap.overlaps {
+				d.NotMeaningful = append(d.NotMeaningful, overlap.name)
+			}
+		}
+	}
+	return d
+}
+
+func percentDelta(base, value float64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return (value - base) / base * 100
+}
+
+func (c Comparison) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, " %-20s %14s %14s %9s\n", "", "plain", "sse", "delta")
+	for _, phase := range []struct {
+		name             string
+		plain, encrypted PhaseStats
+		deltas           PhaseDeltas
+	}{
+		{"Upload", c.Plain.Upload, c.Encrypted.Upload, c.Deltas.Upload},
+		{"Download", c.Plain.Download, c.Encrypted.Download, c.Deltas.Download},
+	} {
+		d := phase.deltas
+		writeTimeRow(&sb, phase.name+" avg time", phase.plain.AvgTime, phase.encrypted.AvgTime, d.AvgTime, d.meaningful("avg_time"))
+		writeTimeRow(&sb, phase.name+" P90 time", phase.plain.P90Time, phase.encrypted.P90Time, d.P90Time, d.meaningful("p90_time"))
+		writeSpeedRow(&sb, phase.name+" avg speed", phase.plain.AvgSpeed, phase.encrypted.AvgSpeed, d.AvgSpeed, d.meaningful("avg_speed"))
+		writeSpeedRow(&sb, phase.name+" P90 speed", phase.plain.P90Speed, phase.encrypted.P90Speed, d.P90Speed, d.meaningful("p90_speed"))
+	}
+	return sb.String()
+}
+
+func writeTimeRow(sb *strings.Builder, name string, plain, encrypted time.Duration, delta float64, meaningful bool) {
+	fmt.Fprintf(sb, " %-20s %14v %14v %+8.1f%%%s\n", name, formatDuration(plain), formatDuration(encrypted), delta, meaningfulMark(meaningful))
+}
+
+func writeSpeedRow(sb *strings.Builder, name string, plain, encrypted, delta float64, meaningful bool) {
+	fmt.Fprintf(sb, " %-20s %9s MB/s %9s MB/s %+8.1f%%%s\n", name, formatSpeed(plain), formatSpeed(encrypted), delta, meaningfulMark(meaningful))
+}
+
+func meaningfulMark(meaningful bool) string {
+	if meaningful {
+		return ""
+	}
+	return "  (difference not statistically meaningful)"
+}
@@ -0,0 +1,158 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Where the effective value of an option came from. The sources override each other in this
+// order, the first one winning: the command line, the environment (the credentials only), the
+// "-profile", the "-preset" and the default of the flag.
+const (
+	sourceFlag    = "flag"
+	sourceProfile = "profile"
+	sourcePreset  = "preset"
+	sourceEnv     = "env"
+	sourceDefault = "default"
+)
+
+// envFlags are the options the environment provides when the command line does not set them,
+// ahead of the "-profile".
+var envFlags = map[string]string{
+	"accessKey":         accessKeyEnvVarName,
+	"secretKey":         secretKeyEnvVarName,
+	"target-access-key": targetAccessKeyEnvVarName,
+	"target-secret-key": targetSecretKeyEnvVarName,
+}
+
+// unhashedFlags do not change what is measured: they name the outputs, where reports go and how
+// they look, or the sources of other options, whose values are hashed themselves. The
+// credentials are left out too, so that colleagues running with their own keys get the same hash.
+var unhashedFlags = map[string]bool{
+	"accessKey": true, "secretKey": true, "target-access-key": true, "target-secret-key": true,
+	"client-encrypt-password": true, "client-encrypt-key-file": true,
+	"profile": true, "preset": true, "list-profiles": true, "list-presets": true, "version": true,
+	"json": true, "summary-line": true, "format": true, "report-template": true, "report-fields": true,
+	"pretty": true, "duration-precision": true, "verbose": true, "label": true, "debug-http": true, "debug-http-output": true,
+	"events": true, "output-dir": true, "junit-output": true, "export-keys": true, "state-file": true,
+	"cpuprofile": true, "memprofile": true, "pprof-listen": true, "otel-endpoint": true, "otel-insecure": true,
+	"statsd-addr": true, "statsd-prefix": true, "statsd-format": true,
+	"cloudwatch-namespace": true, "cloudwatch-region": true,
+	"webhook-url": true, "webhook-format": true, "webhook-on": true,
+}
+
+// applyEnv sets the envFlags the command line did not set from the environment.
+func applyEnv(flags *flag.FlagSet) error {
+	explicit := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	for name, variable := range envFlags {
+		value := os.Getenv(variable)
+		if value == "" || explicit[name] {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf(`$%s: %v`, variable, err)
+		}
+	}
+	return nil
+}
+
+// ConfigRecord is the header of the events output.
+type ConfigRecord struct {
+	Record string           `json:"record"`
+	Config *EffectiveConfig `json:"config"`
+}
+
+// configSources records the source of every option set by one, in the order they are applied.
+type configSources map[string]string
+
+// mark attributes the flags set since the last mark to source.
+func (s configSources) mark(flags *flag.FlagSet, source string) {
+	flags.Visit(func(f *flag.Flag) {
+		if _, ok := s[f.Name]; !ok {
+			s[f.Name] = source
+		}
+	})
+}
+
+// ConfigOption is the effective value of an option, secrets fingerprinted, and its source.
+type ConfigOption struct {
+	Value  string `json:"value" yaml:"value"`
+	Source string `json:"source" yaml:"source"`
+}
+
+// EffectiveConfig is every option of the run once the sources are merged. Hash is the SHA-256
+// of the "name=value" lines of the options, sorted by name, but for the unhashedFlags: two
+// reports of the same hash were run with the same settings.
+type EffectiveConfig struct {
+	Version string                  `json:"version" yaml:"version"`
+	Hash    string                  `json:"hash" yaml:"hash"`
+	Options map[string]ConfigOption `json:"options" yaml:"options"`
+}
+
+// resolveConfig captures the effective configuration; to be called once the command line, the
+// environment, the "-profile" and the "-preset" are applied.
+func resolveConfig(flags *flag.FlagSet, sources configSources) *EffectiveConfig {
+	config := &EffectiveConfig{Version: version, Options: map[string]ConfigOption{}}
+	var settings []string
+	flags.VisitAll(func(f *flag.Flag) {
+		option := ConfigOption{Value: f.Value.String(), Source: sources[f.Name]}
+		if option.Source == "" {
+			option.Source = sourceDefault
+		}
+		if redactedFlags[f.Name] {
+			option.Value = fingerprint(option.Value)
+		}
+		config.Options[f.Name] = option
+		if !unhashedFlags[f.Name] {
+			settings = append(settings, f.Name+"="+option.Value)
+		}
+	})
+	sort.Strings(settings)
+	sum := sha256.New()
+	for _, setting := range settings {
+		fmt.Fprintln(sum, setting)
+	}
+	config.Hash = hex.EncodeToString(sum.Sum(nil))
+	return config
+}
+
+// fingerprint stands in for a secret: its first 4 characters and its length, only the length
+// for secrets of less than 8 characters.
+func fingerprint(secret string) string {
+	n := utf8.RuneCountInString(secret)
+	switch {
+	case n == 0:
+		return ""
+	case n < 8:
+		return fmt.Sprintf("…(%d chars)", n)
+	}
+	return fmt.Sprintf("%s…(%d chars)", string([]rune(secret)[:4]), n)
+}
+
+// String lists the options which are not at their defaults along with the hash.
+func (c *EffectiveConfig) String() string {
+	if c == nil {
+		return ""
+	}
+	var names []string
+	for name, option := range c.Options {
+		if option.Source != sourceDefault {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, " Config      : sha256=%s (%d of %d options set, the rest at their defaults)\n", c.Hash, len(names), len(c.Options))
+	for _, name := range names {
+		fmt.Fprintf(&sb, "  -%s=%s (%s)\n", name, c.Options[name].Value, c.Options[name].Source)
+	}
+	return sb.String()
+}
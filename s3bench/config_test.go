@@ -0,0 +1,100 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigPrecedence(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".s3bench"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, profilesPath), []byte(`profiles:
+  lab:
+    access_key: profile-access-key
+    secret_key: profile-secret-key
+    region: eu-lab-1
+    bucket: `+testBucket+`
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(accessKeyEnvVarName, "env-access-key")
+	t.Setenv(secretKeyEnvVarName, "env-secret-key")
+
+	results, err := Run(context.Background(), Config{Args: []string{
+		"-backend", "fs", "-fs-root", t.TempDir(), "-create-bucket", "-profile", "lab", "-preset", "backup",
+		"-accessKey", "flag-access-key", "-trials", "2", "-fileSize", "1",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := results.Report.Metadata.Config.Options
+	for _, tc := range []struct {
+		flag, value, source string
+	}{
+		// The command line over the environment, the environment over the profile, the profile over
+		// the defaults.
+		{"accessKey", fingerprint("flag-access-key"), sourceFlag},
+		{"trials", "2", sourceFlag},
+		{"secretKey", fingerprint("env-secret-key"), sourceEnv},
+		{"region", "eu-lab-1", sourceProfile},
+		{"bucketName", testBucket, sourceProfile},
+		{"concurrency", "2", sourcePreset},
+		{"signature", signatureV4, sourceDefault},
+	} {
+		if option := options[tc.flag]; option.Value != tc.value || option.Source != tc.source {
+			t.Errorf("-%s=%s from %s, want %s from %s", tc.flag, option.Value, option.Source, tc.value, tc.source)
+		}
+	}
+}
+
+func TestConfigHash(t *testing.T) {
+	resolve := func(args ...string) *EffectiveConfig {
+		t.Helper()
+		flags := flag.NewFlagSet("s3bench", flag.ContinueOnError)
+		flags.Int("trials", 10, "")
+		flags.String("region", "", "")
+		flags.String("accessKey", "", "")
+		flags.String("label", "", "")
+		if err := flags.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		sources := configSources{}
+		sources.mark(flags, sourceFlag)
+		return resolveConfig(flags, sources)
+	}
+
+	// The SHA-256 of the sorted "name=value" lines of the hashed options, the same across versions.
+	sum := sha256.Sum256([]byte("region=eu-lab-1\ntrials=10\n"))
+	base := resolve("-region", "eu-lab-1")
+	if base.Hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("hash %s, want %s", base.Hash, hex.EncodeToString(sum[:]))
+	}
+	for _, tc := range []struct {
+		name string
+		args []string
+		same bool
+	}{
+		// Neither the credentials, the outputs nor the source of a value change what is measured.
+		{"other credentials", []string{"-region", "eu-lab-1", "-accessKey", "someone-else"}, true},
+		{"a label", []string{"-region", "eu-lab-1", "-label", "nightly"}, true},
+		{"the default given explicitly", []string{"-region", "eu-lab-1", "-trials", "10"}, true},
+		{"other trials", []string{"-region", "eu-lab-1", "-trials", "11"}, false},
+		{"other region", []string{"-region", "eu-lab-2"}, false},
+	} {
+		if config := resolve(tc.args...); (config.Hash == base.Hash) != tc.same {
+			t.Errorf("%s: hash %s against %s, want the same one: %t", tc.name, config.Hash, base.Hash, tc.same)
+		}
+	}
+	if config := resolve("-accessKey", "someone-else"); config.Options["accessKey"].Value != fingerprint("someone-else") {
+		t.Errorf("the access key recorded as %q", config.Options["accessKey"].Value)
+	}
+}
@@ -0,0 +1,142 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnectionStats counts how many requests of a phase had to open a fresh connection.
+type ConnectionStats struct {
+	Fresh  int `json:"fresh"`
+	Reused int `json:"reused"`
+}
+
+// connTracker tells fresh from reused connections through httptrace and remembers every
+// connection it has seen to count the distinct ones.
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	// first is the remote address of the first connection.
+	first net.Addr
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: map[net.Conn]struct{}{}}
+}
+
+// trialConns counts the connections the requests of a single trial got. It also measures how
+// long the requests waited for a "100 Continue" after their headers and when the latest one
+// got the first byte of its response, and sums up the request times of "-attribution".
+type trialConns struct {
+	fresh, reused int32
+	wroteHeaders  atomic.Value
+	continueWait  int64
+	firstByte     atomic.Value
+
+	getConn, gotConn, wroteRequest   atomic.Value
+	connectTime, writeTime, waitTime int64
+	// handedOver is when the first request of the trial reached the transport.
+	handedOver atomic.Value
+}
+
+func (t *connTracker) trace(ctx context.Context) (context.Context, *trialConns) {
+	conns := &trialConns{}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) {
+			now := time.Now()
+			conns.getConn.Store(now)
+			conns.handedOver.CompareAndSwap(nil, now)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			now := time.Now()
+			if asked, ok := conns.getConn.Load().(time.Time); ok {
+				atomic.AddInt64(&conns.connectTime, int64(now.Sub(asked)))
+			}
+			conns.gotConn.Store(now)
+			if info.Reused {
+				atomic.AddInt32(&conns.reused, 1)
+			} else {
+				atomic.AddInt32(&conns.fresh, 1)
+			}
+			t.mu.Lock()
+			t.conns[info.Conn] = struct{}{}
+			if t.first == nil {
+				t.first = info.Conn.RemoteAddr()
+			}
+			t.mu.Unlock()
+		},
+		WroteHeaders: func() {
+			conns.wroteHeaders.Store(time.Now())
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			now := time.Now()
+			if got, ok := conns.gotConn.Load().(time.Time); ok {
+				atomic.AddInt64(&conns.writeTime, int64(now.Sub(got)))
+			}
+			conns.wroteRequest.Store(now)
+		},
+		GotFirstResponseByte: func() {
+			now := time.Now()
+			if wrote, ok := conns.wroteRequest.Load().(time.Time); ok {
+				atomic.AddInt64(&conns.waitTime, int64(now.Sub(wrote)))
+			}
+			conns.firstByte.Store(now)
+		},
+		Got100Continue: func() {
+			if wrote, ok := conns.wroteHeaders.Load().(time.Time); ok {
+				atomic.AddInt64(&conns.continueWait, int64(time.Since(wrote)))
+			}
+		},
+	}), conns
+}
+
+func (c *trialConns) counts() (fresh, reused int) {
+	return int(atomic.LoadInt32(&c.fresh)), int(atomic.LoadInt32(&c.reused))
+}
+
+func (c *trialConns) waitedForContinue() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.continueWait))
+}
+
+// handedOverAt is when the first request reached the transport, zero without a request.
+func (c *trialConns) handedOverAt() time.Time {
+	at, _ := c.handedOver.Load().(time.Time)
+	return at
+}
+
+// timeToFirstByte is the time from start until the latest request got its first response byte,
+// 0 without a response.
+func (c *trialConns) timeToFirstByte(start time.Time) time.Duration {
+	if at, ok := c.firstByte.Load().(time.Time); ok {
+		return at.Sub(start)
+	}
+	return 0
+}
+
+// firstRemoteIP is the IP the first connection went to, "" without any connection.
+func (t *connTracker) firstRemoteIP() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if addr, ok := t.first.(*net.TCPAddr); ok {
+		return addr.IP.String()
+	}
+	return ""
+}
+
+func (t *connTracker) opened() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.conns)
+}
+
+func freshMark(verbose bool, fresh int) string {
+	if !verbose || fresh == 0 {
+		return ""
+	}
+	return " (fresh connection)"
+}
@@ -0,0 +1,220 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// listObjectsMaxKeys is how many keys a listing page returns at most.
+const listObjectsMaxKeys = 1000
+
+// RequestCounts are the S3 requests of a run by billing class and the payload bytes they moved.
+// POSTs (multipart uploads) count as PUTs, listings are the GETs of a bucket.
+type RequestCounts struct {
+	Put             int64 `json:"put"`
+	Get             int64 `json:"get"`
+	Head            int64 `json:"head"`
+	Delete          int64 `json:"delete"`
+	List            int64 `json:"list"`
+	UploadedBytes   int64 `json:"uploaded_bytes"`
+	DownloadedBytes int64 `json:"downloaded_bytes"`
+}
+
+func (c RequestCounts) since(earlier RequestCounts) RequestCounts {
+	return RequestCounts{
+		Put: c.Put - earlier.Put, Get: c.Get - earlier.Get, Head: c.Head - earlier.Head,
+		Delete: c.Delete - earlier.Delete, List: c.List - earlier.List,
+		UploadedBytes: c.UploadedBytes - earlier.UploadedBytes, DownloadedBytes: c.DownloadedBytes - earlier.DownloadedBytes,
+	}
+}
+
+// Prices are the "-price-*" flags, 0 when not given; in whatever currency they are given in.
+// PUT pricing applies to PUT and LIST requests, GET pricing to GET and HEAD ones, DELETEs are
+// free.
+type Prices struct {
+	EgressPerGB    float64 `json:"egress_per_gb,omitempty"`
+	PerThousandPut float64 `json:"per_1k_put,omitempty"`
+	PerThousandGet float64 `json:"per_1k_get,omitempty"`
+}
+
+func (p Prices) set() bool {
+	return p.EgressPerGB > 0 || p.PerThousandPut > 0 || p.PerThousandGet > 0
+}
+
+// CostReport is what a run cost in requests and transfer and, with prices, their estimated cost.
+// Planned ones are estimated from the options by "-dry-run", ramp stages and retries excluded.
+type CostReport struct {
+	Requests RequestCounts `json:"requests"`
+	Planned  bool          `json:"planned,omitempty"`
+	Prices   *Prices       `json:"prices,omitempty"`
+	Cost     *Cost         `json:"cost,omitempty"`
+}
+
+type Cost struct {
+	Put    float64 `json:"put"`
+	Get    float64 `json:"get"`
+	Egress float64 `json:"egress"`
+	Total  float64 `json:"total"`
+}
+
+func newCostReport(requests RequestCounts, prices Prices) *CostReport {
+	c := &CostReport{Requests: requests}
+	if !prices.set() {
+		return c
+	}
+	c.Prices = &prices
+	c.Cost = &Cost{
+		Put:    float64(requests.Put+requests.List) / 1000 * prices.PerThousandPut,
+		Get:    float64(requests.Get+requests.Head) / 1000 * prices.PerThousandGet,
+		Egress: float64(requests.DownloadedBytes) / (1 << 30) * prices.EgressPerGB,
+	}
+	c.Cost.Total = c.Cost.Put + c.Cost.Get + c.Cost.Egress
+	return c
+}
+
+func (c CostReport) String() string {
+	r := c.Requests
+	planned := ""
+	if c.Planned {
+		planned = " (planned)"
+	}
+	s := fmt.Sprintf(" Requests    : put=%s get=%s head=%s delete=%s list=%s uploaded=%s downloaded=%s%s\n",
+		formatByteCount(r.Put), formatByteCount(r.Get), formatByteCount(r.Head), formatByteCount(r.Delete), formatByteCount(r.List),
+		formatBytes(r.UploadedBytes), formatBytes(r.DownloadedBytes), planned)
+	if c.Cost != nil {
+		s += fmt.Sprintf(" Cost        : ~%.4f (put %.4f, get %.4f, egress %.4f), estimated from the given prices\n", c.Cost.Total, c.Cost.Put, c.Cost.Get, c.Cost.Egress)
+	}
+	return s
+}
+
+// requestCounter counts the requests of all the clients as they go out, which includes the parts
+// of multipart uploads and the retries of minio-go. A nil requestCounter counts nothing.
+type requestCounter struct {
+	put, get, head, delete, list atomic.Int64
+	uploaded, downloaded         atomic.Int64
+}
+
+func (c *requestCounter) transport(base http.RoundTripper) http.RoundTripper {
+	if c == nil {
+		return base
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		download := false
+		switch req.Method {
+		case http.MethodPut, http.MethodPost:
+			if req.URL.Query().Has("delete") {
+				c.delete.Add(1)
+				break
+			}
+			c.put.Add(1)
+			if req.ContentLength > 0 {
+				c.uploaded.Add(req.ContentLength)
+			}
+		case http.MethodHead:
+			c.head.Add(1)
+		case http.MethodDelete:
+			c.delete.Add(1)
+		case http.MethodGet:
+			if isListing(req) {
+				c.list.Add(1)
+			} else {
+				c.get.Add(1)
+				download = true
+			}
+		}
+		resp, err := base.RoundTrip(req)
+		if err == nil && download {
+			resp.Body = &countingBody{ReadCloser: resp.Body, count: &c.downloaded}
+		}
+		return resp, err
+	})
+}
+
+func isListing(req *http.Request) bool {
+	query := req.URL.Query()
+	for _, param := range []string{"list-type", "uploads", "versions", "prefix", "delimiter", "marker"} {
+		if query.Has(param) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *requestCounter) snapshot() RequestCounts {
+	if c == nil {
+		return RequestCounts{}
+	}
+	return RequestCounts{
+		Put: c.put.Load(), Get: c.get.Load(), Head: c.head.Load(), Delete: c.delete.Load(), List: c.list.Load(),
+		UploadedBytes: c.uploaded.Load(), DownloadedBytes: c.downloaded.Load(),
+	}
+}
+
+// countingBody adds the bytes read from a response body to count.
+type countingBody struct {
+	io.ReadCloser
+	count *atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.count.Add(int64(n))
+	return n, err
+}
+
+// workloadPlan is what the runs are going to do, as far as the requests go.
+type workloadPlan struct {
+	runs                                          int
+	trials, overwriteTrials, missTrials, verified int
+	sizes                                         sizeDistribution
+	statBeforeGet, keepObjects, verifyListing     bool
+	// cacheProbe downloads every object twice, transformed once more; the transformed downloads
+	// are estimated at the size of the objects.
+	cacheProbe, transformed bool
+}
+
+// requests estimates the requests of the plan: every upload is a single PUT, every object is
+// stat'ed before the cleanup removes it.
+func (p workloadPlan) requests() RequestCounts {
+	var (
+		c     RequestCounts
+		bytes int64
+	)
+	for trial := 1; trial <= p.trials; trial++ {
+		bytes += p.sizes.size(trial)
+	}
+	trials := int64(p.trials)
+	c.Put = trials * int64(1+p.overwriteTrials)
+	c.UploadedBytes = bytes * int64(1+p.overwriteTrials)
+	passes := int64(1)
+	if p.cacheProbe {
+		passes++
+	}
+	if p.transformed {
+		passes++
+	}
+	c.Get = trials*passes + int64(p.verified)
+	c.DownloadedBytes = bytes * passes
+	if p.trials > 0 {
+		c.DownloadedBytes += bytes / trials * int64(p.verified)
+	}
+	c.Head = int64(p.missTrials)
+	if p.statBeforeGet {
+		c.Head += trials * passes
+	}
+	if !p.keepObjects {
+		c.Head += trials
+		c.Delete = trials
+	}
+	if p.verifyListing {
+		c.List = (trials + listObjectsMaxKeys - 1) / listObjectsMaxKeys
+	}
+	runs := int64(p.runs)
+	return RequestCounts{
+		Put: c.Put * runs, Get: c.Get * runs, Head: c.Head * runs, Delete: c.Delete * runs, List: c.List * runs,
+		UploadedBytes: c.UploadedBytes * runs, DownloadedBytes: c.DownloadedBytes * runs,
+	}
+}
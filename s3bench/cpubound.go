@@ -0,0 +1,79 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"time"
+)
+
+// The heuristics telling a client-CPU-bound run apart, conservative on purpose: a phase is CPU
+// bound when more than half of its resource samples saw the process use at least 90% of all the
+// cores, and only with at least minCPUSamples samples; the uploads are generation bound when
+// generating their payloads took at least a quarter of the time the uploads took.
+const (
+	cpuSaturatedShare    = 0.9
+	cpuBoundSampleShare  = 0.5
+	minCPUSamples        = 3
+	generationBoundShare = 0.25
+)
+
+// PhaseCPU is how much of a phase saw the client CPU saturated, by the resource samples taken
+// within its plateau.
+type PhaseCPU struct {
+	Phase     string `json:"phase"`
+	Samples   int    `json:"samples"`
+	Saturated int    `json:"saturated"`
+	CPUBound  bool   `json:"cpu_bound"`
+}
+
+// cpuSaturation counts the samples taken within from and to, and those of them which saw the
+// process use at least cpuSaturatedShare of the cores.
+func cpuSaturation(phase string, samples []resourceSample, from, to time.Time, cores int) PhaseCPU {
+	p := PhaseCPU{Phase: phase}
+	saturated := cpuSaturatedShare * float64(cores) * 100
+	for _, s := range samples {
+		if s.At.Before(from) || s.At.After(to) {
+			continue
+		}
+		p.Samples++
+		if s.CPUPercent >= saturated {
+			p.Saturated++
+		}
+	}
+	p.CPUBound = p.Samples >= minCPUSamples && float64(p.Saturated) > cpuBoundSampleShare*float64(p.Samples)
+	return p
+}
+
+// checkCPUBound applies the heuristics to the upload and the download phase; generated is the
+// time the upload plateau spent on generating the payloads, outside of the upload times.
+func (c *ClientResources) checkCPUBound(samples []resourceSample, upload, download PhaseStats, generated time.Duration) {
+	for _, phase := range []struct {
+		name  string
+		stats PhaseStats
+	}{{"upload", upload}, {"download", download}} {
+		if phase.stats.Count == 0 {
+			continue
+		}
+		p := cpuSaturation(phase.name, samples, phase.stats.Start, phase.stats.Start.Add(phase.stats.Elapsed), c.Cores)
+		c.Phases = append(c.Phases, p)
+		c.CPUBound = c.CPUBound || p.CPUBound
+	}
+	if uploaded := upload.AvgTime * time.Duration(upload.Count); uploaded > 0 {
+		c.GenerationShare = float64(generated) / float64(uploaded) * 100
+		c.GenerationBound = c.GenerationShare >= generationBoundShare*100
+	}
+}
+
+// cpuBoundWarnings explain the verdicts of the heuristics along with their rules.
+func (c ClientResources) cpuBoundWarnings() string {
+	var s string
+	for _, p := range c.Phases {
+		if p.CPUBound {
+			s += fmt.Sprintf("  WARNING: results likely limited by client CPU, it was saturated in %d of %d samples of the %s phase (rule: over half of the samples at 90%% of all cores or more)\n", p.Saturated, p.Samples, p.Phase)
+		}
+	}
+	if c.GenerationBound {
+		s += fmt.Sprintf("  WARNING: results likely limited by client CPU, generating the payloads took %.0f%% of the upload time (rule: 25%% or more)\n", c.GenerationShare)
+	}
+	return s
+}
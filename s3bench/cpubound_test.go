@@ -0,0 +1,91 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// cpuSamples are resource samples a second apart from start, of the given CPU usage in percent
+// of a core.
+func cpuSamples(start time.Time, percents ...float64) []resourceSample {
+	samples := make([]resourceSample, len(percents))
+	for i, percent := range percents {
+		samples[i] = resourceSample{At: start.Add(time.Duration(i) * time.Second), CPUPercent: percent}
+	}
+	return samples
+}
+
+func TestCPUSaturation(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name     string
+		percents []float64
+		cores    int
+		// from and to are the seconds since start the phase ran within.
+		from, to           int
+		samples, saturated int
+		bound              bool
+	}{
+		{"saturated", []float64{380, 395, 360, 399}, 4, 0, 3, 4, 4, true},
+		// 90% of all the cores is 360% of a core with 4 of them.
+		{"just saturated", []float64{360, 360, 100}, 4, 0, 2, 3, 2, true},
+		{"just below", []float64{359, 359, 359}, 4, 0, 2, 3, 0, false},
+		// Exactly half of them is not the majority.
+		{"half saturated", []float64{400, 400, 100, 100}, 4, 0, 3, 4, 2, false},
+		{"too few samples", []float64{100, 100}, 1, 0, 1, 2, 2, false},
+		// The samples outside of the phase do not count.
+		{"outside of the phase", []float64{100, 100, 100, 10, 10, 10}, 1, 3, 5, 3, 0, false},
+		{"single core", []float64{95, 99, 40, 92}, 1, 0, 3, 4, 3, true},
+	} {
+		p := cpuSaturation("upload", cpuSamples(start, tc.percents...), start.Add(time.Duration(tc.from)*time.Second), start.Add(time.Duration(tc.to)*time.Second), tc.cores)
+		if p.Samples != tc.samples || p.Saturated != tc.saturated || p.CPUBound != tc.bound {
+			t.Errorf("%s: %+v, want %d samples, %d saturated, bound=%t", tc.name, p, tc.samples, tc.saturated, tc.bound)
+		}
+	}
+}
+
+func TestCheckCPUBound(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	samples := append(cpuSamples(start, 200, 195, 190), cpuSamples(start.Add(10*time.Second), 40, 50, 60)...)
+	upload := PhaseStats{Count: 10, AvgTime: 100 * time.Millisecond, Start: start, Elapsed: 2 * time.Second}
+	download := PhaseStats{Count: 10, AvgTime: 50 * time.Millisecond, Start: start.Add(10 * time.Second), Elapsed: 2 * time.Second}
+	for _, tc := range []struct {
+		name      string
+		generated time.Duration
+		// generation is the share of the upload time spent on generating the payloads.
+		generation float64
+		bound      bool
+		warnings   []string
+	}{
+		{"uploads CPU bound", 100 * time.Millisecond, 10, false, []string{"saturated in 3 of 3 samples of the upload phase"}},
+		// A quarter of the 1s the uploads took.
+		{"generation bound", 250 * time.Millisecond, 25, true, []string{"upload phase", "generating the payloads took 25% of the upload time"}},
+	} {
+		c := ClientResources{Cores: 2}
+		c.checkCPUBound(samples, upload, download, tc.generated)
+		if len(c.Phases) != 2 || !c.Phases[0].CPUBound || c.Phases[1].CPUBound || !c.CPUBound {
+			t.Errorf("%s: phases %+v, want only the upload phase CPU bound", tc.name, c.Phases)
+		}
+		if c.GenerationShare != tc.generation || c.GenerationBound != tc.bound {
+			t.Errorf("%s: generation share %v%% bound=%t, want %v%% and %t", tc.name, c.GenerationShare, c.GenerationBound, tc.generation, tc.bound)
+		}
+		warnings := c.cpuBoundWarnings()
+		for _, warning := range tc.warnings {
+			if !strings.Contains(warnings, warning) {
+				t.Errorf("%s: the warnings do not say %q:\n%s", tc.name, warning, warnings)
+			}
+		}
+		if n := strings.Count(warnings, "WARNING"); n != len(tc.warnings) {
+			t.Errorf("%s: %d warnings, want %d:\n%s", tc.name, n, len(tc.warnings), warnings)
+		}
+	}
+
+	// Neither a phase without trials nor uploads without time are judged.
+	c := ClientResources{Cores: 2}
+	c.checkCPUBound(samples, PhaseStats{}, download, time.Second)
+	if len(c.Phases) != 1 || c.Phases[0].Phase != "download" || c.GenerationShare != 0 || c.cpuBoundWarnings() != "" {
+		t.Errorf("without uploads: %+v", c)
+	}
+}
@@ -0,0 +1,262 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Modes of "-debug-http".
+const (
+	debugHTTPFirst  = "first"
+	debugHTTPErrors = "errors"
+	debugHTTPAll    = "all"
+)
+
+// debugHTTPPrefix is how much of a credential is left in the dumps, enough to tell the
+// signature version and the access key apart but none of the secret.
+const debugHTTPPrefix = 12
+
+// debugHTTPCutHeaders carry credentials which are only dumped as their first debugHTTPPrefix
+// characters, debugHTTPSecretHeaders secrets and keys which are not dumped at all.
+// debugHTTPSecretQuery hold those of presigned URLs.
+var (
+	debugHTTPCutHeaders    = map[string]bool{"Authorization": true, "Proxy-Authorization": true}
+	debugHTTPSecretHeaders = map[string]bool{
+		"X-Amz-Security-Token": true,
+		"Cookie":               true,
+		"Set-Cookie":           true,
+		"X-Amz-Server-Side-Encryption-Customer-Key":             true,
+		"X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key": true,
+	}
+	debugHTTPSecretQuery = []string{"X-Amz-Signature", "X-Amz-Credential", "X-Amz-Security-Token", "Signature", "AWSAccessKeyId"}
+)
+
+func validateDebugHTTP(mode string) error {
+	switch mode {
+	case "", debugHTTPFirst, debugHTTPErrors, debugHTTPAll:
+		return nil
+	}
+	return fmt.Errorf(`unknown mode %q, expected %q, %q or %q`, mode, debugHTTPFirst, debugHTTPErrors, debugHTTPAll)
+}
+
+// httpDumper writes the sanitized headers of requests and responses for "-debug-http": that of
+// the first request of every phase, of the failing ones or of all of them. The exchanges of a
+// trial are buffered and only written once it is over, so that the dumping stays out of its
+// time. A nil httpDumper dumps nothing.
+type httpDumper struct {
+	mode string
+
+	mu sync.Mutex
+	w  io.Writer
+	// dumped are the phases whose first request was dumped.
+	dumped map[string]bool
+}
+
+func newHTTPDumper(mode string, w io.Writer) *httpDumper {
+	if mode == "" {
+		return nil
+	}
+	return &httpDumper{mode: mode, w: w, dumped: map[string]bool{}}
+}
+
+type debugHTTPKey struct{}
+
+// httpExchange is a request with its response, or the error it failed with. It is only
+// written out once the trial is over.
+type httpExchange struct {
+	req     *http.Request
+	resp    *http.Response
+	err     error
+	elapsed time.Duration
+}
+
+// failed tells the requests which did not succeed, but for those the benchmark cancelled itself.
+func (e httpExchange) failed() bool {
+	if e.err != nil {
+		return !errors.Is(e.err, context.Canceled)
+	}
+	return e.resp.StatusCode >= http.StatusBadRequest
+}
+
+func (e httpExchange) String() string {
+	var sb strings.Builder
+	dumpRequest(&sb, e.req)
+	if e.err != nil {
+		fmt.Fprintf(&sb, "<<< failed after %s: %v\n\n", formatDuration(e.elapsed), e.err)
+	} else {
+		dumpResponse(&sb, e.resp, e.elapsed)
+	}
+	return sb.String()
+}
+
+// trialDump collects the exchanges of a trial.
+type trialDump struct {
+	dumper *httpDumper
+	phase  string
+	trial  int
+
+	mu        sync.Mutex
+	exchanges []httpExchange
+}
+
+// trial has the exchanges of the requests made with ctx held back until flush.
+func (d *httpDumper) trial(ctx context.Context, phase string, trial int) (context.Context, *trialDump) {
+	if d == nil {
+		return ctx, nil
+	}
+	dump := &trialDump{dumper: d, phase: phase, trial: trial}
+	return context.WithValue(ctx, debugHTTPKey{}, dump), dump
+}
+
+// flush writes the exchanges of the trial the mode asks for, to be deferred by the operation.
+func (t *trialDump) flush() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	exchanges := t.exchanges
+	t.mu.Unlock()
+	d := t.dumper
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch d.mode {
+	case debugHTTPFirst:
+		if len(exchanges) == 0 || d.dumped[t.phase] {
+			return
+		}
+		d.dumped[t.phase] = true
+		exchanges = exchanges[:1]
+	case debugHTTPErrors:
+		failed := exchanges[:0:0]
+		for _, exchange := range exchanges {
+			if exchange.failed() {
+				failed = append(failed, exchange)
+			}
+		}
+		exchanges = failed
+	}
+	for _, exchange := range exchanges {
+		fmt.Fprintf(d.w, "--- %s trial %d\n%s", t.phase, t.trial, exchange)
+	}
+}
+
+func (d *httpDumper) transport(base http.RoundTripper) http.RoundTripper {
+	if d == nil {
+		return base
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := base.RoundTrip(req)
+		exchange := httpExchange{req: req, resp: resp, err: err, elapsed: time.Since(start)}
+
+		// Requests outside of the trials, e.g. of the setup or the cleanup, are not timed; they
+		// belong to no phase to be the first of.
+		if dump, ok := req.Context().Value(debugHTTPKey{}).(*trialDump); ok {
+			dump.mu.Lock()
+			dump.exchanges = append(dump.exchanges, exchange)
+			dump.mu.Unlock()
+		} else if d.mode == debugHTTPAll || (d.mode == debugHTTPErrors && exchange.failed()) {
+			d.mu.Lock()
+			fmt.Fprintf(d.w, "---\n%s", exchange)
+			d.mu.Unlock()
+		}
+		return resp, err
+	})
+}
+
+func dumpRequest(w io.Writer, req *http.Request) {
+	fmt.Fprintf(w, ">>> %s %s %s\n", req.Method, sanitizeURL(req.URL), req.Proto)
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	fmt.Fprintf(w, "Host: %s\n", host)
+	dumpHeaders(w, req.Header)
+	if req.ContentLength != 0 {
+		fmt.Fprintf(w, "(body omitted, %s)\n", bodySize(req.ContentLength))
+	}
+}
+
+func dumpResponse(w io.Writer, resp *http.Response, elapsed time.Duration) {
+	fmt.Fprintf(w, "<<< %s %s in %s\n", resp.Proto, resp.Status, formatDuration(elapsed))
+	dumpHeaders(w, resp.Header)
+	if resp.ContentLength != 0 {
+		fmt.Fprintf(w, "(body omitted, %s)\n", bodySize(resp.ContentLength))
+	}
+	fmt.Fprintln(w)
+}
+
+func bodySize(length int64) string {
+	if length < 0 {
+		return "of unknown length"
+	}
+	return fmt.Sprintf("%d bytes", length)
+}
+
+// dumpHeaders writes the headers sorted by name, the credentials cut short.
+func dumpHeaders(w io.Writer, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			fmt.Fprintf(w, "%s: %s\n", name, sanitizeHeader(name, value))
+		}
+	}
+}
+
+// sanitizeHeader cuts a credential down to its first debugHTTPPrefix characters and leaves out
+// the secrets.
+func sanitizeHeader(name, value string) string {
+	name = http.CanonicalHeaderKey(name)
+	if debugHTTPSecretHeaders[name] {
+		return fmt.Sprintf("[redacted, %d chars]", len(value))
+	}
+	if !debugHTTPCutHeaders[name] {
+		return value
+	}
+	if len(value) <= debugHTTPPrefix {
+		return fmt.Sprintf("[redacted, %d chars]", len(value))
+	}
+	return fmt.Sprintf("%s…[redacted, %d chars]", value[:debugHTTPPrefix], len(value))
+}
+
+// sanitizeURL drops the credentials of a presigned URL from the query.
+func sanitizeURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+	query := u.Query()
+	for _, param := range debugHTTPSecretQuery {
+		if query.Has(param) {
+			query.Set(param, "[redacted]")
+		}
+	}
+	sanitized := *u
+	sanitized.RawQuery = query.Encode()
+	return sanitized.String()
+}
+
+// debugHTTPOutput opens the file of "-debug-http-output", the standard error without.
+func debugHTTPOutput(path string) (io.Writer, func() error, error) {
+	if path == "" {
+		return os.Stderr, func() error { return nil }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
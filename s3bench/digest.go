@@ -0,0 +1,60 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"crypto/md5"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// checksumAlgorithms are the additional checksums minio-go can send along with a PUT.
+var checksumAlgorithms = []minio.ChecksumType{minio.ChecksumCRC32, minio.ChecksumCRC32C, minio.ChecksumSHA1, minio.ChecksumSHA256}
+
+func parseChecksumAlgorithm(value string) (minio.ChecksumType, error) {
+	if value == "" {
+		return minio.ChecksumNone, nil
+	}
+	names := make([]string, 0, len(checksumAlgorithms))
+	for _, algorithm := range checksumAlgorithms {
+		if strings.EqualFold(value, algorithm.String()) {
+			return algorithm, nil
+		}
+		names = append(names, algorithm.String())
+	}
+	return minio.ChecksumNone, fmt.Errorf(`unknown checksum algorithm %q, expected one of %s`, value, strings.Join(names, ", "))
+}
+
+// digestUpload computes the digests of data requested for the uploads and returns how long it
+// took, so that the cost is reported apart from the upload time. The checksum is computed once
+// and sent as is; minio-go computes Content-MD5 on its own, so that one is hashed here only to
+// measure it.
+func (r runner) digestUpload(data []byte, opts *minio.PutObjectOptions) time.Duration {
+	if !r.sendContentMD5 && !r.checksum.IsSet() {
+		return 0
+	}
+	start := time.Now()
+	if r.sendContentMD5 {
+		md5.Sum(data)
+		opts.SendContentMd5 = true
+	}
+	if r.checksum.IsSet() {
+		if opts.UserMetadata == nil {
+			opts.UserMetadata = map[string]string{}
+		}
+		opts.UserMetadata[r.checksum.Key()] = r.checksum.ChecksumBytes(data).Encoded()
+	}
+	return time.Since(start)
+}
+
+// isDigestMismatch tells rejections due to a mismatching Content-MD5 or checksum apart
+// from other upload failures.
+func isDigestMismatch(err error) bool {
+	switch errorResponse(err).Code {
+	case "BadDigest", "InvalidDigest", "XAmzContentChecksumMismatch", "XAmzContentSHA256Mismatch", "InvalidChecksum":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const encodingGzip = "gzip"
+
+func validateContentEncoding(encoding string) error {
+	if encoding != "" && encoding != encodingGzip {
+		return fmt.Errorf(`unsupported content encoding %q, only %q is`, encoding, encodingGzip)
+	}
+	return nil
+}
+
+// EncodingStats compares what went over the wire with the decoded payloads of
+// "-content-encoding". Compression happens before an upload is timed, decoding is part of the
+// download like in a client decoding on the fly. Mangled downloads came back without the
+// Content-Encoding header or already decoded, i.e. the backend stripped or interpreted it.
+type EncodingStats struct {
+	Encoding        string        `json:"encoding"`
+	Ratio           float64       `json:"ratio"`
+	AvgCompressTime time.Duration `json:"avg_compress_time"`
+	P90CompressTime time.Duration `json:"p90_compress_time"`
+	Mangled         int           `json:"mangled,omitempty"`
+}
+
+func (e EncodingStats) String(upload, download PhaseStats) string {
+	s := fmt.Sprintf(` Encoding    : %s ratio=%.2f compress.p90=%s compress.avg=%s
+  upload.throughput: wire=%s MB/s decoded=%s MB/s, download.throughput: wire=%s MB/s decoded=%s MB/s
+`,
+		e.Encoding, e.Ratio, formatDuration(e.P90CompressTime), formatDuration(e.AvgCompressTime),
+		formatSpeed(upload.Throughput), formatSpeed(upload.DecodedThroughput), formatSpeed(download.Throughput), formatSpeed(download.DecodedThroughput))
+	if e.Mangled > 0 {
+		s += fmt.Sprintf("  WARNING: %d downloads came back without \"Content-Encoding: %s\" or already decoded\n", e.Mangled, e.Encoding)
+	}
+	return s
+}
+
+// compressor gzips the payloads of a worker into a buffer of its own.
+type compressor struct {
+	buf bytes.Buffer
+	gz  *gzip.Writer
+}
+
+func newCompressor() *compressor {
+	c := &compressor{}
+	c.gz = gzip.NewWriter(&c.buf)
+	return c
+}
+
+func (c *compressor) compress(data []byte) ([]byte, time.Duration) {
+	start := time.Now()
+	c.buf.Reset()
+	c.gz.Reset(&c.buf)
+	c.gz.Write(data)
+	c.gz.Close()
+	return c.buf.Bytes(), time.Since(start)
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// receiveEncoded reads a download of an encoded object and returns the wire and the decoded
+// sizes. A body which is not gzip at all is taken as decoded by the backend already.
+func receiveEncoded(payload io.Reader) (wire, decoded int64, mangled bool, err error) {
+	var (
+		counter = &countingReader{r: payload}
+		body    = bufio.NewReader(counter)
+	)
+	if magic, _ := body.Peek(2); !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		decoded, err = io.Copy(io.Discard, body)
+		return counter.n, decoded, true, err
+	}
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return counter.n, 0, false, err
+	}
+	decoded, err = io.Copy(io.Discard, gz)
+	return counter.n, decoded, false, err
+}
+
+// encodingStripped tells whether the response headers of payload lost the encoding. Stores
+// which do not expose them are given the benefit of the doubt.
+func encodingStripped(payload io.Reader, encoding string) bool {
+	object, ok := payload.(interface {
+		Stat() (minio.ObjectInfo, error)
+	})
+	if !ok {
+		return false
+	}
+	info, err := object.Stat()
+	return err == nil && !strings.EqualFold(info.Metadata.Get("Content-Encoding"), encoding)
+}
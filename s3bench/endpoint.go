@@ -0,0 +1,114 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// bucketNamePattern is what S3 accepts as a bucket name, periods and hyphens aside.
+var bucketNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// endpointAddress is an "-endpoint" taken apart: an optional scheme, which decides on TLS (the
+// default), the host with an optional port and whatever path was given along.
+type endpointAddress struct {
+	scheme string
+	host   string
+	path   string
+}
+
+// parseEndpoint accepts "host", "host:port", "[ipv6]:port" and any of them with an "http://" or
+// "https://" scheme and a path, e.g. "https://minio.local:9000/bucket/".
+func parseEndpoint(value string) (endpointAddress, error) {
+	var e endpointAddress
+	if strings.ContainsAny(value, " \t\n") {
+		return e, fmt.Errorf(`%q contains whitespace`, value)
+	}
+	rest := value
+	if scheme, after, ok := strings.Cut(value, "://"); ok {
+		switch strings.ToLower(scheme) {
+		case "http", "https":
+			e.scheme, rest = strings.ToLower(scheme), after
+		default:
+			return e, fmt.Errorf(`%q has the unsupported scheme %q, expected "http" or "https"`, value, scheme)
+		}
+	}
+	if strings.ContainsAny(rest, "?#") {
+		return e, fmt.Errorf(`%q has a query or a fragment, an endpoint is a host and a port`, value)
+	}
+	if i := strings.Index(rest, "/"); i >= 0 {
+		rest, e.path = rest[:i], strings.Trim(rest[i:], "/")
+	}
+	if rest == "" {
+		return e, fmt.Errorf(`%q has no host`, value)
+	}
+
+	host, port, hasPort := rest, "", false
+	switch {
+	case strings.HasPrefix(rest, "["):
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return e, fmt.Errorf(`%q has an unterminated IPv6 address`, value)
+		}
+		host = rest[1:end]
+		if ip := net.ParseIP(host); ip == nil || ip.To4() != nil {
+			return e, fmt.Errorf(`%q has an invalid IPv6 address %q`, value, host)
+		}
+		switch after := rest[end+1:]; {
+		case after == "":
+		case strings.HasPrefix(after, ":"):
+			port, hasPort = after[1:], true
+		default:
+			return e, fmt.Errorf(`%q has %q after the IPv6 address, expected a ":port"`, value, after)
+		}
+	case strings.Count(rest, ":") > 1:
+		return e, fmt.Errorf(`%q looks like an IPv6 address, which needs brackets, e.g. "[::1]:9000"`, value)
+	case strings.Contains(rest, ":"):
+		host, port, hasPort = strings.Cut(rest, ":")
+	}
+	if host == "" {
+		return e, fmt.Errorf(`%q has no host`, value)
+	}
+	if hasPort {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return e, fmt.Errorf(`%q has the invalid port %q, expected 1 to 65535`, value, port)
+		}
+	}
+	e.host = rest
+	return e, nil
+}
+
+// secure tells whether to connect with TLS: unless "http://" is asked for.
+func (e endpointAddress) secure() bool {
+	return e.scheme != "http"
+}
+
+// String is the endpoint without its path.
+func (e endpointAddress) String() string {
+	if e.scheme == "" {
+		return e.host
+	}
+	return e.scheme + "://" + e.host
+}
+
+// normalizeEndpoint drops the path of an endpoint, with a warning unless it can stand in for the
+// missing bucket name: then it is returned as the bucket. flagName is that of the warnings.
+func normalizeEndpoint(flagName, value, bucketName string) (endpoint, bucket string, err error) {
+	e, err := parseEndpoint(value)
+	if err != nil {
+		return "", "", err
+	}
+	switch {
+	case e.path == "":
+	case bucketName == "" && bucketNamePattern.MatchString(e.path):
+		log.Printf(`Using %q of "-%s" as the bucket as "-bucketName" is not set`, e.path, flagName)
+		bucket = e.path
+	default:
+		log.Printf(`WARNING: ignoring the path %q of "-%s", the bucket is given with "-bucketName"`, e.path, flagName)
+	}
+	return e.String(), bucket, nil
+}
@@ -0,0 +1,81 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		// endpoint, path and secure are the endpoint parsed, err part of the error otherwise.
+		endpoint, path string
+		secure         bool
+		err            string
+	}{
+		{"minio.local", "minio.local", "", true, ""},
+		{"minio.local:9000", "minio.local:9000", "", true, ""},
+		{"127.0.0.1:9000", "127.0.0.1:9000", "", true, ""},
+		{"http://127.0.0.1:9000", "http://127.0.0.1:9000", "", false, ""},
+		{"HTTPS://minio.local", "https://minio.local", "", true, ""},
+		{"https://minio.local:9000/bench/", "https://minio.local:9000", "bench", true, ""},
+		{"http://minio.local/a/b", "http://minio.local", "a/b", false, ""},
+		{"[::1]", "[::1]", "", true, ""},
+		{"http://[::1]:9000/bench", "http://[::1]:9000", "bench", false, ""},
+		{"[fe80::1%eth0]:9000", "", "", false, "invalid IPv6 address"},
+
+		{"", "", "", false, "no host"},
+		{"https:///bench", "", "", false, "no host"},
+		{":9000", "", "", false, "no host"},
+		{"minio.local:", "", "", false, "invalid port"},
+		{"minio.local:0", "", "", false, "invalid port"},
+		{"minio.local:65536", "", "", false, "invalid port"},
+		{"minio.local:s3", "", "", false, "invalid port"},
+		{"::1", "", "", false, "needs brackets"},
+		{"[::1", "", "", false, "unterminated"},
+		{"[127.0.0.1]:9000", "", "", false, "invalid IPv6 address"},
+		{"[::1]9000", "", "", false, `expected a ":port"`},
+		{"ftp://minio.local", "", "", false, "unsupported scheme"},
+		{"https://minio.local/?x-id=1", "", "", false, "query or a fragment"},
+		{"minio .local", "", "", false, "whitespace"},
+	} {
+		e, err := parseEndpoint(tc.value)
+		switch {
+		case tc.err != "":
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("parseEndpoint(%q) = %v, %v, want an error with %q", tc.value, e, err, tc.err)
+			}
+		case err != nil:
+			t.Errorf("parseEndpoint(%q): %v", tc.value, err)
+		case e.String() != tc.endpoint || e.path != tc.path || e.secure() != tc.secure:
+			t.Errorf("parseEndpoint(%q) = %q with path %q secure=%t, want %q with %q secure=%t", tc.value, e, e.path, e.secure(), tc.endpoint, tc.path, tc.secure)
+		}
+	}
+}
+
+func TestNormalizeEndpoint(t *testing.T) {
+	for _, tc := range []struct {
+		value, bucketName string
+		endpoint, bucket  string
+	}{
+		{"minio.local:9000", "", "minio.local:9000", ""},
+		{"https://minio.local:9000/", "", "https://minio.local:9000", ""},
+		// The path stands in for the missing bucket name.
+		{"https://minio.local:9000/bench", "", "https://minio.local:9000", "bench"},
+		{"http://minio.local/bench/", "", "http://minio.local", "bench"},
+		// The one given stays, paths which are no bucket name are dropped.
+		{"https://minio.local:9000/bench", "other", "https://minio.local:9000", ""},
+		{"https://minio.local:9000/a/b", "", "https://minio.local:9000", ""},
+		{"https://minio.local:9000/Bench", "", "https://minio.local:9000", ""},
+	} {
+		endpoint, bucket, err := normalizeEndpoint("endpoint", tc.value, tc.bucketName)
+		if err != nil {
+			t.Errorf("normalizeEndpoint(%q, %q): %v", tc.value, tc.bucketName, err)
+			continue
+		}
+		if endpoint != tc.endpoint || bucket != tc.bucket {
+			t.Errorf("normalizeEndpoint(%q, %q) = %q, %q, want %q, %q", tc.value, tc.bucketName, endpoint, bucket, tc.endpoint, tc.bucket)
+		}
+	}
+}
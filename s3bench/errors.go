@@ -0,0 +1,135 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// errorClass is what went wrong with a failed operation, regardless of the wording of the
+// message. An OpError of the class matches it with errors.Is, e.g. errors.Is(err, errorThrottle).
+type errorClass string
+
+const (
+	errorThrottle errorClass = "throttle"
+	errorTimeout  errorClass = "timeout"
+	errorAuth     errorClass = "auth"
+	errorNotFound errorClass = "not-found"
+	// errorIntegrity is a stored object which is not what was sent, wrapped by the error.
+	errorIntegrity errorClass = "integrity"
+	// errorDigest is an upload the server rejected for a Content-MD5 or a checksum which did
+	// not match the body.
+	errorDigest errorClass = "digest"
+	errorOther  errorClass = "other"
+)
+
+func (c errorClass) Error() string {
+	return string(c)
+}
+
+// OpError is a failed operation of a trial: the phase, the trial and the key it was for, which
+// try of it failed and how many bytes it got through before. Err is the cause, wrapping the
+// minio-go error if any.
+type OpError struct {
+	Phase     string
+	Trial     int
+	Key       string
+	Attempt   int
+	BytesDone int64
+	Err       error
+}
+
+// Error leaves the key to the message of Err, which names it along with the bucket.
+func (e *OpError) Error() string {
+	context := e.Phase
+	if e.Trial > 0 {
+		context += fmt.Sprintf(" trial %d", e.Trial)
+	}
+	if e.Attempt > 1 {
+		context += fmt.Sprintf(", attempt %d", e.Attempt)
+	}
+	if e.BytesDone > 0 {
+		context += fmt.Sprintf(", %s done", formatBytes(e.BytesDone))
+	}
+	return fmt.Sprintf("%v (%s)", e.Err, context)
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+func (e *OpError) Is(target error) bool {
+	class, ok := target.(errorClass)
+	return ok && classifyError(e.Err) == class
+}
+
+// classifyError looks through the wrapped errors for the S3 error response or the network
+// error behind err.
+func classifyError(err error) errorClass {
+	var netErr net.Error
+	switch resp := errorResponse(err); {
+	case errors.Is(err, errorIntegrity):
+		return errorIntegrity
+	case isDigestMismatch(err):
+		return errorDigest
+	case isThrottled(resp):
+		return errorThrottle
+	case errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout():
+		return errorTimeout
+	case isAuthError(err) || resp.StatusCode == http.StatusForbidden:
+		return errorAuth
+	case isNotFound(err):
+		return errorNotFound
+	}
+	return errorOther
+}
+
+// errorResponse is the S3 error response wrapped by err, the zero one without. Unlike
+// minio.ToErrorResponse it sees through wrapping.
+func errorResponse(err error) minio.ErrorResponse {
+	var resp minio.ErrorResponse
+	if errors.As(err, &resp) {
+		return resp
+	}
+	return minio.ErrorResponse{}
+}
+
+func isThrottled(resp minio.ErrorResponse) bool {
+	switch resp.Code {
+	case "SlowDown", "SlowDownRead", "SlowDownWrite", "RequestLimitExceeded", "Throttling", "ThrottlingException", "TooManyRequests", "RequestThrottled":
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+}
+
+func isNotFound(err error) bool {
+	resp := errorResponse(err)
+	return resp.Code == "NoSuchKey" || resp.StatusCode == http.StatusNotFound
+}
+
+// failureExitCode tells rejected credentials apart from other failures.
+func failureExitCode(err error) int {
+	if classifyError(err) == errorAuth {
+		return exitAuthFailed
+	}
+	return 1
+}
+
+// errorCounts counts failed operations by class.
+type errorCounts map[errorClass]int
+
+func (c errorCounts) String() string {
+	counts := make([]string, 0, len(c))
+	for class, n := range c {
+		counts = append(counts, fmt.Sprintf("%s=%d", class, n))
+	}
+	sort.Strings(counts)
+	return strings.Join(counts, " ")
+}
@@ -0,0 +1,93 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestClassifyError(t *testing.T) {
+	response := func(code string, status int, message string) error {
+		return minio.ErrorResponse{Code: code, StatusCode: status, Message: message}
+	}
+	wrapped := func(err error) error {
+		return &OpError{Phase: "upload", Trial: 3, Err: fmt.Errorf("Unable to upload file-3.dat: %w", err)}
+	}
+	for _, tc := range []struct {
+		name string
+		err  error
+		want errorClass
+		code int
+	}{
+		{"SlowDown", response("SlowDown", http.StatusServiceUnavailable, ""), errorThrottle, 1},
+		{"RequestLimitExceeded", response("RequestLimitExceeded", http.StatusBadRequest, ""), errorThrottle, 1},
+		{"429 without a code", response("", http.StatusTooManyRequests, ""), errorThrottle, 1},
+		{"503 without a code", response("", http.StatusServiceUnavailable, ""), errorThrottle, 1},
+		{"SignatureDoesNotMatch", response("SignatureDoesNotMatch", http.StatusForbidden, ""), errorAuth, exitAuthFailed},
+		{"InvalidAccessKeyId", response("InvalidAccessKeyId", http.StatusForbidden, ""), errorAuth, exitAuthFailed},
+		{"AuthorizationHeaderMalformed", response("AuthorizationHeaderMalformed", http.StatusBadRequest, ""), errorAuth, exitAuthFailed},
+		{"403 of another code", response("AllAccessDisabled", http.StatusForbidden, ""), errorAuth, exitAuthFailed},
+		{"NoSuchKey", response("NoSuchKey", http.StatusNotFound, ""), errorNotFound, 1},
+		{"404 without a code", response("", http.StatusNotFound, ""), errorNotFound, 1},
+		{"InternalError", response("InternalError", http.StatusInternalServerError, ""), errorOther, 1},
+		{"BadDigest", response("BadDigest", http.StatusBadRequest, "The Content-MD5 you specified did not match what we received."), errorDigest, 1},
+		{"XAmzContentSHA256Mismatch", response("XAmzContentSHA256Mismatch", http.StatusBadRequest, ""), errorDigest, 1},
+
+		// InvalidRequest is about the signature only for some of its messages.
+		{"InvalidRequest of the signature version", response("InvalidRequest", http.StatusBadRequest, "The authorization mechanism you have provided is not supported. Please use AWS4-HMAC-SHA256."), errorAuth, exitAuthFailed},
+		{"InvalidRequest of the payload hash", response("InvalidRequest", http.StatusBadRequest, "Missing required header for this request: x-amz-content-sha256"), errorAuth, exitAuthFailed},
+		{"InvalidRequest of a signature", response("InvalidRequest", http.StatusBadRequest, "Signature version 2 is not supported"), errorAuth, exitAuthFailed},
+		{"InvalidRequest of something else", response("InvalidRequest", http.StatusBadRequest, "Content-MD5 must be set for this request"), errorOther, 1},
+
+		{"deadline exceeded", context.DeadlineExceeded, errorTimeout, 1},
+		{"cancelled", context.Canceled, errorOther, 1},
+		{"net timeout", &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, errorTimeout, 1},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, errorOther, 1},
+		{"DNS failure", &net.DNSError{Err: "no such host", Name: "minio.local", IsNotFound: true}, errorOther, 1},
+		{"DNS timeout", &net.DNSError{Err: "i/o timeout", Name: "minio.local", IsTimeout: true}, errorTimeout, 1},
+
+		{"integrity", fmt.Errorf("%w: file-3.dat differs at byte 512", errorIntegrity), errorIntegrity, 1},
+		{"plain error", errors.New("unexpected EOF"), errorOther, 1},
+		{"nil response", minio.ErrorResponse{}, errorOther, 1},
+	} {
+		for _, err := range []error{tc.err, wrapped(tc.err)} {
+			if got := classifyError(err); got != tc.want {
+				t.Errorf("%s: classifyError(%v) = %s, want %s", tc.name, err, got, tc.want)
+			}
+			if code := failureExitCode(err); code != tc.code {
+				t.Errorf("%s: exit code %d, want %d", tc.name, code, tc.code)
+			}
+		}
+		if op := wrapped(tc.err); !errors.Is(op, tc.want) {
+			t.Errorf("%s: errors.Is(%v, %s) is false", tc.name, op, tc.want)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	removed, left := &CleanupStats{Removed: 3}, &CleanupStats{Removed: 2, Failed: 1, Remaining: []RemainingObject{{Bucket: testBucket, Key: "file-3.dat"}}}
+	for _, tc := range []struct {
+		name     string
+		passed   bool
+		cleanups []*CleanupStats
+		want     int
+	}{
+		{"passed", true, []*CleanupStats{removed}, 0},
+		// The objects are kept with "-keep-objects".
+		{"no cleanup", true, []*CleanupStats{nil}, 0},
+		{"objects left behind", true, []*CleanupStats{removed, left}, exitCleanupIncomplete},
+		// The thresholds failing say more about the run than the cleanup.
+		{"thresholds failed", false, []*CleanupStats{left}, exitThresholdsFailed},
+	} {
+		if got := exitCode(tc.passed, tc.cleanups...); got != tc.want {
+			t.Errorf("%s: exit code %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event is a single trial as written to the events output, one JSON document per line. Start is
+// the wall-clock start of the trial, its Duration comes from the monotonic clock.
+type Event struct {
+	Variant string `json:"variant,omitempty"`
+	Phase   string `json:"phase"`
+	// Pass tells the download passes of "-cache-probe" apart.
+	Pass           int           `json:"pass,omitempty"`
+	Stage          string        `json:"stage"`
+	Worker         int           `json:"worker"`
+	Host           string        `json:"host,omitempty"`
+	Trial          int           `json:"trial"`
+	Key            string        `json:"key"`
+	Start          time.Time     `json:"start"`
+	Duration       time.Duration `json:"duration"`
+	Bytes          int64         `json:"bytes"`
+	Speed          float64       `json:"speed"`
+	StatDuration   time.Duration `json:"stat_duration,omitempty"`
+	DigestDuration time.Duration `json:"digest_duration,omitempty"`
+	Error          string        `json:"error,omitempty"`
+	ErrorClass     string        `json:"error_class,omitempty"`
+	Attempt        int           `json:"attempt,omitempty"`
+	Recovered      bool          `json:"recovered,omitempty"`
+	Skipped        bool          `json:"skipped,omitempty"`
+	Cancelled      bool          `json:"cancelled,omitempty"`
+	ContinueWait   time.Duration `json:"continue_wait,omitempty"`
+	TTFB           time.Duration `json:"ttfb,omitempty"`
+	EncodeDuration time.Duration `json:"encode_duration,omitempty"`
+	DecodedBytes   int64         `json:"decoded_bytes,omitempty"`
+	Mangled        bool          `json:"mangled,omitempty"`
+	VerifyDuration time.Duration `json:"verify_duration,omitempty"`
+	// ETag and VersionID are those the upload returned.
+	ETag      string `json:"etag,omitempty"`
+	VersionID string `json:"version_id,omitempty"`
+	// CryptDuration is spent on "-client-encrypt", it is not part of the duration.
+	CryptDuration time.Duration `json:"crypt_duration,omitempty"`
+}
+
+// Events are written as whole lines only and reach the file once the buffer holds
+// eventBufferSize bytes or every eventFlushInterval, whichever comes first, so that a run which
+// gets killed leaves the events up to then as valid JSONL.
+const (
+	eventBufferSize    = 64 * 1024
+	eventFlushInterval = time.Second
+)
+
+// eventWriter appends events to a JSONL file. A nil eventWriter does nothing.
+type eventWriter struct {
+	mu   sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+	line bytes.Buffer
+	enc  *json.Encoder
+	done chan struct{}
+}
+
+func newEventWriter(path string) (*eventWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &eventWriter{file: file, buf: bufio.NewWriterSize(file, eventBufferSize), done: make(chan struct{})}
+	w.enc = json.NewEncoder(&w.line)
+	go w.flushEvery(w.done, eventFlushInterval)
+	return w, nil
+}
+
+func (w *eventWriter) flushEvery(done <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			w.buf.Flush()
+			w.mu.Unlock()
+		}
+	}
+}
+
+// write appends a record, usually an Event. A line which does not fit into the buffer any more
+// flushes it first, bufio would split the line otherwise.
+func (w *eventWriter) write(event any) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.line.Reset()
+	if err := w.enc.Encode(event); err != nil {
+		return
+	}
+	if w.line.Len() > w.buf.Available() && w.buf.Buffered() > 0 {
+		w.buf.Flush()
+	}
+	w.buf.Write(w.line.Bytes())
+}
+
+func (w *eventWriter) close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// A fatal error of a worker may close the writer while the run closes it too.
+	if w.done == nil {
+		return nil
+	}
+	close(w.done)
+	w.done = nil
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,115 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseEvents decodes every line of content, which must end with a whole one.
+func parseEvents(t *testing.T, content []byte) []map[string]any {
+	t.Helper()
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		t.Fatalf("the events end with a partial line: %q", content[bytes.LastIndexByte(content, '\n')+1:])
+	}
+	var events []map[string]any
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var event map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("line %d: %v", len(events)+1, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestEventsOfAKilledRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	w, err := newEventWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.close()
+
+	// The file as a run killed at any moment leaves it: the writer is never closed. The errors
+	// make some of the lines longer than the buffer.
+	deadline := time.Now().Add(eventFlushInterval + 500*time.Millisecond)
+	written, snapshots := 0, 0
+	for time.Now().Before(deadline) {
+		written++
+		event := Event{Phase: "upload", Stage: stagePlateau, Trial: written, Key: "file-" + strconv.Itoa(written) + ".dat", Start: time.Now(), Duration: time.Millisecond}
+		if written%50 == 0 {
+			event.Error = strings.Repeat("x", eventBufferSize+1)
+		}
+		w.write(event)
+		if written%20 == 0 {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parseEvents(t, content)
+			snapshots++
+		}
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flushed every eventFlushInterval, the events of more than a second ago are all there.
+	if events := parseEvents(t, content); len(events) == 0 || snapshots == 0 {
+		t.Errorf("%d of %d events on disk after %v", len(events), written, eventFlushInterval)
+	}
+}
+
+func TestCancelledRunOutputs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "run")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(100*time.Millisecond, cancel)
+	results, err := Run(ctx, Config{Args: []string{
+		"-backend", "fs", "-fs-root", t.TempDir(), "-bucketName", testBucket, "-create-bucket",
+		"-trials", "100000", "-fileSize", "1", "-output-dir", dir,
+	}})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled run: %v", err)
+	}
+	if n := len(results.Samples["upload"]); n == 0 || n == 100000 {
+		t.Errorf("%d uploads measured, want the run cancelled mid-way", n)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, runReportJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report Report
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatalf("%s: %v", runReportJSON, err)
+	}
+	if report.Upload.Count != len(results.Samples["upload"]) {
+		t.Errorf("%s has %d uploads, the run measured %d", runReportJSON, report.Upload.Count, len(results.Samples["upload"]))
+	}
+	content, err = os.ReadFile(filepath.Join(dir, runSamples))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploads := 0
+	for _, event := range parseEvents(t, content) {
+		if event["phase"] == "upload" && event["stage"] == stagePlateau {
+			uploads++
+		}
+	}
+	if uploads != report.Upload.Count {
+		t.Errorf("%s has %d uploads, the report %d", runSamples, uploads, report.Upload.Count)
+	}
+}
@@ -0,0 +1,303 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// Operations faults can be injected into.
+var faultOperations = []string{"put", "get", "stat", "delete", "list"}
+
+// fault is a rule of "-fault-inject": fail ("put:error:0.1") or delay ("get:latency:500ms:0.2")
+// an operation with the given probability. Downloads may also fail halfway through the body
+// ("get:truncate:0.1") or come back with a bit flipped in the middle ("get:bitflip:0.1"),
+// uploads may time out after the object was stored ("put:lost-ack:0.1") or succeed without
+// storing anything ("put:phantom:0.1"). It is a testing feature only.
+type fault struct {
+	operation string
+	latency   time.Duration
+	// outcome is a fault striking instead of or after the operation: "truncate", "bitflip",
+	// "lost-ack" or "phantom".
+	outcome     string
+	probability float64
+}
+
+var errInjectedTruncation = minio.ErrorResponse{
+	Code:       "InjectedFault",
+	Message:    `body truncated by "-fault-inject"`,
+	StatusCode: http.StatusInternalServerError,
+}
+
+func parseFaults(value string) ([]fault, error) {
+	var faults []fault
+	for _, rule := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(rule), ":")
+		if len(parts) < 3 {
+			return nil, fmt.Errorf(`fault %q is neither of the "op:error:probability" nor of the "op:latency:duration:probability" form`, rule)
+		}
+		f := fault{operation: strings.ToLower(parts[0])}
+		known := false
+		for _, operation := range faultOperations {
+			known = known || f.operation == operation
+		}
+		if !known {
+			return nil, fmt.Errorf(`fault %q: unknown operation, expected one of %s`, rule, strings.Join(faultOperations, ", "))
+		}
+
+		probability := parts[2]
+		switch {
+		case parts[1] == "error" && len(parts) == 3:
+		case parts[1] == "truncate" && len(parts) == 3:
+			if f.operation != "get" {
+				return nil, fmt.Errorf(`fault %q: only "get" can be truncated`, rule)
+			}
+			f.outcome = parts[1]
+		case parts[1] == "bitflip" && len(parts) == 3:
+			if f.operation != "get" {
+				return nil, fmt.Errorf(`fault %q: only "get" can flip a bit`, rule)
+			}
+			f.outcome = parts[1]
+		case parts[1] == "lost-ack" && len(parts) == 3:
+			if f.operation != "put" {
+				return nil, fmt.Errorf(`fault %q: only "put" can lose its acknowledgement`, rule)
+			}
+			f.outcome = parts[1]
+		case parts[1] == "phantom" && len(parts) == 3:
+			if f.operation != "put" {
+				return nil, fmt.Errorf(`fault %q: only "put" can be a phantom`, rule)
+			}
+			f.outcome = parts[1]
+		case parts[1] == "latency" && len(parts) == 4:
+			latency, err := time.ParseDuration(parts[2])
+			if err != nil || latency <= 0 {
+				return nil, fmt.Errorf(`fault %q: invalid latency %q`, rule, parts[2])
+			}
+			f.latency, probability = latency, parts[3]
+		default:
+			return nil, fmt.Errorf(`fault %q: unknown kind %q, expected "error", "latency", "truncate", "bitflip", "lost-ack" or "phantom"`, rule, parts[1])
+		}
+		p, err := strconv.ParseFloat(probability, 64)
+		if err != nil || p < 0 || p > 1 {
+			return nil, fmt.Errorf(`fault %q: probability %q is not within [0, 1]`, rule, probability)
+		}
+		f.probability = p
+		faults = append(faults, f)
+	}
+	return faults, nil
+}
+
+func faultInjectionWarning(spec string, seed int64) string {
+	return fmt.Sprintf("WARNING: TESTING ONLY, faults are injected (%s, seed=%d): failures and latencies are not all real", spec, seed)
+}
+
+// faultInjector draws the faults of every worker from a generator of its own, seeded from the
+// seed and the worker, so that the faults a worker runs into do not depend on how the workers
+// are scheduled. The operations outside of the trials, e.g. of the setup, draw from that of
+// worker 0.
+type faultInjector struct {
+	faults []fault
+	seed   int64
+
+	mu      sync.Mutex
+	workers map[int]*faultRand
+}
+
+// faultRand is the generator of a worker, shared by the phases overlapping with "-interleave".
+type faultRand struct {
+	mu  sync.Mutex
+	rng *mathrand.Rand
+}
+
+func newFaultInjector(faults []fault, seed int64) *faultInjector {
+	return &faultInjector{faults: faults, seed: seed, workers: map[int]*faultRand{}}
+}
+
+func (i *faultInjector) worker(worker int) *faultRand {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	r, ok := i.workers[worker]
+	if !ok {
+		r = &faultRand{rng: mathrand.New(mathrand.NewSource(i.seed + int64(worker)*1_000_003))}
+		i.workers[worker] = r
+	}
+	return r
+}
+
+func (r *faultRand) hit(f fault) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.Float64() < f.probability
+}
+
+// forWorker has the faults of the stores of a worker drawn from its generator.
+func forWorker(clients []ObjectStore, worker int) []ObjectStore {
+	var bound []ObjectStore
+	for i, client := range clients {
+		store, ok := client.(faultStore)
+		if !ok {
+			continue
+		}
+		if bound == nil {
+			bound = append([]ObjectStore{}, clients...)
+		}
+		store.rng = store.injector.worker(worker)
+		bound[i] = store
+	}
+	if bound == nil {
+		return clients
+	}
+	return bound
+}
+
+// inject delays and/or fails an operation as the rules say.
+func (s faultStore) inject(ctx context.Context, operation string) error {
+	for _, f := range s.injector.faults {
+		if f.operation != operation || f.outcome != "" || !s.rng.hit(f) {
+			continue
+		}
+		if f.latency == 0 {
+			return minio.ErrorResponse{
+				Code:       "InjectedFault",
+				Message:    fmt.Sprintf(`%s failed by "-fault-inject"`, operation),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+		select {
+		case <-time.After(f.latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// strikes tells whether the operation, which went through, should have the outcome anyway.
+func (s faultStore) strikes(operation, outcome string) bool {
+	for _, f := range s.injector.faults {
+		if f.operation == operation && f.outcome == outcome && s.rng.hit(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrap returns a store whose operations are subject to the faults before they reach store.
+func (i *faultInjector) wrap(store ObjectStore) ObjectStore {
+	if i == nil {
+		return store
+	}
+	return faultStore{ObjectStore: store, injector: i, rng: i.worker(0)}
+}
+
+type faultStore struct {
+	ObjectStore
+	injector *faultInjector
+	rng      *faultRand
+}
+
+func (s faultStore) PutObject(ctx context.Context, bucketName, key string, reader io.Reader, size int64, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	if err := s.inject(ctx, "put"); err != nil {
+		return minio.UploadInfo{}, err
+	}
+	if s.strikes("put", "phantom") {
+		// Acknowledged like by a buggy gateway, while nothing gets stored.
+		n, err := io.Copy(io.Discard, reader)
+		return minio.UploadInfo{Bucket: bucketName, Key: key, Size: n}, err
+	}
+	info, err := s.ObjectStore.PutObject(ctx, bucketName, key, reader, size, opts)
+	if err == nil && s.strikes("put", "lost-ack") {
+		return minio.UploadInfo{}, fmt.Errorf(`acknowledgement lost by "-fault-inject": %w`, context.DeadlineExceeded)
+	}
+	return info, err
+}
+
+func (s faultStore) GetObject(ctx context.Context, bucketName, key string, opts minio.GetObjectOptions) (io.ReadCloser, error) {
+	if err := s.inject(ctx, "get"); err != nil {
+		return nil, err
+	}
+	object, err := s.ObjectStore.GetObject(ctx, bucketName, key, opts)
+	if err != nil {
+		return object, err
+	}
+	switch {
+	case s.strikes("get", "truncate"):
+		return &faultReader{ReadCloser: object, outcome: "truncate", at: -1}, nil
+	case s.strikes("get", "bitflip"):
+		return &faultReader{ReadCloser: object, outcome: "bitflip", at: -1}, nil
+	}
+	return object, nil
+}
+
+// faultReader fails once it has passed on the first half of the body ("truncate") or flips the
+// lowest bit of the byte in its middle ("bitflip"). The size is the one of the response to the
+// GET, which the objects of all the stores tell, so that the fault takes no request of its own.
+type faultReader struct {
+	io.ReadCloser
+	outcome string
+	// at is the offset of the fault, -1 until the response is in.
+	at, offset int64
+}
+
+func (r *faultReader) Read(p []byte) (int, error) {
+	if r.at < 0 {
+		object, ok := r.ReadCloser.(interface {
+			Stat() (minio.ObjectInfo, error)
+		})
+		if !ok {
+			return 0, fmt.Errorf(`"-fault-inject" cannot tell the size of a %T`, r.ReadCloser)
+		}
+		info, err := object.Stat()
+		if err != nil {
+			return 0, err
+		}
+		r.at = info.Size / 2
+	}
+	if r.outcome == "truncate" {
+		if r.offset >= r.at {
+			return 0, errInjectedTruncation
+		}
+		if int64(len(p)) > r.at-r.offset {
+			p = p[:r.at-r.offset]
+		}
+	}
+	n, err := r.ReadCloser.Read(p)
+	if r.outcome == "bitflip" && r.at >= r.offset && r.at < r.offset+int64(n) {
+		p[r.at-r.offset] ^= 1
+	}
+	r.offset += int64(n)
+	return n, err
+}
+
+func (s faultStore) StatObject(ctx context.Context, bucketName, key string, opts minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	if err := s.inject(ctx, "stat"); err != nil {
+		return minio.ObjectInfo{}, err
+	}
+	return s.ObjectStore.StatObject(ctx, bucketName, key, opts)
+}
+
+func (s faultStore) RemoveObject(ctx context.Context, bucketName, key string, opts minio.RemoveObjectOptions) error {
+	if err := s.inject(ctx, "delete"); err != nil {
+		return err
+	}
+	return s.ObjectStore.RemoveObject(ctx, bucketName, key, opts)
+}
+
+func (s faultStore) ListObjects(ctx context.Context, bucketName string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
+	if err := s.inject(ctx, "list"); err != nil {
+		objects := make(chan minio.ObjectInfo, 1)
+		objects <- minio.ObjectInfo{Err: err}
+		close(objects)
+		return objects
+	}
+	return s.ObjectStore.ListObjects(ctx, bucketName, opts)
+}
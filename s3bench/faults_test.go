@@ -0,0 +1,138 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const testBucket = "bench"
+
+// newTestStore is a store of the local files of a temporary directory, counting its requests.
+func newTestStore(t *testing.T) (*fsStore, *requestCounter) {
+	t.Helper()
+	requests := &requestCounter{}
+	store, err := newFSStore(t.TempDir(), testBucket, false, requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, requests
+}
+
+func putTestObject(t *testing.T, store ObjectStore, key string, data []byte) {
+	t.Helper()
+	if _, err := store.PutObject(context.Background(), testBucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseFaults(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		// err is part of the error message, none for a valid value.
+		err string
+	}{
+		{"put:error:0.1", ""},
+		{"get:latency:500ms:0.2", ""},
+		{"get:truncate:0.1, get:bitflip:0.1", ""},
+		{"put:lost-ack:1,put:phantom:0", ""},
+		{"put:error", "neither"},
+		{"copy:error:0.1", "unknown operation"},
+		{"get:corrupt:0.1", `"error", "latency", "truncate", "bitflip", "lost-ack" or "phantom"`},
+		{"put:truncate:0.1", `only "get"`},
+		{"put:bitflip:0.1", `only "get"`},
+		{"get:phantom:0.1", `only "put"`},
+		{"get:latency:0s:0.1", "invalid latency"},
+		{"put:error:1.5", "not within [0, 1]"},
+	} {
+		_, err := parseFaults(tc.value)
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("parseFaults(%q): %v", tc.value, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("parseFaults(%q) = %v, want an error with %q", tc.value, err, tc.err)
+		}
+	}
+}
+
+func TestFaultReaders(t *testing.T) {
+	data := bytes.Repeat([]byte{0xaa}, 1001)
+	for _, tc := range []struct {
+		outcome string
+		want    []byte
+		err     error
+	}{
+		{"truncate", data[:500], errInjectedTruncation},
+		{"bitflip", append(append(append([]byte{}, data[:500]...), 0xab), data[501:]...), nil},
+	} {
+		t.Run(tc.outcome, func(t *testing.T) {
+			store, requests := newTestStore(t)
+			putTestObject(t, store, "file-1.dat", data)
+			injector := newFaultInjector([]fault{{operation: "get", outcome: tc.outcome, probability: 1}}, 1)
+
+			object, err := injector.wrap(store).GetObject(context.Background(), testBucket, "file-1.dat", minio.GetObjectOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(object)
+			object.Close()
+			if !errors.Is(err, tc.err) {
+				t.Errorf("read error %v, want %v", err, tc.err)
+			}
+			if !bytes.Equal(got, tc.want) {
+				t.Errorf("read %d bytes differing from the %d expected", len(got), len(tc.want))
+			}
+			// The size is that of the response, the fault takes no request of its own.
+			if requests.head.Load() != 0 || requests.get.Load() != 1 {
+				t.Errorf("%d HEAD and %d GET requests, want only the GET", requests.head.Load(), requests.get.Load())
+			}
+		})
+	}
+}
+
+func TestFaultsPerWorker(t *testing.T) {
+	store, _ := newTestStore(t)
+	putTestObject(t, store, "file-1.dat", []byte("payload"))
+	faults := []fault{{operation: "stat", probability: 0.5}}
+
+	// strikes lists which of n stats of the worker failed, other workers stat'ing in between.
+	strikes := func(injector *faultInjector, worker, n, others int) []bool {
+		client := forWorker([]ObjectStore{injector.wrap(store)}, worker)[0]
+		other := forWorker([]ObjectStore{injector.wrap(store)}, worker+1)[0]
+		var hits []bool
+		for i := 0; i < n; i++ {
+			for j := 0; j < others; j++ {
+				other.StatObject(context.Background(), testBucket, "file-1.dat", minio.StatObjectOptions{})
+			}
+			_, err := client.StatObject(context.Background(), testBucket, "file-1.dat", minio.StatObjectOptions{})
+			hits = append(hits, err != nil)
+		}
+		return hits
+	}
+
+	alone, scheduled := strikes(newFaultInjector(faults, 42), 3, 64, 0), strikes(newFaultInjector(faults, 42), 3, 64, 3)
+	if !equalStrikes(alone, scheduled) {
+		t.Errorf("the faults of a worker depend on those of the others:\n%v\n%v", alone, scheduled)
+	}
+	if other := strikes(newFaultInjector(faults, 43), 3, 64, 0); equalStrikes(alone, other) {
+		t.Errorf("the faults do not depend on -seed")
+	}
+}
+
+func equalStrikes(a, b []bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formatting is how the human readable output renders the numbers, structured outputs always
+// carry them in full precision.
+type formatting struct {
+	// durationPrecision ("-duration-precision") is the number of significant digits durations
+	// are rounded to, 0 keeps them exact.
+	durationPrecision int
+	// prettyNumbers ("-pretty") groups the digits of byte counts and speeds, "1,048,576" instead
+	// of "1048576".
+	prettyNumbers bool
+}
+
+// defaultFormatting is that of a run without "-duration-precision" and "-pretty".
+var defaultFormatting = formatting{durationPrecision: 3}
+
+// currentFormatting is the formatting of the run in progress, set by runCLI from its flags for
+// as long as it runs and back to defaultFormatting after, so that nothing carries over to the
+// next run; runs in parallel share it.
+var currentFormatting = defaultFormatting
+
+const maxDurationPrecision = 19
+
+// formatDuration renders d for humans: 1.234567891s becomes 1.23s and 987.654321ms becomes
+// 988ms with the default precision.
+func formatDuration(d time.Duration) string {
+	return roundSignificant(d, currentFormatting.durationPrecision).String()
+}
+
+func roundSignificant(d time.Duration, digits int) time.Duration {
+	if digits <= 0 || d == 0 {
+		return d
+	}
+	limit := int64(1)
+	for i := 0; i < digits && limit <= int64(time.Duration(1<<63-1))/10; i++ {
+		limit *= 10
+	}
+	abs, unit := int64(d), time.Duration(1)
+	if abs < 0 {
+		abs = -abs
+	}
+	for ; abs >= limit; abs /= 10 {
+		unit *= 10
+	}
+	return d.Round(unit)
+}
+
+func validateDurationPrecision(digits int) error {
+	if digits < 0 || digits > maxDurationPrecision {
+		return fmt.Errorf(`"-duration-precision" should be between 0 and %d, got %d`, maxDurationPrecision, digits)
+	}
+	return nil
+}
+
+// formatSpeed renders a speed in MB/s for humans, with two decimals.
+func formatSpeed(mbps float64) string {
+	s := strconv.FormatFloat(mbps, 'f', 2, 64)
+	if !currentFormatting.prettyNumbers {
+		return s
+	}
+	integer, fraction, _ := strings.Cut(s, ".")
+	return groupDigits(integer) + "." + fraction
+}
+
+// formatByteCount renders an exact number of bytes for humans.
+func formatByteCount(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	if !currentFormatting.prettyNumbers {
+		return s
+	}
+	return groupDigits(s)
+}
+
+// groupDigits separates the thousands of a decimal integer with commas, regardless of the locale.
+func groupDigits(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	if len(s) <= 3 {
+		return sign + s
+	}
+	var sb strings.Builder
+	sb.WriteString(sign)
+	head := len(s) % 3
+	if head == 0 {
+		head = 3
+	}
+	sb.WriteString(s[:head])
+	for i := head; i < len(s); i += 3 {
+		sb.WriteByte(',')
+		sb.WriteString(s[i : i+3])
+	}
+	return sb.String()
+}
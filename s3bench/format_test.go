@@ -0,0 +1,123 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withFormatting sets "-duration-precision" and "-pretty" for the test.
+func withFormatting(t *testing.T, precision int, pretty bool) {
+	t.Helper()
+	t.Cleanup(func() { currentFormatting = defaultFormatting })
+	currentFormatting = formatting{durationPrecision: precision, prettyNumbers: pretty}
+}
+
+// formatTestReport is a run whose durations range from microseconds to hours.
+func formatTestReport() Report {
+	start := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	return Report{
+		Label:    "nightly",
+		Metadata: RunMetadata{Concurrency: 8, RampUp: time.Minute, PercentileMethod: percentileLinear},
+		Upload: PhaseStats{Count: 1200, AvgTime: 987654321 * time.Nanosecond, P90Time: 1234567891 * time.Nanosecond, P90Speed: 12345.6789,
+			AvgSpeed: 8765.4321, Bytes: 1258291200, Elapsed: 61*time.Minute + 2500*time.Millisecond, Start: start},
+		Download: PhaseStats{Count: 1200, AvgTime: 12345678 * time.Nanosecond, P90Time: 45678 * time.Nanosecond, P90Speed: 0.4567,
+			AvgSpeed: 0.123, Bytes: 1258291200, Elapsed: 26*time.Hour + 3*time.Minute + 4567*time.Millisecond, Start: start.Add(time.Hour)},
+		Cost:   &CostReport{Requests: RequestCounts{Put: 1200, Get: 1234567, Head: 12, Delete: 1200, UploadedBytes: 1258291200, DownloadedBytes: 1258291200}},
+		Timing: Timing{Setup: 1500 * time.Microsecond, Upload: 61*time.Minute + 2500*time.Millisecond, Download: 26*time.Hour + 3*time.Minute + 4567*time.Millisecond, Total: 27*time.Hour + 4*time.Minute + 7068*time.Millisecond},
+	}
+}
+
+func TestFormatDurationGolden(t *testing.T) {
+	durations := []time.Duration{
+		0, 1, 999, 1234567 * time.Nanosecond / 1000, 12345678 * time.Nanosecond / 1000, 987654321, 1234567891,
+		59999 * time.Millisecond, time.Hour + 2*time.Minute + 3456*time.Millisecond, 26*time.Hour + 59*time.Minute + 59*time.Second,
+		-987654321,
+	}
+	var sb strings.Builder
+	for _, precision := range []int{3, 1, 2, 5, 0} {
+		withFormatting(t, precision, false)
+		fmt.Fprintf(&sb, "precision %d:\n", precision)
+		for _, d := range durations {
+			fmt.Fprintf(&sb, "  %-22s %s\n", d.String(), formatDuration(d))
+		}
+	}
+	assertGolden(t, "durations.txt", []byte(sb.String()))
+}
+
+func TestReportGolden(t *testing.T) {
+	withFormatting(t, 3, false)
+	report := formatTestReport()
+	brief, err := newReportTemplate("brief", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "report.txt", []byte(report.String()))
+	assertGolden(t, "report-brief.txt", []byte(renderReport(brief, report)))
+	assertGolden(t, "report.md", []byte(markdownReport(report, report.String())))
+}
+
+func TestTablesGolden(t *testing.T) {
+	withFormatting(t, 3, false)
+	start := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	var sb strings.Builder
+	sb.WriteString(PerWorkerStats{
+		Upload:   []WorkerStats{{Worker: 0, Count: 600, AvgTime: 987654321, P90Time: 1234567891, Bytes: 629145600}, {Worker: 1, Count: 600, AvgTime: 12345678, P90Time: 45678, Bytes: 629145600}},
+		Download: []WorkerStats{{Worker: 0, Count: 1200, AvgTime: 12345678, P90Time: 23456789, Bytes: 1258291200}},
+	}.String())
+	sb.WriteString(PerHostStats{Upload: []HostStats{{Host: "node1:9000", Count: 600, AvgTime: 987654321, P90Time: 1234567891, Bytes: 629145600, Throughput: 12.5}}}.String())
+	sb.WriteString(PerBucketStats{Download: []BucketStats{{Bucket: "bench-1", Count: 600, AvgTime: 12345678, P90Time: 45678, Bytes: 629145600, Throughput: 1234.5}}}.String())
+	sb.WriteString(formatWindows([]WindowStats{{Phase: "upload", Start: start, Count: 42, Mean: 987654321, P90: 1234567891}}))
+	assertGolden(t, "tables.txt", []byte(sb.String()))
+}
+
+func TestNumberFormatting(t *testing.T) {
+	for _, tc := range []struct {
+		pretty    bool
+		speed     float64
+		count     int64
+		speedWant string
+		countWant string
+	}{
+		{false, 0, 0, "0.00", "0"},
+		{false, 12345.6789, 1048576, "12345.68", "1048576"},
+		{false, -1234.5, -1234567, "-1234.50", "-1234567"},
+		{true, 0, 0, "0.00", "0"},
+		{true, 999.994, 999, "999.99", "999"},
+		{true, 999.995, 1000, "1,000.00", "1,000"},
+		{true, 12345.6789, 1048576, "12,345.68", "1,048,576"},
+		{true, 123456789.5, 123456789, "123,456,789.50", "123,456,789"},
+		{true, -1234.5, -1234567, "-1,234.50", "-1,234,567"},
+	} {
+		withFormatting(t, defaultFormatting.durationPrecision, tc.pretty)
+		if got := formatSpeed(tc.speed); got != tc.speedWant {
+			t.Errorf("pretty=%t: formatSpeed(%v) = %q, want %q", tc.pretty, tc.speed, got, tc.speedWant)
+		}
+		if got := formatByteCount(tc.count); got != tc.countWant {
+			t.Errorf("pretty=%t: formatByteCount(%d) = %q, want %q", tc.pretty, tc.count, got, tc.countWant)
+		}
+	}
+}
+
+func TestPrettyReport(t *testing.T) {
+	withFormatting(t, 3, false)
+	report := formatTestReport()
+	plain, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withFormatting(t, 3, true)
+	assertGolden(t, "report-pretty.txt", []byte(report.String()))
+	// The machine readable outputs keep the exact numbers either way.
+	pretty, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(pretty) != string(plain) {
+		t.Errorf("-pretty changed the JSON report:\n%s\n%s", plain, pretty)
+	}
+}
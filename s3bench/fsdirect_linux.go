@@ -0,0 +1,31 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"os"
+	"syscall"
+)
+
+const directSupported = true
+
+// directAlignment is what O_DIRECT needs the buffers, the offsets and the lengths aligned to.
+const directAlignment = 4096
+
+func openFile(path string, flag int, direct bool) (*os.File, error) {
+	if direct {
+		flag |= syscall.O_DIRECT
+	}
+	return os.OpenFile(path, flag, 0o644)
+}
+
+// clearDirect turns O_DIRECT off, for the tail of a file which does not fill a block.
+func clearDirect(f *os.File) error {
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), syscall.F_GETFL, 0)
+	if errno != 0 {
+		return errno
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), syscall.F_SETFL, flags&^syscall.O_DIRECT); errno != 0 {
+		return errno
+	}
+	return nil
+}
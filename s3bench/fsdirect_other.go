@@ -0,0 +1,22 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+
+//go:build !linux
+
+package s3bench
+
+import (
+	"errors"
+	"os"
+)
+
+const directSupported = false
+
+const directAlignment = 4096
+
+func openFile(path string, flag int, direct bool) (*os.File, error) {
+	return os.OpenFile(path, flag, 0o644)
+}
+
+func clearDirect(f *os.File) error {
+	return errors.New(`O_DIRECT is only supported on Linux`)
+}
@@ -0,0 +1,619 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// The "-backend" object stores: S3 over the network, or the local files of a directory for a
+// baseline of what the client machine itself can push and pull.
+const (
+	backendS3 = "s3"
+	backendFS = "fs"
+)
+
+const (
+	// fsBufferSize is what a file is written and read in, a multiple of directAlignment.
+	fsBufferSize = 1024 * 1024
+	// fsMetaDir of every bucket holds the metadata of its objects, one JSON file per key.
+	fsMetaDir = ".s3bench-meta"
+	// fsTempSuffix marks the files which are still being written, renamed into place once done.
+	fsTempSuffix = ".s3bench-tmp"
+)
+
+// fsStore implements ObjectStore over the files of root: a bucket is a directory of it, an object
+// the file of its key below, "/" separating the directories. The directories it creates are
+// removed again by removeCreated, as far as they are empty by then. With direct, the files are
+// written and read with O_DIRECT, bypassing the page cache.
+type fsStore struct {
+	root     string
+	direct   bool
+	requests *requestCounter
+	buffers  sync.Pool
+	temp     atomic.Int64
+
+	mu      sync.Mutex
+	created []string
+	known   map[string]bool
+}
+
+// fsObjectMeta is what S3 would return as the headers of an object.
+type fsObjectMeta struct {
+	Header map[string]string `json:"header"`
+}
+
+func newFSStore(root, bucketName string, direct bool, requests *requestCounter) (*fsStore, error) {
+	if direct && !directSupported {
+		return nil, errors.New(`"-fs-direct" needs O_DIRECT, which is only supported on Linux`)
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	s := &fsStore{root: root, direct: direct, requests: requests, known: map[string]bool{}}
+	s.buffers.New = func() any {
+		buf := alignedBuffer(fsBufferSize)
+		return &buf
+	}
+	if err := s.mkdirAll(filepath.Join(root, bucketName)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func fsBackendWarning(root string, direct bool) string {
+	via := "the page cache"
+	if direct {
+		via = "O_DIRECT"
+	}
+	return fmt.Sprintf("WARNING: backend=fs, NOT S3: the objects are the local files under %s, written via %s", root, via)
+}
+
+// alignedBuffer is a buffer of size bytes starting at a multiple of directAlignment.
+func alignedBuffer(size int) []byte {
+	buf := make([]byte, size+directAlignment)
+	offset := 0
+	if misaligned := int(uintptr(unsafe.Pointer(&buf[0])) % directAlignment); misaligned != 0 {
+		offset = directAlignment - misaligned
+	}
+	return buf[offset : offset+size]
+}
+
+// mkdirAll creates dir and its missing parents, noting every one created.
+func (s *fsStore) mkdirAll(dir string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.known[dir] {
+		return nil
+	}
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil || d == filepath.Dir(d) {
+			break
+		}
+		missing = append(missing, d)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		s.created = append(s.created, missing[i])
+	}
+	s.known[dir] = true
+	return nil
+}
+
+// removeCreated removes the directories the store created, the deepest first, and returns
+// those which could not be removed as they still hold files, e.g. with "-keep-objects".
+func (s *fsStore) removeCreated() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var left []string
+	for i := len(s.created) - 1; i >= 0; i-- {
+		if err := os.Remove(s.created[i]); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			left = append(left, s.created[i])
+		}
+	}
+	s.created, s.known = nil, map[string]bool{}
+	return left
+}
+
+func (s *fsStore) bucketDir(bucketName string) string {
+	return filepath.Join(s.root, bucketName)
+}
+
+// paths are the file of the object and the one of its metadata; a key must not leave its bucket.
+func (s *fsStore) paths(bucketName, key string) (object, meta string, err error) {
+	dir := s.bucketDir(bucketName)
+	object = filepath.Join(dir, filepath.FromSlash(key))
+	if key == "" || !strings.HasPrefix(object, dir+string(filepath.Separator)) || strings.HasPrefix(key, fsMetaDir+"/") || strings.HasSuffix(key, fsTempSuffix) {
+		return "", "", fsError("InvalidArgument", http.StatusBadRequest, bucketName, key, fmt.Errorf(`key %q is not supported by the file system backend`, key))
+	}
+	return object, filepath.Join(dir, fsMetaDir, filepath.FromSlash(key)+".json"), nil
+}
+
+// fsError is the error S3 would respond with.
+func fsError(code string, status int, bucketName, key string, err error) error {
+	return minio.ErrorResponse{Code: code, StatusCode: status, BucketName: bucketName, Key: key, Message: err.Error()}
+}
+
+// objectError turns a failed file operation on the object into the error of S3.
+func (s *fsStore) objectError(bucketName, key string, err error) error {
+	if !errors.Is(err, fs.ErrNotExist) {
+		return fsError("InternalError", http.StatusInternalServerError, bucketName, key, err)
+	}
+	if _, statErr := os.Stat(s.bucketDir(bucketName)); statErr != nil {
+		return fsError("NoSuchBucket", http.StatusNotFound, bucketName, key, fmt.Errorf(`bucket %s does not exist`, bucketName))
+	}
+	return fsError("NoSuchKey", http.StatusNotFound, bucketName, key, fmt.Errorf(`key %s does not exist`, key))
+}
+
+func (s *fsStore) EndpointURL() *url.URL {
+	return &url.URL{Scheme: "file", Path: filepath.ToSlash(s.root)}
+}
+
+func (s *fsStore) PutObject(ctx context.Context, bucketName, key string, reader io.Reader, size int64, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	s.requests.put.Add(1)
+	object, meta, err := s.paths(bucketName, key)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+	if _, err := os.Stat(s.bucketDir(bucketName)); err != nil {
+		return minio.UploadInfo{}, s.objectError(bucketName, key, err)
+	}
+	if err := s.mkdirAll(filepath.Dir(object)); err != nil {
+		return minio.UploadInfo{}, s.objectError(bucketName, key, err)
+	}
+
+	// Like S3, a reader never sees a partial object.
+	temp := fmt.Sprintf("%s.%d%s", object, s.temp.Add(1), fsTempSuffix)
+	written, err := s.writeFile(ctx, temp, reader)
+	s.requests.uploaded.Add(written)
+	if err == nil && size >= 0 && written != size {
+		err = fsError("IncompleteBody", http.StatusBadRequest, bucketName, key, fmt.Errorf(`received %d of %d bytes`, written, size))
+	}
+	if err == nil {
+		err = os.Rename(temp, object)
+	}
+	if err != nil {
+		os.Remove(temp)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return minio.UploadInfo{}, ctxErr
+		}
+		if errors.As(err, new(minio.ErrorResponse)) {
+			return minio.UploadInfo{}, err
+		}
+		return minio.UploadInfo{}, s.objectError(bucketName, key, err)
+	}
+	if err := s.writeMeta(meta, opts); err != nil {
+		return minio.UploadInfo{}, s.objectError(bucketName, key, err)
+	}
+
+	info, err := os.Stat(object)
+	if err != nil {
+		return minio.UploadInfo{}, s.objectError(bucketName, key, err)
+	}
+	return minio.UploadInfo{Bucket: bucketName, Key: key, ETag: fsETag(info), Size: info.Size(), LastModified: info.ModTime()}, nil
+}
+
+// writeFile writes the whole reader to the file in aligned chunks; with O_DIRECT, the tail
+// which does not fill a block is written through the page cache.
+func (s *fsStore) writeFile(ctx context.Context, path string, reader io.Reader) (int64, error) {
+	f, err := openFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, s.direct)
+	if err != nil {
+		return 0, err
+	}
+	buf := s.buffers.Get().(*[]byte)
+	defer s.buffers.Put(buf)
+
+	var written int64
+	for {
+		if err := ctx.Err(); err != nil {
+			f.Close()
+			return written, err
+		}
+		n, readErr := io.ReadFull(reader, *buf)
+		chunk := (*buf)[:n]
+		if s.direct && n%directAlignment != 0 {
+			aligned := n - n%directAlignment
+			if _, err := f.Write(chunk[:aligned]); err != nil {
+				f.Close()
+				return written, err
+			}
+			written += int64(aligned)
+			if err := clearDirect(f); err != nil {
+				f.Close()
+				return written, err
+			}
+			chunk = chunk[aligned:]
+		}
+		if _, err := f.Write(chunk); err != nil {
+			f.Close()
+			return written, err
+		}
+		written += int64(len(chunk))
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			f.Close()
+			return written, readErr
+		}
+	}
+	return written, f.Close()
+}
+
+// writeMeta stores the headers of the object, the user metadata prefixed as S3 returns it.
+func (s *fsStore) writeMeta(path string, opts minio.PutObjectOptions) error {
+	header := map[string]string{}
+	for k, v := range opts.UserMetadata {
+		if strings.HasPrefix(strings.ToLower(k), "x-amz-") {
+			header[http.CanonicalHeaderKey(k)] = v
+		} else {
+			header[http.CanonicalHeaderKey("X-Amz-Meta-"+k)] = v
+		}
+	}
+	if opts.ContentEncoding != "" {
+		header["Content-Encoding"] = opts.ContentEncoding
+	}
+	if opts.ContentType != "" {
+		header["Content-Type"] = opts.ContentType
+	}
+	if len(header) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	content, err := json.Marshal(fsObjectMeta{Header: header})
+	if err != nil {
+		return err
+	}
+	if err := s.mkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0o644)
+}
+
+// fsETag stands in for the ETag, telling the versions of an object apart without hashing it.
+func fsETag(info fs.FileInfo) string {
+	return fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size())
+}
+
+func (s *fsStore) GetObject(ctx context.Context, bucketName, key string, opts minio.GetObjectOptions) (io.ReadCloser, error) {
+	s.requests.get.Add(1)
+	object, _, err := s.paths(bucketName, key)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Header().Get("Range") != "" {
+		return nil, fsError("NotImplemented", http.StatusNotImplemented, bucketName, key, errors.New(`ranged reads are not supported by the file system backend`))
+	}
+	f, err := openFile(object, os.O_RDONLY, s.direct)
+	if err != nil {
+		return nil, s.objectError(bucketName, key, err)
+	}
+	return &fsObject{ctx: ctx, store: s, bucketName: bucketName, key: key, f: f, buf: s.buffers.Get().(*[]byte)}, nil
+}
+
+// fsObject reads the file in aligned chunks, as O_DIRECT requires, whatever the buffers of its
+// reader.
+type fsObject struct {
+	ctx             context.Context
+	store           *fsStore
+	bucketName, key string
+	f               *os.File
+	buf             *[]byte
+	pending         []byte
+	eof             bool
+}
+
+func (o *fsObject) fill() error {
+	if err := o.ctx.Err(); err != nil {
+		return err
+	}
+	n, err := o.f.Read(*o.buf)
+	o.pending = (*o.buf)[:n]
+	o.store.requests.downloaded.Add(int64(n))
+	if err == io.EOF || err == nil && n == 0 {
+		o.eof = true
+		return nil
+	}
+	return err
+}
+
+func (o *fsObject) Read(p []byte) (int, error) {
+	for len(o.pending) == 0 {
+		if o.eof {
+			return 0, io.EOF
+		}
+		if err := o.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, o.pending)
+	o.pending = o.pending[n:]
+	return n, nil
+}
+
+// WriteTo spares io.Copy the copies into its own buffers.
+func (o *fsObject) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for {
+		if len(o.pending) > 0 {
+			n, err := w.Write(o.pending)
+			total += int64(n)
+			o.pending = o.pending[n:]
+			if err != nil {
+				return total, err
+			}
+		}
+		if o.eof {
+			return total, nil
+		}
+		if err := o.fill(); err != nil {
+			return total, err
+		}
+	}
+}
+
+// Stat offers the headers of the object, as minio.Object does.
+func (o *fsObject) Stat() (minio.ObjectInfo, error) {
+	return o.store.stat(o.bucketName, o.key)
+}
+
+func (o *fsObject) Close() error {
+	if o.buf != nil {
+		o.store.buffers.Put(o.buf)
+		o.buf, o.pending = nil, nil
+	}
+	return o.f.Close()
+}
+
+func (s *fsStore) StatObject(ctx context.Context, bucketName, key string, opts minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	s.requests.head.Add(1)
+	return s.stat(bucketName, key)
+}
+
+func (s *fsStore) stat(bucketName, key string) (minio.ObjectInfo, error) {
+	object, meta, err := s.paths(bucketName, key)
+	if err != nil {
+		return minio.ObjectInfo{}, err
+	}
+	info, err := os.Stat(object)
+	if err == nil && info.IsDir() {
+		err = fs.ErrNotExist
+	}
+	if err != nil {
+		return minio.ObjectInfo{}, s.objectError(bucketName, key, err)
+	}
+	result := minio.ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime(), ETag: fsETag(info), Metadata: http.Header{}, UserMetadata: minio.StringMap{}}
+	content, err := os.ReadFile(meta)
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	}
+	var stored fsObjectMeta
+	if err == nil {
+		err = json.Unmarshal(content, &stored)
+	}
+	if err != nil {
+		return minio.ObjectInfo{}, s.objectError(bucketName, key, err)
+	}
+	for k, v := range stored.Header {
+		result.Metadata.Set(k, v)
+		if name, ok := strings.CutPrefix(k, "X-Amz-Meta-"); ok {
+			result.UserMetadata[name] = v
+		}
+	}
+	result.ContentType = stored.Header["Content-Type"]
+	return result, nil
+}
+
+func (s *fsStore) RemoveObject(ctx context.Context, bucketName, key string, opts minio.RemoveObjectOptions) error {
+	s.requests.delete.Add(1)
+	object, meta, err := s.paths(bucketName, key)
+	if err != nil {
+		return err
+	}
+	// Like S3, removing a missing key succeeds.
+	for _, path := range []string{object, meta} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return s.objectError(bucketName, key, err)
+		}
+	}
+	// The directories of the key go along once empty, as its prefixes do in S3.
+	dir := s.bucketDir(bucketName)
+	for _, path := range []string{object, meta} {
+		for d := filepath.Dir(path); d != dir && d != filepath.Join(dir, fsMetaDir); d = filepath.Dir(d) {
+			if os.Remove(d) != nil {
+				break
+			}
+			s.mu.Lock()
+			delete(s.known, d)
+			s.mu.Unlock()
+		}
+	}
+	return nil
+}
+
+func (s *fsStore) CopyObject(ctx context.Context, dst minio.CopyDestOptions, src minio.CopySrcOptions) (minio.UploadInfo, error) {
+	info, err := s.stat(src.Bucket, src.Object)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+	object, err := s.GetObject(ctx, src.Bucket, src.Object, minio.GetObjectOptions{})
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+	defer object.Close()
+	opts := minio.PutObjectOptions{UserMetadata: map[string]string{}, ContentEncoding: info.Metadata.Get("Content-Encoding"), ContentType: info.ContentType}
+	for k, v := range info.Metadata {
+		if strings.HasPrefix(k, "X-Amz-") && !strings.HasPrefix(k, "X-Amz-Meta-") {
+			opts.UserMetadata[k] = v[0]
+		}
+	}
+	for k, v := range info.UserMetadata {
+		opts.UserMetadata[k] = v
+	}
+	if dst.ReplaceMetadata {
+		opts.UserMetadata = dst.UserMetadata
+	}
+	return s.PutObject(ctx, dst.Bucket, dst.Object, object, info.Size, opts)
+}
+
+// ListObjects lists the keys in order, the prefixes of the directories below the one of the
+// prefix folded into common prefixes unless recursive.
+func (s *fsStore) ListObjects(ctx context.Context, bucketName string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
+	s.requests.list.Add(1)
+	objects := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(objects)
+		listed, err := s.list(bucketName, opts)
+		if err != nil {
+			listed = []minio.ObjectInfo{{Err: err}}
+		}
+		for _, object := range listed {
+			select {
+			case objects <- object:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return objects
+}
+
+func (s *fsStore) list(bucketName string, opts minio.ListObjectsOptions) ([]minio.ObjectInfo, error) {
+	dir := s.bucketDir(bucketName)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fsError("NoSuchBucket", http.StatusNotFound, bucketName, "", fmt.Errorf(`bucket %s does not exist`, bucketName))
+	}
+	var listed []minio.ObjectInfo
+	prefixes := map[string]bool{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// Removed by a concurrent worker.
+				return nil
+			}
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		key := filepath.ToSlash(rel)
+		if entry.IsDir() {
+			key += "/"
+			// Only the directories along the prefix and below it can hold its keys.
+			if key == fsMetaDir+"/" || !strings.HasPrefix(key, opts.Prefix) && !strings.HasPrefix(opts.Prefix, key) {
+				return filepath.SkipDir
+			}
+			// The walk only gets below the prefix through its first directory there.
+			if !opts.Recursive && strings.HasPrefix(key, opts.Prefix) && key != opts.Prefix {
+				prefixes[key] = true
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(key, opts.Prefix) || strings.HasSuffix(key, fsTempSuffix) || key <= opts.StartAfter {
+			return nil
+		}
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		listed = append(listed, minio.ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime(), ETag: fsETag(info)})
+		return nil
+	})
+	if err != nil {
+		return nil, fsError("InternalError", http.StatusInternalServerError, bucketName, "", err)
+	}
+	for prefix := range prefixes {
+		if prefix > opts.StartAfter {
+			listed = append(listed, minio.ObjectInfo{Key: prefix})
+		}
+	}
+	sort.Slice(listed, func(i, j int) bool { return listed[i].Key < listed[j].Key })
+	return listed, nil
+}
+
+func (s *fsStore) BucketExists(ctx context.Context, bucketName string) (bool, error) {
+	s.requests.head.Add(1)
+	info, err := os.Stat(s.bucketDir(bucketName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fsError("InternalError", http.StatusInternalServerError, bucketName, "", err)
+	}
+	return info.IsDir(), nil
+}
+
+func (s *fsStore) MakeBucket(ctx context.Context, bucketName string, opts minio.MakeBucketOptions) error {
+	s.requests.put.Add(1)
+	dir := s.bucketDir(bucketName)
+	if _, err := os.Stat(dir); err == nil {
+		return fsError("BucketAlreadyOwnedByYou", http.StatusConflict, bucketName, "", fmt.Errorf(`bucket %s already exists`, bucketName))
+	}
+	if err := s.mkdirAll(dir); err != nil {
+		return fsError("InternalError", http.StatusInternalServerError, bucketName, "", err)
+	}
+	return nil
+}
+
+func (s *fsStore) RemoveBucket(ctx context.Context, bucketName string) error {
+	s.requests.delete.Add(1)
+	dir := s.bucketDir(bucketName)
+	os.Remove(filepath.Join(dir, fsMetaDir))
+	if err := os.Remove(dir); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fsError("NoSuchBucket", http.StatusNotFound, bucketName, "", fmt.Errorf(`bucket %s does not exist`, bucketName))
+		}
+		return fsError("BucketNotEmpty", http.StatusConflict, bucketName, "", err)
+	}
+	return nil
+}
+
+// A file is written at once, there are no multipart uploads.
+func (s *fsStore) ListIncompleteUploads(ctx context.Context, bucketName, objectPrefix string, recursive bool) <-chan minio.ObjectMultipartInfo {
+	uploads := make(chan minio.ObjectMultipartInfo)
+	close(uploads)
+	return uploads
+}
+
+func (s *fsStore) RemoveIncompleteUpload(ctx context.Context, bucketName, key string) error {
+	return nil
+}
+
+func (s *fsStore) ListObjectParts(ctx context.Context, bucketName, key, uploadID string, partNumberMarker, maxParts int) (minio.ListObjectPartsResult, error) {
+	return minio.ListObjectPartsResult{}, fsError("NoSuchUpload", http.StatusNotFound, bucketName, key, fmt.Errorf(`upload %s does not exist`, uploadID))
+}
+
+func (s *fsStore) AbortMultipartUpload(ctx context.Context, bucketName, key, uploadID string) error {
+	return fsError("NoSuchUpload", http.StatusNotFound, bucketName, key, fmt.Errorf(`upload %s does not exist`, uploadID))
+}
+
+func (s *fsStore) PresignedGetObject(ctx context.Context, bucketName, key string, expires time.Duration, params url.Values) (*url.URL, error) {
+	return nil, errors.New(`presigned URLs are not supported by the file system backend`)
+}
@@ -0,0 +1,103 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	// quiesceInterval is the pause between the latency probes of "-wait-for-quiesce".
+	quiesceInterval = time.Second
+	// quiesceSamples consecutive probes within quiesceTolerance of each other count as settled.
+	quiesceSamples   = 3
+	quiesceTolerance = 0.1
+)
+
+// GapStats is the pause between the uploads and the downloads: the fixed "-phase-gap" and
+// the wait for the server to settle with "-wait-for-quiesce".
+type GapStats struct {
+	Fixed    time.Duration `json:"fixed"`
+	Quiesce  time.Duration `json:"quiesce,omitempty"`
+	Quiesced bool          `json:"quiesced,omitempty"`
+	Probes   int           `json:"probes,omitempty"`
+	Total    time.Duration `json:"total"`
+}
+
+func (g GapStats) String() string {
+	s := fmt.Sprintf(" Gap         : total=%s fixed=%s", formatDuration(g.Total), formatDuration(g.Fixed))
+	switch {
+	case g.Probes == 0:
+	case g.Quiesced:
+		s += fmt.Sprintf(" quiesced after %s (%d probes)", formatDuration(g.Quiesce), g.Probes)
+	default:
+		s += fmt.Sprintf(" WARNING: not quiesced within %s (%d probes)", formatDuration(g.Quiesce), g.Probes)
+	}
+	return s + "\n"
+}
+
+// gap waits before the downloads, so that background work triggered by the uploads (healing,
+// replication) does not depress the download numbers.
+func (r runner) gap() *GapStats {
+	start := time.Now()
+	g := &GapStats{Fixed: r.phaseGap}
+	if r.phaseGap > 0 {
+		fmt.Fprintf(r.progress, " - Pausing for %v\n", r.phaseGap)
+		time.Sleep(r.phaseGap)
+	}
+	if r.quiesceTimeout > 0 {
+		quiesceStart := time.Now()
+		g.Probes, g.Quiesced = r.waitForQuiesce()
+		g.Quiesce = time.Since(quiesceStart)
+	}
+	g.Total = time.Since(start)
+	return g
+}
+
+// waitForQuiesce probes the StatObject latency of an uploaded object until it stabilizes or
+// "-quiesce-timeout" passes.
+func (r runner) waitForQuiesce() (probes int, quiesced bool) {
+	var (
+		deadline  = time.Now().Add(r.quiesceTimeout)
+		latencies []time.Duration
+	)
+	for {
+		start := time.Now()
+		_, err := r.client.StatObject(context.Background(), r.bucket(r.storedTrial(1)), r.key(r.storedTrial(1)), minio.StatObjectOptions{})
+		latency := time.Since(start)
+		probes++
+		if err != nil {
+			log.Printf(`WARNING: quiesce probe of %s failed: %v`, r.key(r.storedTrial(1)), err)
+			latencies = latencies[:0]
+		} else {
+			fmt.Fprintf(r.progress, " - Probe: %d,\tstat.time=%s\n", probes, formatDuration(latency))
+			if latencies = append(latencies, latency); len(latencies) > quiesceSamples {
+				latencies = latencies[1:]
+			}
+			if len(latencies) == quiesceSamples && settled(latencies) {
+				return probes, true
+			}
+		}
+		if time.Until(deadline) < quiesceInterval {
+			return probes, false
+		}
+		time.Sleep(quiesceInterval)
+	}
+}
+
+func settled(latencies []time.Duration) bool {
+	lowest, highest := latencies[0], latencies[0]
+	for _, l := range latencies {
+		if l < lowest {
+			lowest = l
+		}
+		if l > highest {
+			highest = l
+		}
+	}
+	return float64(highest) <= float64(lowest)*(1+quiesceTolerance)
+}
@@ -0,0 +1,82 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// standardGetQuery are the parameters of a GET besides the "x-" ones which minio-go sends; it
+// silently drops any other.
+var standardGetQuery = map[string]bool{
+	"partNumber":                   true,
+	"versionId":                    true,
+	"response-cache-control":       true,
+	"response-content-disposition": true,
+	"response-content-encoding":    true,
+	"response-content-language":    true,
+	"response-content-type":        true,
+	"response-expires":             true,
+}
+
+// getQueryParam is a "-get-query" parameter, e.g. of a transformation done by a gateway.
+type getQueryParam struct {
+	name, value string
+}
+
+type getQueryFlags []getQueryParam
+
+func (q *getQueryFlags) String() string {
+	params := make([]string, 0, len(*q))
+	for _, p := range *q {
+		params = append(params, p.name+"="+p.value)
+	}
+	return strings.Join(params, ",")
+}
+
+func (q *getQueryFlags) Set(value string) error {
+	name, paramValue, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf(`%q is not of the "param=value" form`, value)
+	}
+	if !strings.HasPrefix(name, "x-") && !standardGetQuery[name] {
+		return fmt.Errorf(`%q: only parameters starting with "x-" and the standard ones of a GET, e.g. "response-content-type", get sent`, name)
+	}
+	*q = append(*q, getQueryParam{name: name, value: paramValue})
+	return nil
+}
+
+func (q getQueryFlags) apply(opts *minio.GetObjectOptions) {
+	for _, p := range q {
+		opts.AddReqParam(p.name, p.value)
+	}
+}
+
+// TransformedGET compares the downloads to a pass over the same keys with the "-get-query"
+// parameters attached, which the server transforms the objects by. The deltas are in percent of
+// the raw downloads; the sizes of the transformed objects are only checked to be non-zero.
+type TransformedGET struct {
+	Query  []string    `json:"query"`
+	Stats  PhaseStats  `json:"stats"`
+	Deltas PhaseDeltas `json:"deltas"`
+}
+
+func newTransformedGET(query getQueryFlags, raw, transformed PhaseStats) *TransformedGET {
+	params := make([]string, 0, len(query))
+	for _, p := range query {
+		params = append(params, p.name+"="+p.value)
+	}
+	return &TransformedGET{Query: params, Stats: transformed, Deltas: comparePhases(raw, transformed)}
+}
+
+func (t TransformedGET) String(raw PhaseStats) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, " Transformed : the downloads with ?%s (n=%d, %s)\n", strings.Join(t.Query, "&"), t.Stats.Count, formatBytes(t.Stats.Bytes))
+	fmt.Fprintf(&sb, " %-20s %14s %14s %9s\n", "", "raw", "transformed", "delta")
+	writeTimeRow(&sb, "  avg time", raw.AvgTime, t.Stats.AvgTime, t.Deltas.AvgTime, t.Deltas.meaningful("avg_time"))
+	writeTimeRow(&sb, "  P90 time", raw.P90Time, t.Stats.P90Time, t.Deltas.P90Time, t.Deltas.meaningful("p90_time"))
+	writeSpeedRow(&sb, "  avg speed", raw.AvgSpeed, t.Stats.AvgSpeed, t.Deltas.AvgSpeed, t.Deltas.meaningful("avg_speed"))
+	return sb.String()
+}
@@ -0,0 +1,169 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// GrowthReport is the outcome of "-growth-benchmark": the same key uploaded again and again, a
+// step larger each time. The payload of every step extends the one of the step before, as it is
+// the same deterministic stream cut at a later offset.
+type GrowthReport struct {
+	Label     string           `json:"label,omitempty"`
+	RunID     string           `json:"run_id"`
+	Key       string           `json:"key"`
+	Seed      int64            `json:"seed"`
+	Step      int64            `json:"step"`
+	Points    []GrowthPoint    `json:"points"`
+	Failed    int              `json:"failed"`
+	Fit       LatencyFit       `json:"fit"`
+	Verified  *bool            `json:"verified,omitempty"`
+	Elapsed   time.Duration    `json:"elapsed"`
+	Resources *ClientResources `json:"client_resources,omitempty"`
+	Config    *EffectiveConfig `json:"config,omitempty"`
+}
+
+// GrowthPoint is a single upload of the growing object. FittedSpeed is the speed the fit predicts
+// for its size.
+type GrowthPoint struct {
+	Size        int64         `json:"size"`
+	Time        time.Duration `json:"time"`
+	Speed       float64       `json:"speed"`
+	FittedSpeed float64       `json:"fitted_speed"`
+	Error       string        `json:"error,omitempty"`
+}
+
+func (g GrowthReport) Passed() bool {
+	return g.Failed == 0 && (g.Verified == nil || *g.Verified)
+}
+
+func (g GrowthReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, " Growth      : %s grew by %s in %d steps to %s, elapsed=%s\n",
+		g.Key, formatBytes(g.Step), len(g.Points), formatBytes(g.Step*int64(len(g.Points))), formatDuration(g.Elapsed))
+	if g.Fit.Throughput > 0 {
+		fmt.Fprintf(&sb, " Fit         : %s\n", g.Fit)
+	} else {
+		sb.WriteString(" Fit         : none, the upload times did not grow with the size\n")
+	}
+	fmt.Fprintf(&sb, " %12s %12s %14s %14s\n", "size", "time", "speed (MB/s)", "fitted (MB/s)")
+	for _, p := range g.Points {
+		if p.Error != "" {
+			fmt.Fprintf(&sb, " %12s %12s %s\n", formatBytes(p.Size), "FAILED", p.Error)
+			continue
+		}
+		fmt.Fprintf(&sb, " %12s %12s %14s %14s\n", formatBytes(p.Size), formatDuration(p.Time), formatSpeed(p.Speed), formatSpeed(p.FittedSpeed))
+	}
+	if g.Verified != nil {
+		verdict := "matched"
+		if !*g.Verified {
+			verdict = "did NOT match"
+		}
+		fmt.Fprintf(&sb, " Verified    : the final object %s its payload\n", verdict)
+	}
+	if g.Resources != nil {
+		sb.WriteString(g.Resources.String())
+	}
+	return sb.String()
+}
+
+// growth runs "-growth-benchmark". Every upload streams its payload, so the memory does not grow
+// with the object.
+func (r runner) growth(step int64, steps int, verify bool) GrowthReport {
+	seed := r.seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	report := GrowthReport{Label: r.label, RunID: r.runID, Key: r.key(1), Seed: seed, Step: step, Config: r.config}
+
+	fmt.Fprintf(r.progress, "Growth:\n")
+	sampler := startResourceSampler()
+	start := time.Now()
+	for i := 1; i <= steps; i++ {
+		p := GrowthPoint{Size: step * int64(i)}
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if r.uploadTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, r.uploadTimeout)
+		}
+		uploadStart := time.Now()
+		_, err := r.client.PutObject(ctx, r.bucketName, report.Key, newPayloadReader(seed, 1, 0, p.Size), p.Size, minio.PutObjectOptions{
+			ServerSideEncryption: r.sse,
+			UserMetadata:         r.uploadMetadata(),
+		})
+		p.Time = time.Since(uploadStart)
+		cancel()
+		if err != nil {
+			p.Error = err.Error()
+			report.Failed++
+			fmt.Fprintf(r.progress, " - Step: %d,\tsize=%s, FAILED: %v\n", i, formatBytes(p.Size), err)
+		} else {
+			p.Speed = transferSpeed(p.Size, p.Time)
+			fmt.Fprintf(r.progress, " - Step: %d,\tsize=%s, time=%s, speed=%s MB/s\n", i, formatBytes(p.Size), formatDuration(p.Time), formatSpeed(p.Speed))
+		}
+		report.Points = append(report.Points, p)
+	}
+	report.Elapsed = time.Since(start)
+	report.Resources = sampler.Stop()
+	report.Fit = fitGrowth(report.Points)
+
+	if verify {
+		if last := report.Points[len(report.Points)-1]; last.Error == "" {
+			fmt.Fprintf(r.progress, "Verifying %s:\n", report.Key)
+			matched := r.verifyGrowth(report.Key, seed, last.Size)
+			report.Verified = &matched
+		} else {
+			fmt.Fprintf(r.progress, "Not verifying %s, its last upload failed\n", report.Key)
+		}
+	}
+	if !r.keepObjects {
+		r.removeKeys(r.bucketName, []string{report.Key})
+	}
+	return report
+}
+
+// fitGrowth fits the successful points and fills in the speed the fit predicts for each of them.
+func fitGrowth(points []GrowthPoint) LatencyFit {
+	var measured []latencyPoint
+	for _, p := range points {
+		if p.Error == "" {
+			measured = append(measured, latencyPoint{size: p.Size, duration: p.Time})
+		}
+	}
+	fit, _ := fitLatency(measured)
+	for i := range points {
+		if points[i].Error == "" {
+			points[i].FittedSpeed = transferSpeed(points[i].Size, fit.predict(points[i].Size))
+		}
+	}
+	return fit
+}
+
+// verifyGrowth downloads the final object and compares its digest to the one of its payload,
+// both streamed.
+func (r runner) verifyGrowth(key string, seed int64, size int64) bool {
+	object, err := r.client.GetObject(context.Background(), r.bucketName, key, minio.GetObjectOptions{})
+	if err != nil {
+		r.fatalf(`Unable to download %s from %s, %v`, key, r.bucketName, err)
+		return false
+	}
+	defer object.Close()
+	got, want := sha256.New(), sha256.New()
+	received, err := io.Copy(got, object)
+	if err != nil {
+		r.fatalf(`Unable to receive %s from %s, %v`, key, r.bucketName, err)
+		return false
+	}
+	io.Copy(want, newPayloadReader(seed, 1, 0, size))
+	matched := received == size && string(got.Sum(nil)) == string(want.Sum(nil))
+	if !matched {
+		fmt.Fprintf(r.progress, " - %s: received %s of %s, the content does not match its payload\n", key, formatBytes(received), formatBytes(size))
+	}
+	return matched
+}
@@ -0,0 +1,88 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// prefixProbeKeys bounds the objects looked at under the prefix before a run writes to it.
+const prefixProbeKeys = 10
+
+// benchmarkKeyPattern matches the keys the benchmark writes under its prefix, those of "-runs",
+// "-compare-sse", "-key-depth" and the permission probes included.
+var benchmarkKeyPattern = regexp.MustCompile(`^((run-\d+|plain|sse)/)?((\d+/)*file-\d+\.dat|s3bench-preflight-[0-9a-f]+)$`)
+
+// checkPrefixes refuses to write to a prefix holding foreign objects unless force, the download
+// location of "-download-bucket" included when the uploads are copied there.
+func (r runner) checkPrefixes(force bool) error {
+	if force {
+		return nil
+	}
+	locations := []runner{r}
+	if r.readFrom != nil && r.readFrom.via == stagingViaCopy {
+		locations = append(locations, r.reader())
+	}
+	for _, location := range locations {
+		foreign, err := location.foreignObjects()
+		if err != nil {
+			return fmt.Errorf(`Unable to check the prefix for foreign objects: %w`, err)
+		}
+		if len(foreign) > 0 {
+			return fmt.Errorf(`Refusing to write to prefix %q which holds objects the benchmark did not create: %s (at most %d are looked at); pass "-force" to run anyway`,
+				location.prefix, strings.Join(foreign, ", "), prefixProbeKeys)
+		}
+	}
+	return nil
+}
+
+// foreignObjects lists up to prefixProbeKeys objects under the prefix of every bucket and returns
+// those which the benchmark did not leave there: keys it does not write or objects without the
+// run ID metadata of any run. Uploads would overwrite them, which is refused without "-force".
+func (r runner) foreignObjects() ([]string, error) {
+	var foreign []string
+	buckets := r.buckets
+	if len(buckets) == 0 {
+		buckets = []string{r.bucketName}
+	}
+	for _, bucket := range buckets {
+		keys, err := r.probePrefix(bucket)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if !benchmarkKeyPattern.MatchString(strings.TrimPrefix(key, r.prefix)) {
+				foreign = append(foreign, bucket+"/"+key)
+				continue
+			}
+			info, err := r.client.StatObject(context.Background(), bucket, key, minio.StatObjectOptions{})
+			if err != nil {
+				return nil, fmt.Errorf(`unable to stat %s in %s, %w`, key, bucket, err)
+			}
+			if info.Metadata.Get("X-Amz-Meta-"+metaRunID) == "" {
+				foreign = append(foreign, bucket+"/"+key)
+			}
+		}
+	}
+	return foreign, nil
+}
+
+func (r runner) probePrefix(bucket string) ([]string, error) {
+	// Stops the listing after the first page.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var keys []string
+	for object := range r.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: r.prefix, Recursive: true, MaxKeys: prefixProbeKeys}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf(`unable to list %s in %s, %w`, r.prefix, bucket, object.Err)
+		}
+		if keys = append(keys, object.Key); len(keys) == prefixProbeKeys {
+			break
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,88 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestCheckPrefixes(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// previous leaves the objects of a previous run under the prefix, foreign are put along.
+		previous bool
+		foreign  []string
+		force    bool
+		// refused lists what the refusal names, nil for none.
+		refused []string
+	}{
+		{"empty prefix", false, nil, false, nil},
+		{"objects of a previous run", true, nil, false, nil},
+		{"foreign key", true, []string{"run/notes.txt"}, false, []string{"bench/run/notes.txt"}},
+		// A key the benchmark writes, without the metadata it writes.
+		{"foreign object", true, []string{"run/file-7.dat"}, false, []string{"bench/run/file-7.dat"}},
+		{"foreign key forced", true, []string{"run/notes.txt"}, true, nil},
+		// Another prefix is none of the business of the run.
+		{"outside the prefix", true, []string{"other/notes.txt", "runs/notes.txt"}, false, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store, requests := newTestStore(t)
+			if tc.previous {
+				previous := newTestRunner(t, store, requests, 3, 100)
+				previous.prefix, previous.keepObjects = "run/", true
+				previous.metadata = objectMetadata("previous", "", time.Now())
+				previous.run()
+			}
+			for _, key := range tc.foreign {
+				putTestObject(t, store, key, []byte("someone's data"))
+			}
+
+			r := newTestRunner(t, store, requests, 3, 100)
+			r.prefix = "run/"
+			err := r.checkPrefixes(tc.force)
+			if tc.refused == nil {
+				if err != nil {
+					t.Errorf("refused: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "Refusing") {
+				t.Fatalf("not refused: %v", err)
+			}
+			for _, key := range tc.refused {
+				if !strings.Contains(err.Error(), key) {
+					t.Errorf("the refusal does not name %s: %v", key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckPrefixesBounded(t *testing.T) {
+	store, requests := newTestStore(t)
+	for i := 0; i < 3*prefixProbeKeys; i++ {
+		putTestObject(t, store, fmt.Sprintf("run/file-%d.dat", i), []byte("x"))
+	}
+	r := newTestRunner(t, store, requests, 3, 100)
+	r.prefix = "run/"
+	heads := requests.head.Load()
+	if err := r.checkPrefixes(false); err == nil {
+		t.Fatal("not refused")
+	}
+	if stats := requests.head.Load() - heads; stats > prefixProbeKeys {
+		t.Errorf("%d objects looked at, want at most %d", stats, prefixProbeKeys)
+	}
+
+	// Nothing stored by the benchmark under the prefix, and the benchmark runs on.
+	for i := 0; i < 3*prefixProbeKeys; i++ {
+		store.RemoveObject(context.Background(), testBucket, fmt.Sprintf("run/file-%d.dat", i), minio.RemoveObjectOptions{})
+	}
+	if err := r.checkPrefixes(false); err != nil {
+		t.Errorf("refused an emptied prefix: %v", err)
+	}
+}
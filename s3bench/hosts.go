@@ -0,0 +1,112 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PerHostStats breaks the phases down by the node ("-hosts") the trials went to.
+type PerHostStats struct {
+	Upload   []HostStats `json:"upload"`
+	Download []HostStats `json:"download"`
+}
+
+type HostStats struct {
+	Host       string        `json:"host"`
+	Count      int           `json:"count"`
+	AvgTime    time.Duration `json:"avg_time"`
+	P90Time    time.Duration `json:"p90_time"`
+	Bytes      int64         `json:"bytes"`
+	Throughput float64       `json:"throughput"`
+}
+
+type hostRecorder struct {
+	times                sampleSet
+	bytes                int64
+	windowStart, lastEnd time.Time
+}
+
+func (h *hostRecorder) record(s sample) {
+	h.times.add(float64(s.duration))
+	h.bytes += s.bytes
+	if h.windowStart.IsZero() || s.start.Before(h.windowStart) {
+		h.windowStart = s.start
+	}
+	if end := s.start.Add(s.duration); end.After(h.lastEnd) {
+		h.lastEnd = end
+	}
+}
+
+// hostStats returns the per-host breakdown ordered by host, or nil when not tracked.
+func (p *phaseRecorder) hostStats() []HostStats {
+	if p.hosts == nil {
+		return nil
+	}
+	stats := make([]HostStats, 0, len(p.hosts))
+	for host, h := range p.hosts {
+		stats = append(stats, HostStats{
+			Host:       host,
+			Count:      h.times.count(),
+			AvgTime:    time.Duration(h.times.mean()),
+			P90Time:    time.Duration(h.times.percentile(0.9)),
+			Bytes:      h.bytes,
+			Throughput: float64(h.bytes) / h.lastEnd.Sub(h.windowStart).Seconds() / 1024 / 1024, // MB/s
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Host < stats[j].Host })
+	return stats
+}
+
+func (p PerHostStats) String() string {
+	var sb strings.Builder
+	sb.WriteString(" Hosts       :\n")
+	fmt.Fprintf(&sb, "  %-9s %-24s %7s %14s %14s %14s\n", "phase", "host", "ops", "mean", "p90", "throughput")
+	for _, phase := range []struct {
+		name  string
+		stats []HostStats
+	}{{"upload", p.Upload}, {"download", p.Download}} {
+		var fastest, slowest HostStats
+		for i, h := range phase.stats {
+			fmt.Fprintf(&sb, "  %-9s %-24s %7d %14v %14v %9s MB/s\n", phase.name, h.Host, h.Count, formatDuration(h.AvgTime), formatDuration(h.P90Time), formatSpeed(h.Throughput))
+			if i == 0 || h.AvgTime < fastest.AvgTime {
+				fastest = h
+			}
+			if i == 0 || h.AvgTime > slowest.AvgTime {
+				slowest = h
+			}
+		}
+		if fastest.AvgTime > 0 && float64(slowest.AvgTime) > workerSpreadFactor*float64(fastest.AvgTime) {
+			fmt.Fprintf(&sb, "  NOTE: %s host %s is %.1fx slower on average than %s\n",
+				phase.name, slowest.Host, float64(slowest.AvgTime)/float64(fastest.AvgTime), fastest.Host)
+		}
+	}
+	return sb.String()
+}
+
+// clientsFor returns the worker's own clients with "-shared-client=false". Otherwise workers
+// are assigned round-robin to the shared clients of the hosts.
+func (r runner) clientsFor(worker int) []ObjectStore {
+	if r.workerClients != nil {
+		return forWorker(r.workerClients[worker-1], worker)
+	}
+	if len(r.hosts) == 0 {
+		return forWorker([]ObjectStore{r.client}, worker)
+	}
+	return forWorker([]ObjectStore{r.hosts[(worker-1)%len(r.hosts)]}, worker)
+}
+
+func parseHosts(value string) ([]string, error) {
+	var hosts []string
+	for _, host := range strings.Split(value, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf(`no hosts in %q`, value)
+	}
+	return hosts, nil
+}
@@ -0,0 +1,150 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+// incompleteUploadParts is the page size of listing the parts of an incomplete upload.
+const incompleteUploadParts = 1000
+
+// IncompleteUpload is a multipart upload which was initiated and then neither completed nor
+// aborted, e.g. by a crashed run. Its parts take up space without showing up as an object.
+type IncompleteUpload struct {
+	Bucket    string    `json:"bucket"`
+	Key       string    `json:"key"`
+	UploadID  string    `json:"upload_id"`
+	Initiated time.Time `json:"initiated"`
+	Parts     int       `json:"parts"`
+	Size      int64     `json:"size"`
+	// Kept uploads are younger than "-older-than".
+	Kept    bool   `json:"kept,omitempty"`
+	Removed bool   `json:"removed,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// IncompleteCleanup is the outcome of the "cleanup" command. Reclaimed is the size of the removed
+// uploads, or of those which would be removed with DryRun.
+type IncompleteCleanup struct {
+	Uploads   []IncompleteUpload `json:"uploads"`
+	Removed   int                `json:"removed"`
+	Kept      int                `json:"kept"`
+	Failed    int                `json:"failed"`
+	Reclaimed int64              `json:"reclaimed"`
+	DryRun    bool               `json:"dry_run,omitempty"`
+}
+
+func (c IncompleteCleanup) String() string {
+	verb := "removed"
+	if c.DryRun {
+		verb = "to remove"
+	}
+	removed := c.Removed
+	if c.DryRun {
+		removed = len(c.Uploads) - c.Kept
+	}
+	return fmt.Sprintf(" Incomplete  : found=%d %s=%d (%s) kept=%d failed=%d\n",
+		len(c.Uploads), verb, removed, formatBytes(c.Reclaimed), c.Kept, c.Failed)
+}
+
+// cleanupIncompleteUploads lists the incomplete multipart uploads under the prefix of every
+// bucket, or in the whole buckets with all, and aborts those older than olderThan unless dryRun.
+// It is apart from the removal of the objects: the parts of incomplete uploads are not objects.
+func (r runner) cleanupIncompleteUploads(all bool, olderThan time.Duration, dryRun bool, progress io.Writer) IncompleteCleanup {
+	prefix := r.prefix
+	if all {
+		prefix = ""
+	}
+	buckets := r.buckets
+	if len(buckets) == 0 {
+		buckets = []string{r.bucketName}
+	}
+	c := IncompleteCleanup{Uploads: []IncompleteUpload{}, DryRun: dryRun}
+	now := time.Now()
+	for _, bucket := range buckets {
+		uploads, err := r.incompleteUploads(bucket, prefix)
+		if err != nil {
+			r.fatalf(`Unable to list the incomplete uploads under %q in %s: %v`, prefix, bucket, err)
+			return c
+		}
+		for _, upload := range uploads {
+			switch {
+			case now.Sub(upload.Initiated) < olderThan:
+				upload.Kept = true
+				c.Kept++
+			case dryRun:
+				c.Reclaimed += upload.Size
+			default:
+				if err := r.client.AbortMultipartUpload(context.Background(), bucket, upload.Key, upload.UploadID); err != nil {
+					log.Printf(`Unable to abort the upload %s of %s in %s, %v`, upload.UploadID, upload.Key, bucket, err)
+					upload.Error = err.Error()
+					c.Failed++
+					break
+				}
+				upload.Removed = true
+				c.Removed++
+				c.Reclaimed += upload.Size
+			}
+			fmt.Fprintf(progress, " - %s/%s,\tupload=%s initiated=%s parts=%d size=%s%s\n", bucket, upload.Key, upload.UploadID,
+				upload.Initiated.Local().Format(time.RFC3339), upload.Parts, formatBytes(upload.Size), incompleteMark(upload, dryRun))
+			c.Uploads = append(c.Uploads, upload)
+		}
+	}
+	return c
+}
+
+func incompleteMark(upload IncompleteUpload, dryRun bool) string {
+	switch {
+	case upload.Kept:
+		return " (kept, too recent)"
+	case upload.Error != "":
+		return " (FAILED)"
+	case dryRun:
+		return " (would be removed)"
+	}
+	return " (removed)"
+}
+
+// incompleteUploads lists the incomplete uploads under prefix along with the size of their parts.
+func (r runner) incompleteUploads(bucket, prefix string) ([]IncompleteUpload, error) {
+	// Stops the listing on the first error.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var uploads []IncompleteUpload
+	for info := range r.client.ListIncompleteUploads(ctx, bucket, prefix, true) {
+		if info.Err != nil {
+			return nil, info.Err
+		}
+		upload := IncompleteUpload{Bucket: bucket, Key: info.Key, UploadID: info.UploadID, Initiated: info.Initiated}
+		switch err := r.sizeIncompleteUpload(ctx, &upload); {
+		case errorResponse(err).Code == "NoSuchUpload":
+			// Completed or aborted since it was listed.
+		case err != nil:
+			return nil, fmt.Errorf(`unable to list the parts of %s, %v`, info.Key, err)
+		default:
+			uploads = append(uploads, upload)
+		}
+	}
+	return uploads, nil
+}
+
+func (r runner) sizeIncompleteUpload(ctx context.Context, upload *IncompleteUpload) error {
+	for marker := 0; ; {
+		parts, err := r.client.ListObjectParts(ctx, upload.Bucket, upload.Key, upload.UploadID, marker, incompleteUploadParts)
+		if err != nil {
+			return err
+		}
+		for _, part := range parts.ObjectParts {
+			upload.Parts++
+			upload.Size += part.Size
+		}
+		if !parts.IsTruncated {
+			return nil
+		}
+		marker = parts.NextPartNumberMarker
+	}
+}
@@ -0,0 +1,132 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// multipartStore holds incomplete multipart uploads of the given part sizes, by upload ID.
+type multipartStore struct {
+	ObjectStore
+	uploads map[string]*testUpload
+	// aborted are the upload IDs aborted, failing those of failAbort.
+	aborted   []string
+	failAbort string
+}
+
+type testUpload struct {
+	key       string
+	initiated time.Time
+	parts     []int64
+	// vanished uploads are completed between their listing and the listing of their parts.
+	vanished bool
+}
+
+func (s *multipartStore) ListIncompleteUploads(ctx context.Context, bucketName, objectPrefix string, recursive bool) <-chan minio.ObjectMultipartInfo {
+	ids := make([]string, 0, len(s.uploads))
+	for id, upload := range s.uploads {
+		if strings.HasPrefix(upload.key, objectPrefix) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	uploads := make(chan minio.ObjectMultipartInfo, len(ids))
+	for _, id := range ids {
+		uploads <- minio.ObjectMultipartInfo{Key: s.uploads[id].key, UploadID: id, Initiated: s.uploads[id].initiated}
+	}
+	close(uploads)
+	return uploads
+}
+
+func (s *multipartStore) ListObjectParts(ctx context.Context, bucketName, key, uploadID string, partNumberMarker, maxParts int) (minio.ListObjectPartsResult, error) {
+	upload, ok := s.uploads[uploadID]
+	if !ok || upload.vanished {
+		return minio.ListObjectPartsResult{}, minio.ErrorResponse{Code: "NoSuchUpload", StatusCode: http.StatusNotFound}
+	}
+	result := minio.ListObjectPartsResult{}
+	for number := partNumberMarker + 1; number <= len(upload.parts); number++ {
+		if len(result.ObjectParts) == maxParts {
+			result.IsTruncated, result.NextPartNumberMarker = true, number-1
+			break
+		}
+		result.ObjectParts = append(result.ObjectParts, minio.ObjectPart{PartNumber: number, Size: upload.parts[number-1]})
+	}
+	return result, nil
+}
+
+func (s *multipartStore) AbortMultipartUpload(ctx context.Context, bucketName, key, uploadID string) error {
+	if uploadID == s.failAbort {
+		return errors.New("connection reset")
+	}
+	s.aborted = append(s.aborted, uploadID)
+	delete(s.uploads, uploadID)
+	return nil
+}
+
+func partsOf(n int, size int64) []int64 {
+	parts := make([]int64, n)
+	for i := range parts {
+		parts[i] = size
+	}
+	return parts
+}
+
+func TestCleanupIncompleteUploads(t *testing.T) {
+	const mib = 1 << 20
+	for _, tc := range []struct {
+		name      string
+		all       bool
+		dryRun    bool
+		olderThan time.Duration
+		// found are the upload IDs listed, aborted those aborted.
+		found, aborted []string
+		kept, failed   int
+		reclaimed      int64
+	}{
+		{"prefix", false, false, time.Hour, []string{"crashed", "failing", "large", "recent"}, []string{"crashed", "large"}, 1, 1, 15*mib + 1500},
+		{"prefix dry run", false, true, time.Hour, []string{"crashed", "failing", "large", "recent"}, nil, 1, 0, 15*mib + 1500 + 5*mib},
+		{"any age", false, false, 0, []string{"crashed", "failing", "large", "recent"}, []string{"crashed", "large", "recent"}, 0, 1, 15*mib + 1500 + 5*mib},
+		{"whole bucket", true, false, time.Hour, []string{"crashed", "failing", "large", "other", "recent"}, []string{"crashed", "large", "other"}, 1, 1, 15*mib + 1500 + 5*mib},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Now()
+			store, requests := newTestStore(t)
+			uploads := &multipartStore{ObjectStore: store, failAbort: "failing", uploads: map[string]*testUpload{
+				"crashed": {key: "run/file-1.dat", initiated: now.Add(-2 * time.Hour), parts: partsOf(3, 5*mib)},
+				"failing": {key: "run/file-2.dat", initiated: now.Add(-2 * time.Hour), parts: partsOf(1, 5*mib)},
+				// More parts than a page of them.
+				"large":    {key: "run/file-3.dat", initiated: now.Add(-3 * time.Hour), parts: partsOf(incompleteUploadParts+500, 1)},
+				"recent":   {key: "run/file-4.dat", initiated: now.Add(-time.Minute), parts: partsOf(1, 5*mib)},
+				"vanished": {key: "run/file-5.dat", initiated: now.Add(-2 * time.Hour), parts: partsOf(1, 5*mib), vanished: true},
+				"other":    {key: "other/file-1.dat", initiated: now.Add(-2 * time.Hour), parts: partsOf(1, 5*mib)},
+			}}
+			r := newTestRunner(t, uploads, requests, 1, 1)
+			r.prefix = "run/"
+
+			c := r.cleanupIncompleteUploads(tc.all, tc.olderThan, tc.dryRun, io.Discard)
+			var found []string
+			for _, upload := range c.Uploads {
+				found = append(found, upload.UploadID)
+				if upload.UploadID == "large" && (upload.Parts != incompleteUploadParts+500 || upload.Size != incompleteUploadParts+500) {
+					t.Errorf("the large upload has %d parts of %d bytes, want all of them", upload.Parts, upload.Size)
+				}
+			}
+			sort.Strings(uploads.aborted)
+			if strings.Join(found, ",") != strings.Join(tc.found, ",") || strings.Join(uploads.aborted, ",") != strings.Join(tc.aborted, ",") {
+				t.Errorf("found %v and aborted %v, want %v and %v", found, uploads.aborted, tc.found, tc.aborted)
+			}
+			if c.Kept != tc.kept || c.Failed != tc.failed || c.Removed != len(tc.aborted) || c.Reclaimed != tc.reclaimed {
+				t.Errorf("kept=%d failed=%d removed=%d reclaimed=%d, want %d, %d, %d and %d", c.Kept, c.Failed, c.Removed, c.Reclaimed, tc.kept, tc.failed, len(tc.aborted), tc.reclaimed)
+			}
+		})
+	}
+}
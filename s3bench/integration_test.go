@@ -0,0 +1,140 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+
+//go:build integration
+
+package s3bench
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	// integrationImage is the MinIO server the benchmark runs against, started with docker.
+	integrationImage    = "minio/minio:latest"
+	integrationUser     = "integration"
+	integrationPassword = "integration-secret"
+	integrationTrials   = 5
+)
+
+// startMinIO starts a throwaway MinIO container, removed once the test is over, and returns its
+// endpoint; the test is skipped without docker.
+func startMinIO(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker is not installed")
+	}
+	if err := exec.Command("docker", "info").Run(); err != nil {
+		t.Skipf("docker is not available: %v", err)
+	}
+	out, err := exec.Command("docker", "run", "-d", "--rm", "-p", "127.0.0.1::9000",
+		"-e", "MINIO_ROOT_USER="+integrationUser, "-e", "MINIO_ROOT_PASSWORD="+integrationPassword,
+		integrationImage, "server", "/data").Output()
+	if err != nil {
+		t.Fatalf("Unable to start %s: %v", integrationImage, err)
+	}
+	container := strings.TrimSpace(string(out))
+	t.Cleanup(func() {
+		if err := exec.Command("docker", "rm", "-f", container).Run(); err != nil {
+			t.Logf("Unable to remove the container %s: %v", container, err)
+		}
+	})
+	out, err = exec.Command("docker", "port", container, "9000/tcp").Output()
+	if err != nil {
+		t.Fatalf("Unable to find the port of %s: %v", container, err)
+	}
+	// One line per address family, the first one is the 127.0.0.1 one.
+	endpoint := "http://" + strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+
+	deadline := time.Now().Add(time.Minute)
+	for {
+		response, err := http.Get(endpoint + "/minio/health/live")
+		if err == nil {
+			response.Body.Close()
+			if response.StatusCode == http.StatusOK {
+				return endpoint
+			}
+		}
+		if time.Now().After(deadline) {
+			logs, _ := exec.Command("docker", "logs", container).CombinedOutput()
+			t.Fatalf("MinIO did not become ready at %s: %v\n%s", endpoint, err, logs)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func TestIntegration(t *testing.T) {
+	endpoint := startMinIO(t)
+	t.Setenv(accessKeyEnvVarName, integrationUser)
+	t.Setenv(secretKeyEnvVarName, integrationPassword)
+
+	client, err := minio.New(strings.TrimPrefix(endpoint, "http://"), &minio.Options{Creds: credentials.NewStaticV4(integrationUser, integrationPassword, "")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := client.MakeBucket(ctx, testBucket, minio.MakeBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "run")
+	results, err := Run(ctx, Config{Args: []string{
+		"-endpoint", endpoint, "-bucketName", testBucket, "-trials", strconv.Itoa(integrationTrials), "-fileSize", "1", "-concurrency", "2",
+		"-threshold", "upload.avg_speed>0", "-threshold", "download.avg_speed>0", "-output-dir", dir,
+	}})
+	if err != nil {
+		t.Fatalf("benchmark failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, runReportJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report Report
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatalf("Unable to decode %s: %v", runReportJSON, err)
+	}
+	if report.SchemaVersion != reportSchemaVersion {
+		t.Errorf("schema version %q, want %q", report.SchemaVersion, reportSchemaVersion)
+	}
+	for _, phase := range []struct {
+		name  string
+		stats PhaseStats
+	}{{"upload", report.Upload}, {"download", report.Download}} {
+		if phase.stats.Count != integrationTrials || phase.stats.Failed != 0 {
+			t.Errorf("%s: %d trials, %d failed, want %d and none", phase.name, phase.stats.Count, phase.stats.Failed, integrationTrials)
+		}
+		if phase.stats.AvgSpeed <= 0 || phase.stats.P90Time <= 0 {
+			t.Errorf("%s: avg speed %v MB/s, P90 time %v, want both positive", phase.name, phase.stats.AvgSpeed, phase.stats.P90Time)
+		}
+		if n := len(results.Samples[phase.name]); n != integrationTrials {
+			t.Errorf("%s: %d samples, want %d", phase.name, n, integrationTrials)
+		}
+	}
+	if !report.Passed() {
+		t.Errorf("the thresholds failed: %+v", report.Thresholds)
+	}
+	if report.Cleanup == nil || report.Cleanup.incomplete() {
+		t.Errorf("cleanup %+v, want every object removed", report.Cleanup)
+	}
+	for object := range client.ListObjects(ctx, testBucket, minio.ListObjectsOptions{Recursive: true}) {
+		t.Errorf("cleanup left %s behind (%v)", object.Key, object.Err)
+	}
+
+	var exit *ExitError
+	if _, err := Run(ctx, Config{Args: []string{"-endpoint", endpoint, "-bucketName", testBucket, "-secretKey", "wrong", "-trials", "1"}}); !errors.As(err, &exit) || exit.Code != exitAuthFailed {
+		t.Errorf("wrong credentials: %v, want exit code %d", err, exitAuthFailed)
+	}
+}
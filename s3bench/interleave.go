@@ -0,0 +1,100 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// InterleaveStats is the aggregate of the overlapping upload and download phases of
+// "-interleave", over the window from the first trial start to the last trial end of either.
+type InterleaveStats struct {
+	Bytes      int64         `json:"bytes"`
+	Elapsed    time.Duration `json:"elapsed"`
+	Throughput float64       `json:"throughput"`
+}
+
+func (s InterleaveStats) String() string {
+	return fmt.Sprintf(" Interleaved : combined.throughput=%s MB/s over %s (%s uploaded and downloaded)\n",
+		formatSpeed(s.Throughput), formatDuration(s.Elapsed), formatBytes(s.Bytes))
+}
+
+func newInterleaveStats(upload, download PhaseStats) *InterleaveStats {
+	start, end := upload.Start, upload.Start.Add(upload.Elapsed)
+	if download.Count > 0 {
+		if download.Start.Before(start) {
+			start = download.Start
+		}
+		if downloadEnd := download.Start.Add(download.Elapsed); downloadEnd.After(end) {
+			end = downloadEnd
+		}
+	}
+	s := &InterleaveStats{Bytes: upload.Bytes + download.Bytes, Elapsed: end.Sub(start)}
+	if s.Elapsed > 0 {
+		s.Throughput = float64(s.Bytes) / s.Elapsed.Seconds() / 1024 / 1024 // MB/s
+	}
+	return s
+}
+
+// interleaved runs the upload and the download phases at the same time on two worker pools.
+// Every download takes an object whose upload completed, so none starts before its object
+// exists; the downloads left over by failed uploads are skipped. It returns the number of
+// upload trials.
+func (r runner) interleaved(uploads, downloads *phaseRecorder, uploadWindows, downloadWindows *windowRecorder) int {
+	var (
+		ready    = make(chan int, r.trials)
+		uploaded int
+		wg       sync.WaitGroup
+	)
+	// The downloader maps trials onto the uploaded objects, all of which are going to be there.
+	r.uploaded = r.trials
+	uploader, downloader := r, r
+	uploader.progress, downloader.progress = labelWriter{r.progress, "upload  "}, labelWriter{r.progress, "download"}
+	upload, download := uploadWindows.wrap(uploader.uploader("upload", 0)), downloadWindows.wrap(downloader.downloader)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer close(ready)
+		uploaded = r.schedule("upload", uploads).run(upload, func(s sample) {
+			uploads.record(s)
+			if s.err == nil {
+				ready <- s.trial
+			}
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		r.schedule("download", downloads).run(func(worker int) operation {
+			op := download(worker)
+			return func(_ int, stage string) sample {
+				trial, ok := <-ready
+				if !ok {
+					return sample{skipped: true}
+				}
+				// Waiting for an upload is not a delay of the download.
+				due := time.Now()
+				s := op(trial, stage)
+				s.due = due
+				return s
+			}
+		}, downloads.record)
+	}()
+	wg.Wait()
+	return uploaded
+}
+
+// labelWriter tells the trial lines of the two pools apart. Every line is a write of its own.
+type labelWriter struct {
+	w     io.Writer
+	label string
+}
+
+func (l labelWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(l.w, l.label); err != nil {
+		return 0, err
+	}
+	return l.w.Write(p)
+}
@@ -0,0 +1,167 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// The de-facto JUnit XML format as understood by Jenkins and most CI systems.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string  `xml:"name,attr"`
+	Tests     int     `xml:"tests,attr"`
+	Failures  int     `xml:"failures,attr"`
+	Errors    int     `xml:"errors,attr"`
+	Time      float64 `xml:"time,attr"`
+	Timestamp string  `xml:"timestamp,attr,omitempty"`
+	// Properties carry the hash of the configuration.
+	Properties *junitProperties `xml:"properties,omitempty"`
+	Cases      []junitTestCase  `xml:"testcase"`
+}
+
+// junitProperties is left out without properties, the schema wants at least one in it.
+type junitProperties struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitSuiteName names the suite of a variant ("plain", "sse-kms" with "-compare-sse").
+func junitSuiteName(variant string) string {
+	if variant == "" {
+		return "s3bench"
+	}
+	return "s3bench." + variant
+}
+
+// junitReportSuite renders every measured phase as a passed test case and every threshold
+// check as a test case which fails when violated.
+func junitReportSuite(variant string, r Report) junitTestSuite {
+	suite := junitTestSuite{Name: junitSuiteName(variant)}
+	if !r.Upload.Start.IsZero() {
+		suite.Timestamp = r.Upload.Start.UTC().Format("2006-01-02T15:04:05")
+	}
+	if r.Metadata.Config != nil {
+		suite.Properties = &junitProperties{Properties: []junitProperty{{Name: "config_hash", Value: r.Metadata.Config.Hash}}}
+	}
+
+	classname := suite.Name + ".phases"
+	for _, phase := range r.phases() {
+		c := junitTestCase{Name: phase.name, Classname: classname, Time: phase.stats.Elapsed.Seconds()}
+		if phase.stats.Failed > 0 {
+			message := fmt.Sprintf("%d trials failed", phase.stats.Failed)
+			if phase.stats.Aborted {
+				message += fmt.Sprintf(", aborted after %d operations", phase.stats.AbortedAfter)
+			}
+			c.Failure = &junitProblem{Message: message, Type: "trials"}
+		}
+		suite.Cases = append(suite.Cases, c)
+		suite.Time += phase.stats.Elapsed.Seconds()
+	}
+
+	if r.Listing != nil {
+		c := junitTestCase{Name: "listing", Classname: classname, Time: r.Listing.Elapsed.Seconds()}
+		if failed := r.Listing.failures(); failed > 0 {
+			c.Failure = &junitProblem{
+				Message: fmt.Sprintf("%d of %d uploaded objects are missing from the listing or listed with another size", failed, r.Listing.Expected),
+				Type:    "listing",
+				Text:    r.Listing.String(),
+			}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	if r.Verification != nil {
+		c := junitTestCase{Name: "verification", Classname: classname}
+		if failed := len(r.Verification.Failures); failed > 0 {
+			c.Failure = &junitProblem{
+				Message: fmt.Sprintf("%d of %d verified objects do not match", failed, r.Verification.Verified+failed),
+				Type:    "verification",
+				Text:    r.Verification.String(),
+			}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	classname = suite.Name + ".thresholds"
+	for _, t := range r.Thresholds {
+		c := junitTestCase{Name: t.Check, Classname: classname}
+		if !t.Passed {
+			c.Failure = &junitProblem{
+				Message: fmt.Sprintf("%s measured %s, expected %s", t.Metric, t.measured(), t.Check),
+				Type:    "threshold",
+			}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	return suite.counted()
+}
+
+// junitErrorSuite renders a failure which aborted the benchmark as an errored test case.
+func junitErrorSuite(variant string, failure error) junitTestSuite {
+	suite := junitTestSuite{
+		Name:      junitSuiteName(variant),
+		Timestamp: time.Now().UTC().Format("2006-01-02T15:04:05"),
+		Cases: []junitTestCase{{
+			Name:      "benchmark",
+			Classname: junitSuiteName(variant),
+			Error:     &junitProblem{Message: failure.Error(), Type: "error"},
+		}},
+	}
+	return suite.counted()
+}
+
+func (s junitTestSuite) counted() junitTestSuite {
+	s.Tests = len(s.Cases)
+	for _, c := range s.Cases {
+		if c.Failure != nil {
+			s.Failures++
+		}
+		if c.Error != nil {
+			s.Errors++
+		}
+	}
+	return s
+}
+
+func writeJUnit(path string, suites ...junitTestSuite) error {
+	doc := junitTestSuites{Suites: suites}
+	for _, s := range suites {
+		doc.Tests += s.Tests
+		doc.Failures += s.Failures
+		doc.Errors += s.Errors
+		doc.Time += s.Time
+	}
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append([]byte(xml.Header), append(out, '\n')...), 0o644)
+}
@@ -0,0 +1,81 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// junitTestReport is a run whose downloads partly failed and broke one of two thresholds.
+func junitTestReport() Report {
+	start := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	return Report{
+		Metadata: RunMetadata{Config: &EffectiveConfig{Hash: "9f86d081884c7d65"}},
+		Upload:   PhaseStats{Count: 20, AvgTime: 80 * time.Millisecond, P90Time: 120 * time.Millisecond, Elapsed: 1600 * time.Millisecond, Start: start},
+		Download: PhaseStats{Count: 18, AvgTime: 40 * time.Millisecond, P90Time: 2500 * time.Millisecond, Elapsed: 3250 * time.Millisecond, Start: start.Add(2 * time.Second),
+			Failed: 2, Aborted: true, AbortedAfter: 20},
+		Verification: &Verification{Seed: 1, Verified: 4, Failures: []VerificationFailure{{Key: "file-3.dat", Offsets: []int64{1024}, MismatchedBytes: 1}}},
+		Thresholds: []ThresholdResult{
+			{Check: "upload.p90_time<2s", Metric: "upload.p90_time", Measured: float64(120 * time.Millisecond), Limit: float64(2 * time.Second), Passed: true},
+			{Check: "download.p90_time<2s", Metric: "download.p90_time", Measured: float64(2500 * time.Millisecond), Limit: float64(2 * time.Second)},
+		},
+	}
+}
+
+func TestJUnitGolden(t *testing.T) {
+	report := junitTestReport()
+	failed := junitErrorSuite("run-2", errors.New("Unable to create the buckets: Access Denied."))
+	failed.Timestamp = "2024-03-01T12:40:00"
+
+	path := filepath.Join(t.TempDir(), "results.xml")
+	if err := writeJUnit(path, junitReportSuite("run-1", report), failed); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "junit.xml", out)
+
+	// The counts of the de-facto schema add up over the suites and their cases.
+	var doc junitTestSuites
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("not well-formed: %v", err)
+	}
+	var tests, failures, errs int
+	for _, suite := range doc.Suites {
+		var suiteFailures, suiteErrors int
+		for _, c := range suite.Cases {
+			if c.Name == "" || c.Classname == "" {
+				t.Errorf("suite %s: a test case without a name or classname", suite.Name)
+			}
+			if c.Failure != nil {
+				suiteFailures++
+			}
+			if c.Error != nil {
+				suiteErrors++
+			}
+		}
+		if suite.Tests != len(suite.Cases) || suite.Failures != suiteFailures || suite.Errors != suiteErrors {
+			t.Errorf("suite %s counts tests=%d failures=%d errors=%d, its cases %d, %d and %d", suite.Name, suite.Tests, suite.Failures, suite.Errors, len(suite.Cases), suiteFailures, suiteErrors)
+		}
+		tests, failures, errs = tests+suite.Tests, failures+suite.Failures, errs+suite.Errors
+	}
+	if doc.Tests != tests || doc.Failures != failures || doc.Errors != errs {
+		t.Errorf("testsuites counts tests=%d failures=%d errors=%d, its suites %d, %d and %d", doc.Tests, doc.Failures, doc.Errors, tests, failures, errs)
+	}
+}
+
+func TestJUnitPhaseTimes(t *testing.T) {
+	suite := junitReportSuite("", junitTestReport())
+	if suite.Name != "s3bench" || suite.Timestamp != "2024-03-01T12:30:00" {
+		t.Errorf("suite %q at %q, want s3bench at the start of the uploads", suite.Name, suite.Timestamp)
+	}
+	if want := 4.85; suite.Time != want {
+		t.Errorf("suite time %v, want the %v seconds of the phases", suite.Time, want)
+	}
+}
@@ -0,0 +1,117 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExportedKey is an object the run stored, as "-export-keys" writes it and "-scan-keys" reads
+// it. SHA256 is only known with "-checksum SHA256".
+type ExportedKey struct {
+	Bucket     string    `json:"bucket"`
+	Key        string    `json:"key"`
+	Size       int64     `json:"size"`
+	ETag       string    `json:"etag"`
+	VersionID  string    `json:"version_id,omitempty"`
+	SHA256     string    `json:"sha256,omitempty"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+// keyExporter collects the stored objects for "-export-keys", the last upload of a key replacing
+// those before. A nil keyExporter exports nothing.
+type keyExporter struct {
+	path string
+
+	mu   sync.Mutex
+	keys map[string]ExportedKey
+}
+
+func newKeyExporter(path string) *keyExporter {
+	if path == "" {
+		return nil
+	}
+	return &keyExporter{path: path, keys: map[string]ExportedKey{}}
+}
+
+// wrap records the objects of the successful uploads before passing the samples on to record.
+func (e *keyExporter) wrap(record func(sample)) func(sample) {
+	if e == nil {
+		return record
+	}
+	return func(s sample) {
+		if s.err == nil && !s.cancelled && !s.skipped && s.key != "" {
+			e.add(ExportedKey{Bucket: s.bucket, Key: s.key, Size: s.bytes, ETag: s.etag, VersionID: s.versionID, SHA256: s.sha256, UploadedAt: s.start.Add(s.duration).UTC()})
+		}
+		record(s)
+	}
+}
+
+func (e *keyExporter) add(key ExportedKey) {
+	if e == nil {
+		return
+	}
+	key.ETag = strings.Trim(key.ETag, `"`)
+	e.mu.Lock()
+	e.keys[key.Bucket+"/"+key.Key] = key
+	e.mu.Unlock()
+}
+
+// write replaces the file with the objects recorded so far, ordered by bucket and key.
+func (e *keyExporter) write() error {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	keys := make([]ExportedKey, 0, len(e.keys))
+	for _, key := range e.keys {
+		keys = append(keys, key)
+	}
+	e.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Bucket != keys[j].Bucket {
+			return keys[i].Bucket < keys[j].Bucket
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	content, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(e.path, append(content, '\n'), 0o644)
+}
+
+// checksumHex is the hex form of the base64 checksum the upload sent.
+func checksumHex(encoded string) string {
+	sum, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(sum)
+}
+
+// exportKeys writes the "-export-keys" file at the end of a phase which stored objects.
+func (r runner) exportKeys() {
+	if err := r.exported.write(); err != nil {
+		log.Printf(`WARNING: unable to write the keys to %s: %v`, r.exported.path, err)
+	}
+}
+
+// readExportedKeys reads the objects of an "-export-keys" file, as "-scan-keys" does.
+func readExportedKeys(content []byte) ([]scannedObject, error) {
+	var keys []ExportedKey
+	if err := json.Unmarshal(content, &keys); err != nil {
+		return nil, err
+	}
+	objects := make([]scannedObject, 0, len(keys))
+	for _, key := range keys {
+		objects = append(objects, scannedObject{key: key.Key, size: key.Size})
+	}
+	return objects, nil
+}
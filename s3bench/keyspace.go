@@ -0,0 +1,79 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	mathrand "math/rand"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxKeyDepth bounds "-key-depth", far beyond what key length limits leave room for anyway.
+	maxKeyDepth = 32
+	// keyspaceSeedOffset keeps the directories of a trial apart from its size, which is drawn
+	// with the same seed.
+	keyspaceSeedOffset = 1 << 40
+)
+
+// keyspace nests the keys of the trials depth directories deep with fanout directories per
+// level, e.g. "07/13/02/file-1.dat". Every trial has its directory drawn by the seed, so that the
+// trials spread over the tree the same way whenever the seed is the same. The zero keyspace is
+// flat.
+type keyspace struct {
+	depth, fanout int
+	seed          int64
+}
+
+// KeyspaceShape is the keyspace of a run with "-key-depth": Leaves is the number of directories
+// at the deepest level the trials are spread over.
+type KeyspaceShape struct {
+	Depth  int     `json:"depth"`
+	Fanout int     `json:"fanout"`
+	Leaves float64 `json:"leaves"`
+	Seed   int64   `json:"seed"`
+}
+
+func (k KeyspaceShape) String() string {
+	return fmt.Sprintf(" Keyspace    : depth=%d fanout=%d (%s leaf directories, seed %d)\n", k.Depth, k.Fanout, strconv.FormatFloat(k.Leaves, 'g', -1, 64), k.Seed)
+}
+
+func newKeyspace(depth, fanout int, seed int64) (keyspace, error) {
+	switch {
+	case depth < 0 || depth > maxKeyDepth:
+		return keyspace{}, fmt.Errorf(`"-key-depth" must be between 0 and %d`, maxKeyDepth)
+	case depth > 0 && fanout < 2:
+		return keyspace{}, fmt.Errorf(`"-key-fanout" must be at least 2 with "-key-depth"`)
+	}
+	return keyspace{depth: depth, fanout: fanout, seed: seed}, nil
+}
+
+func (k keyspace) nested() bool {
+	return k.depth > 0
+}
+
+// dir is the directory of a trial with a trailing slash, empty for a flat keyspace.
+func (k keyspace) dir(trial int) string {
+	if !k.nested() {
+		return ""
+	}
+	width := len(strconv.Itoa(k.fanout - 1))
+	rng := mathrand.New(mathrand.NewSource(k.seed + keyspaceSeedOffset + int64(trial)))
+	var sb strings.Builder
+	for level := 0; level < k.depth; level++ {
+		fmt.Fprintf(&sb, "%0*d/", width, rng.Intn(k.fanout))
+	}
+	return sb.String()
+}
+
+// shape is what the metadata records of the keyspace, nil for a flat one.
+func (k keyspace) shape() *KeyspaceShape {
+	if !k.nested() {
+		return nil
+	}
+	leaves := 1.0
+	for level := 0; level < k.depth; level++ {
+		leaves *= float64(k.fanout)
+	}
+	return &KeyspaceShape{Depth: k.depth, Fanout: k.fanout, Leaves: leaves, Seed: k.seed}
+}
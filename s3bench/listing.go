@@ -0,0 +1,106 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// ListingCheck compares a listing of the run prefix taken right after the upload phase with
+// what the uploads reported as stored ("-verify-listing"). Objects missing from the listing or
+// listed with another size are integrity errors.
+type ListingCheck struct {
+	Expected   int               `json:"expected"`
+	Listed     int               `json:"listed"`
+	Missing    []string          `json:"missing,omitempty"`
+	Mismatched []ListingMismatch `json:"mismatched,omitempty"`
+	Elapsed    time.Duration     `json:"elapsed"`
+}
+
+type ListingMismatch struct {
+	Key          string `json:"key"`
+	Size         int64  `json:"size"`
+	ExpectedSize int64  `json:"expected_size"`
+}
+
+func (l ListingCheck) failures() int {
+	return len(l.Missing) + len(l.Mismatched)
+}
+
+func (l ListingCheck) String() string {
+	s := fmt.Sprintf(" Listing     : expected=%d listed=%d missing=%d mismatched=%d elapsed=%s\n",
+		l.Expected, l.Listed, len(l.Missing), len(l.Mismatched), formatDuration(l.Elapsed))
+	for _, key := range l.Missing {
+		s += fmt.Sprintf("  %s: not listed\n", key)
+	}
+	for _, m := range l.Mismatched {
+		s += fmt.Sprintf("  %s: listed.size=%s expected=%s\n", m.Key, formatByteCount(m.Size), formatByteCount(m.ExpectedSize))
+	}
+	return s
+}
+
+// storedObjects collects the keys and sizes of the successful uploads.
+type storedObjects struct {
+	mu    sync.Mutex
+	sizes map[string]int64
+}
+
+func newStoredObjects() *storedObjects {
+	return &storedObjects{sizes: map[string]int64{}}
+}
+
+// wrap records the stored objects of the samples before passing them on to record.
+func (o *storedObjects) wrap(record func(sample)) func(sample) {
+	return func(s sample) {
+		if s.err == nil {
+			o.mu.Lock()
+			o.sizes[s.key] = s.bytes
+			o.mu.Unlock()
+		}
+		record(s)
+	}
+}
+
+// checkListing lists the run prefix and matches it against the stored objects. Other objects
+// under the prefix are none of its business.
+func (r runner) checkListing(stored *storedObjects) *ListingCheck {
+	start := time.Now()
+	check := &ListingCheck{Expected: len(stored.sizes)}
+	listed := make(map[string]int64, len(stored.sizes))
+	buckets := r.buckets
+	if len(buckets) == 0 {
+		buckets = []string{r.bucketName}
+	}
+	for _, bucket := range buckets {
+		for object := range r.client.ListObjects(context.Background(), bucket, minio.ListObjectsOptions{Prefix: r.prefix, Recursive: true}) {
+			if object.Err != nil {
+				r.fatalf(`Unable to list %s in %s, %v`, r.prefix, bucket, object.Err)
+				return nil
+			}
+			if _, ok := stored.sizes[object.Key]; ok {
+				listed[object.Key] = object.Size
+			}
+		}
+	}
+	check.Elapsed = time.Since(start)
+	check.Listed = len(listed)
+
+	for key, expected := range stored.sizes {
+		size, ok := listed[key]
+		switch {
+		case !ok:
+			check.Missing = append(check.Missing, key)
+		case size != expected:
+			check.Mismatched = append(check.Mismatched, ListingMismatch{Key: key, Size: size, ExpectedSize: expected})
+		}
+	}
+	sort.Strings(check.Missing)
+	sort.Slice(check.Mismatched, func(i, j int) bool { return check.Mismatched[i].Key < check.Mismatched[j].Key })
+	fmt.Fprintf(r.progress, " - Listed %d of %d uploaded objects in %s\n", check.Listed, check.Expected, formatDuration(check.Elapsed))
+	return check
+}
@@ -0,0 +1,77 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// User metadata attached to every uploaded object, so that its origin can be told later.
+const (
+	metaRunID     = "s3bench-run-id"
+	metaLabel     = "s3bench-label"
+	metaHost      = "s3bench-host"
+	metaTimestamp = "s3bench-timestamp"
+)
+
+func newRunID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func objectMetadata(runID, label string, started time.Time) map[string]string {
+	host, _ := os.Hostname()
+	metadata := map[string]string{
+		metaRunID:     runID,
+		metaHost:      host,
+		metaTimestamp: started.UTC().Format(time.RFC3339),
+	}
+	if label != "" {
+		metadata[metaLabel] = label
+	}
+	return metadata
+}
+
+// createdByRun tells whether the object carries the run ID of this run.
+func createdByRun(info minio.ObjectInfo, runID string) bool {
+	return info.Metadata.Get("X-Amz-Meta-"+metaRunID) == runID
+}
+
+// aclHeader carries the "-acl" canned ACL, which minio-go passes on as is from the user metadata.
+const aclHeader = "x-amz-acl"
+
+var cannedACLs = []string{
+	"private", "public-read", "public-read-write", "authenticated-read",
+	"aws-exec-read", "bucket-owner-read", "bucket-owner-full-control",
+}
+
+func validateACL(acl string) error {
+	for _, known := range cannedACLs {
+		if acl == known {
+			return nil
+		}
+	}
+	return fmt.Errorf(`unknown canned ACL %q, expected one of %s`, acl, strings.Join(cannedACLs, ", "))
+}
+
+// uploadMetadata is a copy of the metadata to upload objects with, the "-acl" included.
+func (r runner) uploadMetadata() map[string]string {
+	if r.metadata == nil && r.acl == "" {
+		return nil
+	}
+	metadata := make(map[string]string, len(r.metadata)+2)
+	for k, v := range r.metadata {
+		metadata[k] = v
+	}
+	if r.acl != "" {
+		metadata[aclHeader] = r.acl
+	}
+	return metadata
+}
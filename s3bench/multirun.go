@@ -0,0 +1,115 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// MultiRunReport aggregates the repetitions of "-runs" over their P90s.
+type MultiRunReport struct {
+	Aggregate struct {
+		Upload   RunAggregate `json:"upload"`
+		Download RunAggregate `json:"download"`
+	} `json:"aggregate"`
+	Runs []Report `json:"runs"`
+}
+
+// RunAggregate summarizes one phase over the runs. The stability indicator is the relative
+// standard deviation of the per-run P90 times, in percent. PooledP90Time is the P90 of the
+// trials of all the runs together, as if one run measured them.
+type RunAggregate struct {
+	MeanP90Time   time.Duration `json:"mean_p90_time"`
+	MinP90Time    time.Duration `json:"min_p90_time"`
+	MaxP90Time    time.Duration `json:"max_p90_time"`
+	PooledP90Time time.Duration `json:"pooled_p90_time"`
+	MeanP90Speed  float64       `json:"mean_p90_speed"`
+	MinP90Speed   float64       `json:"min_p90_speed"`
+	MaxP90Speed   float64       `json:"max_p90_speed"`
+	P90TimeRSD    float64       `json:"p90_time_rsd_pct"`
+}
+
+// aggregateRuns summarizes the reports of the runs, pooled being the Results of all of them merged.
+func aggregateRuns(runs []Report, pooled Results) MultiRunReport {
+	m := MultiRunReport{Runs: runs}
+	m.Aggregate.Upload = aggregatePhase(runs, func(r Report) PhaseStats { return r.Upload })
+	m.Aggregate.Download = aggregatePhase(runs, func(r Report) PhaseStats { return r.Download })
+	m.Aggregate.Upload.PooledP90Time = pooled.Percentile("upload", 0.9)
+	m.Aggregate.Download.PooledP90Time = pooled.Percentile("download", 0.9)
+	return m
+}
+
+func aggregatePhase(runs []Report, phase func(Report) PhaseStats) RunAggregate {
+	var (
+		a                 RunAggregate
+		sumTime, sumSpeed float64
+	)
+	for i, run := range runs {
+		stats := phase(run)
+		sumTime += float64(stats.P90Time)
+		sumSpeed += stats.P90Speed
+		if i == 0 || stats.P90Time < a.MinP90Time {
+			a.MinP90Time = stats.P90Time
+		}
+		if stats.P90Time > a.MaxP90Time {
+			a.MaxP90Time = stats.P90Time
+		}
+		if i == 0 || stats.P90Speed < a.MinP90Speed {
+			a.MinP90Speed = stats.P90Speed
+		}
+		if stats.P90Speed > a.MaxP90Speed {
+			a.MaxP90Speed = stats.P90Speed
+		}
+	}
+	if len(runs) == 0 {
+		return a
+	}
+	mean := sumTime / float64(len(runs))
+	a.MeanP90Time, a.MeanP90Speed = time.Duration(mean), sumSpeed/float64(len(runs))
+
+	var squares float64
+	for _, run := range runs {
+		d := float64(phase(run).P90Time) - mean
+		squares += d * d
+	}
+	if mean > 0 {
+		a.P90TimeRSD = math.Sqrt(squares/float64(len(runs))) / mean * 100
+	}
+	return a
+}
+
+func (m MultiRunReport) Passed() bool {
+	for _, run := range m.Runs {
+		if !run.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+func (m MultiRunReport) String() string {
+	var sb strings.Builder
+	for i, run := range m.Runs {
+		fmt.Fprintf(&sb, "Report (run %d/%d):\n%s\n", i+1, len(m.Runs), run)
+	}
+	fmt.Fprintf(&sb, "Aggregate (%d runs):\n", len(m.Runs))
+	for _, phase := range []struct {
+		name string
+		a    RunAggregate
+	}{{"Upload", m.Aggregate.Upload}, {"Download", m.Aggregate.Download}} {
+		fmt.Fprintf(&sb, " %-12s: p90.time mean=%s min=%s max=%s pooled=%s rsd=%.1f%%, p90.speed mean=%s min=%s max=%s MB/s\n",
+			phase.name, formatDuration(phase.a.MeanP90Time), formatDuration(phase.a.MinP90Time), formatDuration(phase.a.MaxP90Time), formatDuration(phase.a.PooledP90Time), phase.a.P90TimeRSD,
+			formatSpeed(phase.a.MeanP90Speed), formatSpeed(phase.a.MinP90Speed), formatSpeed(phase.a.MaxP90Speed))
+	}
+	return sb.String()
+}
+
+func (m MultiRunReport) summaryLine(st styler) string {
+	lines := make([]string, 0, len(m.Runs))
+	for i, run := range m.Runs {
+		lines = append(lines, fmt.Sprintf("run %d: %s", i+1, run.summaryLine(st)))
+	}
+	return strings.Join(lines, "\n")
+}
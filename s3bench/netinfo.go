@@ -0,0 +1,142 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// LinkUtilization relates aggregate phase throughput to the speed of the
+// network link the benchmark traffic leaves through.
+type LinkUtilization struct {
+	Interface string  `json:"interface,omitempty"`
+	Speed     float64 `json:"speed_bps"`
+	Upload    float64 `json:"upload_pct"`
+	Download  float64 `json:"download_pct"`
+}
+
+func newLinkUtilization(iface string, bitsPerSecond float64, report Report) *LinkUtilization {
+	return &LinkUtilization{
+		Interface: iface,
+		Speed:     bitsPerSecond,
+		Upload:    report.Upload.Throughput * 1024 * 1024 * 8 / bitsPerSecond * 100,
+		Download:  report.Download.Throughput * 1024 * 1024 * 8 / bitsPerSecond * 100,
+	}
+}
+
+func (l LinkUtilization) String() string {
+	name := ""
+	if l.Interface != "" {
+		name = l.Interface + ", "
+	}
+	return fmt.Sprintf(` Link        : %s%s, upload=%.1f%% download=%.1f%%
+`, name, formatBitRate(l.Speed), l.Upload, l.Download)
+}
+
+// parseLinkSpeed parses values like "10Gbps", "25G", "100Mbit" or "1000000" into bits per second.
+func parseLinkSpeed(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	split := strings.IndexFunc(value, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+	number, unit := value, ""
+	if split >= 0 {
+		number, unit = value[:split], strings.ToLower(strings.TrimSpace(value[split:]))
+	}
+
+	speed, err := strconv.ParseFloat(number, 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf(`invalid link speed %q`, value)
+	}
+
+	unit = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(unit, "ps"), "/s"), "it")
+	switch unit {
+	case "", "b":
+	case "k", "kb":
+		speed *= 1e3
+	case "m", "mb":
+		speed *= 1e6
+	case "g", "gb":
+		speed *= 1e9
+	case "t", "tb":
+		speed *= 1e12
+	default:
+		return 0, fmt.Errorf(`invalid link speed unit in %q`, value)
+	}
+	return speed, nil
+}
+
+func formatBitRate(bitsPerSecond float64) string {
+	for _, unit := range []struct {
+		name  string
+		scale float64
+	}{{"Tbit/s", 1e12}, {"Gbit/s", 1e9}, {"Mbit/s", 1e6}, {"Kbit/s", 1e3}} {
+		if bitsPerSecond >= unit.scale {
+			return strconv.FormatFloat(bitsPerSecond/unit.scale, 'f', -1, 64) + " " + unit.name
+		}
+	}
+	return strconv.FormatFloat(bitsPerSecond, 'f', -1, 64) + " bit/s"
+}
+
+// egressInterface returns the name of the interface the kernel would route traffic to hostport through.
+// No packets are sent: connecting a UDP socket only performs the route lookup.
+func egressInterface(hostport string) (string, error) {
+	conn, err := net.Dial("udp", hostport)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	return interfaceByAddress(conn.LocalAddr().(*net.UDPAddr).IP)
+}
+
+// interfaceByAddress returns the name of the interface which has ip assigned.
+func interfaceByAddress(ip net.IP) (string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return iface.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf(`no interface has address %s`, ip)
+}
+
+// interfaceAddress returns the address to bind to for sending through the named interface,
+// preferring IPv4 as S3 endpoints are mostly reached through it.
+func interfaceAddress(name string) (net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var found net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+		if found == nil {
+			found = ipNet.IP
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf(`interface %s has no usable address`, name)
+	}
+	return found, nil
+}
@@ -0,0 +1,23 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// detectLinkSpeed reads the negotiated speed of the interface from sysfs, in bits per second.
+func detectLinkSpeed(iface string) (float64, error) {
+	raw, err := os.ReadFile(fmt.Sprintf("/sys/class/net/%s/speed", iface))
+	if err != nil {
+		return 0, err
+	}
+	// Virtual interfaces report -1 (or fail to read) when the speed is unknown.
+	mbps, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	if err != nil || mbps <= 0 {
+		return 0, fmt.Errorf(`unknown speed of %s`, iface)
+	}
+	return float64(mbps) * 1e6, nil
+}
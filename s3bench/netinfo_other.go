@@ -0,0 +1,11 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+
+//go:build !linux
+
+package s3bench
+
+import "errors"
+
+func detectLinkSpeed(iface string) (float64, error) {
+	return 0, errors.New(`link speed detection is only supported on Linux`)
+}
@@ -0,0 +1,14 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"io"
+	mathrand "math/rand"
+)
+
+// newPayloadReader returns the deterministic content of a trial's object: the same seed, trial
+// and attempt always produce the same bytes, so expected payloads can be regenerated instead
+// of kept. Attempt 0 is the initial upload, the following ones are overwrites of the same key.
+func newPayloadReader(seed int64, trial, attempt int, size int64) io.Reader {
+	return io.LimitReader(mathrand.New(mathrand.NewSource(seed+int64(attempt)<<32+int64(trial))), size)
+}
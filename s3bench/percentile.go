@@ -0,0 +1,53 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"math"
+)
+
+// percentileMethod tells how a percentile is picked from sorted samples. Tools disagree on
+// this and with a handful of samples the methods may differ a lot.
+type percentileMethod string
+
+const (
+	// percentileNearestRank picks the smallest sample with at least p of the samples at or below it.
+	percentileNearestRank percentileMethod = "nearest-rank"
+	// percentileLinear interpolates between the closest ranks (Hyndman-Fan type 7, as numpy does).
+	percentileLinear percentileMethod = "linear"
+
+	// minSamplesForP90 is the sample count below which P90 is essentially the maximum.
+	minSamplesForP90 = 10
+)
+
+func parsePercentileMethod(value string) (percentileMethod, error) {
+	switch method := percentileMethod(value); method {
+	case percentileNearestRank, percentileLinear:
+		return method, nil
+	default:
+		return "", fmt.Errorf(`unsupported percentile method %q`, value)
+	}
+}
+
+// estimate returns the p-th (0..1) percentile of n sorted values, where at(i) is the i-th smallest.
+// The zero method is linear.
+func (m percentileMethod) estimate(n int, p float64, at func(i int) float64) float64 {
+	if n == 0 {
+		return 0
+	}
+	if m == percentileNearestRank {
+		rank := int(math.Ceil(p * float64(n)))
+		if rank < 1 {
+			rank = 1
+		}
+		return at(rank - 1)
+	}
+
+	h := p * float64(n-1)
+	lower := int(math.Floor(h))
+	if lower >= n-1 {
+		return at(n - 1)
+	}
+	low := at(lower)
+	return low + (h-float64(lower))*(at(lower+1)-low)
+}
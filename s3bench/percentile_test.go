@@ -0,0 +1,77 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPercentileMethods(t *testing.T) {
+	// The vector of the nearest-rank example of Wikipedia, whose linear percentiles are those of
+	// numpy.percentile.
+	textbook := []float64{15, 20, 35, 40, 50}
+	for _, tc := range []struct {
+		name   string
+		method percentileMethod
+		values []float64
+		p      float64
+		want   float64
+	}{
+		{"empty", percentileLinear, nil, 0.9, 0},
+		{"empty", percentileNearestRank, nil, 0.9, 0},
+
+		{"n=1", percentileLinear, []float64{7}, 0, 7},
+		{"n=1", percentileLinear, []float64{7}, 0.9, 7},
+		{"n=1", percentileLinear, []float64{7}, 1, 7},
+		{"n=1", percentileNearestRank, []float64{7}, 0, 7},
+		{"n=1", percentileNearestRank, []float64{7}, 0.9, 7},
+		{"n=1", percentileNearestRank, []float64{7}, 1, 7},
+
+		{"n=2", percentileLinear, []float64{10, 20}, 0.5, 15},
+		{"n=2", percentileLinear, []float64{10, 20}, 0.9, 19},
+		{"n=2", percentileNearestRank, []float64{10, 20}, 0.5, 10},
+		{"n=2", percentileNearestRank, []float64{10, 20}, 0.9, 20},
+
+		{"p=0", percentileLinear, textbook, 0, 15},
+		{"p=0", percentileNearestRank, textbook, 0, 15},
+		{"p=1", percentileLinear, textbook, 1, 50},
+		{"p=1", percentileNearestRank, textbook, 1, 50},
+
+		// Ranks falling exactly on a sample need no interpolation.
+		{"exact rank", percentileLinear, textbook, 0.25, 20},
+		{"exact rank", percentileLinear, textbook, 0.75, 40},
+		{"exact rank", percentileNearestRank, textbook, 0.4, 20},
+		{"exact rank", percentileNearestRank, textbook, 0.6, 35},
+
+		{"textbook", percentileNearestRank, textbook, 0.05, 15},
+		{"textbook", percentileNearestRank, textbook, 0.3, 20},
+		{"textbook", percentileNearestRank, textbook, 0.5, 35},
+		{"textbook", percentileLinear, textbook, 0.4, 29},
+		{"textbook", percentileLinear, textbook, 0.5, 35},
+		{"textbook", percentileLinear, textbook, 0.9, 46},
+		{"textbook", percentileLinear, []float64{1, 2, 3, 4}, 0.9, 3.7},
+		{"textbook", percentileNearestRank, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 0.9, 9},
+		{"textbook", percentileLinear, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 0.9, 9.1},
+
+		// The zero method is linear.
+		{"zero method", "", []float64{1, 2, 3, 4}, 0.9, 3.7},
+	} {
+		got := tc.method.estimate(len(tc.values), tc.p, func(i int) float64 { return tc.values[i] })
+		if math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%s: %q.estimate(%v, %v) = %v, want %v", tc.name, tc.method, tc.values, tc.p, got, tc.want)
+		}
+	}
+}
+
+func TestParsePercentileMethod(t *testing.T) {
+	for _, value := range []string{"linear", "nearest-rank"} {
+		if method, err := parsePercentileMethod(value); err != nil || string(method) != value {
+			t.Errorf("parsePercentileMethod(%q) = %q, %v", value, method, err)
+		}
+	}
+	for _, value := range []string{"", "R-7", "nearest"} {
+		if _, err := parsePercentileMethod(value); err == nil {
+			t.Errorf("parsePercentileMethod(%q) succeeded, want an error", value)
+		}
+	}
+}
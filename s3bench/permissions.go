@@ -0,0 +1,115 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// PermissionCheck is the outcome of probing a single operation with the run's credentials.
+type PermissionCheck struct {
+	Operation string        `json:"operation"`
+	Allowed   bool          `json:"allowed"`
+	Required  bool          `json:"required"`
+	Latency   time.Duration `json:"latency"`
+	Error     string        `json:"error,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
+}
+
+// checkPermissions probes PUT, STAT, GET, LIST and DELETE with a tiny object under the run
+// prefix. DELETE goes last and is attempted regardless of the other outcomes, so that the
+// probe object is gone whenever the credentials allow it.
+func (r runner) checkPermissions() []PermissionCheck {
+	var (
+		ctx    = context.Background()
+		key    = fmt.Sprintf("%ss3bench-preflight-%s", r.prefix, r.runID)
+		probe  = []byte("s3-simple-benchmarker permission probe")
+		checks []PermissionCheck
+	)
+	attempt := func(operation string, required bool, do func() error) {
+		start := time.Now()
+		err := do()
+		check := PermissionCheck{Operation: operation, Required: required, Latency: time.Since(start), Allowed: err == nil}
+		// A missing object is not denied access: the probe upload may have been the one which was denied.
+		if resp := errorResponse(err); resp.Code == "NoSuchKey" {
+			check.Allowed = true
+		}
+		if !check.Allowed {
+			check.Error = err.Error()
+			check.RequestID = errorResponse(err).RequestID
+		}
+		checks = append(checks, check)
+	}
+
+	attempt("PUT", !r.scanning(), func() error {
+		_, err := r.client.PutObject(ctx, r.bucketName, key, bytes.NewReader(probe), int64(len(probe)), minio.PutObjectOptions{
+			ServerSideEncryption: r.sse,
+			UserMetadata:         r.uploadMetadata(),
+		})
+		return err
+	})
+	// Buckets may reject uploads for their ACL alone.
+	if put := &checks[len(checks)-1]; !put.Allowed && r.acl != "" {
+		put.Error += fmt.Sprintf(` (with "-acl %s")`, r.acl)
+	}
+	attempt("STAT", r.statBeforeGet || !r.keepObjects, func() error {
+		_, err := r.client.StatObject(ctx, r.bucketName, key, minio.StatObjectOptions{})
+		return err
+	})
+	attempt("GET", true, func() error {
+		object, err := r.client.GetObject(ctx, r.bucketName, key, minio.GetObjectOptions{})
+		if err != nil {
+			return err
+		}
+		defer object.Close()
+		_, err = io.Copy(io.Discard, object)
+		return err
+	})
+	attempt("LIST", false, func() error {
+		for object := range r.client.ListObjects(ctx, r.bucketName, minio.ListObjectsOptions{Prefix: key, MaxKeys: 1}) {
+			return object.Err
+		}
+		return nil
+	})
+	attempt("DELETE", !r.keepObjects, func() error {
+		return r.client.RemoveObject(ctx, r.bucketName, key, minio.RemoveObjectOptions{})
+	})
+	return checks
+}
+
+// permissionsPassed tells whether every operation the workload needs is allowed. Denials of
+// the others are only warnings.
+func permissionsPassed(checks []PermissionCheck) bool {
+	for _, check := range checks {
+		if check.Required && !check.Allowed {
+			return false
+		}
+	}
+	return true
+}
+
+func formatPermissions(checks []PermissionCheck) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, " %-9s %-8s %12s  %s\n", "operation", "access", "latency", "detail")
+	for _, check := range checks {
+		access, detail := "allowed", ""
+		if !check.Allowed {
+			access, detail = "denied", check.Error
+			if check.RequestID != "" {
+				detail += " (request ID " + check.RequestID + ")"
+			}
+			if !check.Required {
+				detail = "WARNING: not needed by this workload, " + detail
+			}
+		}
+		line := fmt.Sprintf(" %-9s %-8s %12v  %s", check.Operation, access, formatDuration(check.Latency), detail)
+		sb.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	return sb.String()
+}
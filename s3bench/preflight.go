@@ -0,0 +1,45 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptrace"
+	"sync"
+)
+
+// preflight makes sure the bucket is reachable before anything is timed and, when the
+// endpoint is pinned with "-resolve", that the connection really went to the pinned address.
+func preflight(client ObjectStore, bucketName string, resolve resolveFlags, progress io.Writer) error {
+	var (
+		mu     sync.Mutex
+		remote net.Addr
+	)
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			remote = info.Conn.RemoteAddr()
+			mu.Unlock()
+		},
+	})
+	exists, err := client.BucketExists(ctx, bucketName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf(`bucket %q does not exist`, bucketName)
+	}
+
+	hostport := endpointHostPort(client)
+	pinned, ok := resolve.lookup(hostport)
+	if !ok || remote == nil {
+		return nil
+	}
+	fmt.Fprintf(progress, "Preflight: %s connected to %s\n", hostport, remote)
+	if host, _, err := net.SplitHostPort(remote.String()); err != nil || !net.ParseIP(host).Equal(net.ParseIP(pinned)) {
+		return fmt.Errorf(`connection to %s went to %s instead of pinned %s`, hostport, remote, pinned)
+	}
+	return nil
+}
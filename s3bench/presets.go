@@ -0,0 +1,76 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// workloadPreset is a named set of flag values mimicking a well-known workload. The values only
+// apply to the flags which are not given explicitly.
+type workloadPreset struct {
+	name        string
+	description string
+	settings    []presetSetting
+}
+
+type presetSetting struct {
+	flag, value string
+}
+
+// presets are the "-preset" choices. There are neither range reads nor read/write ratios to
+// choose from: every object is uploaded once and downloaded whole once per download trial.
+var presets = []workloadPreset{
+	{"backup", "large objects written and read back at low concurrency", []presetSetting{
+		{"fileSize", "256"}, {"concurrency", "2"}, {"trials", "20"},
+	}},
+	{"webassets", "small objects of varying size read at high concurrency, each looked up before it is fetched", []presetSetting{
+		{"fileSize", "1"}, {"size-distribution", sizeUniform}, {"size-min", "4KiB"}, {"size-max", "512KiB"},
+		{"concurrency", "32"}, {"trials", "1000"}, {"stat-before-get", "true"},
+	}},
+	{"analytics", "large objects scanned whole by several readers, with their time to first byte", []presetSetting{
+		{"fileSize", "512"}, {"concurrency", "8"}, {"trials", "40"},
+	}},
+}
+
+func findPreset(name string) (workloadPreset, error) {
+	names := make([]string, 0, len(presets))
+	for _, p := range presets {
+		if p.name == name {
+			return p, nil
+		}
+		names = append(names, p.name)
+	}
+	return workloadPreset{}, fmt.Errorf(`unknown preset %q, expected one of %s`, name, strings.Join(names, ", "))
+}
+
+// apply sets the flags of the preset which were not set on the command line.
+func (p workloadPreset) apply(flags *flag.FlagSet) error {
+	explicit := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	for _, s := range p.settings {
+		if explicit[s.flag] {
+			continue
+		}
+		if err := flags.Set(s.flag, s.value); err != nil {
+			return fmt.Errorf(`preset %s sets "-%s" to %q: %v`, p.name, s.flag, s.value, err)
+		}
+	}
+	return nil
+}
+
+func (p workloadPreset) String() string {
+	settings := make([]string, 0, len(p.settings))
+	for _, s := range p.settings {
+		settings = append(settings, fmt.Sprintf("-%s=%s", s.flag, s.value))
+	}
+	return strings.Join(settings, " ")
+}
+
+func writePresets(w io.Writer) {
+	for _, p := range presets {
+		fmt.Fprintf(w, "%s: %s\n  %s\n", p.name, p.description, p)
+	}
+}
@@ -0,0 +1,60 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	// Run on the flags of the tool, every preset names flags which exist and values they take.
+	for _, preset := range presets {
+		t.Run(preset.name, func(t *testing.T) {
+			results, err := Run(context.Background(), Config{Args: []string{
+				"-backend", "fs", "-fs-root", t.TempDir(), "-bucketName", testBucket, "-create-bucket",
+				"-preset", preset.name, "-trials", "2", "-fileSize", "1",
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			metadata := results.Report.Metadata
+			if metadata.Preset != preset.name {
+				t.Errorf("the report names the preset %q", metadata.Preset)
+			}
+			for _, s := range preset.settings {
+				option := metadata.Config.Options[s.flag]
+				switch {
+				// Given explicitly, they win over the preset.
+				case s.flag == "trials" || s.flag == "fileSize":
+					if option.Source != sourceFlag {
+						t.Errorf("-%s=%s from %s, want the command line to win", s.flag, option.Value, option.Source)
+					}
+				case option.Source != sourcePreset || option.Value != s.value:
+					t.Errorf("-%s=%s from %s, want %s from the preset", s.flag, option.Value, option.Source, s.value)
+				}
+			}
+			if results.Report.Upload.Count != 2 {
+				t.Errorf("%d uploads, want the 2 trials given explicitly", results.Report.Upload.Count)
+			}
+		})
+	}
+
+	if _, err := findPreset("archive"); err == nil || !strings.Contains(err.Error(), "backup, webassets, analytics") {
+		t.Errorf("an unknown preset: %v, want the presets listed", err)
+	}
+	flags := flag.NewFlagSet("s3bench", flag.ContinueOnError)
+	flags.Int("trials", 10, "")
+	bad := workloadPreset{name: "bad", settings: []presetSetting{{"trials", "many"}}}
+	if err := bad.apply(flags); err == nil || !strings.Contains(err.Error(), `preset bad sets "-trials" to "many"`) {
+		t.Errorf("a preset of an invalid value: %v", err)
+	}
+}
+
+func TestWritePresets(t *testing.T) {
+	var b bytes.Buffer
+	writePresets(&b)
+	assertGolden(t, "presets.txt", b.Bytes())
+}
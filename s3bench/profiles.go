@@ -0,0 +1,177 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// profilesPath is where the "-profile" settings are looked up, under the home directory.
+var profilesPath = filepath.Join(".s3bench", "profiles.yaml")
+
+// profilesFile holds the named profiles of the clusters benchmarked, e.g.
+//
+//	profiles:
+//	  ceph-lab:
+//	    endpoint: https://ceph.lab:7480
+//	    access_key: env:CEPH_LAB_ACCESS
+//	    secret_key: file:/run/secrets/ceph
+//	    region: us-east-1
+//	    bucket: bench
+//	    tls:
+//	      ca_file: /etc/ssl/ceph-lab.pem
+type profilesFile struct {
+	Profiles map[string]profile `yaml:"profiles"`
+}
+
+// profile is the defaults of the flags of a cluster. The keys may be given as "env:NAME" or
+// "file:PATH" references instead of in the clear.
+type profile struct {
+	Endpoint  string `yaml:"endpoint"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	Signature string `yaml:"signature"`
+	TLS       struct {
+		CAFile             string `yaml:"ca_file"`
+		InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	} `yaml:"tls"`
+}
+
+func defaultProfilesPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf(`unable to locate the profiles, %v`, err)
+	}
+	return filepath.Join(home, profilesPath), nil
+}
+
+func readProfiles(path string) (profilesFile, error) {
+	var profiles profilesFile
+	f, err := os.Open(path)
+	if err != nil {
+		return profiles, err
+	}
+	defer f.Close()
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&profiles); err != nil && !errors.Is(err, io.EOF) {
+		return profiles, fmt.Errorf(`%s: %v`, path, err)
+	}
+	return profiles, nil
+}
+
+// applyProfile sets the flags named profile has values for, unless they were set on the command
+// line.
+func applyProfile(flags *flag.FlagSet, path, name string) error {
+	profiles, err := readProfiles(path)
+	if err != nil {
+		return err
+	}
+	p, ok := profiles.Profiles[name]
+	if !ok {
+		return fmt.Errorf(`%s: no profile %q, there are: %s`, path, name, strings.Join(profiles.names(), ", "))
+	}
+	explicit := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	for _, s := range []struct {
+		key, flag, value string
+		secret           bool
+	}{
+		{"endpoint", "endpoint", p.Endpoint, false},
+		{"access_key", "accessKey", p.AccessKey, true},
+		{"secret_key", "secretKey", p.SecretKey, true},
+		{"region", "region", p.Region, false},
+		{"bucket", "bucketName", p.Bucket, false},
+		{"signature", "signature", p.Signature, false},
+		{"tls.ca_file", "ca-cert", p.TLS.CAFile, false},
+		{"tls.insecure_skip_verify", "insecure-skip-verify", strconv.FormatBool(p.TLS.InsecureSkipVerify), false},
+	} {
+		if s.value == "" || explicit[s.flag] {
+			continue
+		}
+		value := s.value
+		if s.secret {
+			if value, err = resolveSecret(value); err != nil {
+				return fmt.Errorf(`%s: profiles.%s.%s: %v`, path, name, s.key, err)
+			}
+		}
+		if err := flags.Set(s.flag, value); err != nil {
+			return fmt.Errorf(`%s: profiles.%s.%s: %v`, path, name, s.key, err)
+		}
+	}
+	return nil
+}
+
+// resolveSecret reads "env:NAME" from the environment and "file:PATH" from the file, without
+// its trailing newline; anything else is the secret itself.
+func resolveSecret(value string) (string, error) {
+	if name, ok := strings.CutPrefix(value, "env:"); ok {
+		secret, set := os.LookupEnv(name)
+		if !set {
+			return "", fmt.Errorf(`environment variable %s is not set`, name)
+		}
+		return secret, nil
+	}
+	if path, ok := strings.CutPrefix(value, "file:"); ok {
+		secret, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(secret), "\r\n"), nil
+	}
+	return value, nil
+}
+
+func (f profilesFile) names() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeProfiles prints the names and the endpoints of the profiles, never their keys.
+func writeProfiles(w io.Writer, path string) error {
+	profiles, err := readProfiles(path)
+	if err != nil {
+		return err
+	}
+	for _, name := range profiles.names() {
+		p := profiles.Profiles[name]
+		bucket := ""
+		if p.Bucket != "" {
+			bucket = " bucket=" + p.Bucket
+		}
+		fmt.Fprintf(w, "%s: %s%s\n", name, p.Endpoint, bucket)
+	}
+	return nil
+}
+
+// loadCACert reads the PEM certificates "-ca-cert" trusts on top of the system ones.
+func loadCACert(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf(`%s holds no PEM certificate`, path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,111 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"strings"
+	"time"
+)
+
+// profiler captures CPU and heap profiles of the measurement phases only. A nil profiler
+// does nothing, so the runner may call it unconditionally.
+type profiler struct {
+	cpuProfile, memProfile string
+	cpuFile                *os.File
+}
+
+func newProfiler(cpuProfile, memProfile string) *profiler {
+	if cpuProfile == "" && memProfile == "" {
+		return nil
+	}
+	return &profiler{cpuProfile: cpuProfile, memProfile: memProfile}
+}
+
+func (p *profiler) start(title string) error {
+	if p == nil || p.cpuProfile == "" {
+		return nil
+	}
+	f, err := os.Create(profilePath(p.cpuProfile, title))
+	if err != nil {
+		return err
+	}
+	if err := runtimepprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	p.cpuFile = f
+	return nil
+}
+
+func (p *profiler) stop(title string) error {
+	if p == nil {
+		return nil
+	}
+	if p.cpuFile != nil {
+		runtimepprof.StopCPUProfile()
+		err := p.cpuFile.Close()
+		p.cpuFile = nil
+		if err != nil {
+			return err
+		}
+	}
+	if p.memProfile == "" {
+		return nil
+	}
+
+	f, err := os.Create(profilePath(p.memProfile, title))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// Get up-to-date statistics of what is still retained.
+	runtime.GC()
+	return runtimepprof.WriteHeapProfile(f)
+}
+
+// profilePath tells apart the profiles of sub-runs (e.g. "cpu.prof" -> "cpu.plain.prof").
+func profilePath(path, title string) string {
+	if title == "" {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + title + ext
+}
+
+// startPprofServer exposes net/http/pprof on addr. The returned function shuts it down.
+func startPprofServer(addr string) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	server := &http.Server{Handler: mux}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf(`pprof server failed: %v`, err)
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "pprof is available at http://%s/debug/pprof/\n", listener.Addr())
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
+}
@@ -0,0 +1,127 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// The ways the uploads get to the download location.
+const (
+	stagingViaCopy        = "copy"
+	stagingViaReplication = "replication"
+)
+
+// readLocation is where the download phase reads the objects from with "-download-bucket" or
+// "-download-prefix". The uploads get there by a server-side copy of every object, or by a
+// replication rule of the server between the locations, which is waited for.
+type readLocation struct {
+	bucketName string
+	prefix     string
+	via        string
+	interval   time.Duration
+	timeout    time.Duration
+}
+
+// StagingStats is the step making the uploads readable at the download location, timed apart
+// from the upload and the download phases: the copies, or the waits for the replicas. Failed
+// copies and replicas missing after Timeout are not downloaded successfully either.
+type StagingStats struct {
+	Bucket  string        `json:"bucket"`
+	Prefix  string        `json:"prefix"`
+	Via     string        `json:"via"`
+	Count   int           `json:"count"`
+	Failed  int           `json:"failed,omitempty"`
+	AvgTime time.Duration `json:"avg_time"`
+	P90Time time.Duration `json:"p90_time"`
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+func (s StagingStats) String() string {
+	verb := "copied"
+	if s.Via == stagingViaReplication {
+		verb = "replicated"
+	}
+	return fmt.Sprintf(" Staging     : %s to %s/%s p90.time=%s avg.time=%s (n=%d, failed=%d)\n",
+		verb, s.Bucket, s.Prefix, formatDuration(s.P90Time), formatDuration(s.AvgTime), s.Count, s.Failed)
+}
+
+// reader is the runner of the download phase, which reads from the download location if any.
+func (r runner) reader() runner {
+	if r.readFrom == nil {
+		return r
+	}
+	rr := r
+	rr.bucketName, rr.buckets, rr.prefix = r.readFrom.bucketName, nil, r.readFrom.prefix
+	return rr
+}
+
+// stage copies every stored object to the download location, or waits until it is replicated
+// there with the uploaded size.
+func (r runner) stage() *StagingStats {
+	to := r.reader()
+	stats := &StagingStats{Bucket: to.bucketName, Prefix: to.prefix, Via: r.readFrom.via}
+	if r.readFrom.via == stagingViaReplication {
+		stats.Timeout = r.readFrom.timeout
+	}
+	var (
+		mu    sync.Mutex
+		times = r.newSampleSet()
+	)
+	schedule{workers: r.concurrency, trials: r.storedCount()}.run(func(worker int) operation {
+		return func(i int, stage string) sample {
+			trial := r.storedTrial(i)
+			from, key := r.key(trial), to.key(trial)
+			start := time.Now()
+			etag, err := r.stageObject(r.bucket(trial), from, to.bucketName, key, r.sizes.size(trial))
+			elapsed := time.Since(start)
+			if err == nil && r.readFrom.via == stagingViaCopy {
+				r.exported.add(ExportedKey{Bucket: to.bucketName, Key: key, Size: r.sizes.size(trial), ETag: etag, UploadedAt: start.Add(elapsed).UTC()})
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Printf(`%v`, &OpError{Phase: "staging", Trial: trial, Key: key, Attempt: 1, Err: err})
+				stats.Failed++
+				return sample{}
+			}
+			times.add(float64(elapsed))
+			fmt.Fprintf(r.progress, " - Trial: %d,\t%s %s, time=%s\n", trial, stats.Via, key, formatDuration(elapsed))
+			return sample{}
+		}
+	}, func(sample) {})
+
+	stats.Count = times.count()
+	stats.AvgTime, stats.P90Time = time.Duration(times.mean()), time.Duration(times.percentile(0.9))
+	return stats
+}
+
+// stageObject returns the ETag of the copy, if it made one.
+func (r runner) stageObject(srcBucket, src, dstBucket, dst string, size int64) (string, error) {
+	if r.readFrom.via == stagingViaCopy {
+		info, err := r.client.CopyObject(context.Background(),
+			minio.CopyDestOptions{Bucket: dstBucket, Object: dst, Encryption: r.sse},
+			minio.CopySrcOptions{Bucket: srcBucket, Object: src})
+		if err != nil {
+			return "", fmt.Errorf(`Unable to copy %s from %s to %s in %s, %w`, src, srcBucket, dst, dstBucket, err)
+		}
+		return info.ETag, nil
+	}
+	deadline := time.Now().Add(r.readFrom.timeout)
+	for {
+		info, err := r.client.StatObject(context.Background(), dstBucket, dst, minio.StatObjectOptions{})
+		if err == nil && info.Size == size {
+			return "", nil
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf(`%s was not replicated to %s in %s within %v`, src, dst, dstBucket, r.readFrom.timeout)
+		}
+		time.Sleep(r.readFrom.interval)
+	}
+}
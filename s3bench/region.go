@@ -0,0 +1,100 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// regionProbeTimeout bounds the probe of the bucket for a wrong-region redirect.
+const regionProbeTimeout = 30 * time.Second
+
+// RegionRedirect is a redirect of AWS to the region of the bucket which the setup followed: the
+// run went to Endpoint, signed for Region, instead of the configured endpoint and region.
+type RegionRedirect struct {
+	FromEndpoint string `json:"from_endpoint"`
+	FromRegion   string `json:"from_region,omitempty"`
+	Endpoint     string `json:"endpoint"`
+	Region       string `json:"region"`
+}
+
+// redirectedRegion is the region a wrong-region error of the bucket names as its own, and whether
+// the endpoint is wrong as well, as for a "PermanentRedirect", rather than only the signing
+// region, as for an "AuthorizationHeaderMalformed". HEAD requests carry no error body, their 301
+// and 400 only have the "x-amz-bucket-region" header.
+func redirectedRegion(err error) (region string, wrongEndpoint, ok bool) {
+	resp := minio.ToErrorResponse(err)
+	if resp.Region == "" {
+		return "", false, false
+	}
+	switch {
+	case resp.Code == "PermanentRedirect" || resp.StatusCode == http.StatusMovedPermanently:
+		return resp.Region, true, true
+	case resp.Code == "AuthorizationHeaderMalformed" || resp.Code == "InvalidRegion" || resp.StatusCode == http.StatusBadRequest:
+		return resp.Region, false, true
+	}
+	return "", false, false
+}
+
+// regionalEndpoint is the endpoint of the region for an AWS endpoint, with its scheme kept, or
+// false for any other host, whose regional endpoints are unknown.
+func regionalEndpoint(endpoint, region string) (string, bool) {
+	e, err := parseEndpoint(endpoint)
+	if err != nil {
+		return "", false
+	}
+	host, port, err := net.SplitHostPort(e.host)
+	if err != nil {
+		host, port = e.host, ""
+	}
+	if !strings.HasSuffix(host, ".amazonaws.com") || !strings.HasPrefix(host, "s3") {
+		return "", false
+	}
+	e.host = "s3." + region + ".amazonaws.com"
+	if port != "" {
+		e.host = net.JoinHostPort(e.host, port)
+	}
+	return e.String(), true
+}
+
+// probeRegion heads the bucket once to find out whether AWS redirects it to another region. It
+// returns the endpoint and the region to use, and the redirect, if one was followed; any other
+// error is left to the preflight check. Without follow, or when the redirect cannot be followed,
+// the error names the endpoint and the region of the bucket.
+func probeRegion(client ObjectStore, bucketName, endpoint, region string, follow, pinnedHost bool) (string, string, *RegionRedirect, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), regionProbeTimeout)
+	defer cancel()
+	_, err := client.BucketExists(ctx, bucketName)
+	bucketRegion, wrongEndpoint, ok := redirectedRegion(err)
+	if !ok || !wrongEndpoint && bucketRegion == region {
+		return endpoint, region, nil, nil
+	}
+	redirect := &RegionRedirect{FromEndpoint: endpoint, FromRegion: region, Endpoint: endpoint, Region: bucketRegion}
+	var advice string
+	if wrongEndpoint {
+		regional, known := regionalEndpoint(endpoint, bucketRegion)
+		if !known {
+			return "", "", nil, fmt.Errorf(`The bucket %s is in the region %s, which %s redirects to another endpoint: point "-endpoint" to the one of %s`, bucketName, bucketRegion, endpoint, bucketRegion)
+		}
+		redirect.Endpoint, advice = regional, fmt.Sprintf(`"-endpoint %s" and `, regional)
+		if pinnedHost {
+			follow = false
+		}
+	}
+	if !follow {
+		signed := ""
+		if region != "" {
+			signed = " signed for " + region
+		}
+		return "", "", nil, fmt.Errorf(`The bucket %s is in the region %s, not reachable at %s%s: use %s"-region %s"`, bucketName, bucketRegion, endpoint, signed, advice, bucketRegion)
+	}
+	log.Printf(`WARNING: the bucket %s is in the region %s, following the redirect to %s`, bucketName, bucketRegion, redirect.Endpoint)
+	return redirect.Endpoint, redirect.Region, redirect, nil
+}
@@ -0,0 +1,104 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newRegionRedirector is a server answering every request as AWS answers those of a bucket of
+// another region: with the status and the region of the bucket in "x-amz-bucket-region".
+func newRegionRedirector(t *testing.T, status int, bucketRegion string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("x-amz-bucket-region", bucketRegion)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProbeRegion(t *testing.T) {
+	const aws = "https://s3.amazonaws.com"
+	for _, tc := range []struct {
+		name               string
+		status             int
+		endpoint           string
+		follow, pinnedHost bool
+		// wantEndpoint and wantRegion are those of the followed redirect, or empty with the error.
+		wantEndpoint, wantRegion, wantErr string
+	}{
+		{"301 followed", http.StatusMovedPermanently, aws, true, false, "https://s3.eu-west-1.amazonaws.com", "eu-west-1", ""},
+		{"301 not followed", http.StatusMovedPermanently, aws, false, false, "", "", `use "-endpoint https://s3.eu-west-1.amazonaws.com" and "-region eu-west-1"`},
+		// The regional endpoint would not match the pinned host.
+		{"301 with -host-header", http.StatusMovedPermanently, aws, true, true, "", "", `"-endpoint https://s3.eu-west-1.amazonaws.com" and "-region eu-west-1"`},
+		{"301 of another provider", http.StatusMovedPermanently, "https://storage.example.com", true, false, "", "", `point "-endpoint" to the one of eu-west-1`},
+		// Only the signing region is wrong, the endpoint stays.
+		{"400 followed", http.StatusBadRequest, aws, true, false, aws, "eu-west-1", ""},
+		{"400 not followed", http.StatusBadRequest, aws, false, false, "", "", `signed for us-east-1: use "-region eu-west-1"`},
+	} {
+		for _, sdk := range []string{sdkMinio, sdkAWS} {
+			server := newRegionRedirector(t, tc.status, "eu-west-1")
+			opts := clientOptions{accessKey: "access", secretKey: "secret", signature: signatureV4, region: "us-east-1", requests: &requestCounter{}, wire: newWireCounter()}
+			newClient := newObjectStore
+			if sdk == sdkAWS {
+				newClient = newAWSClient
+			}
+			store, err := newClient(server.URL, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The store talks to the server, the endpoint is the one the redirect is judged by.
+			endpoint, region, redirect, err := probeRegion(store, testBucket, tc.endpoint, "us-east-1", tc.follow, tc.pinnedHost)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) || redirect != nil {
+					t.Errorf("%s (%s): %v with redirect %+v, want the error %q", tc.name, sdk, err, redirect, tc.wantErr)
+				}
+				continue
+			}
+			want := RegionRedirect{FromEndpoint: tc.endpoint, FromRegion: "us-east-1", Endpoint: tc.wantEndpoint, Region: tc.wantRegion}
+			if err != nil || endpoint != tc.wantEndpoint || region != tc.wantRegion || redirect == nil || *redirect != want {
+				t.Errorf("%s (%s): %s %s %+v %v, want the redirect %+v", tc.name, sdk, endpoint, region, redirect, err, want)
+			}
+		}
+	}
+
+	// Neither a bucket of the configured region nor an error without a region is a redirect.
+	for _, tc := range []struct {
+		name         string
+		status       int
+		bucketRegion string
+	}{
+		{"found", http.StatusOK, "us-east-1"},
+		{"400 of the same region", http.StatusBadRequest, "us-east-1"},
+		{"403", http.StatusForbidden, ""},
+	} {
+		server := newRegionRedirector(t, tc.status, tc.bucketRegion)
+		store, err := newObjectStore(server.URL, clientOptions{accessKey: "access", secretKey: "secret", signature: signatureV4, region: "us-east-1", requests: &requestCounter{}, wire: newWireCounter()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if endpoint, region, redirect, err := probeRegion(store, testBucket, aws, "us-east-1", true, false); err != nil || endpoint != aws || region != "us-east-1" || redirect != nil {
+			t.Errorf("%s: %s %s %+v %v, want the endpoint and the region kept", tc.name, endpoint, region, redirect, err)
+		}
+	}
+}
+
+func TestRegionalEndpoint(t *testing.T) {
+	for _, tc := range []struct {
+		endpoint, want string
+		ok             bool
+	}{
+		{"https://s3.amazonaws.com", "https://s3.eu-west-1.amazonaws.com", true},
+		{"https://s3.us-east-2.amazonaws.com", "https://s3.eu-west-1.amazonaws.com", true},
+		{"http://s3.amazonaws.com:8080", "http://s3.eu-west-1.amazonaws.com:8080", true},
+		{"https://minio.example.com", "", false},
+		{"https://bucket.amazonaws.com", "", false},
+	} {
+		if got, ok := regionalEndpoint(tc.endpoint, "eu-west-1"); got != tc.want || ok != tc.ok {
+			t.Errorf("regionalEndpoint(%s) = %s %t, want %s %t", tc.endpoint, got, ok, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// traceOp is a line of a "-replay" trace file.
+type traceOp struct {
+	Offset float64 `json:"offset_ms"`
+	Op     string  `json:"op"`
+	Key    string  `json:"key"`
+	Size   int64   `json:"size"`
+}
+
+func (op traceOp) at(speed float64) time.Duration {
+	return time.Duration(op.Offset / speed * float64(time.Millisecond))
+}
+
+// readTrace parses and validates a trace, ordering the operations by offset.
+func readTrace(path string) ([]traceOp, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var ops []traceOp
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var op traceOp
+		if err := json.Unmarshal([]byte(text), &op); err != nil {
+			return nil, fmt.Errorf(`line %d: %v`, line, err)
+		}
+		op.Op = strings.ToLower(op.Op)
+		switch {
+		case op.Op != "put" && op.Op != "get":
+			return nil, fmt.Errorf(`line %d: unknown op %q, expected "put" or "get"`, line, op.Op)
+		case op.Key == "":
+			return nil, fmt.Errorf(`line %d: key is missing`, line)
+		case op.Offset < 0 || op.Size < 0:
+			return nil, fmt.Errorf(`line %d: neither offset_ms nor size may be negative`, line)
+		}
+		ops = append(ops, op)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf(`no operations in %s`, path)
+	}
+	sort.SliceStable(ops, func(i, j int) bool { return ops[i].Offset < ops[j].Offset })
+	return ops, nil
+}
+
+// describeTrace is what validating a trace prints.
+func describeTrace(ops []traceOp, speed float64) string {
+	counts := map[string]int{}
+	for _, op := range ops {
+		counts[op.Op]++
+	}
+	return fmt.Sprintf("%d operations (put=%d get=%d) over %v at speed %gx", len(ops), counts["put"], counts["get"], ops[len(ops)-1].at(speed), speed)
+}
+
+// ReplayReport is the outcome of replaying a trace.
+type ReplayReport struct {
+	Label      string           `json:"label,omitempty"`
+	RunID      string           `json:"run_id"`
+	Trace      string           `json:"trace"`
+	Speed      float64          `json:"speed"`
+	Operations []ReplayOpStats  `json:"operations"`
+	Lateness   ReplayAdherence  `json:"lateness"`
+	Elapsed    time.Duration    `json:"elapsed"`
+	Resources  *ClientResources `json:"client_resources,omitempty"`
+	Config     *EffectiveConfig `json:"config,omitempty"`
+}
+
+type ReplayOpStats struct {
+	Op         string        `json:"op"`
+	Count      int           `json:"count"`
+	AvgTime    time.Duration `json:"avg_time"`
+	P90Time    time.Duration `json:"p90_time"`
+	Bytes      int64         `json:"bytes"`
+	Throughput float64       `json:"throughput"`
+}
+
+// ReplayAdherence tells how late the operations started versus their scaled offsets.
+type ReplayAdherence struct {
+	Avg time.Duration `json:"avg"`
+	P90 time.Duration `json:"p90"`
+	Max time.Duration `json:"max"`
+}
+
+func (r ReplayReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, " Replay      : %s at %gx, elapsed=%s\n", r.Trace, r.Speed, formatDuration(r.Elapsed))
+	for _, op := range r.Operations {
+		fmt.Fprintf(&sb, " %-12s: p90.time=%s avg.time=%s throughput=%s MB/s (n=%d)\n",
+			strings.ToUpper(op.Op), formatDuration(op.P90Time), formatDuration(op.AvgTime), formatSpeed(op.Throughput), op.Count)
+	}
+	fmt.Fprintf(&sb, " Lateness    : p90=%s avg=%s max=%s\n", formatDuration(r.Lateness.P90), formatDuration(r.Lateness.Avg), formatDuration(r.Lateness.Max))
+	if r.Resources != nil {
+		sb.WriteString(r.Resources.String())
+	}
+	return sb.String()
+}
+
+// replay runs the operations at their offsets on the worker pool; an operation starts late
+// when all workers are busy, which is what the lateness shows.
+func (r runner) replay(path string, ops []traceOp, speed float64, prepopulate bool) ReplayReport {
+	written := map[string]bool{}
+	if prepopulate {
+		fmt.Fprintf(r.progress, "Prepopulate:\n")
+		sizes := map[string]int64{}
+		for _, op := range ops {
+			if op.Op == "get" && op.Size > sizes[op.Key] {
+				sizes[op.Key] = op.Size
+			}
+		}
+		for key, size := range sizes {
+			if r.halted() {
+				break
+			}
+			r.replayPut(key, make([]byte, size))
+			written[key] = true
+		}
+	}
+	for _, op := range ops {
+		if op.Op == "put" {
+			written[op.Key] = true
+		}
+	}
+
+	var (
+		mu          sync.Mutex
+		times       = map[string]sampleSet{"put": r.newSampleSet(), "get": r.newSampleSet()}
+		transferred = map[string]int64{}
+		lateness    = r.newSampleSet()
+		maxLate     time.Duration
+		queue       = make(chan traceOp)
+		wg          sync.WaitGroup
+	)
+
+	fmt.Fprintf(r.progress, "Replay:\n")
+	sampler := startResourceSampler()
+	start := time.Now()
+	for w := 0; w < r.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf []byte
+			for op := range queue {
+				late := time.Since(start.Add(op.at(speed)))
+				var duration time.Duration
+				switch op.Op {
+				case "put":
+					if int64(cap(buf)) < op.Size {
+						buf = make([]byte, op.Size)
+					}
+					duration = r.replayPut(op.Key, buf[:op.Size])
+				case "get":
+					duration, op.Size = r.replayGet(op.Key)
+				}
+				if r.halted() {
+					continue
+				}
+				fmt.Fprintf(r.progress, " - %s %s,\ttime=%s, late=%s\n", op.Op, op.Key, formatDuration(duration), formatDuration(late))
+
+				mu.Lock()
+				times[op.Op].add(float64(duration))
+				transferred[op.Op] += op.Size
+				lateness.add(float64(late))
+				if late > maxLate {
+					maxLate = late
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, op := range ops {
+		if r.halted() {
+			break
+		}
+		time.Sleep(time.Until(start.Add(op.at(speed))))
+		queue <- op
+	}
+	close(queue)
+	wg.Wait()
+	elapsed := time.Since(start)
+	resources := sampler.Stop()
+
+	if !r.keepObjects {
+		keys := make([]string, 0, len(written))
+		for key := range written {
+			keys = append(keys, r.prefix+key)
+		}
+		sort.Strings(keys)
+		r.removeKeys(r.bucketName, keys)
+	}
+
+	report := ReplayReport{
+		Label: r.label, RunID: r.runID, Trace: path, Speed: speed, Elapsed: elapsed, Resources: resources, Config: r.config,
+		Lateness: ReplayAdherence{Avg: time.Duration(lateness.mean()), P90: time.Duration(lateness.percentile(0.9)), Max: maxLate},
+	}
+	for _, op := range []string{"put", "get"} {
+		if times[op].count() == 0 {
+			continue
+		}
+		report.Operations = append(report.Operations, ReplayOpStats{
+			Op:         op,
+			Count:      times[op].count(),
+			AvgTime:    time.Duration(times[op].mean()),
+			P90Time:    time.Duration(times[op].percentile(0.9)),
+			Bytes:      transferred[op],
+			Throughput: float64(transferred[op]) / elapsed.Seconds() / 1024 / 1024, // MB/s
+		})
+	}
+	return report
+}
+
+func (r runner) replayPut(key string, data []byte) time.Duration {
+	rand.Read(data)
+	key = r.prefix + key
+	start := time.Now()
+	_, err := r.client.PutObject(context.Background(), r.bucketName, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ServerSideEncryption: r.sse,
+		UserMetadata:         r.uploadMetadata(),
+	})
+	duration := time.Since(start)
+	if err != nil {
+		r.fatalf(`Unable to upload %s to %s, %v`, key, r.bucketName, err)
+	}
+	return duration
+}
+
+func (r runner) replayGet(key string) (time.Duration, int64) {
+	key = r.prefix + key
+	start := time.Now()
+	object, err := r.client.GetObject(context.Background(), r.bucketName, key, minio.GetObjectOptions{})
+	if err != nil {
+		r.fatalf(`Unable to download %s from %s, %v`, key, r.bucketName, err)
+		return time.Since(start), 0
+	}
+	defer object.Close()
+	size, err := io.Copy(io.Discard, object)
+	duration := time.Since(start)
+	if err != nil {
+		r.fatalf(`Unable to receive %s from %s, %v`, key, r.bucketName, err)
+	}
+	return duration, size
+}
@@ -0,0 +1,106 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// replication describes the second site which objects uploaded to the endpoint are expected
+// to get replicated to.
+type replication struct {
+	target     ObjectStore
+	bucketName string
+	interval   time.Duration
+	timeout    time.Duration
+}
+
+// ReplicationStats is the distribution of the delays until uploaded objects became visible
+// with the same size and ETag on the target.
+type ReplicationStats struct {
+	Count    int           `json:"count"`
+	Missing  int           `json:"missing"`
+	AvgDelay time.Duration `json:"avg_delay"`
+	P50Delay time.Duration `json:"p50_delay"`
+	P90Delay time.Duration `json:"p90_delay"`
+	P99Delay time.Duration `json:"p99_delay"`
+	Timeout  time.Duration `json:"timeout"`
+}
+
+func (s ReplicationStats) String() string {
+	return fmt.Sprintf(" Replication : p50.delay=%s p90.delay=%s p99.delay=%s avg.delay=%s (n=%d, missing=%d after %v)\n",
+		formatDuration(s.P50Delay), formatDuration(s.P90Delay), formatDuration(s.P99Delay), formatDuration(s.AvgDelay), s.Count, s.Missing, s.Timeout)
+}
+
+// checkReplication uploads its own set of objects and, right after each upload, polls the
+// target until the object shows up there or the timeout expires.
+func (r runner) checkReplication() *ReplicationStats {
+	rr := r
+	rr.prefix = r.prefix + "replication/"
+
+	var (
+		mu      sync.Mutex
+		delays  = r.newSampleSet()
+		missing int
+	)
+	rr.uploaded = schedule{workers: r.concurrency, trials: r.trials}.run(func(worker int) operation {
+		upload := rr.upload("replication", 0, worker)
+		return func(i int, stage string) sample {
+			s := upload(i, stage)
+			if s.err != nil {
+				return s
+			}
+			delay, ok := rr.awaitReplica(s.key, s.bytes, s.etag, s.start.Add(s.duration))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if ok {
+				delays.add(float64(delay))
+				fmt.Fprintf(r.progress, "   %s replicated after %s\n", s.key, formatDuration(delay))
+			} else {
+				missing++
+				fmt.Fprintf(r.progress, "   %s not replicated within %v\n", s.key, r.replication.timeout)
+			}
+			return s
+		}
+	}, func(sample) {})
+
+	if !r.keepObjects {
+		rr.removeFiles()
+	}
+
+	return &ReplicationStats{
+		Count:    delays.count(),
+		Missing:  missing,
+		AvgDelay: time.Duration(delays.mean()),
+		P50Delay: time.Duration(delays.percentile(0.5)),
+		P90Delay: time.Duration(delays.percentile(0.9)),
+		P99Delay: time.Duration(delays.percentile(0.99)),
+		Timeout:  r.replication.timeout,
+	}
+}
+
+// awaitReplica returns how long after uploaded the object became visible on the target. Errors
+// other than a missing object are retried too, as the target may be briefly unavailable.
+func (r runner) awaitReplica(key string, size int64, etag string, uploaded time.Time) (time.Duration, bool) {
+	deadline := uploaded.Add(r.replication.timeout)
+	for {
+		info, err := r.replication.target.StatObject(context.Background(), r.replication.bucketName, key, minio.StatObjectOptions{})
+		if err == nil && info.Size == size && sameETag(info.ETag, etag) {
+			return time.Since(uploaded), true
+		}
+		if time.Now().After(deadline) {
+			return 0, false
+		}
+		time.Sleep(r.replication.interval)
+	}
+}
+
+func sameETag(a, b string) bool {
+	return strings.Trim(a, `"`) == strings.Trim(b, `"`)
+}
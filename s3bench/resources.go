@@ -0,0 +1,118 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const resourceSamplingInterval = time.Second
+
+// ClientResources describes how much of the client machine the benchmark itself consumed.
+// CPU is in percent of a single core, so 400 means four fully busy cores.
+type ClientResources struct {
+	PeakRSS        uint64        `json:"peak_rss_bytes"`
+	AvgCPU         float64       `json:"avg_cpu_pct"`
+	GCPause        time.Duration `json:"gc_pause"`
+	PeakGoroutines int           `json:"peak_goroutines"`
+	Cores          int           `json:"cores"`
+	ClientLimited  bool          `json:"client_limited"`
+	// Phases, GenerationShare (in percent) and the verdicts are those of the heuristics of
+	// checkCPUBound.
+	Phases          []PhaseCPU `json:"phases,omitempty"`
+	GenerationShare float64    `json:"generation_pct,omitempty"`
+	GenerationBound bool       `json:"generation_bound,omitempty"`
+	CPUBound        bool       `json:"cpu_bound"`
+}
+
+func (c ClientResources) String() string {
+	s := fmt.Sprintf(` Client      : peak.rss=%.1f MB avg.cpu=%.0f%% (of %d cores) gc.pause=%s goroutines.peak=%d
+`, float64(c.PeakRSS)/1024/1024, c.AvgCPU, c.Cores, formatDuration(c.GCPause), c.PeakGoroutines)
+	if c.ClientLimited {
+		s += "  WARNING: the client was close to using all of its CPU cores, results may be client-limited\n"
+	}
+	return s + c.cpuBoundWarnings()
+}
+
+type resourceSample struct {
+	At         time.Time
+	CPUPercent float64
+	Goroutines int
+}
+
+// resourceSampler collects process resource usage from its own goroutine so that none of
+// the work happens on the timed paths.
+type resourceSampler struct {
+	stop    chan struct{}
+	done    sync.WaitGroup
+	samples []resourceSample
+
+	startCPU     time.Duration
+	startGCPause uint64
+	started      time.Time
+}
+
+func startResourceSampler() *resourceSampler {
+	s := &resourceSampler{stop: make(chan struct{}), started: time.Now()}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	s.startGCPause = mem.PauseTotalNs
+	s.startCPU, _ = processCPUTime()
+
+	s.done.Add(1)
+	go s.loop()
+	return s
+}
+
+func (s *resourceSampler) loop() {
+	defer s.done.Done()
+
+	ticker := time.NewTicker(resourceSamplingInterval)
+	defer ticker.Stop()
+
+	lastAt, lastCPU := s.started, s.startCPU
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			sample := resourceSample{At: now, Goroutines: runtime.NumGoroutine()}
+			if cpu, err := processCPUTime(); err == nil {
+				sample.CPUPercent = float64(cpu-lastCPU) / float64(now.Sub(lastAt)) * 100
+				lastCPU = cpu
+			}
+			lastAt = now
+			s.samples = append(s.samples, sample)
+		}
+	}
+}
+
+// Stop ends sampling and summarizes the whole sampled window.
+func (s *resourceSampler) Stop() *ClientResources {
+	close(s.stop)
+	s.done.Wait()
+
+	elapsed := time.Since(s.started)
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	r := &ClientResources{
+		GCPause:        time.Duration(mem.PauseTotalNs - s.startGCPause),
+		PeakGoroutines: runtime.NumGoroutine(),
+		Cores:          runtime.NumCPU(),
+	}
+	for _, sample := range s.samples {
+		if sample.Goroutines > r.PeakGoroutines {
+			r.PeakGoroutines = sample.Goroutines
+		}
+	}
+	if cpu, err := processCPUTime(); err == nil && elapsed > 0 {
+		r.AvgCPU = float64(cpu-s.startCPU) / float64(elapsed) * 100
+	}
+	r.PeakRSS, _ = peakRSS()
+	r.ClientLimited = r.AvgCPU >= 0.9*float64(r.Cores)*100
+	return r
+}
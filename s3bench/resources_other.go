@@ -0,0 +1,20 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+
+//go:build !unix
+
+package s3bench
+
+import (
+	"errors"
+	"time"
+)
+
+var errResourceUsageUnsupported = errors.New(`resource usage is not supported on this platform`)
+
+func processCPUTime() (time.Duration, error) {
+	return 0, errResourceUsageUnsupported
+}
+
+func peakRSS() (uint64, error) {
+	return 0, errResourceUsageUnsupported
+}
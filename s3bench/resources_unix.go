@@ -0,0 +1,32 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+
+//go:build unix
+
+package s3bench
+
+import (
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// processCPUTime returns user and system CPU time consumed by the process so far.
+func processCPUTime() (time.Duration, error) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, err
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), nil
+}
+
+func peakRSS() (uint64, error) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, err
+	}
+	// Darwin reports bytes, the other unixes report kilobytes.
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return uint64(usage.Maxrss), nil
+	}
+	return uint64(usage.Maxrss) * 1024, nil
+}
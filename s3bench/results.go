@@ -0,0 +1,147 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+
+// Package s3bench is the benchmark as a library: Run runs it in-process as the command line tool
+// would and returns the Results of the trials it measured.
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Config is what a Run is given: the command line as the tool takes it, the program name left out,
+// e.g. {"-endpoint", "http://127.0.0.1:9000", "-bucketName", "bench", "-json"}.
+type Config struct {
+	Args []string
+}
+
+// ExitError is the failure of a Run with the exit code of the tool: 2 when the credentials are
+// rejected, 3 when thresholds failed, 4 when objects were left behind and 1 for anything else.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("the run exited with %d", e.Code)
+}
+
+// Run is the tool in-process: the phases stop early once ctx is done, the trials in flight
+// completing. A failure which would exit the tool halts the run the same way and is returned as
+// an ExitError.
+func Run(ctx context.Context, config Config) (Results, error) {
+	results := Results{Samples: map[string][]Sample{}}
+	code := runCLI(ctx, config.Args, &results)
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	if code != 0 {
+		return results, &ExitError{Code: code}
+	}
+	return results, nil
+}
+
+// Sample is a trial the statistics of its phase are made of: a successful one of the plateau
+// or of a burst.
+type Sample struct {
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Bytes    int64         `json:"bytes"`
+	// Speed is in MB/s.
+	Speed float64 `json:"speed"`
+}
+
+// Results are the samples of the "upload" and the "download" phases of a Run, in the order they
+// started, along with the report for the reports of a single run. The samples of "-runs" and
+// "-compare-sse" are those of every run, Report is nil then, as it is for "-replay" and
+// "-growth-benchmark".
+type Results struct {
+	Report  *Report
+	Samples map[string][]Sample
+	// method is the "-percentile-method" of Percentile.
+	method percentileMethod
+}
+
+// newResults are results without samples yet, whose Percentile estimates with method.
+func newResults(method percentileMethod) Results {
+	return Results{Samples: map[string][]Sample{}, method: method}
+}
+
+// resultSample is the Sample of a trial.
+func resultSample(s sample) Sample {
+	return Sample{Start: s.start, Duration: s.duration, Bytes: s.bytes, Speed: s.speed}
+}
+
+// add appends the samples of a phase, keeping the phase in start order.
+func (r *Results) add(phase string, samples []Sample) {
+	if r == nil || len(samples) == 0 {
+		return
+	}
+	r.Samples[phase] = append(r.Samples[phase], samples...)
+	sort.SliceStable(r.Samples[phase], func(i, j int) bool { return r.Samples[phase][i].Start.Before(r.Samples[phase][j].Start) })
+}
+
+// Percentile is the p-th (0..1) percentile of the durations of phase, as the report estimates
+// it with the "all" sample strategy, 0 without samples.
+func (r Results) Percentile(phase string, p float64) time.Duration {
+	return time.Duration(r.durations(phase).percentile(p))
+}
+
+// Mean is the mean duration of phase, 0 without samples.
+func (r Results) Mean(phase string) time.Duration {
+	return time.Duration(r.durations(phase).mean())
+}
+
+func (r Results) durations(phase string) *allSamples {
+	durations := &allSamples{method: r.method}
+	for _, s := range r.Samples[phase] {
+		durations.add(float64(s.Duration))
+	}
+	return durations
+}
+
+// HistogramBucket counts the samples up to UpperBound, those above the previous bound.
+type HistogramBucket struct {
+	UpperBound time.Duration `json:"upper_bound"`
+	Count      int           `json:"count"`
+}
+
+// Histogram counts the durations of phase into the buckets of the given ascending upper bounds,
+// with a last bucket of an UpperBound of 0 for the durations above all of them.
+func (r Results) Histogram(phase string, bounds []time.Duration) []HistogramBucket {
+	histogram := make([]HistogramBucket, len(bounds)+1)
+	for i, bound := range bounds {
+		histogram[i].UpperBound = bound
+	}
+	for _, s := range r.Samples[phase] {
+		i := sort.Search(len(bounds), func(i int) bool { return s.Duration <= bounds[i] })
+		histogram[i].Count++
+	}
+	return histogram
+}
+
+// Merge are the samples of both results, as if one run had measured them; the merged report
+// would be none of the two, Report is nil.
+func (r Results) Merge(other Results) Results {
+	merged := newResults(r.method)
+	for _, results := range []Results{r, other} {
+		for phase, samples := range results.Samples {
+			merged.add(phase, samples)
+		}
+	}
+	return merged
+}
+
+// FilterWindow are the samples which started from from up to, but excluding, to; Report is nil.
+func (r Results) FilterWindow(from, to time.Time) Results {
+	window := newResults(r.method)
+	for phase, samples := range r.Samples {
+		first := sort.Search(len(samples), func(i int) bool { return !samples[i].Start.Before(from) })
+		last := sort.Search(len(samples), func(i int) bool { return !samples[i].Start.Before(to) })
+		if first < last {
+			window.Samples[phase] = append([]Sample(nil), samples[first:last]...)
+		}
+	}
+	return window
+}
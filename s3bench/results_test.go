@@ -0,0 +1,154 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// resultsOf are upload results of the given durations in milliseconds, a second apart from start.
+func resultsOf(start time.Time, millis ...int) Results {
+	results := Results{Samples: map[string][]Sample{}}
+	for i, ms := range millis {
+		results.Samples["upload"] = append(results.Samples["upload"], Sample{Start: start.Add(time.Duration(i) * time.Second), Duration: time.Duration(ms) * time.Millisecond, Bytes: 1 << 20})
+	}
+	return results
+}
+
+func TestResultsPercentile(t *testing.T) {
+	results := resultsOf(time.Now(), 50, 10, 40, 20, 30)
+	for _, tc := range []struct {
+		method percentileMethod
+		p      float64
+		want   time.Duration
+	}{
+		{percentileLinear, 0.5, 30 * time.Millisecond},
+		{percentileLinear, 0.9, 46 * time.Millisecond},
+		{percentileNearestRank, 0.9, 50 * time.Millisecond},
+		{percentileNearestRank, 0, 10 * time.Millisecond},
+	} {
+		results.method = tc.method
+		if got := results.Percentile("upload", tc.p); got != tc.want {
+			t.Errorf("%s P%v = %v, want %v", tc.method, tc.p*100, got, tc.want)
+		}
+	}
+	if got := results.Percentile("download", 0.9); got != 0 {
+		t.Errorf("P90 of a phase without samples = %v, want 0", got)
+	}
+}
+
+func TestResultsHistogram(t *testing.T) {
+	results := resultsOf(time.Now(), 5, 10, 11, 20, 35, 99)
+	histogram := results.Histogram("upload", []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond})
+	want := []HistogramBucket{{10 * time.Millisecond, 2}, {20 * time.Millisecond, 2}, {50 * time.Millisecond, 1}, {0, 1}}
+	if len(histogram) != len(want) {
+		t.Fatalf("%d buckets, want %d", len(histogram), len(want))
+	}
+	for i := range want {
+		if histogram[i] != want[i] {
+			t.Errorf("bucket %d = %+v, want %+v", i, histogram[i], want[i])
+		}
+	}
+}
+
+func TestResultsMergeAndFilterWindow(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	first, second := resultsOf(start, 10, 20, 30), resultsOf(start.Add(500*time.Millisecond), 40, 50)
+	first.Report = &Report{}
+
+	merged := first.Merge(second)
+	if merged.Report != nil {
+		t.Error("a merge kept the report of one of the results")
+	}
+	var durations []time.Duration
+	for _, s := range merged.Samples["upload"] {
+		durations = append(durations, s.Duration/time.Millisecond)
+	}
+	if want := []time.Duration{10, 40, 20, 50, 30}; fmt.Sprint(durations) != fmt.Sprint(want) {
+		t.Errorf("merged durations %v, want %v by start", durations, want)
+	}
+	if len(first.Samples["upload"]) != 3 {
+		t.Errorf("the merge changed the results merged into: %d samples", len(first.Samples["upload"]))
+	}
+
+	window := merged.FilterWindow(start.Add(time.Second), start.Add(2*time.Second))
+	if samples := window.Samples["upload"]; len(samples) != 2 || samples[0].Duration != 20*time.Millisecond || samples[1].Duration != 50*time.Millisecond {
+		t.Errorf("window of [1s, 2s) holds %+v, want the samples of 20ms and 50ms", samples)
+	}
+	if empty := merged.FilterWindow(start.Add(time.Hour), start.Add(2*time.Hour)); len(empty.Samples) != 0 {
+		t.Errorf("a window after the run holds %+v", empty.Samples)
+	}
+}
+
+func TestAggregateRunsPooled(t *testing.T) {
+	start := time.Now()
+	first, second := resultsOf(start, 10, 20, 30), resultsOf(start, 110, 120, 130)
+	runs := []Report{{Upload: PhaseStats{P90Time: first.Percentile("upload", 0.9)}}, {Upload: PhaseStats{P90Time: second.Percentile("upload", 0.9)}}}
+	multi := aggregateRuns(runs, first.Merge(second))
+	// The P90 of the pooled trials is that of the slower run, not the mean of the two P90s.
+	if got, want := multi.Aggregate.Upload.PooledP90Time, 125*time.Millisecond; got != want {
+		t.Errorf("pooled P90 %v, want %v", got, want)
+	}
+	if got, want := multi.Aggregate.Upload.MeanP90Time, 78*time.Millisecond; got != want {
+		t.Errorf("mean P90 %v, want %v", got, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	args := []string{"-backend", "fs", "-fs-root", t.TempDir(), "-bucketName", testBucket, "-create-bucket", "-trials", "4", "-fileSize", "1"}
+	// Run twice, as a library would, every run gets flags of its own.
+	for i := 0; i < 2; i++ {
+		results, err := Run(context.Background(), Config{Args: args})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results.Report == nil || results.Report.Upload.Count != 4 || results.Report.Download.Count != 4 {
+			t.Fatalf("report %+v, want 4 uploads and 4 downloads", results.Report)
+		}
+		if len(results.Samples["upload"]) != 4 || len(results.Samples["download"]) != 4 {
+			t.Errorf("%d upload and %d download samples, want 4 each", len(results.Samples["upload"]), len(results.Samples["download"]))
+		}
+		if p90 := results.Percentile("upload", 0.9); p90 != results.Report.Upload.P90Time {
+			t.Errorf("P90 of the samples %v, the report has %v", p90, results.Report.Upload.P90Time)
+		}
+	}
+
+	// The samples of "-runs" are those of every run.
+	results, err := Run(context.Background(), Config{Args: append(args[:len(args):len(args)], "-runs", "2")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.Report != nil || len(results.Samples["upload"]) != 8 {
+		t.Errorf("report %+v and %d upload samples of two runs, want none and 8", results.Report, len(results.Samples["upload"]))
+	}
+
+	// The formatting of a run is none of the next one's.
+	if _, err := Run(context.Background(), Config{Args: append(args[:len(args):len(args)], "-duration-precision", "1", "-pretty")}); err != nil {
+		t.Fatal(err)
+	}
+	if currentFormatting != defaultFormatting {
+		t.Errorf("formatting %+v after the run, want %+v", currentFormatting, defaultFormatting)
+	}
+
+	var exit *ExitError
+	if _, err := Run(context.Background(), Config{Args: append(args[:len(args):len(args)], "-trials", "0")}); !errors.As(err, &exit) || exit.Code != 1 {
+		t.Errorf("invalid options: %v, want exit code 1", err)
+	}
+	// A failed trial halts the run with "-abort-threshold 0", the process goes on.
+	if _, err := Run(context.Background(), Config{Args: append(args[:len(args):len(args)], "-fault-inject", "get:error:1")}); !errors.As(err, &exit) || exit.Code == 0 {
+		t.Errorf("failed download: %v, want an exit code", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = Run(ctx, Config{Args: args})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled run: %v", err)
+	}
+	if n := len(results.Samples["upload"]); n != 0 {
+		t.Errorf("cancelled before it started, the run measured %d uploads", n)
+	}
+}
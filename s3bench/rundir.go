@@ -0,0 +1,224 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The fixed names of the files in the "-output-dir".
+const (
+	runReportJSON     = "report.json"
+	runReportMarkdown = "report.md"
+	runSamples        = "samples.jsonl"
+	runConfig         = "config.yaml"
+	runStdoutLog      = "stdout.log"
+	runIndex          = "index.json"
+)
+
+// redactedFlags hold credentials, or URLs which usually embed a token.
+var redactedFlags = map[string]bool{
+	"accessKey":               true,
+	"secretKey":               true,
+	"target-access-key":       true,
+	"target-secret-key":       true,
+	"webhook-url":             true,
+	"client-encrypt-password": true,
+}
+
+// runDirectory is the "-output-dir" of a run, which holds all of its outputs under fixed names
+// and an index of them. A nil runDirectory writes nothing.
+type runDirectory struct {
+	path  string
+	files []string
+
+	// stdout is the original standard output while it is copied to the log.
+	stdout *os.File
+	pipe   *os.File
+	copied chan error
+}
+
+// newRunDirectory creates the directory if it is missing; an existing one has to be empty unless
+// force is set, as a run directory is an artifact of a single run.
+func newRunDirectory(path string, force bool) (*runDirectory, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 && !force {
+		return nil, fmt.Errorf(`%s is not empty, pass "-force" to write into it anyway`, path)
+	}
+	return &runDirectory{path: path}, nil
+}
+
+// file is the path of the named file in the directory, which is listed in the index.
+func (d *runDirectory) file(name string) string {
+	d.files = append(d.files, name)
+	return filepath.Join(d.path, name)
+}
+
+// captureStdout copies everything written to the standard output from now on to the log as well.
+func (d *runDirectory) captureStdout() error {
+	log, err := os.Create(d.file(runStdoutLog))
+	if err != nil {
+		return err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		log.Close()
+		return err
+	}
+	d.stdout, d.pipe, d.copied = os.Stdout, w, make(chan error, 1)
+	go func(stdout *os.File) {
+		_, err := io.Copy(io.MultiWriter(stdout, log), r)
+		if closeErr := log.Close(); err == nil {
+			err = closeErr
+		}
+		d.copied <- err
+	}(os.Stdout)
+	os.Stdout = w
+	return nil
+}
+
+// writeConfig writes the effective configuration, the credentials fingerprinted.
+func (d *runDirectory) writeConfig(config *EffectiveConfig) error {
+	if d == nil {
+		return nil
+	}
+	content, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(d.file(runConfig), content, 0o644)
+}
+
+// writeReport writes the report as JSON and as Markdown, text being its human readable form.
+func (d *runDirectory) writeReport(report any, text string) error {
+	if d == nil {
+		return nil
+	}
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(d.file(runReportJSON), append(content, '\n'), 0o644); err != nil {
+		return err
+	}
+	return writeFileAtomic(d.file(runReportMarkdown), []byte(markdownReport(report, text)), 0o644)
+}
+
+// markdownReport tabulates the phases of every run of the report ahead of its human readable form.
+func markdownReport(report any, text string) string {
+	var (
+		sb   strings.Builder
+		runs []struct {
+			title  string
+			report Report
+		}
+	)
+	add := func(title string, r Report) {
+		runs = append(runs, struct {
+			title  string
+			report Report
+		}{title, r})
+	}
+	switch report := report.(type) {
+	case Report:
+		add("", report)
+	case MultiRunReport:
+		for i, r := range report.Runs {
+			add(fmt.Sprintf("run-%d", i+1), r)
+		}
+	case Comparison:
+		add("plain", report.Plain)
+		add("encrypted", report.Encrypted)
+	}
+
+	fmt.Fprintf(&sb, "# %s report\n\n", appName)
+	for _, run := range runs {
+		if run.title != "" {
+			fmt.Fprintf(&sb, "## %s\n\n", run.title)
+		}
+		m := run.report.Metadata
+		fmt.Fprintf(&sb, "- Run ID: `%s`\n- Version: `%s`\n- Concurrency: %d\n", m.RunID, m.Version, m.Concurrency)
+		if run.report.Label != "" {
+			fmt.Fprintf(&sb, "- Label: %s\n", run.report.Label)
+		}
+		sb.WriteString("\n| Phase | Trials | Failed | Avg time | P90 time | P90 speed (MB/s) | Throughput (MB/s) |\n|---|---:|---:|---:|---:|---:|---:|\n")
+		for _, phase := range []struct {
+			name  string
+			stats PhaseStats
+		}{{"upload", run.report.Upload}, {"download", run.report.Download}} {
+			fmt.Fprintf(&sb, "| %s | %d | %d | %s | %s | %s | %s |\n", phase.name, phase.stats.Count, phase.stats.Failed,
+				formatDuration(phase.stats.AvgTime), formatDuration(phase.stats.P90Time), formatSpeed(phase.stats.P90Speed), formatSpeed(phase.stats.Throughput))
+		}
+		sb.WriteString("\n")
+	}
+	fmt.Fprintf(&sb, "## Report\n\n```text\n%s\n```\n", strings.TrimRight(text, "\n"))
+	return sb.String()
+}
+
+// runIndexEntry lets downstream tooling verify the integrity of a file of the directory.
+type runIndexEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// close restores the standard output and writes the index of the files. A run which fails
+// fatally leaves its directory without an index.
+func (d *runDirectory) close() error {
+	if d == nil {
+		return nil
+	}
+	if d.pipe != nil {
+		os.Stdout = d.stdout
+		d.pipe.Close()
+		if err := <-d.copied; err != nil {
+			return fmt.Errorf(`unable to write %s: %w`, runStdoutLog, err)
+		}
+	}
+	sort.Strings(d.files)
+	index := struct {
+		Files []runIndexEntry `json:"files"`
+	}{}
+	for _, name := range d.files {
+		entry, err := indexFile(filepath.Join(d.path, name))
+		if err != nil {
+			return err
+		}
+		entry.Name = name
+		index.Files = append(index.Files, entry)
+	}
+	content, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(d.path, runIndex), append(content, '\n'), 0o644)
+}
+
+func indexFile(path string) (runIndexEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return runIndexEntry{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return runIndexEntry{}, err
+	}
+	return runIndexEntry{Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
@@ -0,0 +1,1430 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// runner performs one upload-download-cleanup cycle against a bucket.
+type runner struct {
+	client ObjectStore
+	// hosts, when set, receive the workers round-robin; client is one of them.
+	hosts      []ObjectStore
+	bucketName string
+	// buckets, when several, receive the trials round-robin; bucketName is the first of them.
+	buckets     []string
+	prefix      string
+	title       string
+	sizes       sizeDistribution
+	trials      int
+	sse         encrypt.ServerSide
+	keepObjects bool
+	// cleanupWaitReplicated bounds how long the cleanup waits for pending replications.
+	cleanupWaitReplicated time.Duration
+	progress              io.Writer
+	profiler              *profiler
+	tracing               *tracing
+	statsd                *statsd
+	label                 string
+	webhook               *webhook
+
+	events        *eventWriter
+	statBeforeGet bool
+
+	// seed selects deterministic payloads when non-zero.
+	seed         int64
+	verifySample float64
+	// verifyMode "blocks" seals the payload blocks and checks them while downloading.
+	verifyMode string
+	// requests counts what the clients send, prices estimate its cost.
+	requests *requestCounter
+	prices   Prices
+	// clock compares the response dates to the local clock per phase, up to maxClockSkew.
+	clock        *clockSkewTracker
+	maxClockSkew time.Duration
+	// wire counts the bytes on the connections per phase.
+	wire *wireCounter
+	// debugHTTP dumps the requests of the trials once they are over.
+	debugHTTP *httpDumper
+	// preset names the "-preset" the workload flags were defaulted to.
+	preset string
+	// keys nests the keys of the trials with "-key-depth".
+	keys keyspace
+	// cancels gives up on some uploads with "-abort-ratio", kept are the trials whose uploads it
+	// left alone once the upload phase is over.
+	cancels cancelPlan
+	kept    []int
+	// start is when the first run began with "-start-at" or "-start-in".
+	start *SyncedStart
+
+	concurrency      int
+	rampUp, rampDown time.Duration
+	rate             float64
+	// autoTrials stops the upload and the download phases once their P90 estimate moved less than
+	// confidence over the last trials, trials is then the "-max-trials" cap.
+	autoTrials       bool
+	confidence       float64
+	window           time.Duration
+	newSampleSet     sampleStrategy
+	sampleStrategy   string
+	perWorkerStats   bool
+	attribution      bool
+	verbose          bool
+	conns            *connTracker
+	percentileMethod percentileMethod
+	// bootstrapResamples, when positive, resamples the plateau trials of the phases after the run.
+	bootstrapResamples int
+	bootstrapSeed      int64
+
+	signature            string
+	disableContentSHA256 bool
+	sendContentMD5       bool
+	checksum             minio.ChecksumType
+
+	// runID is attached to the objects along with the rest of metadata, unless "-no-metadata".
+	runID    string
+	metadata map[string]string
+	// acl is the "-acl" canned ACL of the uploads.
+	acl string
+	// expectContinue is the "-expect-continue" timeout of the uploads, 0 without.
+	expectContinue time.Duration
+	// uploadPace is the rate in bytes per second the payload of every upload is produced at, 0
+	// without "-upload-pace".
+	uploadPace int64
+	// timeBudgets caps the wall-clock of the "upload" and "download" phases, 0 without
+	// "-upload-time-budget" and "-download-time-budget".
+	timeBudgets map[string]time.Duration
+	// userAgent is recorded in the metadata.
+	userAgent string
+	// ipVersion is the "-ip-version" the connections are restricted to.
+	ipVersion string
+
+	// faultInject is the "-fault-inject" specification, labelled in the outputs.
+	faultInject string
+	faultSeed   int64
+
+	// junitOutput receives an errored test case when the run is aborted.
+	junitOutput string
+	// halt records the failure which stopped the run, cancelling ctx; ctx stops the phases once
+	// done, results keeps their samples for Run. All may be nil.
+	halt    *runHalt
+	ctx     context.Context
+	results *Results
+
+	// overwriteTrials is the number of passes re-uploading every object after the upload phase.
+	overwriteTrials int
+	// scanPrefix, when set, replaces the upload phase: the downloads read a sample of scanSample
+	// existing objects listed under it, scanned.
+	scanPrefix string
+	// keysScan, when set, describes the "-scan-keys" objects scanned holds, which replace the upload
+	// phase just the same.
+	keysScan *ScanStats
+	// verifyListing lists the run prefix after the upload phase to find uploads which did not stick.
+	verifyListing bool
+	// state, when set, persists the completed trials so that a run which died can be resumed.
+	state *stateFile
+	// contentEncoding ("gzip") compresses the uploads and decodes the downloads.
+	contentEncoding string
+	// interleave runs the upload and the download phases at the same time.
+	interleave bool
+	scanSample int
+	scanned    []scannedObject
+	// replication, when set, is checked in a phase of its own after the download phase.
+	replication *replication
+	// readFrom, when set, is where the download phase reads the uploads from, after staging
+	// them there.
+	readFrom *readLocation
+	// altEndpoint, when set, serves the objects once more in a phase after the download phase.
+	altEndpoint *altEndpoint
+	// missTrials is the number of probes for objects which do not exist.
+	missTrials int
+	// cacheProbe repeats the download phase, pass labels the downloads of either pass in the
+	// events then.
+	cacheProbe bool
+	pass       int
+	// getQuery are the "-get-query" parameters, which the downloads of a transformed runner
+	// attach.
+	getQuery    getQueryFlags
+	transformed bool
+	// affinity maps the downloads of the workers onto the stored objects.
+	affinity *downloadAffinity
+	// exported collects the stored objects for "-export-keys".
+	exported *keyExporter
+	// runDir is the "-output-dir" the objects left behind by a failed cleanup are listed in.
+	runDir *runDirectory
+	// config is the effective configuration the reports carry.
+	config *EffectiveConfig
+	// burst, when positive, runs the upload and the download phases as "-trials" bursts of this
+	// many operations released at once, burstGap apart.
+	burst    int
+	burstGap time.Duration
+	// clientCrypt encrypts the uploads and decrypts the downloads with "-client-encrypt".
+	clientCrypt *clientCrypt
+
+	// abortThreshold is the number of failed trials which stops a phase; with 0 the first
+	// failure aborts the run.
+	abortThreshold int
+
+	// uploadRetries is how many times a failed upload is retried, each try limited to
+	// uploadTimeout unless 0.
+	uploadRetries int
+	uploadTimeout time.Duration
+
+	// workerClients, when set, are the clients of every worker, which rotate over its trials.
+	// Otherwise the workers share client or the clients of the hosts.
+	workerClients [][]ObjectStore
+	clientMode    string
+
+	// phaseGap pauses between the uploads and the downloads, followed by waiting up to
+	// quiesceTimeout (unless 0) for the server to settle.
+	phaseGap       time.Duration
+	quiesceTimeout time.Duration
+
+	// setup is how long it took to get to the run, reported as a part of its timing.
+	setup time.Duration
+
+	// uploaded is the number of objects the upload phase created, ramp stages included.
+	uploaded int
+}
+
+// sample is a single measured trial.
+type sample struct {
+	worker    int
+	host      string
+	bucket    string
+	trial     int
+	key       string
+	etag      string
+	versionID string
+	// sha256 is the hex "-checksum SHA256" of the upload, only kept for "-export-keys".
+	sha256       string
+	stage        string
+	start        time.Time
+	duration     time.Duration
+	bytes        int64
+	speed        float64
+	statDuration time.Duration
+	// digestDuration is spent on computing the upload digests before the upload is timed.
+	digestDuration time.Duration
+	// continueWait is how long the upload waited for "100 Continue"; it is part of the duration.
+	continueWait time.Duration
+	// ttfb is the time to the first byte of a download.
+	ttfb        time.Duration
+	freshConns  int
+	reusedConns int
+	// err is set for failed trials, which only make it into the failure counts, an *OpError of
+	// the attempt which failed last.
+	err     error
+	attempt int
+	// recovered uploads were found stored by a retry although their try had failed.
+	recovered bool
+	// skipped downloads found their scanned object gone.
+	skipped bool
+	// encodeDuration is spent on compressing an upload before it is timed, decoded are the bytes
+	// of the payload before the encoding with "-content-encoding". mangled downloads lost it.
+	encodeDuration time.Duration
+	decoded        int64
+	mangled        bool
+	// verifyDuration is spent on checking the blocks of a download with "-verify blocks", it is
+	// not part of the duration; corruptAt is the offset of the first corrupted one.
+	verifyDuration time.Duration
+	blocks         int64
+	corrupted      bool
+	corruptAt      int64
+	// cancelled uploads were given up on by "-abort-ratio", cancelWait after the cancellation;
+	// cancelLate ones completed before it took effect.
+	cancelled  bool
+	cancelLate bool
+	cancelWait time.Duration
+	// generateDuration is spent on the payload before the upload is timed, requests are the
+	// request times of the timed operation.
+	generateDuration time.Duration
+	requests         requestTimes
+	// cryptDuration is spent on the "-client-encrypt" of the plaintext bytes, before an upload
+	// is timed or taken out of the download time.
+	cryptDuration time.Duration
+	plaintext     int64
+	// due is when the schedule meant the trial to start, handedOver when its first request
+	// reached the transport; prepared is the part of it spent on the payload before the timing.
+	due, handedOver time.Time
+	prepared        time.Duration
+}
+
+func (r runner) run() Report {
+	// Every run counts its own distinct connections.
+	r.conns = newConnTracker()
+
+	header := ""
+	if r.title != "" {
+		header = fmt.Sprintf(" (%s)", r.title)
+	}
+
+	if r.faultInject != "" {
+		fmt.Fprintf(r.progress, "%s\n", faultInjectionWarning(r.faultInject, r.faultSeed))
+	}
+
+	if resumed := r.state.stats(); resumed != nil {
+		fmt.Fprintf(r.progress, "Resuming from %s: upload=%d download=%d trials restored\n", resumed.StateFile, resumed.Restored["upload"], resumed.Restored["download"])
+	}
+
+	timing, watch := Timing{Setup: r.setup}, startStopwatch()
+	requests := r.requests.snapshot()
+	sampler := startResourceSampler()
+	if err := r.profiler.start(r.title); err != nil {
+		sampler.Stop()
+		r.fatalf(`Unable to start CPU profile: %v`, err)
+		return Report{}
+	}
+
+	var (
+		uploadWindows   = newWindowRecorder(r.title, "upload", r.window, r.events, r.percentileMethod)
+		downloadWindows = newWindowRecorder(r.title, "download", r.window, r.events, r.percentileMethod)
+		uploads         = r.newPhaseRecorder()
+		downloads       = r.newPhaseRecorder()
+		overwrites      *phaseRecorder
+		misses          *phaseRecorder
+		repeats         *phaseRecorder
+		transformed     *phaseRecorder
+		gap             *GapStats
+		alt             *AltEndpointStats
+		replicated      *ReplicationStats
+		scanned         = r.keysScan
+		stored          *storedObjects
+		bursts          []BurstStats
+		listing         *ListingCheck
+		staging         *StagingStats
+		skews           []PhaseClockSkew
+		wire            []PhaseWire
+		recordUpload    = r.exported.wrap(r.affinity.wrap(uploads.record))
+	)
+	if r.verifyListing {
+		stored = newStoredObjects()
+		recordUpload = stored.wrap(recordUpload)
+	}
+	phases := []runPhase{
+		{"Listing", r.scanPrefix != "", &timing.Listing, func() {
+			r.scanned, scanned = r.scanObjects()
+			r.uploaded, r.trials = len(r.scanned), len(r.scanned)
+		}},
+		{"Upload and download (interleaved)", r.interleave, &timing.Upload, func() {
+			r.uploaded = r.interleaved(uploads, downloads, uploadWindows, downloadWindows)
+		}},
+		{"Upload", !r.scanning() && !r.interleave, &timing.Upload, func() {
+			upload, record := r.state.resume("upload", uploadWindows.wrap(r.uploader("upload", 0))), r.state.track("upload", recordUpload)
+			if r.burst > 0 {
+				var stats []BurstStats
+				r.uploaded, stats = r.burstSchedule("upload", uploads).run(upload, record)
+				bursts = append(bursts, stats...)
+			} else {
+				r.uploaded = r.schedule("upload", uploads).run(upload, record)
+			}
+			r.kept = r.cancels.kept(r.uploaded)
+			r.state.complete("upload")
+			r.exportKeys()
+		}},
+		{"Listing check", r.verifyListing, &timing.ListingCheck, func() {
+			listing = r.checkListing(stored)
+		}},
+		// Every pass overwrites all the objects before the next one starts, so that the last pass
+		// is what verification expects.
+		{"Overwrite", r.overwriteTrials > 0, &timing.Overwrite, func() {
+			overwrites = r.newPhaseRecorder()
+			for attempt := 1; attempt <= r.overwriteTrials; attempt++ {
+				schedule{workers: r.concurrency, trials: r.uploaded, abort: overwrites.abort}.run(r.uploader("overwrite", attempt), r.exported.wrap(overwrites.record))
+			}
+			r.exportKeys()
+		}},
+		{"Staging", r.readFrom != nil, &timing.Staging, func() {
+			staging = r.stage()
+			r.exportKeys()
+		}},
+		{"Gap", r.phaseGap > 0 || r.quiesceTimeout > 0, &timing.Gap, func() {
+			gap = r.gap()
+		}},
+		{"Download", !r.interleave, &timing.Download, func() {
+			reader := r.reader()
+			if r.cacheProbe {
+				reader.pass = 1
+			}
+			download, record := r.state.resume("download", downloadWindows.wrap(reader.downloader)), r.state.track("download", downloads.record)
+			if r.burst > 0 {
+				_, stats := r.burstSchedule("download", downloads).run(download, record)
+				bursts = append(bursts, stats...)
+			} else {
+				r.schedule("download", downloads).run(download, record)
+			}
+			r.state.complete("download")
+		}},
+		// The same schedule reads the same keys in the same order.
+		{"Cache probe", r.cacheProbe, &timing.CacheProbe, func() {
+			repeats = r.newPhaseRecorder()
+			reader := r.reader()
+			reader.pass = 2
+			r.schedule("cache-probe", repeats).run(reader.downloader, repeats.record)
+		}},
+		{"Transformed download", len(r.getQuery) > 0, &timing.Transformed, func() {
+			transformed = r.newPhaseRecorder()
+			reader := r.reader()
+			reader.transformed = true
+			r.schedule("transformed", transformed).run(reader.downloader, transformed.record)
+		}},
+		{"Alt endpoint", r.altEndpoint != nil, &timing.AltEndpoint, func() {
+			alt = r.checkAltEndpoint()
+		}},
+		{"Replication", r.replication != nil, &timing.Replication, func() {
+			replicated = r.checkReplication()
+		}},
+		{"Miss", r.missTrials > 0, &timing.Miss, func() {
+			misses = r.newPhaseRecorder()
+			schedule{workers: r.concurrency, trials: r.missTrials, abort: misses.abort}.run(r.prober, misses.record)
+		}},
+	}
+	// payload is what the trials of all the phases transferred so far, the recorders of the later
+	// phases included once they exist.
+	payload := func() int64 {
+		return uploads.transferred() + downloads.transferred() + overwrites.transferred() + repeats.transferred() + transformed.transferred() + misses.transferred()
+	}
+	for _, phase := range phases {
+		if !phase.enabled {
+			continue
+		}
+		if r.halted() {
+			break
+		}
+		fmt.Fprintf(r.progress, "%s%s:\n", phase.title, header)
+		r.clock.begin()
+		r.wire.begin()
+		before := payload()
+		phase.run()
+		*phase.elapsed = watch.lap()
+		if skew, ok := r.clock.end(phase.title); ok {
+			skews = append(skews, skew)
+		}
+		if bytes, ok := r.wire.end(phase.title, payload()-before); ok {
+			wire = append(wire, bytes)
+		}
+	}
+	timing.WarmUp = warmUp(r.rampUp, timing.Upload) + warmUp(r.rampUp, timing.Download)
+
+	if err := r.profiler.stop(r.title); err != nil {
+		log.Printf(`Unable to write profiles: %v`, err)
+	}
+	resources := sampler.Stop()
+	watch.lap()
+	// The objects of a halted run are left for "-state-file" to resume from, as are those of an
+	// interrupted one.
+	if r.halted() {
+		return Report{}
+	}
+
+	var verification *Verification
+	if r.verifySample > 0 {
+		fmt.Fprintf(r.progress, "Verify%s:\n", header)
+		r.wire.begin()
+		verification = r.verifyFiles()
+		timing.Verify = watch.lap()
+		if bytes, ok := r.wire.end("Verify", 0); ok {
+			wire = append(wire, bytes)
+		}
+	}
+
+	if scanned != nil {
+		scanned.Skipped = downloads.skipped
+	}
+	var cancelled *CancelStats
+	if r.cancels.ratio > 0 {
+		cancelled = uploads.cancelStats(r.cancels.ratio)
+		r.removeIncompleteUploads(cancelled)
+	}
+	var cleanup *CleanupStats
+	// Scanned objects are someone's data, they are never removed.
+	if !r.keepObjects && !r.scanning() {
+		r.wire.begin()
+		removed := r.removeFiles()
+		if r.readFrom != nil {
+			removed.add(r.reader().removeFiles())
+		}
+		r.writeRemaining(&removed)
+		cleanup = &removed
+	}
+	if bytes, ok := r.wire.end("Cleanup", 0); ok {
+		wire = append(wire, bytes)
+	}
+	timing.Cleanup = watch.lap()
+	timing.Total = r.setup + watch.elapsed()
+
+	r.results.add("upload", uploads.samples)
+	r.results.add("download", downloads.samples)
+	report := Report{
+		SchemaVersion:   reportSchemaVersion,
+		Label:           r.label,
+		Upload:          uploads.stats(),
+		Download:        downloads.stats(),
+		ClientResources: resources,
+		Verification:    verification,
+		Cleanup:         cleanup,
+		Cancelled:       cancelled,
+		Scan:            scanned,
+		Listing:         listing,
+		Windows:         append(uploadWindows.close(), downloadWindows.close()...),
+		Bursts:          bursts,
+		Connections:     r.conns.opened(),
+		ClockSkew:       newClockSkew(skews, r.maxClockSkew),
+		Wire:            wire,
+		Timing:          timing,
+		Metadata: RunMetadata{
+			StatBeforeGet: r.statBeforeGet,
+			Concurrency:   r.concurrency,
+			RampUp:        r.rampUp,
+			RampDown:      r.rampDown,
+			Rate:          r.rate,
+
+			SampleStrategy:   r.sampleStrategy,
+			PercentileMethod: r.percentileMethod,
+
+			Signature:            r.signature,
+			DisableContentSHA256: r.disableContentSHA256,
+			SendContentMD5:       r.sendContentMD5,
+			ChecksumAlgorithm:    r.checksum.String(),
+			RunID:                r.runID,
+			OverwriteTrials:      r.overwriteTrials,
+			Start:                r.start,
+			Keyspace:             r.keys.shape(),
+			Config:               r.config,
+			Burst:                r.burst,
+			BurstGap:             r.burstGap,
+			ClientEncrypt:        r.clientCrypt.cipher(),
+			Preset:               r.preset,
+			FaultInject:          r.faultInject,
+			FaultInjectSeed:      r.faultSeed,
+			MissTrials:           r.missTrials,
+			CacheProbe:           r.cacheProbe,
+			DownloadAffinity:     r.affinity.String(),
+			UploadPace:           r.uploadPace,
+			AbortThreshold:       r.abortThreshold,
+			UploadRetries:        r.uploadRetries,
+			UploadTimeout:        r.uploadTimeout,
+			ClientMode:           r.clientMode,
+			ACL:                  r.acl,
+			ExpectContinue:       r.expectContinue,
+			UserAgent:            r.userAgent,
+			Version:              version,
+			IPVersion:            r.ipVersion,
+			RemoteIP:             r.conns.firstRemoteIP(),
+			PhaseGap:             r.phaseGap,
+			QuiesceTimeout:       r.quiesceTimeout,
+		},
+	}
+	if r.workerClients != nil {
+		report.Metadata.ClientsPerWorker = len(r.workerClients[0])
+	}
+	if r.sizes.kind != sizeFixed {
+		sizes := r.sizes.realized(r.uploaded)
+		report.Sizes = &sizes
+		report.SizeCurve = newSizeCurve(uploads.bySize, downloads.bySize)
+	}
+	if r.attribution {
+		report.Attribution = &LatencyAttribution{Upload: uploads.budget, Download: downloads.budget}
+	}
+	if r.perWorkerStats {
+		report.Workers = &PerWorkerStats{Upload: uploads.workerStats(), Download: downloads.workerStats()}
+	}
+	if len(r.hosts) > 1 {
+		report.Hosts = &PerHostStats{Upload: uploads.hostStats(), Download: downloads.hostStats()}
+	}
+	if len(r.buckets) > 1 {
+		report.Buckets = &PerBucketStats{Upload: uploads.bucketStats(), Download: downloads.bucketStats()}
+	}
+	if r.statBeforeGet {
+		stat := summarize(downloads.statTimes, r.newSampleSet())
+		report.Stat = &stat
+	}
+	report.Replication = replicated
+	report.Gap = gap
+	report.Staging = staging
+	report.Resumed = r.state.stats()
+	report.Cost = newCostReport(r.requests.snapshot().since(requests), r.prices)
+	if r.contentEncoding != "" {
+		report.Encoding = &EncodingStats{
+			Encoding:        r.contentEncoding,
+			AvgCompressTime: time.Duration(uploads.encodeTimes.mean()),
+			P90CompressTime: time.Duration(uploads.encodeTimes.percentile(0.9)),
+			Mangled:         downloads.mangled,
+		}
+		if uploads.decoded > 0 {
+			report.Encoding.Ratio = float64(report.Upload.Bytes) / float64(uploads.decoded)
+		}
+	}
+	if r.clientCrypt != nil {
+		report.ClientEncryption = newClientEncryptionStats(uploads, downloads)
+	}
+	if r.verifyMode == verifyBlocks {
+		sort.Slice(downloads.corrupted, func(i, j int) bool { return downloads.corrupted[i].Key < downloads.corrupted[j].Key })
+		report.Blocks = &BlockVerification{
+			Objects:       downloads.verifyTimes.count(),
+			Blocks:        downloads.blocks,
+			Corrupted:     downloads.corrupted,
+			AvgVerifyTime: time.Duration(downloads.verifyTimes.mean()),
+			P90VerifyTime: time.Duration(downloads.verifyTimes.percentile(0.9)),
+		}
+	}
+	if r.interleave {
+		report.Interleaved = newInterleaveStats(report.Upload, report.Download)
+	}
+	report.AltEndpoint = alt
+	if repeats != nil {
+		report.CacheProbe = newCacheProbe(report.Download, repeats.stats())
+	}
+	if transformed != nil {
+		report.Transformed = newTransformedGET(r.getQuery, report.Download, transformed.stats())
+	}
+	if misses != nil {
+		miss := misses.stats()
+		report.Miss = &miss
+	}
+	if overwrites != nil {
+		overwrite := overwrites.stats()
+		report.Overwrite = &overwrite
+	}
+	if r.sendContentMD5 || r.checksum.IsSet() {
+		digest := summarize(uploads.digestTimes, r.newSampleSet())
+		report.Digest = &digest
+	}
+	if r.uploadPace > 0 {
+		report.Pace = newUploadPace(r.uploadPace, report.Upload)
+	}
+	if r.expectContinue > 0 {
+		waits := summarize(uploads.continueTimes, r.newSampleSet())
+		report.Continue = &waits
+	}
+	if r.verbose {
+		for _, phase := range report.phases() {
+			printWorstDelays(r.progress, phase.name, phase.stats.ClientDelay)
+		}
+	}
+	resources.checkCPUBound(sampler.samples, report.Upload, report.Download, uploads.generated)
+	r.statsd.summary(report)
+	return report
+}
+
+// runPhase is a step of the run's phase list, timed into elapsed.
+type runPhase struct {
+	title   string
+	enabled bool
+	elapsed *time.Duration
+	run     func()
+}
+
+func (r runner) schedule(phase string, p *phaseRecorder) schedule {
+	p.backlog = newBacklogRecorder(r.title, phase, r.rate, r.events)
+	p.stability = newStabilityTracker(r.autoTrials, r.confidence)
+	p.timeBudget = newTimeBudget(r.timeBudgets[phase])
+	return schedule{workers: r.concurrency, trials: r.trials, rampUp: r.rampUp, rampDown: r.rampDown, abort: p.abort, rate: r.rate, backlog: p.backlog, budget: p.timeBudget}
+}
+
+// phaseRecorder accumulates the statistics of the plateau trials of a phase. Its wall-clock
+// window spans from the first plateau trial start to the last plateau trial end.
+type phaseRecorder struct {
+	mu                   sync.Mutex
+	newSampleSet         sampleStrategy
+	times, speeds        sampleSet
+	statTimes            sampleSet
+	digestTimes          sampleSet
+	continueTimes        sampleSet
+	ttfbTimes            sampleSet
+	bytes                int64
+	windowStart, lastEnd time.Time
+	conns                ConnectionStats
+
+	// workers is only tracked with "-per-worker-stats".
+	workers map[int]*workerRecorder
+	// hosts is only tracked with several "-hosts", buckets with several "-bucketName".
+	hosts   map[string]*hostRecorder
+	buckets map[string]*hostRecorder
+
+	// abort is cancelled once abortThreshold trials failed, which stops the phase's schedule.
+	abort          context.Context
+	cancel         context.CancelFunc
+	abortThreshold int
+	operations     int
+	failed         int
+	abortedAfter   int
+	// errors counts the failed trials by class.
+	errors errorCounts
+	// wasted is what the failed trials transferred before they failed.
+	wasted    int64
+	recovered int
+	skipped   int
+	// anomalies are the successful trials which measured a non-positive duration.
+	anomalies int
+	// budget attributes the plateau trial times with "-attribution".
+	budget LatencyBudget
+	// generated is the time the plateau trials spent on generating their payloads.
+	generated time.Duration
+	// cancelTimes are the times to cancel the uploads "-abort-ratio" gave up on.
+	cancelTimes sampleSet
+	cancelLate  int
+	// trend fits the latency by trial number of the plateau trials.
+	trend trendRecorder
+	// bySize is only tracked with a "-size-distribution" other than "fixed".
+	bySize map[int64]*sizeMean
+	// encodeTimes, decoded and mangled are only tracked with "-content-encoding".
+	encodeTimes sampleSet
+	decoded     int64
+	mangled     int
+	// verifyTimes, blocks and corrupted are only tracked with "-verify blocks".
+	verifyTimes sampleSet
+	blocks      int64
+	corrupted   []CorruptedBlock
+	// cryptTimes, crypt, endToEnd and plaintext are only tracked with "-client-encrypt".
+	cryptTimes      sampleSet
+	crypt, endToEnd time.Duration
+	plaintext       int64
+	// payload is what the successful trials of every stage transferred.
+	payload int64
+	// delays are the client delays of the plateau trials.
+	delays delayRecorder
+	// bootstrap is only kept with "-bootstrap".
+	bootstrap *bootstrapper
+	// samples are only kept for the Results of Run.
+	samples     []Sample
+	keepSamples bool
+
+	backlog *backlogRecorder
+	// stability, when set, stops the phase through abort once its P90 estimate is stable.
+	stability *stabilityTracker
+	// timeBudget, when set, stops the phase once its "-upload-time-budget" or
+	// "-download-time-budget" is spent.
+	timeBudget *timeBudget
+}
+
+type workerRecorder struct {
+	times sampleSet
+	bytes int64
+}
+
+func (r runner) newPhaseRecorder() *phaseRecorder {
+	p := &phaseRecorder{newSampleSet: r.newSampleSet, times: r.newSampleSet(), speeds: r.newSampleSet(), statTimes: r.newSampleSet(), digestTimes: r.newSampleSet(), continueTimes: r.newSampleSet(), ttfbTimes: r.newSampleSet(), cancelTimes: r.newSampleSet(), encodeTimes: r.newSampleSet(), verifyTimes: r.newSampleSet(), cryptTimes: r.newSampleSet()}
+	p.delays.delays = r.newSampleSet()
+	p.bootstrap = newBootstrapper(r.bootstrapResamples, r.bootstrapSeed)
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	p.abort, p.cancel = context.WithCancel(ctx)
+	p.abortThreshold = r.abortThreshold
+	p.keepSamples = r.results != nil
+	if r.perWorkerStats {
+		p.workers = map[int]*workerRecorder{}
+	}
+	if len(r.hosts) > 1 {
+		p.hosts = map[string]*hostRecorder{}
+	}
+	if len(r.buckets) > 1 {
+		p.buckets = map[string]*hostRecorder{}
+	}
+	if r.sizes.kind != sizeFixed {
+		p.bySize = map[int64]*sizeMean{}
+	}
+	return p
+}
+
+func (p *phaseRecorder) record(s sample) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Connections are accounted for every stage: whatever ramp-up opened is what the plateau reuses.
+	p.conns.Fresh += s.freshConns
+	p.conns.Reused += s.reusedConns
+	p.operations++
+	if s.skipped {
+		p.skipped++
+		return
+	}
+	if s.cancelled {
+		if s.cancelLate {
+			p.cancelLate++
+		} else {
+			p.cancelTimes.add(float64(s.cancelWait))
+		}
+		return
+	}
+	if s.recovered {
+		p.recovered++
+	}
+	if s.err != nil {
+		p.failed++
+		p.wasted += s.bytes
+		if p.errors == nil {
+			p.errors = errorCounts{}
+		}
+		p.errors[classifyError(s.err)]++
+		if s.corrupted {
+			p.corrupted = append(p.corrupted, CorruptedBlock{Key: s.key, Offset: s.corruptAt})
+		}
+		if p.abortThreshold > 0 && p.failed >= p.abortThreshold && p.abortedAfter == 0 {
+			p.abortedAfter = p.operations
+			p.cancel()
+		}
+		return
+	}
+	p.payload += s.bytes
+	// Left out of the statistics rather than poisoning the percentiles, whatever the stage.
+	if s.duration <= 0 {
+		p.anomalies++
+		return
+	}
+	if s.stage != stagePlateau && s.stage != stageBurst {
+		return
+	}
+
+	p.times.add(float64(s.duration))
+	if p.keepSamples {
+		p.samples = append(p.samples, resultSample(s))
+	}
+	p.speeds.add(s.speed)
+	if p.stability.add(p.times) {
+		p.cancel()
+	}
+	p.trend.add(s.trial, s.duration)
+	if p.bySize != nil {
+		m := p.bySize[s.bytes]
+		if m == nil {
+			m = &sizeMean{}
+			p.bySize[s.bytes] = m
+		}
+		m.count++
+		m.total += s.duration
+	}
+	p.encodeTimes.add(float64(s.encodeDuration))
+	p.decoded += s.decoded
+	if s.mangled {
+		p.mangled++
+	}
+	if s.blocks > 0 {
+		p.verifyTimes.add(float64(s.verifyDuration))
+		p.blocks += s.blocks
+	}
+	if s.plaintext > 0 {
+		p.cryptTimes.add(float64(s.cryptDuration))
+		p.crypt += s.cryptDuration
+		p.endToEnd += s.duration + s.cryptDuration
+		p.plaintext += s.plaintext
+	}
+	p.generated += s.generateDuration
+	p.budget.add(s.generateDuration+s.digestDuration+s.encodeDuration+s.verifyDuration+s.cryptDuration, s.duration, s.requests)
+	p.statTimes.add(float64(s.statDuration))
+	p.digestTimes.add(float64(s.digestDuration))
+	p.continueTimes.add(float64(s.continueWait))
+	if s.ttfb > 0 {
+		p.ttfbTimes.add(float64(s.ttfb))
+	}
+	if delay, ok := s.clientDelay(); ok {
+		p.delays.add(s, delay)
+	}
+	p.bytes += s.bytes
+	if p.windowStart.IsZero() || s.start.Before(p.windowStart) {
+		p.windowStart = s.start
+	}
+	if end := s.start.Add(s.duration); end.After(p.lastEnd) {
+		p.lastEnd = end
+	}
+
+	if p.workers != nil {
+		w := p.workers[s.worker]
+		if w == nil {
+			w = &workerRecorder{times: p.newSampleSet()}
+			p.workers[s.worker] = w
+		}
+		w.times.add(float64(s.duration))
+		w.bytes += s.bytes
+	}
+	if p.hosts != nil {
+		h := p.hosts[s.host]
+		if h == nil {
+			h = &hostRecorder{times: p.newSampleSet()}
+			p.hosts[s.host] = h
+		}
+		h.record(s)
+	}
+	if p.buckets != nil {
+		b := p.buckets[s.bucket]
+		if b == nil {
+			b = &hostRecorder{times: p.newSampleSet()}
+			p.buckets[s.bucket] = b
+		}
+		b.record(s)
+	}
+}
+
+// transferred is the payload of the phase so far, nothing for a phase which did not run.
+func (p *phaseRecorder) transferred() int64 {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.payload
+}
+
+// workerStats returns the per-worker breakdown ordered by worker ID, or nil when not tracked.
+func (p *phaseRecorder) workerStats() []WorkerStats {
+	if p.workers == nil {
+		return nil
+	}
+	stats := make([]WorkerStats, 0, len(p.workers))
+	for id, w := range p.workers {
+		stats = append(stats, WorkerStats{
+			Worker:  id,
+			Count:   w.times.count(),
+			AvgTime: time.Duration(w.times.mean()),
+			P90Time: time.Duration(w.times.percentile(0.9)),
+			Bytes:   w.bytes,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Worker < stats[j].Worker })
+	return stats
+}
+
+func (p *phaseRecorder) stats() PhaseStats {
+	stats := summarize(p.times, p.speeds).withThroughput(p.bytes, p.lastEnd.Sub(p.windowStart))
+	stats.Connections = p.conns
+	stats.Start = p.windowStart
+	stats.Failed, stats.Aborted, stats.AbortedAfter = p.failed, p.abortedAfter > 0, p.abortedAfter
+	stats.WastedBytes, stats.Recovered, stats.ClockAnomalies = p.wasted, p.recovered, p.anomalies
+	stats.Errors = p.errors
+	stats.Backlog = p.backlog.stats()
+	stats.Trend = p.trend.stats()
+	stats.Stability = p.stability.stats(p.operations)
+	stats.TimeBudget = p.timeBudget.stats()
+	stats.ClientDelay = p.delays.stats()
+	stats.Bootstrap = p.bootstrap.stats(p.times, p.speeds)
+	if p.decoded > 0 {
+		stats.DecodedBytes = p.decoded
+		stats.DecodedThroughput = float64(p.decoded) / p.lastEnd.Sub(p.windowStart).Seconds() / 1024 / 1024 // MB/s
+	}
+	if p.ttfbTimes.count() > 0 {
+		stats.AvgTTFB, stats.P90TTFB = time.Duration(p.ttfbTimes.mean()), time.Duration(p.ttfbTimes.percentile(0.9))
+	}
+	return stats
+}
+
+// failed aborts the run unless "-abort-threshold" is set, in which case the trial is returned
+// as failed for the phase to account for.
+func (r runner) failed(phase, stage string, s sample, err error) sample {
+	op := &OpError{Phase: phase, Trial: s.trial, Key: s.key, Attempt: s.attempt, BytesDone: s.bytes, Err: err}
+	if op.Attempt == 0 {
+		op.Attempt = 1
+	}
+	if r.abortThreshold == 0 {
+		r.exitf(failureExitCode(op), "%v", op)
+	}
+	r.events.write(Event{
+		Variant: r.title, Phase: phase, Pass: r.pass, Stage: stage, Host: s.host, Trial: s.trial, Key: s.key, Start: s.start,
+		Duration: s.duration, Bytes: s.bytes, Error: op.Error(), ErrorClass: string(classifyError(op)), Attempt: op.Attempt,
+	})
+	fmt.Fprintf(r.progress, " - Trial: %d%s,	FAILED: %v\n", s.trial, stageMark(stage), op)
+	s.err = op
+	return s
+}
+
+// fatalf flushes telemetry and notifies about the failure, so that it is visible there too, and
+// halts the run.
+func (r runner) fatalf(format string, args ...any) {
+	r.exitf(1, format, args...)
+}
+
+// exitf is fatalf with a specific exit code, the one runCLI returns. Only the first failure
+// halting the run is flushed and notified, the trials in flight failing along with it are not.
+func (r runner) exitf(code int, format string, args ...any) {
+	if !r.halt.stop(code) {
+		return
+	}
+	if err := r.state.flush(); err != nil {
+		log.Printf(`Unable to save the state to %s: %v`, r.state.path, err)
+	}
+	r.webhook.notify(nil, false, fmt.Errorf(format, args...))
+	if r.junitOutput != "" {
+		if err := writeJUnit(r.junitOutput, junitErrorSuite(r.title, fmt.Errorf(format, args...))); err != nil {
+			log.Printf(`Unable to write JUnit output: %v`, err)
+		}
+	}
+	log.Printf(format, args...)
+}
+
+// fail is exitf for runCLI, which returns code, running the cleanups on the way out.
+func (r runner) fail(code int, format string, args ...any) int {
+	r.exitf(code, format, args...)
+	return code
+}
+
+// halted tells whether a failure stopped the run, which is then over: what is left of it returns
+// without going on.
+func (r runner) halted() bool {
+	return r.halt.exitCode() != 0
+}
+
+// runHalt is shared by the runners of a runCLI, so that a failure halts every one of them.
+type runHalt struct {
+	mu     sync.Mutex
+	code   int
+	cancel context.CancelFunc
+}
+
+// newRunHalt returns the context of the phases, cancelled once the run halts.
+func newRunHalt(ctx context.Context) (context.Context, *runHalt) {
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, &runHalt{cancel: cancel}
+}
+
+// stop records code unless the run halted already, which it reports by returning false. Without
+// a runHalt, e.g. in the tests, every failure is the first.
+func (h *runHalt) stop(code int) bool {
+	if h == nil {
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.code != 0 {
+		return false
+	}
+	h.code = code
+	h.cancel()
+	return true
+}
+
+// exitCode is that of the failure which halted the run, 0 while it goes on.
+func (h *runHalt) exitCode() int {
+	if h == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.code
+}
+
+func (r runner) key(i int) string {
+	return fmt.Sprintf("%s%sfile-%d.dat", r.prefix, r.keys.dir(i), i)
+}
+
+func stageMark(stage string) string {
+	if stage == stagePlateau {
+		return ""
+	}
+	return " [" + stage + "]"
+}
+
+// uploader writes attempt (0 for the initial upload) of the objects; phase names it in the
+// outputs.
+func (r runner) uploader(phase string, attempt int) operationFactory {
+	return func(worker int) operation {
+		return r.upload(phase, attempt, worker)
+	}
+}
+
+func (r runner) upload(phase string, attempt, worker int) operation {
+	buf := make([]byte, r.sizes.largest())
+	clients := r.clientsFor(worker)
+	host := clients[0].EndpointURL().Host
+	// Every worker gets its own copy as the digests are added to it per trial.
+	metadata := r.uploadMetadata()
+	var comp *compressor
+	if r.contentEncoding != "" {
+		comp = newCompressor()
+	}
+	sealer := r.clientCrypt.sealer(r.sizes.largest())
+
+	return func(i int, stage string) sample {
+		client := clients[i%len(clients)]
+		data := buf[:r.sizes.size(i)]
+		generateStart := time.Now()
+		if r.seed != 0 {
+			io.ReadFull(newPayloadReader(r.seed, i, attempt, int64(len(data))), data)
+		} else {
+			rand.Read(data)
+		}
+		if r.verifyMode == verifyBlocks {
+			sealBlocks(data, i)
+		}
+		generateDuration := time.Since(generateStart)
+
+		// Compression is done before the upload is timed, like the digests.
+		body, encodeDuration, decoded := data, time.Duration(0), int64(0)
+		if comp != nil {
+			body, encodeDuration = comp.compress(data)
+			decoded = int64(len(data))
+		}
+		key, bucket := r.key(i), r.bucket(i)
+		var cryptDuration time.Duration
+		if sealer != nil {
+			var err error
+			if body, cryptDuration, err = sealer.seal(data); err != nil {
+				r.statsd.count(phase+".errors", 1)
+				return r.failed(phase, stage, sample{host: host, trial: i, key: key, start: generateStart},
+					fmt.Errorf(`Unable to encrypt %s, %w`, key, err))
+			}
+		}
+
+		ctx, span := r.tracing.startTrial(context.Background(), "s3bench."+phase, bucket, key, int64(len(body)))
+		ctx, conns := r.conns.trace(ctx)
+		ctx, dump := r.debugHTTP.trial(ctx, phase, i)
+		defer dump.flush()
+		opts := minio.PutObjectOptions{
+			ServerSideEncryption: r.sse,
+			DisableContentSha256: r.disableContentSHA256,
+			UserMetadata:         metadata,
+			ContentEncoding:      r.contentEncoding,
+		}
+		digestDuration := r.digestUpload(body, &opts)
+		var sha256 string
+		if r.exported != nil && r.checksum == minio.ChecksumSHA256 {
+			sha256 = checksumHex(opts.UserMetadata[r.checksum.Key()])
+		}
+		startTime := time.Now()
+
+		if at, cancelled := r.cancels.point(i); cancelled && attempt == 0 {
+			wait, completed, err := r.cancelUpload(ctx, client, bucket, key, body, opts, int64(at*float64(len(body))))
+			endTrial(span, 1, err)
+			if err != nil {
+				r.statsd.count(phase+".errors", 1)
+				return r.failed(phase, stage, sample{host: host, trial: i, key: key, start: startTime},
+					fmt.Errorf(`Unable to upload %s to %s before cancelling it, %w`, key, bucket, err))
+			}
+			r.events.write(Event{Variant: r.title, Phase: phase, Stage: stage, Worker: worker, Host: host, Trial: i, Key: key, Start: startTime, Duration: time.Since(startTime), Cancelled: true})
+			fmt.Fprintf(r.progress, " - Trial: %d%s,\tCANCELLED after %d%% of the payload, time-to-cancel=%s%s\n", i, stageMark(stage), int(at*100), formatDuration(wait), completedMark(completed))
+			return sample{host: host, bucket: bucket, trial: i, key: key, start: startTime, cancelled: true, cancelLate: completed, cancelWait: wait}
+		}
+		info, tries, recovered, err := r.put(ctx, client, bucket, key, body, opts, startTime)
+		duration := time.Since(startTime)
+		continueWait := conns.waitedForContinue()
+		if r.expectContinue > 0 {
+			traceContinueWait(span, continueWait)
+		}
+		endTrial(span, tries, err)
+		if err != nil && isDigestMismatch(err) {
+			r.statsd.count(phase+".errors", 1)
+			return r.failed(phase, stage, sample{host: host, trial: i, key: key, start: startTime, attempt: tries},
+				fmt.Errorf(`Upload of %s to %s rejected due to a digest mismatch, %w`, key, bucket, err))
+		}
+		if err != nil {
+			r.statsd.count(phase+".errors", 1)
+			return r.failed(phase, stage, sample{host: host, trial: i, key: key, start: startTime, attempt: tries},
+				fmt.Errorf(`Unable to upload %s to %s, %w`, key, bucket, err))
+		}
+		if err := r.checkUploadInfo(info, body); err != nil {
+			r.statsd.count(phase+".errors", 1)
+			return r.failed(phase, stage, sample{host: host, trial: i, key: key, start: startTime, attempt: tries},
+				fmt.Errorf(`Upload of %s to %s is corrupt, %w`, key, bucket, err))
+		}
+
+		uploadSpeed := transferSpeed(int64(len(body)), duration)
+		if recovered {
+			r.statsd.count(phase+".recovered", 1)
+		}
+		r.statsd.timing(phase+".duration", duration)
+		r.statsd.histogram(phase+".speed", uploadSpeed)
+		r.events.write(Event{
+			Variant: r.title, Phase: phase, Stage: stage, Worker: worker, Host: host, Trial: i, Key: key, Start: startTime,
+			Duration: duration, Bytes: int64(len(body)), Speed: uploadSpeed, DigestDuration: digestDuration, Recovered: recovered,
+			ContinueWait: continueWait, EncodeDuration: encodeDuration, DecodedBytes: decoded, ETag: info.ETag, VersionID: info.VersionID,
+			CryptDuration: cryptDuration,
+		})
+
+		fresh, reused := conns.counts()
+		fmt.Fprintf(r.progress, " - Trial: %d%s,\ttime=%s, speed=%s MB/s%s\n", i, stageMark(stage), formatDuration(duration), formatSpeed(uploadSpeed), freshMark(r.verbose, fresh))
+		return sample{
+			host: host, bucket: bucket, trial: i, key: key, etag: info.ETag, versionID: info.VersionID, sha256: sha256, start: startTime, duration: duration, bytes: int64(len(body)), speed: uploadSpeed,
+			digestDuration: digestDuration, continueWait: continueWait, freshConns: fresh, reusedConns: reused, recovered: recovered,
+			encodeDuration: encodeDuration, decoded: decoded, generateDuration: generateDuration, requests: conns.attribution(),
+			cryptDuration: cryptDuration, plaintext: plaintextSize(sealer, data),
+			handedOver: conns.handedOverAt(), prepared: startTime.Sub(generateStart),
+		}
+	}
+}
+
+// put uploads with up to "-upload-retries" retries. A retry first checks whether the failed try
+// stored the object after all, e.g. when only its response timed out: the upload then counts as
+// recovered, taking until that discovery. Objects older than since, give or take the one second
+// precision of the modification times, are not taken for it. The tries made are returned along.
+func (r runner) put(ctx context.Context, client ObjectStore, bucket, key string, data []byte, opts minio.PutObjectOptions, since time.Time) (minio.UploadInfo, int, bool, error) {
+	for try := 0; ; try++ {
+		if try > 0 {
+			stored, err := client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+			if err == nil && stored.Size == int64(len(data)) && !stored.LastModified.Before(since.Truncate(time.Second)) {
+				return minio.UploadInfo{Bucket: bucket, Key: key, ETag: stored.ETag, Size: stored.Size}, try, true, nil
+			}
+		}
+
+		tryCtx, cancel := ctx, context.CancelFunc(func() {})
+		if r.uploadTimeout > 0 {
+			tryCtx, cancel = context.WithTimeout(ctx, r.uploadTimeout)
+		}
+		info, err := client.PutObject(tryCtx, bucket, key, r.payload(data), int64(len(data)), opts)
+		cancel()
+		if err == nil || try == r.uploadRetries || isDigestMismatch(err) {
+			return info, try + 1, false, err
+		}
+		fmt.Fprintf(r.progress, "   retrying %s, %v\n", key, err)
+	}
+}
+
+// downloader cycles through the uploaded objects, so that ramp stages never run out of keys.
+// With "-stat-before-get" each download is preceded by a separately timed StatObject.
+func (r runner) downloader(worker int) operation {
+	clients := r.clientsFor(worker)
+	host := clients[0].EndpointURL().Host
+	phase, opts := "download", minio.GetObjectOptions{}
+	if r.transformed {
+		phase = "transformed"
+		r.getQuery.apply(&opts)
+	}
+	storedTrial := r.affinity.picker(r, worker)
+
+	return func(i int, stage string) sample {
+		client := clients[i%len(clients)]
+		trial := storedTrial(i)
+		key, expectedFileSize := r.object(trial)
+		bucket := r.bucket(trial)
+		ctx, span := r.tracing.startTrial(context.Background(), "s3bench.download", bucket, key, expectedFileSize)
+		ctx, conns := r.conns.trace(ctx)
+		ctx, dump := r.debugHTTP.trial(ctx, phase, i)
+		defer dump.flush()
+
+		var statDuration time.Duration
+		if r.statBeforeGet {
+			statStart := time.Now()
+			_, err := client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+			statDuration = time.Since(statStart)
+			if r.vanished(err) {
+				endTrial(span, 1, nil)
+				return r.skipped(stage, sample{host: host, trial: i, key: key, start: statStart})
+			}
+			if err != nil {
+				endTrial(span, 1, err)
+				r.statsd.count("download.errors", 1)
+				return r.failed(phase, stage, sample{host: host, trial: i, key: key, start: statStart},
+					fmt.Errorf(`Unable to stat %s in %s, %w`, key, bucket, err))
+			}
+		}
+		startTime := time.Now()
+		// The stat is timed apart.
+		statRequests := conns.attribution()
+
+		payload, err := client.GetObject(ctx, bucket, key, opts)
+		if err != nil {
+			endTrial(span, 1, err)
+			r.statsd.count("download.errors", 1)
+			return r.failed(phase, stage, sample{host: host, trial: i, key: key, start: startTime},
+				fmt.Errorf(`Unable to download %s from %s, %w`, key, bucket, err))
+		}
+		var (
+			payloadSize, decoded int64
+			mangled              bool
+			verifier             *blockVerifier
+			verifyDuration       time.Duration
+			plaintext            int64
+			cryptDuration        time.Duration
+		)
+		switch {
+		// The transformation changes the content, it is only received.
+		case r.transformed:
+			payloadSize, err = io.Copy(io.Discard, payload)
+		case r.contentEncoding != "":
+			payloadSize, decoded, mangled, err = receiveEncoded(payload)
+			mangled = mangled || encodingStripped(payload, r.contentEncoding)
+		case r.clientCrypt != nil:
+			counter := &countingReader{r: payload}
+			opener := r.clientCrypt.opener(counter)
+			plaintext, err = io.Copy(io.Discard, opener)
+			payloadSize, cryptDuration = counter.n, opener.elapsed
+		case r.verifyMode == verifyBlocks:
+			verifier = newBlockVerifier(trial, expectedFileSize)
+			payloadSize, err = io.Copy(verifier, payload)
+			verifier.close()
+		default:
+			payloadSize, err = io.Copy(io.Discard, payload)
+		}
+		duration := time.Since(startTime)
+		if verifier != nil {
+			verifyDuration = verifier.elapsed
+			duration -= verifyDuration
+		}
+		duration -= cryptDuration
+		ttfb := conns.timeToFirstByte(startTime)
+		payload.Close()
+		if r.vanished(err) {
+			endTrial(span, 1, err)
+			return r.skipped(stage, sample{host: host, trial: i, key: key, start: startTime})
+		}
+		endTrial(span, 1, err)
+		if err != nil {
+			r.statsd.count("download.errors", 1)
+			return r.failed(phase, stage, sample{host: host, trial: i, key: key, start: startTime, duration: duration, bytes: payloadSize},
+				fmt.Errorf(`Unable to receive %s from %s after %d of %d bytes, %w`, key, bucket, payloadSize, expectedFileSize, err))
+		}
+		// Checked before the size, so that a truncation is reported at its offset.
+		if verifier != nil && verifier.corruptAt >= 0 {
+			r.statsd.count("download.errors", 1)
+			return r.failed(phase, stage, sample{host: host, trial: i, key: key, start: startTime, duration: duration, bytes: payloadSize, corrupted: true, corruptAt: verifier.corruptAt},
+				fmt.Errorf(`Corrupted block of %s at offset %d, received %d of %d bytes`, key, verifier.corruptAt, payloadSize, expectedFileSize))
+		}
+
+		if r.transformed && payloadSize == 0 {
+			r.statsd.count("download.errors", 1)
+			return r.failed(phase, stage, sample{host: host, trial: i, key: key, start: startTime, duration: duration},
+				fmt.Errorf(`Empty transformed download of %s from %s`, key, bucket))
+		}
+		// Encoded objects are expected to decode to the generated size, encrypted ones to decrypt to it.
+		if received := payloadSize; !r.transformed && received != expectedFileSize && (r.contentEncoding == "" || decoded != expectedFileSize) && (r.clientCrypt == nil || plaintext != expectedFileSize) {
+			switch {
+			case r.contentEncoding != "":
+				received = decoded
+			case r.clientCrypt != nil:
+				received = plaintext
+			}
+			r.statsd.count("download.errors", 1)
+			return r.failed(phase, stage, sample{host: host, trial: i, key: key, start: startTime, duration: duration, bytes: payloadSize},
+				fmt.Errorf(`Unmatched sizes of %s: actual=%d, expected=%d`, key, received, expectedFileSize))
+		}
+
+		downloadSpeed := transferSpeed(payloadSize, duration)
+		r.statsd.timing("download.duration", duration)
+		r.statsd.histogram("download.speed", downloadSpeed)
+		r.events.write(Event{
+			Variant: r.title, Phase: phase, Pass: r.pass, Stage: stage, Worker: worker, Host: host, Trial: i, Key: key, Start: startTime,
+			Duration: duration, Bytes: payloadSize, Speed: downloadSpeed, StatDuration: statDuration, TTFB: ttfb,
+			DecodedBytes: decoded, Mangled: mangled, VerifyDuration: verifyDuration, CryptDuration: cryptDuration,
+		})
+
+		fresh, reused := conns.counts()
+		if r.statBeforeGet {
+			fmt.Fprintf(r.progress, " - Trial: %d%s,\tstat.time=%s, time=%s, speed=%s MB/s%s\n", i, stageMark(stage), formatDuration(statDuration), formatDuration(duration), formatSpeed(downloadSpeed), freshMark(r.verbose, fresh))
+		} else {
+			fmt.Fprintf(r.progress, " - Trial: %d%s,\ttime=%s, speed=%s MB/s%s\n", i, stageMark(stage), formatDuration(duration), formatSpeed(downloadSpeed), freshMark(r.verbose, fresh))
+		}
+		return sample{
+			host: host, bucket: bucket, trial: i, key: key, start: startTime, duration: duration, bytes: payloadSize, speed: downloadSpeed,
+			statDuration: statDuration, ttfb: ttfb, freshConns: fresh, reusedConns: reused,
+			decoded: decoded, mangled: mangled, verifyDuration: verifyDuration, blocks: verifier.count(),
+			requests: conns.attribution().since(statRequests), cryptDuration: cryptDuration, plaintext: plaintext,
+			handedOver: conns.handedOverAt(),
+		}
+	}
+}
+
+// prober measures the error path: it stats keys under the prefix which are guaranteed not to
+// exist and expects NoSuchKey. Any other outcome is a real error.
+func (r runner) prober(worker int) operation {
+	clients := r.clientsFor(worker)
+	host := clients[0].EndpointURL().Host
+
+	return func(i int, stage string) sample {
+		client := clients[i%len(clients)]
+		key, bucket := r.key(i)+"-missing", r.bucket(i)
+		ctx, span := r.tracing.startTrial(context.Background(), "s3bench.miss", bucket, key, 0)
+		ctx, conns := r.conns.trace(ctx)
+		ctx, dump := r.debugHTTP.trial(ctx, "miss", i)
+		defer dump.flush()
+		startTime := time.Now()
+
+		_, err := client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+		duration := time.Since(startTime)
+		if isNotFound(err) {
+			endTrial(span, 1, nil)
+		} else {
+			endTrial(span, 1, err)
+			r.statsd.count("miss.errors", 1)
+			failure := sample{host: host, trial: i, key: key, start: startTime}
+			if err == nil {
+				return r.failed("miss", stage, failure, fmt.Errorf(`Missing %s unexpectedly exists in %s`, key, bucket))
+			}
+			return r.failed("miss", stage, failure, fmt.Errorf(`Unable to probe missing %s in %s, %w`, key, bucket, err))
+		}
+
+		r.statsd.timing("miss.duration", duration)
+		r.events.write(Event{
+			Variant: r.title, Phase: "miss", Stage: stage, Worker: worker, Host: host, Trial: i, Key: key, Start: startTime,
+			Duration: duration,
+		})
+
+		fresh, reused := conns.counts()
+		fmt.Fprintf(r.progress, " - Trial: %d%s,\ttime=%s%s\n", i, stageMark(stage), formatDuration(duration), freshMark(r.verbose, fresh))
+		return sample{host: host, trial: i, key: key, start: startTime, duration: duration, freshConns: fresh, reusedConns: reused, handedOver: conns.handedOverAt()}
+	}
+}
+
+// removeFiles skips objects which do not carry the run ID, so that a prefix colliding with
+// real data never gets that data deleted.
+func (r runner) removeFiles() CleanupStats {
+	var (
+		buckets []string
+		keys    = map[string][]string{}
+	)
+	for i := 1; i <= r.uploaded; i++ {
+		bucket := r.bucket(i)
+		if keys[bucket] == nil {
+			buckets = append(buckets, bucket)
+		}
+		keys[bucket] = append(keys[bucket], r.key(i))
+	}
+	var stats CleanupStats
+	for _, bucket := range buckets {
+		stats.add(r.removeKeys(bucket, keys[bucket]))
+	}
+	r.confirmRemaining(&stats, buckets)
+	return stats
+}
+
+// removeKeys stats every object first for its run ID and replication status. With
+// "-cleanup-wait-replicated" objects still pending replication are only deleted once it
+// completed or the wait ran out.
+func (r runner) removeKeys(bucket string, keys []string) CleanupStats {
+	var (
+		stats    CleanupStats
+		deadline = time.Now().Add(r.cleanupWaitReplicated)
+	)
+	for _, key := range keys {
+		info, err := r.client.StatObject(context.Background(), bucket, key, minio.StatObjectOptions{})
+		if isNotFound(err) {
+			// A failed trial never stored it.
+			continue
+		}
+		if err != nil {
+			stats.failed(bucket, key, &OpError{Phase: "cleanup", Key: key, Attempt: 1, Err: fmt.Errorf(`Unable to stat %s in %s before removal, %w`, key, bucket, err)})
+			continue
+		}
+		if r.metadata != nil && !createdByRun(info, r.runID) {
+			log.Printf(`WARNING: not removing %s from %s as it was not created by run %s`, key, bucket, r.runID)
+			stats.Skipped++
+			continue
+		}
+		status := replicationStatus(info)
+		if status == replicationPending && r.cleanupWaitReplicated > 0 {
+			waitStart := time.Now()
+			status = r.awaitReplicated(bucket, key, info, deadline)
+			stats.Waited += time.Since(waitStart)
+		}
+		if err := r.client.RemoveObject(context.Background(), bucket, key, minio.RemoveObjectOptions{}); err != nil {
+			stats.failed(bucket, key, &OpError{Phase: "cleanup", Key: key, Attempt: 1, Err: fmt.Errorf(`Unable to remove %s from %s, %w`, key, bucket, err)})
+			continue
+		}
+		stats.Removed++
+		switch status {
+		case replicationPending:
+			stats.Pending++
+		case replicationFailed:
+			stats.ReplicationFailed++
+		}
+	}
+	return stats
+}
@@ -0,0 +1,177 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// newTestRunner runs trials of size bytes against the bucket of the store, one worker at a time.
+func newTestRunner(t *testing.T, store ObjectStore, requests *requestCounter, trials int, size int64) runner {
+	t.Helper()
+	sizes, err := newSizeDistribution(sizeFixed, size, "", "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSampleSet, err := parseSampleStrategy("all", 1, percentileLinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runner{
+		client:           store,
+		bucketName:       testBucket,
+		buckets:          []string{testBucket},
+		sizes:            sizes,
+		trials:           trials,
+		concurrency:      1,
+		progress:         io.Discard,
+		requests:         requests,
+		newSampleSet:     newSampleSet,
+		percentileMethod: percentileLinear,
+		abortThreshold:   trials + 1,
+	}
+}
+
+var errHalfRead = errors.New("connection reset halfway")
+
+// halfReadStore fails every other download halfway through, the complete ones take slow to
+// respond.
+type halfReadStore struct {
+	ObjectStore
+	gets atomic.Int64
+	slow time.Duration
+}
+
+func (s *halfReadStore) GetObject(ctx context.Context, bucketName, objectName string, opts minio.GetObjectOptions) (io.ReadCloser, error) {
+	object, err := s.ObjectStore.GetObject(ctx, bucketName, objectName, opts)
+	if err != nil {
+		return nil, err
+	}
+	if s.gets.Add(1)%2 == 0 {
+		return &halfReader{ReadCloser: object, left: 500}, nil
+	}
+	time.Sleep(s.slow)
+	return object, nil
+}
+
+type halfReader struct {
+	io.ReadCloser
+	left int
+}
+
+func (r *halfReader) Read(p []byte) (int, error) {
+	if r.left == 0 {
+		return 0, errHalfRead
+	}
+	if len(p) > r.left {
+		p = p[:r.left]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.left -= n
+	return n, err
+}
+
+func TestPartialDownloadsLeaveTheStats(t *testing.T) {
+	fs, requests := newTestStore(t)
+	store := &halfReadStore{ObjectStore: fs, slow: 5 * time.Millisecond}
+	report := newTestRunner(t, store, requests, 10, 1000).run()
+
+	download := report.Download
+	if download.Count != 5 || download.Failed != 5 {
+		t.Fatalf("%d downloads measured and %d failed, want 5 of either", download.Count, download.Failed)
+	}
+	if download.Bytes != 5*1000 || download.WastedBytes != 5*500 {
+		t.Errorf("%d bytes measured and %d wasted, want 5000 and 2500", download.Bytes, download.WastedBytes)
+	}
+	// The partial downloads return at once, any of them in the stats would pull them below the
+	// delay of the complete ones.
+	if download.AvgTime < store.slow || download.P90Time < store.slow {
+		t.Errorf("average %v and P90 %v of the downloads, want both above %v", download.AvgTime, download.P90Time, store.slow)
+	}
+	if download.Errors[errorOther] != 5 {
+		t.Errorf("download errors %v, want the 5 partial downloads", download.Errors)
+	}
+}
+
+func TestUploadRetriesRecoverLostAcknowledgements(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		fault   fault
+		retries int
+		// recovered and failed are the outcomes of the 4 uploads, puts the PUT requests sent.
+		recovered, failed, puts int
+	}{
+		{"lost-ack retried", fault{operation: "put", outcome: "lost-ack", probability: 1}, 1, 4, 0, 4},
+		{"lost-ack not retried", fault{operation: "put", outcome: "lost-ack", probability: 1}, 0, 0, 4, 4},
+		// Nothing stored, the retry sends the upload again and fails once more.
+		{"error retried", fault{operation: "put", probability: 1}, 1, 0, 4, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store, requests := newTestStore(t)
+			r := newTestRunner(t, newFaultInjector([]fault{tc.fault}, 1).wrap(store), requests, 4, 100)
+			r.uploadRetries = tc.retries
+			report := r.run()
+
+			upload := report.Upload
+			if upload.Recovered != tc.recovered || upload.Failed != tc.failed || upload.Count != 4-tc.failed {
+				t.Errorf("%d uploads measured, %d recovered and %d failed, want %d recovered and %d failed", upload.Count, upload.Recovered, upload.Failed, tc.recovered, tc.failed)
+			}
+			if puts := int(requests.put.Load()); puts != tc.puts {
+				t.Errorf("%d PUT requests, want %d", puts, tc.puts)
+			}
+		})
+	}
+}
+
+func TestClockAnomaliesLeaveTheStats(t *testing.T) {
+	store, requests := newTestStore(t)
+	path := filepath.Join(t.TempDir(), "run.state")
+	saved, err := openStateFile(path, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Trials of a run whose readings came from a clock stepped back, restored as they were saved.
+	record := saved.track("upload", func(sample) {})
+	start := time.Now()
+	for trial, duration := range map[int]time.Duration{1: -5 * time.Millisecond, 2: 0} {
+		key := fmt.Sprintf("file-%d.dat", trial)
+		putTestObject(t, store, key, make([]byte, 100))
+		record(sample{trial: trial, key: key, start: start, duration: duration, bytes: 100, speed: transferSpeed(100, duration)})
+	}
+	if err := saved.save(); err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := openStateFile(path, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newTestRunner(t, store, requests, 4, 100)
+	r.state = resumed
+	report := r.run()
+	upload := report.Upload
+	if upload.ClockAnomalies != 2 || upload.Count != 2 || upload.Failed != 0 {
+		t.Fatalf("%d uploads measured, %d anomalies and %d failed, want 2, 2 and 0", upload.Count, upload.ClockAnomalies, upload.Failed)
+	}
+	if upload.AvgTime <= 0 || upload.P90Time <= 0 || math.IsInf(upload.P90Speed, 0) || upload.P90Speed <= 0 {
+		t.Errorf("average %v, P90 %v and P90 speed %v of the uploads, want the measured ones only", upload.AvgTime, upload.P90Time, upload.P90Speed)
+	}
+	if got := report.clockAnomalies(); got != "upload=2" {
+		t.Errorf("clock anomalies %q, want upload=2", got)
+	}
+	if report.Download.ClockAnomalies != 0 || report.Download.Count != 4 {
+		t.Errorf("%d downloads measured, %d anomalies, want 4 and none", report.Download.Count, report.Download.ClockAnomalies)
+	}
+	if speed := transferSpeed(100, -time.Millisecond); speed != 0 {
+		t.Errorf("the speed of a negative duration is %v, want 0", speed)
+	}
+}
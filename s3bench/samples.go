@@ -0,0 +1,248 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"math"
+	mathrand "math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultReservoirSize = 10000
+	// hdrRelativeError bounds the relative error of "hdr" percentiles (1%).
+	hdrRelativeError = 0.01
+)
+
+// sampleSet accumulates measured values and estimates their statistics. The mean is always
+// exact; how exact percentiles are depends on the strategy.
+type sampleSet interface {
+	add(v float64)
+	count() int
+	mean() float64
+	// percentile returns the p-th (0..1) percentile or 0 for an empty set.
+	percentile(p float64) float64
+	// resample is a set of values drawn with replacement from those the percentiles are estimated
+	// from, as many as there are of them, for "-bootstrap".
+	resample(rng *mathrand.Rand) sampleSet
+}
+
+// sampleStrategy creates the sample sets for a run, selected with "-sample-strategy":
+//   - "all" keeps every value, percentiles are exact;
+//   - "reservoir:N" keeps a uniform random sample of N values;
+//   - "hdr" counts values in logarithmic buckets with a bounded relative error.
+type sampleStrategy func() sampleSet
+
+func parseSampleStrategy(value string, seed int64, method percentileMethod) (sampleStrategy, error) {
+	name, arg, _ := strings.Cut(value, ":")
+	switch name {
+	case "all":
+		return func() sampleSet { return &allSamples{method: method} }, nil
+	case "reservoir":
+		size := defaultReservoirSize
+		if arg != "" {
+			var err error
+			if size, err = strconv.Atoi(arg); err != nil || size < 1 {
+				return nil, fmt.Errorf(`invalid reservoir size %q`, arg)
+			}
+		}
+		var seq int64
+		var mu sync.Mutex
+		return func() sampleSet {
+			mu.Lock()
+			defer mu.Unlock()
+			seq++
+			return &reservoirSamples{size: size, rng: mathrand.New(mathrand.NewSource(seed + seq)), inner: allSamples{method: method}}
+		}, nil
+	case "hdr":
+		return func() sampleSet { return newHDRSamples(hdrRelativeError, method) }, nil
+	default:
+		return nil, fmt.Errorf(`unsupported sample strategy %q`, value)
+	}
+}
+
+// allSamples keeps every value.
+type allSamples struct {
+	method percentileMethod
+	values []float64
+	sum    float64
+	sorted bool
+}
+
+func (s *allSamples) add(v float64) {
+	s.values = append(s.values, v)
+	s.sum += v
+	s.sorted = false
+}
+
+func (s *allSamples) count() int { return len(s.values) }
+
+func (s *allSamples) mean() float64 {
+	if len(s.values) == 0 {
+		return 0
+	}
+	return s.sum / float64(len(s.values))
+}
+
+func (s *allSamples) percentile(p float64) float64 {
+	if len(s.values) == 0 {
+		return 0
+	}
+	if !s.sorted {
+		sort.Float64s(s.values)
+		s.sorted = true
+	}
+	return s.method.estimate(len(s.values), p, func(i int) float64 { return s.values[i] })
+}
+
+// resample draws from the values in order, so that the same values give the same resamples
+// whichever order they were added in.
+func (s *allSamples) resample(rng *mathrand.Rand) sampleSet {
+	if !s.sorted {
+		sort.Float64s(s.values)
+		s.sorted = true
+	}
+	r := &allSamples{method: s.method, values: make([]float64, len(s.values))}
+	for i := range r.values {
+		r.values[i] = s.values[rng.Intn(len(s.values))]
+		r.sum += r.values[i]
+	}
+	return r
+}
+
+// reservoirSamples keeps a fixed-size uniform sample of all values (Vitter's algorithm R).
+type reservoirSamples struct {
+	size  int
+	rng   *mathrand.Rand
+	seen  int
+	sum   float64
+	inner allSamples
+}
+
+func (s *reservoirSamples) add(v float64) {
+	s.seen++
+	s.sum += v
+	if len(s.inner.values) < s.size {
+		s.inner.add(v)
+		return
+	}
+	if i := s.rng.Intn(s.seen); i < s.size {
+		s.inner.values[i] = v
+		s.inner.sorted = false
+	}
+}
+
+func (s *reservoirSamples) count() int { return s.seen }
+
+func (s *reservoirSamples) mean() float64 {
+	if s.seen == 0 {
+		return 0
+	}
+	return s.sum / float64(s.seen)
+}
+
+func (s *reservoirSamples) percentile(p float64) float64 { return s.inner.percentile(p) }
+
+// resample draws from the values kept only, so that the intervals are those of the estimates.
+func (s *reservoirSamples) resample(rng *mathrand.Rand) sampleSet { return s.inner.resample(rng) }
+
+// hdrSamples counts values in buckets growing geometrically by gamma, so that any value
+// reported for a bucket is within the relative error of every value counted in it.
+type hdrSamples struct {
+	method  percentileMethod
+	gamma   float64
+	buckets map[int]int
+	zeros   int
+	seen    int
+	sum     float64
+}
+
+func newHDRSamples(relativeError float64, method percentileMethod) *hdrSamples {
+	return &hdrSamples{method: method, gamma: (1 + relativeError) / (1 - relativeError), buckets: map[int]int{}}
+}
+
+func (s *hdrSamples) add(v float64) {
+	s.seen++
+	s.sum += v
+	if v <= 0 {
+		s.zeros++
+		return
+	}
+	s.buckets[int(math.Ceil(math.Log(v)/math.Log(s.gamma)))]++
+}
+
+func (s *hdrSamples) count() int { return s.seen }
+
+// value is the representative value of bucket i.
+func (s *hdrSamples) value(i int) float64 {
+	return 2 * math.Pow(s.gamma, float64(i)) / (s.gamma + 1)
+}
+
+// indexes are those of the buckets, in order.
+func (s *hdrSamples) indexes() []int {
+	indexes := make([]int, 0, len(s.buckets))
+	for i := range s.buckets {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+func (s *hdrSamples) mean() float64 {
+	if s.seen == 0 {
+		return 0
+	}
+	return s.sum / float64(s.seen)
+}
+
+func (s *hdrSamples) percentile(p float64) float64 {
+	if s.seen == 0 {
+		return 0
+	}
+	indexes := s.indexes()
+
+	// at maps a rank to the representative value of the bucket holding it.
+	at := func(rank int) float64 {
+		if rank < s.zeros {
+			return 0
+		}
+		seen := s.zeros
+		for _, i := range indexes {
+			seen += s.buckets[i]
+			if seen > rank {
+				return s.value(i)
+			}
+		}
+		return s.value(indexes[len(indexes)-1])
+	}
+	return s.method.estimate(s.seen, p, at)
+}
+
+// resample draws as many values as were counted, each the representative value of its bucket.
+func (s *hdrSamples) resample(rng *mathrand.Rand) sampleSet {
+	r := newHDRSamples(0, s.method)
+	r.gamma = s.gamma
+	indexes := s.indexes()
+	// below[j] is the number of the values of the buckets before indexes[j], the zeros included.
+	below := make([]int, len(indexes))
+	seen := s.zeros
+	for j, i := range indexes {
+		below[j] = seen
+		seen += s.buckets[i]
+	}
+	for n := 0; n < s.seen; n++ {
+		rank := rng.Intn(s.seen)
+		if rank < s.zeros {
+			r.add(0)
+			continue
+		}
+		i := indexes[sort.SearchInts(below, rank+1)-1]
+		r.buckets[i]++
+		r.seen++
+		r.sum += s.value(i)
+	}
+	return r
+}
@@ -0,0 +1,131 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"math"
+	mathrand "math/rand"
+	"testing"
+)
+
+func TestParseSampleStrategy(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		valid bool
+	}{
+		{"all", true},
+		{"reservoir", true},
+		{"reservoir:100", true},
+		{"hdr", true},
+		{"reservoir:0", false},
+		{"reservoir:-1", false},
+		{"reservoir:many", false},
+		{"tdigest", false},
+		{"", false},
+	} {
+		_, err := parseSampleStrategy(tc.value, 1, percentileLinear)
+		if (err == nil) != tc.valid {
+			t.Errorf("parseSampleStrategy(%q): got error %v, want valid=%t", tc.value, err, tc.valid)
+		}
+	}
+}
+
+func TestSampleSetCapacity(t *testing.T) {
+	const n = 50000
+	for _, tc := range []struct {
+		strategy string
+		// kept is how many values the set holds at most after n values.
+		kept func(sampleSet) int
+		max  int
+	}{
+		{"all", func(s sampleSet) int { return len(s.(*allSamples).values) }, n},
+		{"reservoir:1000", func(s sampleSet) int { return len(s.(*reservoirSamples).inner.values) }, 1000},
+		{"reservoir:10", func(s sampleSet) int { return len(s.(*reservoirSamples).inner.values) }, 10},
+		// Values from 1 to n span log(n)/log(gamma) buckets of the 1% relative error.
+		{"hdr", func(s sampleSet) int { return len(s.(*hdrSamples).buckets) }, 545},
+	} {
+		t.Run(tc.strategy, func(t *testing.T) {
+			strategy, err := parseSampleStrategy(tc.strategy, 1, percentileLinear)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := strategy()
+			sum := 0.0
+			for i := 1; i <= n; i++ {
+				s.add(float64(i))
+				sum += float64(i)
+			}
+			if kept := tc.kept(s); kept > tc.max {
+				t.Errorf("kept %d values, want at most %d", kept, tc.max)
+			}
+			if s.count() != n {
+				t.Errorf("count() = %d, want %d", s.count(), n)
+			}
+			if mean := s.mean(); mean != sum/n {
+				t.Errorf("mean() = %v, want the exact %v", mean, sum/n)
+			}
+		})
+	}
+}
+
+func TestReservoirUniformity(t *testing.T) {
+	const (
+		n       = 100000
+		size    = 2000
+		deciles = 10
+	)
+	s := &reservoirSamples{size: size, rng: mathrand.New(mathrand.NewSource(42)), inner: allSamples{method: percentileLinear}}
+	for i := 0; i < n; i++ {
+		s.add(float64(i))
+	}
+	var counts [deciles]int
+	for _, v := range s.inner.values {
+		counts[int(v)*deciles/n]++
+	}
+	// Every decile of the values should hold a tenth of the reservoir, give or take a few
+	// standard deviations of the binomial distribution (sqrt(2000 * 0.1 * 0.9) ~ 13).
+	for decile, count := range counts {
+		if want := size / deciles; math.Abs(float64(count-want)) > 50 {
+			t.Errorf("decile %d holds %d values, want %d±50: %v", decile, count, want, counts)
+		}
+	}
+	if p50, want := s.percentile(0.5), float64(n/2); math.Abs(p50-want)/want > 0.05 {
+		t.Errorf("percentile(0.5) = %v, want %v±5%%", p50, want)
+	}
+}
+
+func TestHDRPercentileError(t *testing.T) {
+	// An exponential distribution of durations around 50ms, in nanoseconds, with a few zeros.
+	rng := mathrand.New(mathrand.NewSource(7))
+	values := make([]float64, 20000)
+	for i := range values {
+		values[i] = math.Round(rng.ExpFloat64() * 50e6)
+	}
+	values[0], values[1] = 0, 0
+
+	for _, method := range []percentileMethod{percentileLinear, percentileNearestRank} {
+		exact, hdr := &allSamples{method: method}, newHDRSamples(hdrRelativeError, method)
+		for _, v := range values {
+			exact.add(v)
+			hdr.add(v)
+		}
+		for _, p := range []float64{0, 0.01, 0.25, 0.5, 0.9, 0.99, 0.999, 1} {
+			want, got := exact.percentile(p), hdr.percentile(p)
+			if math.Abs(got-want) > want*hdrRelativeError*(1+1e-9) {
+				t.Errorf("%s: percentile(%v) = %v, want %v within %v%%", method, p, got, want, hdrRelativeError*100)
+			}
+		}
+	}
+}
+
+func TestEmptySampleSets(t *testing.T) {
+	for _, strategy := range []string{"all", "reservoir:10", "hdr"} {
+		newSet, err := parseSampleStrategy(strategy, 1, percentileLinear)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := newSet()
+		if s.count() != 0 || s.mean() != 0 || s.percentile(0.9) != 0 {
+			t.Errorf("%s: an empty set has count=%d mean=%v p90=%v, want zeros", strategy, s.count(), s.mean(), s.percentile(0.9))
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	mathrand "math/rand"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// scannedObject is an existing object which "-scan-prefix" benchmarks the downloads against.
+type scannedObject struct {
+	key  string
+	size int64
+}
+
+// ScanStats describes the listing of "-scan-prefix", or the keys of "-scan-keys" along with
+// the sizing of those listed without a size. Skipped objects were gone by the time they were
+// downloaded; they are neither samples nor failures.
+type ScanStats struct {
+	Prefix   string        `json:"prefix"`
+	KeysFile string        `json:"keys_file,omitempty"`
+	Listed   int           `json:"listed"`
+	Sampled  int           `json:"sampled"`
+	Bytes    int64         `json:"bytes"`
+	Skipped  int           `json:"skipped,omitempty"`
+	Listing  time.Duration `json:"listing"`
+	Sized    int           `json:"sized,omitempty"`
+	Sizing   time.Duration `json:"sizing,omitempty"`
+	Dropped  []DroppedKey  `json:"dropped,omitempty"`
+}
+
+func (s ScanStats) String() string {
+	if s.KeysFile == "" {
+		return fmt.Sprintf(" Scan        : prefix=%q listed=%d sampled=%d (%s) skipped=%d listing=%s\n",
+			s.Prefix, s.Listed, s.Sampled, formatBytes(s.Bytes), s.Skipped, formatDuration(s.Listing))
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, " Scan        : keys=%q listed=%d sized=%d dropped=%d downloaded=%d (%s) skipped=%d sizing=%s (part of the setup)\n",
+		s.KeysFile, s.Listed, s.Sized, len(s.Dropped), s.Sampled, formatBytes(s.Bytes), s.Skipped, formatDuration(s.Sizing))
+	for _, d := range s.Dropped {
+		fmt.Fprintf(&sb, "   dropped %s: %s\n", d.Key, d.Error)
+	}
+	return sb.String()
+}
+
+// scanObjects lists "-scan-prefix" and keeps a uniform sample of "-sample" objects out of it
+// (reservoir sampling, seeded by "-seed"), so that memory does not grow with the listing.
+func (r runner) scanObjects() ([]scannedObject, *ScanStats) {
+	var (
+		start  = time.Now()
+		rng    = mathrand.New(mathrand.NewSource(r.seed))
+		sample = make([]scannedObject, 0, r.scanSample)
+		stats  = &ScanStats{Prefix: r.scanPrefix}
+	)
+	for object := range r.client.ListObjects(context.Background(), r.bucketName, minio.ListObjectsOptions{Prefix: r.scanPrefix, Recursive: true}) {
+		if object.Err != nil {
+			r.fatalf(`Unable to list %s in %s, %v`, r.scanPrefix, r.bucketName, object.Err)
+			return nil, stats
+		}
+		stats.Listed++
+		o := scannedObject{key: object.Key, size: object.Size}
+		if len(sample) < r.scanSample {
+			sample = append(sample, o)
+		} else if i := rng.Intn(stats.Listed); i < r.scanSample {
+			sample[i] = o
+		}
+	}
+	stats.Listing = time.Since(start)
+	if len(sample) == 0 {
+		r.fatalf(`No objects found under %q in %s`, r.scanPrefix, r.bucketName)
+		return nil, stats
+	}
+	stats.Sampled = len(sample)
+	for _, o := range sample {
+		stats.Bytes += o.size
+	}
+	fmt.Fprintf(r.progress, " - Listed %d objects in %s, sampled %d\n", stats.Listed, formatDuration(stats.Listing), stats.Sampled)
+	return sample, stats
+}
+
+// scanning tells whether the downloads read existing objects instead of uploaded ones.
+func (r runner) scanning() bool {
+	return r.scanPrefix != "" || r.keysScan != nil
+}
+
+// object is the key and the expected size of trial: one of the uploaded objects, or of the
+// scanned ones with "-scan-prefix".
+func (r runner) object(trial int) (string, int64) {
+	if r.scanned != nil {
+		o := r.scanned[trial-1]
+		return o.key, o.size
+	}
+	return r.key(trial), r.sizes.size(trial)
+}
+
+// vanished tells whether a scanned object was deleted after it had been listed.
+func (r runner) vanished(err error) bool {
+	if r.scanned == nil {
+		return false
+	}
+	return isNotFound(err)
+}
+
+// skipped accounts for a trial whose scanned object vanished.
+func (r runner) skipped(stage string, s sample) sample {
+	r.events.write(Event{Variant: r.title, Phase: "download", Stage: stage, Host: s.host, Trial: s.trial, Key: s.key, Start: s.start, Skipped: true})
+	fmt.Fprintf(r.progress, " - Trial: %d%s,\tSKIPPED: %s no longer exists\n", s.trial, stageMark(stage), s.key)
+	s.skipped = true
+	return s
+}
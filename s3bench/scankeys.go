@@ -0,0 +1,128 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// sizingProgressSteps is how many progress lines the sizing of the keys prints at most.
+const sizingProgressSteps = 10
+
+// readScanKeys reads the "-scan-keys" file: a key per line, optionally followed by its size
+// after whitespace. Keys without a size are sized by sizeScanKeys; blank lines and those
+// starting with "#" are skipped. A JSON array is the file of "-export-keys".
+func readScanKeys(path string) ([]scannedObject, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var objects []scannedObject
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("[")) {
+		if objects, err = readExportedKeys(content); err != nil {
+			return nil, err
+		}
+		if len(objects) == 0 {
+			return nil, fmt.Errorf(`no keys in %s`, path)
+		}
+		return objects, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		o := scannedObject{key: text, size: -1}
+		if fields := strings.Fields(text); len(fields) == 2 {
+			size, err := parseByteSize(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf(`line %d: %v`, line, err)
+			}
+			o = scannedObject{key: fields[0], size: size}
+		} else if len(fields) > 2 {
+			return nil, fmt.Errorf(`line %d: expected a key and optionally its size`, line)
+		}
+		objects = append(objects, o)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf(`no keys in %s`, path)
+	}
+	return objects, nil
+}
+
+// DroppedKey is a "-scan-keys" key which could not be sized, which the run leaves out.
+type DroppedKey struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// sizeScanKeys stats the keys listed without a size once, with up to "-concurrency" stats at a
+// time, as a part of the setup. The keys failing the stat are dropped with a warning.
+func (r runner) sizeScanKeys(path string, objects []scannedObject) ([]scannedObject, *ScanStats) {
+	var (
+		start   = time.Now()
+		stats   = &ScanStats{KeysFile: path, Listed: len(objects)}
+		unsized []int
+	)
+	for i, o := range objects {
+		if o.size < 0 {
+			unsized = append(unsized, i)
+		}
+	}
+	if len(unsized) > 0 {
+		fmt.Fprintf(r.progress, "Sizing %d keys of %s:\n", len(unsized), path)
+		var (
+			mu    sync.Mutex
+			done  int
+			every = max64(1, int64(len(unsized))/sizingProgressSteps)
+		)
+		schedule{workers: r.concurrency, trials: len(unsized)}.run(func(worker int) operation {
+			return func(i int, stage string) sample {
+				o := &objects[unsized[i-1]]
+				info, err := r.client.StatObject(context.Background(), r.bucketName, o.key, minio.StatObjectOptions{})
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					log.Printf(`WARNING: dropping %s from the run, unable to stat it in %s, %v`, o.key, r.bucketName, err)
+					stats.Dropped = append(stats.Dropped, DroppedKey{Key: o.key, Error: err.Error()})
+				} else {
+					o.size = info.Size
+				}
+				if done++; int64(done)%every == 0 || done == len(unsized) {
+					fmt.Fprintf(r.progress, " - Sized %d of %d keys\n", done, len(unsized))
+				}
+				return sample{}
+			}
+		}, func(sample) {})
+		stats.Sized = len(unsized) - len(stats.Dropped)
+	}
+
+	sized := make([]scannedObject, 0, len(objects))
+	for _, o := range objects {
+		if o.size >= 0 {
+			sized = append(sized, o)
+			stats.Bytes += o.size
+		}
+	}
+	stats.Sampled = len(sized)
+	stats.Sizing = time.Since(start)
+	if len(sized) == 0 {
+		r.fatalf(`None of the keys of %s could be sized in %s`, path, r.bucketName)
+		return nil, stats
+	}
+	return sized, stats
+}
@@ -0,0 +1,166 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	stageRampUp   = "ramp-up"
+	stagePlateau  = "plateau"
+	stageRampDown = "ramp-down"
+)
+
+// schedule runs a phase on a pool of workers. During ramp-up the workers are started one by
+// one; once the plateau trials are used up they are stopped one by one during ramp-down.
+// Only plateau samples make it into the headline statistics.
+type schedule struct {
+	workers          int
+	trials           int
+	rampUp, rampDown time.Duration
+	// abort, when cancelled, stops the workers from starting further trials; trials in flight
+	// still finish and get recorded.
+	abort context.Context
+	// rate, when positive, paces the trials: trial n is due n/rate after the start, and whichever
+	// worker is free starts it then. backlog is sampled with the trials due but not started.
+	rate    float64
+	backlog *backlogRecorder
+	// budget, when set, stops the workers from starting further trials once it is spent, as abort.
+	budget *timeBudget
+}
+
+// operation performs a trial and measures it. Every worker gets its own operation so that it
+// may keep per-worker state, e.g. a payload buffer.
+type operation func(trial int, stage string) sample
+
+// operationFactory creates the operation of a worker, numbered from 1.
+type operationFactory func(worker int) operation
+
+// run returns the number of trials performed, ramp stages included. Every sample is passed to record.
+func (s schedule) run(newOperation operationFactory, record func(sample)) int {
+	var (
+		start      = time.Now()
+		plateauAt  = start.Add(s.rampUp)
+		nextTrial  int64
+		claimed    int64
+		started    int64
+		plateauEnd time.Time
+		endOnce    sync.Once
+		endedCh    = make(chan struct{})
+
+		wg sync.WaitGroup
+	)
+	if s.budget != nil {
+		var stop context.CancelFunc
+		s.abort, stop = s.budget.begin(s.abort)
+		defer stop()
+	}
+
+	if s.rate > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go s.sampleBacklog(start, &started, done)
+	}
+
+	for w := 0; w < s.workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			// Workers join linearly over the ramp-up window, the first one right away.
+			time.Sleep(time.Until(start.Add(s.rampUp * time.Duration(w) / time.Duration(s.workers))))
+			// ...and leave in reverse order over the ramp-down window, the first one last.
+			stopAfter := s.rampDown * time.Duration(s.workers-w) / time.Duration(s.workers)
+
+			op := newOperation(w + 1)
+			// A trial is due once the previous one of the worker is over, or at its slot under
+			// "-rate" if that is later.
+			due := time.Now()
+			for {
+				if s.abort != nil && s.abort.Err() != nil {
+					return
+				}
+				stage := stagePlateau
+				select {
+				case <-endedCh:
+					stage = stageRampDown
+				default:
+					if time.Now().Before(plateauAt) {
+						stage = stageRampUp
+					} else if atomic.AddInt64(&claimed, 1) > int64(s.trials) {
+						endOnce.Do(func() {
+							plateauEnd = time.Now()
+							close(endedCh)
+						})
+						stage = stageRampDown
+					}
+				}
+				if stage == stageRampDown && !time.Now().Before(plateauEnd.Add(stopAfter)) {
+					return
+				}
+
+				trial := atomic.AddInt64(&nextTrial, 1)
+				if s.rate > 0 {
+					slot := start.Add(s.due(trial))
+					time.Sleep(time.Until(slot))
+					atomic.AddInt64(&started, 1)
+					if slot.After(due) {
+						due = slot
+					}
+				}
+				result := op(int(trial), stage)
+				result.stage, result.worker = stage, w+1
+				if result.due.IsZero() {
+					result.due = due
+				}
+				due = time.Now()
+				record(result)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if s.budget != nil {
+		s.budget.end(s.abort, int(min64(atomic.LoadInt64(&claimed), int64(s.trials))), s.trials)
+	}
+
+	return int(atomic.LoadInt64(&nextTrial))
+}
+
+// due is the offset from the start of the phase at which a paced trial should start.
+func (s schedule) due(trial int64) time.Duration {
+	return time.Duration(float64(trial-1) / s.rate * float64(time.Second))
+}
+
+// sampleBacklog records how many trials are due but not started, until the last one is due:
+// from then on the backlog can only drain, which says nothing about the rate.
+func (s schedule) sampleBacklog(start time.Time, started *int64, done <-chan struct{}) {
+	s.backlog.begin(start)
+	ticker := time.NewTicker(backlogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			if s.abort != nil && s.abort.Err() != nil {
+				return
+			}
+			due := int64(now.Sub(start.Add(backlogGrace)).Seconds()*s.rate) + 1
+			if due > int64(s.trials) {
+				due = int64(s.trials)
+			}
+			// Trials started within the grace period are not due yet, which must not make it negative.
+			backlog := due - atomic.LoadInt64(started)
+			if backlog < 0 {
+				backlog = 0
+			}
+			s.backlog.sample(now, int(backlog))
+			if due == int64(s.trials) {
+				return
+			}
+		}
+	}
+}
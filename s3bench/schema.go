@@ -0,0 +1,111 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// reportSchemaVersion is the "schema_version" of the JSON reports, "major.minor". Within a major
+// version the changes are additive only: fields are added, never renamed, retyped or removed, so
+// that a consumer of 1.0 reads any 1.x report. Anything else bumps the major version.
+const reportSchemaVersion = "1.0"
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// reportSchema is the JSON Schema of the reports, generated from their types: a single run is a
+// Report, "-runs" a MultiRunReport and "-compare-sse" a Comparison of two Reports.
+func reportSchema() map[string]any {
+	s := schemaBuilder{defs: map[string]any{}}
+	refs := []any{}
+	for _, t := range []reflect.Type{reflect.TypeOf(Report{}), reflect.TypeOf(MultiRunReport{}), reflect.TypeOf(Comparison{})} {
+		refs = append(refs, s.schema(t))
+	}
+	return map[string]any{
+		"$schema":        jsonSchemaDialect,
+		"title":          appName + " report",
+		"description":    "Times are in nanoseconds, speeds in MB/s. Within a major schema_version fields are only ever added.",
+		"schema_version": reportSchemaVersion,
+		"anyOf":          refs,
+		"$defs":          s.defs,
+	}
+}
+
+// schemaBuilder collects the named struct types under "$defs", so that they are described once.
+type schemaBuilder struct {
+	defs map[string]any
+}
+
+func (s schemaBuilder) schema(t reflect.Type) map[string]any {
+	switch t {
+	case durationType:
+		return map[string]any{"type": "integer", "description": "nanoseconds"}
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return map[string]any{"anyOf": []any{s.schema(t.Elem()), map[string]any{"type": "null"}}}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		// Empty slices may be encoded as null.
+		return map[string]any{"type": []string{"array", "null"}, "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		if _, ok := s.defs[t.Name()]; !ok {
+			// Claimed before the fields are described, in case they refer back to it.
+			s.defs[t.Name()] = nil
+			s.defs[t.Name()] = s.object(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	}
+	// Interfaces, e.g. the report a webhook carries, are whatever they hold.
+	return map[string]any{}
+}
+
+func (s schemaBuilder) object(t reflect.Type) map[string]any {
+	properties, required := map[string]any{}, []string{}
+	s.fields(t, properties, &required)
+	return map[string]any{"type": "object", "properties": properties, "required": required}
+}
+
+// fields describes the fields of t as encoding/json encodes them, the embedded ones inlined.
+func (s schemaBuilder) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			s.fields(field.Type, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.schema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestReportCompatibility decodes the reports of the previous versions, those of testdata/reports
+// as the tool of that version printed them with "-json". Within a major schema_version fields are
+// only ever added: every field of an older report still has its place in a Report.
+func TestReportCompatibility(t *testing.T) {
+	for _, tc := range []struct {
+		file, version string
+	}{
+		// Before "schema_version", the reports are those of 1.0 without it.
+		{"unversioned.json", ""},
+		{"1.0.json", "1.0"},
+	} {
+		content, err := os.ReadFile(filepath.Join("testdata", "reports", tc.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		var report Report
+		if err := decoder.Decode(&report); err != nil {
+			t.Errorf("%s: %v", tc.file, err)
+			continue
+		}
+		if report.SchemaVersion != tc.version || report.Label != "fixture" {
+			t.Errorf("%s: schema version %q and label %q, want %q and fixture", tc.file, report.SchemaVersion, report.Label, tc.version)
+		}
+		if report.Upload.Count != 3 || report.Download.Count != 3 || report.Upload.P90Time <= 0 || report.Cleanup == nil {
+			t.Errorf("%s: decoded as %+v", tc.file, report)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"bytes"
+	"context"
+	cryptosha256 "crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/minio/minio-go/v7/pkg/signer"
+	"github.com/minio/sha256-simd"
+)
+
+// minioPartSize is the part size minio-go uploads objects larger than it in by default.
+const minioPartSize = 16 * 1024 * 1024
+
+// signingBoundShare is the share of the average upload time from which signing alone is taken
+// to limit the uploads, the rule of the payload generation.
+const signingBoundShare = generationBoundShare
+
+// The ways the requests of an upload are signed, which the SDKs choose by the signature
+// version and the scheme: only plain HTTP has the payload hashed into the signature.
+const (
+	signingV2        = "v2, headers only"
+	signingStreaming = "v4, streaming chunk signatures of the payload"
+	signingUnsigned  = "v4, unsigned payload"
+	signingAWSHashed = "v4, SHA-256 of the payload"
+)
+
+// SigningCost is what "-measure-signing" found signing the requests of an upload costs the client
+// CPU, measured without the network by signing Requests uploads of Size with the code of the SDK.
+// Cap is how fast the workers could upload at most, signing on min(Concurrency, Cores) cores;
+// UploadShare is the share of the average upload time of the run spent on signing.
+type SigningCost struct {
+	Requests    int           `json:"requests"`
+	Size        int64         `json:"size"`
+	Parts       int           `json:"parts"`
+	Method      string        `json:"method"`
+	PerRequest  time.Duration `json:"per_request"`
+	Speed       float64       `json:"speed"`
+	Concurrency int           `json:"concurrency"`
+	Cores       int           `json:"cores"`
+	Cap         float64       `json:"cap"`
+	UploadShare float64       `json:"upload_share,omitempty"`
+	Bound       bool          `json:"bound"`
+}
+
+func (c SigningCost) String() string {
+	s := fmt.Sprintf(" Signing     : per.request=%s speed=%s MB/s cap=%s MB/s (%s, %d requests of %s in %d parts, %d workers on %d cores)\n",
+		formatDuration(c.PerRequest), formatSpeed(c.Speed), formatSpeed(c.Cap), c.Method, c.Requests, formatBytes(c.Size), c.Parts, c.Concurrency, c.Cores)
+	if c.Bound {
+		s += fmt.Sprintf("  WARNING: results likely limited by client CPU, signing alone takes %.0f%% of the upload time (rule: 25%% or more)\n", c.UploadShare)
+	}
+	return s
+}
+
+// observe relates the cost to the uploads of the run.
+func (c *SigningCost) observe(upload PhaseStats) {
+	if upload.Count == 0 || upload.AvgTime <= 0 {
+		return
+	}
+	c.UploadShare = float64(c.PerRequest) / float64(upload.AvgTime) * 100
+	c.Bound = c.UploadShare >= signingBoundShare*100
+}
+
+// signingMethod is how the SDK signs the uploads to an endpoint of the scheme.
+func signingMethod(sdk, signature string, secure, disableContentSHA256 bool) string {
+	switch {
+	case signature == signatureV2:
+		return signingV2
+	case secure || disableContentSHA256:
+		return signingUnsigned
+	case sdk == sdkAWS:
+		return signingAWSHashed
+	}
+	return signingStreaming
+}
+
+// sha256Hasher is the SHA-256 minio-go streams the chunk signatures with.
+type sha256Hasher struct {
+	hash.Hash
+}
+
+func (sha256Hasher) Close() {}
+
+// measureSigning signs requests uploads of size, every part of a multipart upload as a request
+// of its own, and returns the cost. Nothing is sent: the signed bodies are read into the void.
+func measureSigning(method string, requests int, size int64, opts clientOptions, concurrency int) SigningCost {
+	region := opts.region
+	if region == "" {
+		region = awsDefaultRegion
+	}
+	payload := make([]byte, size)
+	io.ReadFull(newPayloadReader(1, 1, 0, size), payload)
+	parts := [][]byte{payload}
+	if size > minioPartSize {
+		parts = parts[:0]
+		for offset := int64(0); offset < size; offset += minioPartSize {
+			parts = append(parts, payload[offset:min64(offset+minioPartSize, size)])
+		}
+	}
+
+	awsSigner := v4.NewSigner()
+	credentials := aws.Credentials{AccessKeyID: opts.accessKey, SecretAccessKey: opts.secretKey}
+	start := time.Now()
+	for i := 0; i < requests; i++ {
+		for _, part := range parts {
+			req, _ := http.NewRequest(http.MethodPut, "https://s3.amazonaws.com/bucket/key", bytes.NewReader(part))
+			req.ContentLength = int64(len(part))
+			switch method {
+			case signingV2:
+				signer.SignV2(*req, opts.accessKey, opts.secretKey, false)
+			case signingUnsigned:
+				req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+				signer.SignV4Trailer(*req, opts.accessKey, opts.secretKey, "", region, nil)
+			case signingAWSHashed:
+				sum := cryptosha256.Sum256(part)
+				awsSigner.SignHTTP(context.Background(), credentials, req, hex.EncodeToString(sum[:]), "s3", region, time.Now())
+			default:
+				signed := signer.StreamingSignV4(req, opts.accessKey, opts.secretKey, "", region, int64(len(part)), time.Now().UTC(), sha256Hasher{sha256.New()})
+				io.Copy(io.Discard, signed.Body)
+			}
+		}
+	}
+	elapsed := time.Since(start)
+
+	cost := SigningCost{Requests: requests, Size: size, Parts: len(parts), Method: method, Concurrency: concurrency, Cores: runtime.NumCPU()}
+	cost.PerRequest = elapsed / time.Duration(requests)
+	cost.Speed = transferSpeed(size, cost.PerRequest)
+	cost.Cap = cost.Speed * float64(min64(int64(concurrency), int64(cost.Cores)))
+	return cost
+}
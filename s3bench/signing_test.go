@@ -0,0 +1,87 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigningMethod(t *testing.T) {
+	for _, tc := range []struct {
+		sdk, signature               string
+		secure, disableContentSHA256 bool
+		want                         string
+	}{
+		{sdkMinio, signatureV2, false, false, signingV2},
+		{sdkMinio, signatureV2, true, false, signingV2},
+		{sdkMinio, signatureV4, false, false, signingStreaming},
+		{sdkMinio, signatureV4, true, false, signingUnsigned},
+		{sdkMinio, signatureV4, false, true, signingUnsigned},
+		{sdkAWS, signatureV4, false, false, signingAWSHashed},
+		{sdkAWS, signatureV4, true, false, signingUnsigned},
+	} {
+		if got := signingMethod(tc.sdk, tc.signature, tc.secure, tc.disableContentSHA256); got != tc.want {
+			t.Errorf("signingMethod(%s, %s, secure=%t, disableContentSHA256=%t) = %q, want %q", tc.sdk, tc.signature, tc.secure, tc.disableContentSHA256, got, tc.want)
+		}
+	}
+}
+
+func TestMeasureSigning(t *testing.T) {
+	opts := clientOptions{accessKey: "access", secretKey: "secret", signature: signatureV4}
+	for _, tc := range []struct {
+		method   string
+		requests int
+		size     int64
+		parts    int
+	}{
+		{signingV2, 3, 1 << 20, 1},
+		{signingUnsigned, 3, 1 << 20, 1},
+		{signingAWSHashed, 3, 1 << 20, 1},
+		{signingStreaming, 3, 1 << 20, 1},
+		// Exactly a part, then split in the parts of minio-go, the last one shorter.
+		{signingStreaming, 1, minioPartSize, 1},
+		{signingStreaming, 1, 2*minioPartSize + 1, 3},
+	} {
+		c := measureSigning(tc.method, tc.requests, tc.size, opts, 4)
+		if c.Method != tc.method || c.Requests != tc.requests || c.Size != tc.size || c.Parts != tc.parts || c.Concurrency != 4 || c.Cores != runtime.NumCPU() {
+			t.Errorf("%s of %d: %+v, want %d requests in %d parts", tc.method, tc.size, c, tc.requests, tc.parts)
+		}
+		// Signing on as many cores as there are workers, at most on all of them.
+		cores := 4
+		if c.Cores < cores {
+			cores = c.Cores
+		}
+		if c.PerRequest <= 0 || c.Speed != transferSpeed(tc.size, c.PerRequest) || c.Cap != c.Speed*float64(cores) {
+			t.Errorf("%s of %d: per request %v at %v MB/s capped at %v MB/s", tc.method, tc.size, c.PerRequest, c.Speed, c.Cap)
+		}
+		if c.UploadShare != 0 || c.Bound {
+			t.Errorf("%s of %d: judged without the uploads: %+v", tc.method, tc.size, c)
+		}
+	}
+}
+
+func TestSigningCostObserve(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		upload PhaseStats
+		share  float64
+		bound  bool
+	}{
+		{"negligible", PhaseStats{Count: 10, AvgTime: 100 * time.Millisecond}, 10, false},
+		// The rule of the payload generation, a quarter of the upload time.
+		{"at the rule", PhaseStats{Count: 10, AvgTime: 40 * time.Millisecond}, 25, true},
+		{"dominant", PhaseStats{Count: 10, AvgTime: 10 * time.Millisecond}, 100, true},
+		{"no uploads", PhaseStats{}, 0, false},
+	} {
+		c := SigningCost{Requests: 10, Size: 1 << 20, Parts: 1, Method: signingStreaming, PerRequest: 10 * time.Millisecond, Concurrency: 1, Cores: 1}
+		c.observe(tc.upload)
+		if c.UploadShare != tc.share || c.Bound != tc.bound {
+			t.Errorf("%s: share %v%% bound=%t, want %v%% and %t", tc.name, c.UploadShare, c.Bound, tc.share, tc.bound)
+		}
+		if warned := strings.Contains(c.String(), "WARNING: results likely limited by client CPU, signing alone"); warned != tc.bound {
+			t.Errorf("%s: warned=%t, want %t:\n%s", tc.name, warned, tc.bound, c)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// minCurveSizes is how many distinct sizes a size curve needs, two always make a perfect line.
+const minCurveSizes = 3
+
+// LatencyFit is the least-squares line duration = Overhead + size / Throughput, over Points
+// sizes. Throughput is in MB/s, it is 0 when the duration did not grow with the size.
+type LatencyFit struct {
+	Points     int           `json:"points"`
+	Overhead   time.Duration `json:"overhead"`
+	Throughput float64       `json:"throughput"`
+	RSquared   float64       `json:"r_squared"`
+}
+
+// latencyPoint is the duration of transferring size bytes.
+type latencyPoint struct {
+	size     int64
+	duration time.Duration
+}
+
+// fitLatency fits the points; ok is false with fewer than two distinct sizes.
+func fitLatency(points []latencyPoint) (fit LatencyFit, ok bool) {
+	var n, sumX, sumY, sumXX, sumXY float64
+	for _, p := range points {
+		x, y := float64(p.size), p.duration.Seconds()
+		n, sumX, sumY, sumXX, sumXY = n+1, sumX+x, sumY+y, sumXX+x*x, sumXY+x*y
+	}
+	if n < 2 || n*sumXX == sumX*sumX {
+		return LatencyFit{}, false
+	}
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	intercept := (sumY - slope*sumX) / n
+	fit = LatencyFit{Points: len(points), Overhead: time.Duration(intercept * float64(time.Second))}
+	if slope > 0 {
+		fit.Throughput = 1 / slope / 1024 / 1024 // MB/s
+	}
+
+	var residual, total float64
+	for _, p := range points {
+		residual += math.Pow(p.duration.Seconds()-(intercept+slope*float64(p.size)), 2)
+		total += math.Pow(p.duration.Seconds()-sumY/n, 2)
+	}
+	if total > 0 {
+		fit.RSquared = 1 - residual/total
+	}
+	return fit, true
+}
+
+// predict is the duration of the size by the fit.
+func (f LatencyFit) predict(size int64) time.Duration {
+	if f.Throughput <= 0 {
+		return 0
+	}
+	return f.Overhead + time.Duration(float64(size)/1024/1024/f.Throughput*float64(time.Second))
+}
+
+func (f LatencyFit) String() string {
+	return fmt.Sprintf("overhead=%s throughput=%s MB/s (r²=%.3f, %d sizes)", formatDuration(f.Overhead), formatSpeed(f.Throughput), f.RSquared, f.Points)
+}
+
+// SizeCurve compresses the trials of a "-size-distribution" other than "fixed" into the
+// request overhead and the streaming throughput of every phase, fitted over the mean duration
+// per distinct size. A phase with fewer than minCurveSizes sizes is not fitted.
+type SizeCurve struct {
+	UploadSizes   int         `json:"upload_sizes"`
+	DownloadSizes int         `json:"download_sizes"`
+	Upload        *LatencyFit `json:"upload,omitempty"`
+	Download      *LatencyFit `json:"download,omitempty"`
+}
+
+func newSizeCurve(upload, download map[int64]*sizeMean) *SizeCurve {
+	return &SizeCurve{UploadSizes: len(upload), DownloadSizes: len(download), Upload: fitSizeMeans(upload), Download: fitSizeMeans(download)}
+}
+
+func (c SizeCurve) String() string {
+	var sb strings.Builder
+	for _, phase := range []struct {
+		name  string
+		sizes int
+		fit   *LatencyFit
+	}{{"upload", c.UploadSizes, c.Upload}, {"download", c.DownloadSizes, c.Download}} {
+		if phase.fit == nil {
+			fmt.Fprintf(&sb, " Size curve  : %s not fitted, %d sizes and at least %d needed\n", phase.name, phase.sizes, minCurveSizes)
+			continue
+		}
+		fmt.Fprintf(&sb, " Size curve  : %s %s\n", phase.name, phase.fit)
+	}
+	return sb.String()
+}
+
+// sizeMean accumulates the durations of the trials of a size.
+type sizeMean struct {
+	count int
+	total time.Duration
+}
+
+func fitSizeMeans(means map[int64]*sizeMean) *LatencyFit {
+	if len(means) < minCurveSizes {
+		return nil
+	}
+	points := make([]latencyPoint, 0, len(means))
+	for size, m := range means {
+		points = append(points, latencyPoint{size: size, duration: m.total / time.Duration(m.count)})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].size < points[j].size })
+	fit, ok := fitLatency(points)
+	if !ok {
+		return nil
+	}
+	return &fit
+}
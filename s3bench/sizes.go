@@ -0,0 +1,173 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"math"
+	mathrand "math/rand"
+	"strconv"
+	"strings"
+)
+
+const (
+	sizeFixed   = "fixed"
+	sizeUniform = "uniform"
+	sizeNormal  = "normal"
+)
+
+// sizeDistribution picks the size of every trial's object. Sizes are a function of the seed and
+// the trial, so that downloads and verification know the size of every key without keeping it.
+type sizeDistribution struct {
+	kind     string
+	mean     int64
+	stddev   int64
+	min, max int64
+	seed     int64
+}
+
+// newSizeDistribution defaults the bounds to mean±stddev for "uniform" and to [1 byte, 2×mean]
+// for "normal".
+func newSizeDistribution(kind string, mean int64, stddevValue, minValue, maxValue string, seed int64) (sizeDistribution, error) {
+	d := sizeDistribution{kind: kind, mean: mean, seed: seed}
+	if kind == sizeFixed {
+		d.min, d.max = mean, mean
+		return d, nil
+	}
+	if kind != sizeUniform && kind != sizeNormal {
+		return d, fmt.Errorf(`unknown distribution %q, expected %q, %q or %q`, kind, sizeFixed, sizeUniform, sizeNormal)
+	}
+
+	var err error
+	for _, p := range []struct {
+		value  string
+		target *int64
+	}{{stddevValue, &d.stddev}, {minValue, &d.min}, {maxValue, &d.max}} {
+		if p.value == "" {
+			continue
+		}
+		if *p.target, err = parseByteSize(p.value); err != nil {
+			return d, err
+		}
+	}
+	if minValue == "" {
+		d.min = 1
+		if kind == sizeUniform {
+			d.min = mean - d.stddev
+		}
+	}
+	if maxValue == "" {
+		d.max = 2 * mean
+		if kind == sizeUniform {
+			d.max = mean + d.stddev
+		}
+	}
+	if d.min < 1 {
+		d.min = 1
+	}
+	if d.max < d.min {
+		return d, fmt.Errorf(`maximum size %d is below the minimum %d`, d.max, d.min)
+	}
+	if kind == sizeNormal && d.stddev == 0 {
+		return d, fmt.Errorf(`%q requires a standard deviation`, sizeNormal)
+	}
+	return d, nil
+}
+
+func (d sizeDistribution) size(trial int) int64 {
+	rng := mathrand.New(mathrand.NewSource(d.seed + int64(trial)))
+	var size int64
+	switch d.kind {
+	case sizeUniform:
+		size = d.min + rng.Int63n(d.max-d.min+1)
+	case sizeNormal:
+		size = int64(math.Round(rng.NormFloat64()*float64(d.stddev) + float64(d.mean)))
+	default:
+		return d.mean
+	}
+	return min64(max64(size, d.min), d.max)
+}
+
+// largest is the upper bound of the sizes, which is what the upload buffers are sized for.
+func (d sizeDistribution) largest() int64 {
+	return d.max
+}
+
+// SizeStats summarizes the sizes which were actually uploaded.
+type SizeStats struct {
+	Distribution string  `json:"distribution"`
+	Mean         float64 `json:"mean"`
+	Min          int64   `json:"min"`
+	Max          int64   `json:"max"`
+}
+
+func (d sizeDistribution) realized(trials int) SizeStats {
+	stats := SizeStats{Distribution: d.kind}
+	var total int64
+	for trial := 1; trial <= trials; trial++ {
+		size := d.size(trial)
+		total += size
+		if trial == 1 || size < stats.Min {
+			stats.Min = size
+		}
+		if size > stats.Max {
+			stats.Max = size
+		}
+	}
+	if trials > 0 {
+		stats.Mean = float64(total) / float64(trials)
+	}
+	return stats
+}
+
+func (s SizeStats) String() string {
+	return fmt.Sprintf(" Sizes       : %s mean=%s min=%s max=%s\n", s.Distribution, formatBytes(int64(s.Mean)), formatBytes(s.Min), formatBytes(s.Max))
+}
+
+var byteUnits = []struct {
+	suffix string
+	scale  int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+	{"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	{"B", 1},
+}
+
+// parseByteSize parses sizes like "8MiB", "512KB" or a plain number of bytes.
+func parseByteSize(value string) (int64, error) {
+	number, scale := strings.TrimSpace(value), int64(1)
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, scale = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix)), unit.scale
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf(`invalid size %q`, value)
+	}
+	return int64(n * float64(scale)), nil
+}
+
+func formatBytes(n int64) string {
+	for i := 2; i >= 0; i-- {
+		if unit := byteUnits[i]; n >= unit.scale {
+			return strconv.FormatFloat(float64(n)/float64(unit.scale), 'f', 2, 64) + " " + unit.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10) + " B"
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,111 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// stabilityWindow is how many of the latest trials the P90 estimate has to stay within the
+	// "-confidence" of its current value for a phase of "-auto-trials" to stop.
+	stabilityWindow = 20
+	// stabilityStride is how many trials apart P90 is estimated over the window: an estimate
+	// sorts all the samples kept.
+	stabilityStride = 5
+	// minStableTrials is the fewest trials such a phase stops after, however stable P90 looks:
+	// over the first few trials it is essentially the maximum.
+	minStableTrials = 50
+)
+
+// StabilityStats is how a phase of "-auto-trials" ended. Trials is the number of trials it ran,
+// failed ones included; Change is how far (in percent) the P90 estimate moved over the last
+// Window trials, Stable whether that was within Confidence before "-max-trials" was hit.
+type StabilityStats struct {
+	Confidence float64 `json:"confidence_pct"`
+	Window     int     `json:"window"`
+	Trials     int     `json:"trials"`
+	Change     float64 `json:"change_pct"`
+	Stable     bool    `json:"stable"`
+}
+
+func (s StabilityStats) String(phase string) string {
+	str := fmt.Sprintf(" Auto trials : %s stopped after %d trials, P90 moved %.1f%% over the last %d (confidence %g%%)\n", phase, s.Trials, s.Change, s.Window, s.Confidence)
+	if !s.Stable {
+		str += fmt.Sprintf("  WARNING: %s P90 not stable yet when \"-max-trials\" stopped it, raise it for a trustworthy P90\n", phase)
+	}
+	return str
+}
+
+// p90Change is the stopping rule of "-auto-trials": the largest difference (in percent) between
+// the latest of the P90 estimates and the window ones before it, i.e. how far P90 moved
+// recently. It is +Inf until there are enough estimates to tell.
+func p90Change(estimates []float64, window int) float64 {
+	if len(estimates) <= window {
+		return math.Inf(1)
+	}
+	latest := estimates[len(estimates)-1]
+	if latest == 0 {
+		return math.Inf(1)
+	}
+	var change float64
+	for _, e := range estimates[len(estimates)-1-window : len(estimates)-1] {
+		if d := math.Abs(e-latest) / latest * 100; d > change {
+			change = d
+		}
+	}
+	return change
+}
+
+// stabilityTracker follows the P90 estimate of a phase every stabilityStride trials, keeping the
+// estimates of the latest window. A nil stabilityTracker never reports the phase stable.
+type stabilityTracker struct {
+	confidence float64
+	trials     int
+	estimates  []float64
+	change     float64
+	stable     bool
+}
+
+func newStabilityTracker(autoTrials bool, confidence float64) *stabilityTracker {
+	if !autoTrials {
+		return nil
+	}
+	return &stabilityTracker{confidence: confidence * 100, change: math.Inf(1)}
+}
+
+// add takes the times after another trial, and returns true once their P90 estimate is stable.
+func (t *stabilityTracker) add(times sampleSet) bool {
+	if t == nil {
+		return false
+	}
+	if t.trials++; t.trials%stabilityStride != 0 {
+		return t.stable
+	}
+	const window = stabilityWindow / stabilityStride
+	if len(t.estimates) > window {
+		copy(t.estimates, t.estimates[1:])
+		t.estimates = t.estimates[:window]
+	}
+	t.estimates = append(t.estimates, times.percentile(0.9))
+	if t.trials < minStableTrials {
+		return false
+	}
+	t.change = p90Change(t.estimates, window)
+	if t.change <= t.confidence {
+		t.stable = true
+	}
+	return t.stable
+}
+
+func (t *stabilityTracker) stats(trials int) *StabilityStats {
+	if t == nil {
+		return nil
+	}
+	s := &StabilityStats{Confidence: t.confidence, Window: stabilityWindow, Trials: trials, Change: t.change, Stable: t.stable}
+	// Too few trials to tell must not break the JSON report.
+	if math.IsInf(s.Change, 1) {
+		s.Change = 0
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"math"
+	mathrand "math/rand"
+	"testing"
+)
+
+func TestP90Change(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		estimates []float64
+		window    int
+		want      float64
+	}{
+		{"no estimates", nil, 4, math.Inf(1)},
+		// The first window has nothing to compare the latest estimate to.
+		{"first window", []float64{100, 100, 100, 100}, 4, math.Inf(1)},
+		{"window full", []float64{100, 100, 100, 100, 100}, 4, 0},
+		// Only the window before the latest estimate counts.
+		{"older estimates", []float64{500, 100, 100, 100, 100, 100}, 4, 0},
+		{"largest difference", []float64{95, 102, 110, 99, 100}, 4, 10},
+		{"zero previous P90", []float64{100, 0, 100, 100, 100}, 4, 100},
+		{"zero latest P90", []float64{100, 100, 100, 100, 0}, 4, math.Inf(1)},
+	} {
+		if got := p90Change(tc.estimates, tc.window); got != tc.want && math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%s: p90Change(%v, %d) = %v, want %v", tc.name, tc.estimates, tc.window, got, tc.want)
+		}
+	}
+}
+
+func TestStabilityTracker(t *testing.T) {
+	// P90 converges on 100 as 100/trials: it moved by 1.31% over the window at 50 trials, 1.02%
+	// at 55, 0.82% at 60, 0.31% at 90 and 0.28% at 95.
+	run := func(confidence float64) (stopped int, tracker *stabilityTracker) {
+		tracker = newStabilityTracker(true, confidence)
+		for trial := 1; trial <= 200; trial++ {
+			if tracker.add(&fixedP90{100 + 100/float64(trial)}) {
+				return trial, tracker
+			}
+		}
+		return 0, tracker
+	}
+	for _, tc := range []struct {
+		confidence float64
+		// stopped is the trial the phase was stopped after, 0 for none.
+		stopped int
+	}{
+		// However stable, not before minStableTrials.
+		{0.05, minStableTrials},
+		// On the first stride within the threshold.
+		{0.01, 60},
+		{0.003, 95},
+		{0.0005, 0},
+	} {
+		stopped, tracker := run(tc.confidence)
+		if stopped != tc.stopped {
+			t.Errorf("confidence %v: stopped after %d trials, want %d (%+v)", tc.confidence, stopped, tc.stopped, tracker.stats(stopped))
+		}
+		if window := stabilityWindow/stabilityStride + 1; len(tracker.estimates) > window {
+			t.Errorf("confidence %v: %d estimates kept, want at most %d", tc.confidence, len(tracker.estimates), window)
+		}
+	}
+
+	var off *stabilityTracker
+	if off.add(&fixedP90{1}) || off.stats(10) != nil {
+		t.Error("a nil tracker stops the phase")
+	}
+}
+
+// fixedP90 is a sample set of a given P90.
+type fixedP90 struct{ p90 float64 }
+
+func (s *fixedP90) add(float64)                       {}
+func (s *fixedP90) count() int                        { return 1 }
+func (s *fixedP90) mean() float64                     { return s.p90 }
+func (s *fixedP90) percentile(p float64) float64      { return s.p90 }
+func (s *fixedP90) resample(*mathrand.Rand) sampleSet { return s }
@@ -0,0 +1,72 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+// startTimeLayout prints the start times with milliseconds, in local time.
+const startTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// SyncedStart records when a run scheduled by "-start-at" or "-start-in" began its first
+// measured operation, so that the reports of several hosts can be aligned afterwards. The clocks
+// of the hosts are taken as they are.
+type SyncedStart struct {
+	Target  time.Time `json:"target"`
+	Started time.Time `json:"started"`
+	// Late is how far past the target the start was, because of the setup or of the sleep.
+	Late time.Duration `json:"late"`
+}
+
+func (s SyncedStart) String() string {
+	return fmt.Sprintf(" Start       : %s (scheduled %s, %s late)\n",
+		s.Started.Format(startTimeLayout), s.Target.Format(startTimeLayout), formatDuration(s.Late))
+}
+
+// parseStartTime resolves "-start-at" (RFC 3339) or "-start-in" to the instant to start at, the
+// zero time when neither is given.
+func parseStartTime(at string, in time.Duration, now time.Time) (time.Time, error) {
+	switch {
+	case at != "" && in != 0:
+		return time.Time{}, fmt.Errorf(`"-start-at" and "-start-in" are mutually exclusive`)
+	case in < 0:
+		return time.Time{}, fmt.Errorf(`"-start-in" must not be negative`)
+	case in > 0:
+		return now.Add(in), nil
+	case at == "":
+		return time.Time{}, nil
+	}
+	target, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		return time.Time{}, fmt.Errorf(`"-start-at" %q is not an RFC 3339 time such as "2024-06-01T14:00:00Z"`, at)
+	}
+	if !target.After(now) {
+		return time.Time{}, fmt.Errorf(`"-start-at" %s has already passed`, target.Format(startTimeLayout))
+	}
+	return target, nil
+}
+
+// waitForStart sleeps until target with a countdown, every 10 seconds and then over the last 5,
+// and returns when it actually started. A target passed during the setup starts right away.
+func waitForStart(progress io.Writer, target time.Time) *SyncedStart {
+	if remaining := time.Until(target); remaining <= 0 {
+		log.Printf(`WARNING: the setup finished %s after the start time, starting right away`, formatDuration(-remaining))
+	} else {
+		fmt.Fprintf(progress, "Waiting until %s to start\n", target.Format(startTimeLayout))
+		for remaining > 0 {
+			seconds := (remaining + time.Second - 1) / time.Second
+			if seconds <= 5 || seconds%10 == 0 {
+				fmt.Fprintf(progress, "Starting in %ds\n", seconds)
+			}
+			// Sleeps to the next whole second before the target, or to the target.
+			time.Sleep(remaining - (seconds-1)*time.Second)
+			remaining = time.Until(target)
+		}
+	}
+	started := time.Now()
+	fmt.Fprintf(progress, "Started at %s\n", started.Format(startTimeLayout))
+	return &SyncedStart{Target: target, Started: started.Truncate(time.Millisecond), Late: started.Sub(target)}
+}
@@ -0,0 +1,320 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// stateVersion is bumped whenever the state format changes incompatibly.
+	stateVersion = 1
+	// stateSaveInterval is how often "-state-file" is written during a run.
+	stateSaveInterval = 5 * time.Second
+)
+
+// runState is what "-state-file" persists of a run, so that a run which died can be resumed:
+// the trials of the upload and download phases which completed, by phase.
+type runState struct {
+	Version    int                     `json:"version"`
+	ConfigHash string                  `json:"config_hash"`
+	RunID      string                  `json:"run_id"`
+	SizeSeed   int64                   `json:"size_seed"`
+	Completed  []string                `json:"completed,omitempty"`
+	Trials     map[string][]savedTrial `json:"trials"`
+	SavedAt    time.Time               `json:"saved_at"`
+}
+
+type savedTrial struct {
+	Trial        int           `json:"trial"`
+	Host         string        `json:"host,omitempty"`
+	Key          string        `json:"key"`
+	ETag         string        `json:"etag,omitempty"`
+	Start        time.Time     `json:"start"`
+	Duration     time.Duration `json:"duration"`
+	Bytes        int64         `json:"bytes"`
+	Speed        float64       `json:"speed"`
+	StatDuration time.Duration `json:"stat_duration,omitempty"`
+	TTFB         time.Duration `json:"ttfb,omitempty"`
+}
+
+// stateEnvelope guards the state against truncated or edited files.
+type stateEnvelope struct {
+	Checksum string          `json:"checksum"`
+	State    json.RawMessage `json:"state"`
+}
+
+// ResumeStats tells which samples of the report were measured by an earlier process.
+type ResumeStats struct {
+	StateFile string         `json:"state_file"`
+	SavedAt   time.Time      `json:"saved_at"`
+	Restored  map[string]int `json:"restored"`
+}
+
+func (s ResumeStats) String() string {
+	return fmt.Sprintf(" Resumed     : from %s saved at %s, restored upload=%d download=%d trials measured by an earlier process\n",
+		s.StateFile, s.SavedAt.Format(time.RFC3339), s.Restored["upload"], s.Restored["download"])
+}
+
+// stateFile keeps the state of the run and writes it to path. A nil stateFile does nothing.
+type stateFile struct {
+	path string
+
+	mu       sync.Mutex
+	state    runState
+	restored map[string]map[int]savedTrial
+	dirty    bool
+
+	// stop ends the saving of keep, which closes stopped once its ticker and signals are stopped.
+	stop, stopped chan struct{}
+}
+
+// openStateFile resumes the state at path if there is one, or starts a new one. hash is the one
+// of the effective configuration: a state is only resumed with the same options.
+func openStateFile(path, hash string) (*stateFile, error) {
+	if path == "" {
+		return nil, nil
+	}
+	s := &stateFile{path: path, state: runState{Version: stateVersion, ConfigHash: hash, Trials: map[string][]savedTrial{}}}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var envelope stateEnvelope
+	if err := json.Unmarshal(content, &envelope); err != nil {
+		return nil, fmt.Errorf(`corrupt state file, remove it to start over: %v`, err)
+	}
+	if sum := sha256.Sum256(envelope.State); hex.EncodeToString(sum[:]) != envelope.Checksum {
+		return nil, fmt.Errorf(`corrupt state file (checksum mismatch), remove it to start over`)
+	}
+	var state runState
+	if err := json.Unmarshal(envelope.State, &state); err != nil {
+		return nil, fmt.Errorf(`corrupt state file, remove it to start over: %v`, err)
+	}
+	if state.Version != stateVersion {
+		return nil, fmt.Errorf(`state file version %d is not supported, expected %d`, state.Version, stateVersion)
+	}
+	if state.ConfigHash != hash {
+		return nil, fmt.Errorf(`state file was written with other options, rerun with the same ones or remove it`)
+	}
+	if state.Trials == nil {
+		state.Trials = map[string][]savedTrial{}
+	}
+	s.state, s.restored = state, map[string]map[int]savedTrial{}
+	for phase, trials := range state.Trials {
+		s.restored[phase] = make(map[int]savedTrial, len(trials))
+		for _, t := range trials {
+			s.restored[phase][t.Trial] = t
+		}
+	}
+	return s, nil
+}
+
+func (s *stateFile) resumed() bool {
+	return s != nil && s.restored != nil
+}
+
+// runID returns the run ID of the resumed run, so that its objects are still recognized as
+// created by it, or else fresh.
+func (s *stateFile) runID(fresh string) string {
+	if s == nil {
+		return fresh
+	}
+	if !s.resumed() {
+		s.state.RunID = fresh
+	}
+	return s.state.RunID
+}
+
+// sizeSeed is like runID for the seed of the object sizes.
+func (s *stateFile) sizeSeed(fresh int64) int64 {
+	if s == nil {
+		return fresh
+	}
+	if !s.resumed() {
+		s.state.SizeSeed = fresh
+	}
+	return s.state.SizeSeed
+}
+
+func (s *stateFile) stats() *ResumeStats {
+	if !s.resumed() {
+		return nil
+	}
+	stats := &ResumeStats{StateFile: s.path, SavedAt: s.state.SavedAt, Restored: map[string]int{}}
+	for phase, trials := range s.restored {
+		stats.Restored[phase] = len(trials)
+	}
+	return stats
+}
+
+// resume returns the saved samples of the trials of phase instead of performing them again.
+func (s *stateFile) resume(phase string, newOperation operationFactory) operationFactory {
+	if !s.resumed() || len(s.restored[phase]) == 0 {
+		return newOperation
+	}
+	saved := s.restored[phase]
+	return func(worker int) operation {
+		op := newOperation(worker)
+		return func(trial int, stage string) sample {
+			t, ok := saved[trial]
+			if !ok {
+				return op(trial, stage)
+			}
+			return sample{
+				host: t.Host, trial: t.Trial, key: t.Key, etag: t.ETag, start: t.Start,
+				duration: t.Duration, bytes: t.Bytes, speed: t.Speed, statDuration: t.StatDuration, ttfb: t.TTFB,
+			}
+		}
+	}
+}
+
+// track adds the successful trials of phase to the state before passing them on to record.
+func (s *stateFile) track(phase string, record func(sample)) func(sample) {
+	if s == nil {
+		return record
+	}
+	return func(sample sample) {
+		record(sample)
+		if sample.err != nil || sample.skipped {
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.restored[phase][sample.trial]; ok {
+			return
+		}
+		s.state.Trials[phase] = append(s.state.Trials[phase], savedTrial{
+			Trial: sample.trial, Host: sample.host, Key: sample.key, ETag: sample.etag,
+			Start: sample.start, Duration: sample.duration, Bytes: sample.bytes, Speed: sample.speed,
+			StatDuration: sample.statDuration, TTFB: sample.ttfb,
+		})
+		s.dirty = true
+	}
+}
+
+func (s *stateFile) complete(phase string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Completed = append(s.state.Completed, phase)
+	s.dirty = true
+}
+
+// save writes the state atomically, so that a crash while saving leaves the previous one.
+func (s *stateFile) save() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.SavedAt = time.Now()
+	state, err := json.Marshal(s.state)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(state)
+	content, err := json.Marshal(stateEnvelope{Checksum: hex.EncodeToString(sum[:]), State: state})
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, content, 0o600); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// flush saves the state if anything changed since it was last saved.
+func (s *stateFile) flush() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	dirty := s.dirty
+	s.mu.Unlock()
+	if !dirty {
+		return nil
+	}
+	return s.save()
+}
+
+// keep saves the state periodically until finish. SIGINT or SIGTERM stop the saving and are
+// passed to interrupt, which halts the run and saves the state once more.
+func (s *stateFile) keep(interrupt func(os.Signal)) {
+	if s == nil {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(stateSaveInterval)
+	s.stop, s.stopped = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(s.stopped)
+		defer signal.Stop(signals)
+		defer ticker.Stop()
+		for {
+			select {
+			case sig := <-signals:
+				interrupt(sig)
+				return
+			case <-ticker.C:
+				if err := s.flush(); err != nil {
+					log.Printf(`WARNING: unable to save the state to %s: %v`, s.path, err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// finish removes the state of a run which completed, the next one starts over. The saving of
+// keep is stopped first, so that neither a tick nor a signal writes the state again.
+func (s *stateFile) finish() {
+	if s == nil {
+		return
+	}
+	s.stopSaving()
+	s.mu.Lock()
+	s.dirty = false
+	s.mu.Unlock()
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf(`WARNING: unable to remove the state file %s: %v`, s.path, err)
+	}
+}
+
+// halt keeps the state of a run which halted for the next one to resume from: the saving of keep
+// is stopped and what the trials in flight completed meanwhile is saved.
+func (s *stateFile) halt() {
+	if s == nil {
+		return
+	}
+	s.stopSaving()
+	if err := s.flush(); err != nil {
+		log.Printf(`Unable to save the state to %s: %v`, s.path, err)
+	}
+}
+
+func (s *stateFile) stopSaving() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.stopped
+		s.stop = nil
+	}
+}
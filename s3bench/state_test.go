@@ -0,0 +1,115 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// workloadHash is the config hash of a few workload and output flags parsed from args.
+func workloadHash(t *testing.T, args ...string) string {
+	t.Helper()
+	flags := flag.NewFlagSet("s3bench", flag.ContinueOnError)
+	flags.Int("trials", 10, "")
+	flags.Int64("seed", 0, "")
+	flags.String("verify-sample", "", "")
+	flags.Bool("json", false, "")
+	flags.String("events", "", "")
+	flags.String("state-file", "", "")
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	sources := configSources{}
+	sources.mark(flags, sourceFlag)
+	return resolveConfig(flags, sources).Hash
+}
+
+func TestStateConfigHash(t *testing.T) {
+	hash := workloadHash(t, "-trials", "20", "-verify-sample", "10%", "-state-file", "run.state")
+	// The outputs of a rerun may differ, the workload not.
+	if rerun := workloadHash(t, "-trials", "20", "-verify-sample", "10%", "-state-file", "other.state", "-json", "-events", "events.jsonl"); rerun != hash {
+		t.Errorf("the outputs changed the hash: %s, then %s", hash, rerun)
+	}
+	if other := workloadHash(t, "-trials", "21", "-verify-sample", "10%", "-state-file", "run.state"); other == hash {
+		t.Error("the number of trials did not change the hash")
+	}
+}
+
+func TestStateResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.state")
+	start := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	state, err := openStateFile(path, "hash-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A random seed of the first run is the seed of the resumed one.
+	state.runID("run-1")
+	state.sizeSeed(42)
+	record := state.track("upload", func(sample) {})
+	record(sample{trial: 3, key: "file-3.dat", start: start, duration: time.Second, bytes: 1024, speed: 1})
+	record(sample{trial: 4, key: "file-4.dat", err: errInjectedTruncation})
+	if err := state.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := openStateFile(path, "hash-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, seed := resumed.runID("run-2"), resumed.sizeSeed(7); id != "run-1" || seed != 42 {
+		t.Errorf("resumed run %s with seed %d, want run-1 with 42", id, seed)
+	}
+	// Only the successful trial is restored, the failed one is tried again.
+	var performed []int
+	op := resumed.resume("upload", func(worker int) operation {
+		return func(trial int, stage string) sample {
+			performed = append(performed, trial)
+			return sample{trial: trial}
+		}
+	})(0)
+	if s := op(3, stagePlateau); s.key != "file-3.dat" || s.duration != time.Second || !s.start.Equal(start) {
+		t.Errorf("trial 3 restored as %+v", s)
+	}
+	op(4, stagePlateau)
+	if len(performed) != 1 || performed[0] != 4 {
+		t.Errorf("performed trials %v, want only 4", performed)
+	}
+
+	if _, err := openStateFile(path, "hash-2"); err == nil || !strings.Contains(err.Error(), "other options") {
+		t.Errorf("resuming with other options: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, bytes.Replace(content, []byte("file-3.dat"), []byte("file-9.dat"), 1), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openStateFile(path, "hash-1"); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("resuming from an edited state: %v", err)
+	}
+}
+
+func TestStateFinishStopsSaving(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.state")
+	state, err := openStateFile(path, "hash-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.keep(func(os.Signal) {})
+	// The last phase completes right before the run finishes.
+	state.complete("download")
+	state.finish()
+	time.Sleep(stateSaveInterval + time.Second)
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the state file of a finished run is back: %v", err)
+	}
+}
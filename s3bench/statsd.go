@@ -0,0 +1,160 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const statsdQueueSize = 1024
+
+// statsd emits metrics over UDP from a background goroutine. Metrics are dropped rather than
+// delaying the caller when the queue is full. A nil statsd does nothing.
+type statsd struct {
+	*statsdSink
+	tags []string
+}
+
+type statsdSink struct {
+	conn      net.Conn
+	prefix    string
+	dogstatsd bool
+	queue     chan string
+	done      sync.WaitGroup
+	// mu guards closed: the workers still emitting when a failed trial closes the sink drop
+	// their metrics instead of sending on the closed queue.
+	mu     sync.Mutex
+	closed bool
+}
+
+func newStatsd(addr, prefix, format string, tags ...string) (*statsd, error) {
+	var dogstatsd bool
+	switch format {
+	case "dogstatsd":
+		dogstatsd = true
+	case "statsd":
+	default:
+		return nil, fmt.Errorf(`unsupported format %q`, format)
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	sink := &statsdSink{conn: conn, prefix: prefix, dogstatsd: dogstatsd, queue: make(chan string, statsdQueueSize)}
+	sink.done.Add(1)
+	go sink.loop()
+
+	s := &statsd{statsdSink: sink}
+	return s.withTags(tags...), nil
+}
+
+func (s *statsdSink) loop() {
+	defer s.done.Done()
+	for line := range s.queue {
+		// Fire and forget: an absent agent must not affect the benchmark.
+		s.conn.Write([]byte(line))
+	}
+}
+
+// withTags returns a statsd sharing the connection which additionally tags every metric.
+// Tags are given as name, value pairs; pairs with an empty value are skipped.
+func (s *statsd) withTags(tags ...string) *statsd {
+	if s == nil {
+		return nil
+	}
+	merged := append([]string{}, s.tags...)
+	for i := 0; i+1 < len(tags); i += 2 {
+		if tags[i+1] != "" {
+			merged = append(merged, sanitizeStatsdTag(tags[i])+":"+sanitizeStatsdTag(tags[i+1]))
+		}
+	}
+	return &statsd{statsdSink: s.statsdSink, tags: merged}
+}
+
+func sanitizeStatsdTag(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ',', '|', '#', ' ', '\n':
+			return '_'
+		}
+		return r
+	}, value)
+}
+
+func (s *statsd) timing(name string, d time.Duration) {
+	s.emit(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), "ms")
+}
+
+func (s *statsd) histogram(name string, value float64) {
+	metricType := "h"
+	if s != nil && !s.dogstatsd {
+		// Plain StatsD derives histograms from timers.
+		metricType = "ms"
+	}
+	s.emit(name, strconv.FormatFloat(value, 'f', -1, 64), metricType)
+}
+
+func (s *statsd) count(name string, value int) {
+	s.emit(name, strconv.Itoa(value), "c")
+}
+
+func (s *statsd) gauge(name string, value float64) {
+	s.emit(name, strconv.FormatFloat(value, 'f', -1, 64), "g")
+}
+
+func (s *statsd) emit(name, value, metricType string) {
+	if s == nil {
+		return
+	}
+	line := s.prefix + name + ":" + value + "|" + metricType
+	if s.dogstatsd && len(s.tags) > 0 {
+		line += "|#" + strings.Join(s.tags, ",")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.queue <- line:
+	default:
+	}
+}
+
+// summary emits the final report figures as gauges.
+func (s *statsd) summary(report Report) {
+	for _, phase := range []struct {
+		name  string
+		stats PhaseStats
+	}{{"upload", report.Upload}, {"download", report.Download}} {
+		s.gauge(phase.name+".avg_time", float64(phase.stats.AvgTime)/float64(time.Millisecond))
+		s.gauge(phase.name+".p90_time", float64(phase.stats.P90Time)/float64(time.Millisecond))
+		s.gauge(phase.name+".avg_speed", phase.stats.AvgSpeed)
+		s.gauge(phase.name+".p90_speed", phase.stats.P90Speed)
+		s.gauge(phase.name+".throughput", phase.stats.Throughput)
+	}
+}
+
+// close sends out whatever is queued; the metrics emitted afterwards are dropped. Only the first
+// call of the sink does anything.
+func (s *statsd) close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+	s.done.Wait()
+	s.conn.Close()
+}
@@ -0,0 +1,110 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// listenStatsd is a local StatsD agent which returns the datagrams it received.
+func listenStatsd(t *testing.T) (string, func(n int) []string) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn.LocalAddr().String(), func(n int) []string {
+		var lines []string
+		buf := make([]byte, 1500)
+		for len(lines) < n {
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			size, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				t.Fatalf("received %q, then: %v", lines, err)
+			}
+			lines = append(lines, string(buf[:size]))
+		}
+		return lines
+	}
+}
+
+func TestStatsdWireFormat(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		want   []string
+	}{
+		{"dogstatsd", []string{
+			"s3bench.upload.duration:12.5|ms|#endpoint:minio.local_9000,label:nightly",
+			"s3bench.upload.speed:31.25|h|#endpoint:minio.local_9000,label:nightly",
+			"s3bench.upload.errors:1|c|#endpoint:minio.local_9000,label:nightly",
+			"s3bench.upload.p90_time:20|g|#endpoint:minio.local_9000,label:nightly,variant:sse",
+		}},
+		{"statsd", []string{
+			"s3bench.upload.duration:12.5|ms",
+			"s3bench.upload.speed:31.25|ms",
+			"s3bench.upload.errors:1|c",
+			"s3bench.upload.p90_time:20|g",
+		}},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			addr, receive := listenStatsd(t)
+			// Tags are sanitized and those of no value skipped.
+			s, err := newStatsd(addr, "s3bench.", tc.format, "endpoint", "minio.local 9000", "bucket", "", "label", "nightly")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.timing("upload.duration", 12500*time.Microsecond)
+			s.histogram("upload.speed", 31.25)
+			s.count("upload.errors", 1)
+			s.withTags("variant", "sse").gauge("upload.p90_time", 20)
+			s.close()
+
+			for i, line := range receive(len(tc.want)) {
+				if line != tc.want[i] {
+					t.Errorf("datagram %d = %q, want %q", i, line, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStatsdUnsupportedFormat(t *testing.T) {
+	if _, err := newStatsd("127.0.0.1:8125", "", "graphite"); err == nil {
+		t.Error("newStatsd accepted the graphite format")
+	}
+}
+
+func TestNilStatsd(t *testing.T) {
+	var s *statsd
+	s.timing("upload.duration", time.Second)
+	s.count("upload.errors", 1)
+	s.summary(Report{})
+	s.withTags("variant", "plain").gauge("upload.avg_time", 1)
+	s.close()
+}
+
+func TestStatsdEmitAfterClose(t *testing.T) {
+	addr, _ := listenStatsd(t)
+	s, err := newStatsd(addr, "s3bench.", "dogstatsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The workers of a phase keep emitting while a failed trial closes the sink.
+	var workers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for j := 0; j < 1000; j++ {
+				s.count("upload.errors", 1)
+			}
+		}()
+	}
+	s.close()
+	workers.Wait()
+	s.timing("upload.duration", time.Second)
+	s.close()
+}
@@ -0,0 +1,49 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// ObjectStore is the set of S3 operations the benchmark performs. It is implemented by
+// minio-go and can be wrapped, e.g. to inject faults.
+type ObjectStore interface {
+	PutObject(ctx context.Context, bucketName, key string, reader io.Reader, size int64, opts minio.PutObjectOptions) (minio.UploadInfo, error)
+	GetObject(ctx context.Context, bucketName, key string, opts minio.GetObjectOptions) (io.ReadCloser, error)
+	StatObject(ctx context.Context, bucketName, key string, opts minio.StatObjectOptions) (minio.ObjectInfo, error)
+	RemoveObject(ctx context.Context, bucketName, key string, opts minio.RemoveObjectOptions) error
+	CopyObject(ctx context.Context, dst minio.CopyDestOptions, src minio.CopySrcOptions) (minio.UploadInfo, error)
+	ListObjects(ctx context.Context, bucketName string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo
+	BucketExists(ctx context.Context, bucketName string) (bool, error)
+	MakeBucket(ctx context.Context, bucketName string, opts minio.MakeBucketOptions) error
+	RemoveBucket(ctx context.Context, bucketName string) error
+	ListIncompleteUploads(ctx context.Context, bucketName, objectPrefix string, recursive bool) <-chan minio.ObjectMultipartInfo
+	RemoveIncompleteUpload(ctx context.Context, bucketName, key string) error
+	ListObjectParts(ctx context.Context, bucketName, key, uploadID string, partNumberMarker, maxParts int) (minio.ListObjectPartsResult, error)
+	AbortMultipartUpload(ctx context.Context, bucketName, key, uploadID string) error
+	PresignedGetObject(ctx context.Context, bucketName, key string, expires time.Duration, params url.Values) (*url.URL, error)
+	EndpointURL() *url.URL
+}
+
+// minioStore adapts minio.Client to ObjectStore.
+type minioStore struct {
+	*minio.Client
+}
+
+func (s minioStore) GetObject(ctx context.Context, bucketName, key string, opts minio.GetObjectOptions) (io.ReadCloser, error) {
+	return s.Client.GetObject(ctx, bucketName, key, opts)
+}
+
+// ListObjectParts and AbortMultipartUpload are only offered by the lower level minio.Core.
+func (s minioStore) ListObjectParts(ctx context.Context, bucketName, key, uploadID string, partNumberMarker, maxParts int) (minio.ListObjectPartsResult, error) {
+	return minio.Core{Client: s.Client}.ListObjectParts(ctx, bucketName, key, uploadID, partNumberMarker, maxParts)
+}
+
+func (s minioStore) AbortMultipartUpload(ctx context.Context, bucketName, key, uploadID string) error {
+	return minio.Core{Client: s.Client}.AbortMultipartUpload(ctx, bucketName, key, uploadID)
+}
@@ -0,0 +1,34 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import "os"
+
+// ANSI SGR colors.
+const (
+	colorNone   = ""
+	colorGreen  = "32"
+	colorYellow = "33"
+	colorRed    = "31"
+)
+
+// styler colors terminal output. The zero value prints plain text, which is what anything
+// that is not a terminal (files, pipes, JSON, webhooks) must get.
+type styler struct {
+	enabled bool
+}
+
+// newStyler enables colors when f is a terminal, unless NO_COLOR (https://no-color.org) is set.
+func newStyler(f *os.File) styler {
+	if _, set := os.LookupEnv("NO_COLOR"); set {
+		return styler{}
+	}
+	info, err := f.Stat()
+	return styler{enabled: err == nil && info.Mode()&os.ModeCharDevice != 0}
+}
+
+func (s styler) paint(color, text string) string {
+	if !s.enabled || color == colorNone {
+		return text
+	}
+	return "\x1b[" + color + "m" + text + "\x1b[0m"
+}
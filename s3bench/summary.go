@@ -0,0 +1,161 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// thresholdMargin is how close to its limit a passing check gets reported as a warning.
+const thresholdMargin = 0.1
+
+// SummaryRecord is the footer of the events output.
+type SummaryRecord struct {
+	Record  string `json:"record"`
+	Variant string `json:"variant,omitempty"`
+	Line    string `json:"line"`
+}
+
+// summaryLine renders the report on one line, e.g.
+// "UP p90 112.00 MB/s / 0.82s, DOWN p90 204.00 MB/s / 0.45s, errors 0". Phases are colored by
+// their threshold checks: red when one failed, yellow when one barely passed, green otherwise.
+func (r Report) summaryLine(st styler) string {
+	phase := func(name, metric string, stats PhaseStats) string {
+		text := fmt.Sprintf("%s p90 %s MB/s / %.2fs", name, formatSpeed(stats.P90Speed), stats.P90Time.Seconds())
+		return st.paint(r.thresholdColor(metric), text)
+	}
+	errors := fmt.Sprintf("errors %d", r.errors())
+	if r.errors() > 0 {
+		errors = st.paint(colorRed, errors)
+	}
+	parts := []string{phase("UP", "upload", r.Upload), phase("DOWN", "download", r.Download), errors}
+	if r.Metadata.Backend == backendFS {
+		parts = append([]string{"backend=fs"}, parts...)
+	}
+	if r.Metadata.ClientEncrypt != "" {
+		parts = append([]string{"client-encrypted"}, parts...)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// errors counts the problems of a run which made it to the report: the failed trials (only
+// recorded with "-abort-threshold") and the corrupted objects found by verification.
+func (r Report) errors() int {
+	errors := 0
+	for _, phase := range r.phases() {
+		errors += phase.stats.Failed
+	}
+	if r.Listing != nil {
+		errors += r.Listing.failures()
+	}
+	if r.Verification != nil {
+		errors += len(r.Verification.Failures)
+	}
+	return errors
+}
+
+type namedPhase struct {
+	name  string
+	stats *PhaseStats
+}
+
+// phases lists the phases which ran, in order.
+func (r Report) phases() []namedPhase {
+	var phases []namedPhase
+	for _, phase := range []namedPhase{{"upload", &r.Upload}, {"overwrite", r.Overwrite}, {"download", &r.Download}, {"miss", r.Miss}} {
+		if phase.stats != nil {
+			phases = append(phases, phase)
+		}
+	}
+	if r.CacheProbe != nil {
+		phases = append(phases, namedPhase{"cache-probe", r.CacheProbe.repeat()})
+	}
+	if r.Transformed != nil {
+		phases = append(phases, namedPhase{"transformed", &r.Transformed.Stats})
+	}
+	if r.AltEndpoint != nil {
+		phases = append(phases, namedPhase{"alt-cold", &r.AltEndpoint.Cold}, namedPhase{"alt-warm", &r.AltEndpoint.Warm})
+	}
+	return phases
+}
+
+// recovered describes the phases with recovered uploads, e.g. "upload=2".
+func (r Report) recovered() string {
+	var recovered []string
+	for _, phase := range r.phases() {
+		if phase.stats.Recovered > 0 {
+			recovered = append(recovered, fmt.Sprintf("%s=%d", phase.name, phase.stats.Recovered))
+		}
+	}
+	return strings.Join(recovered, " ")
+}
+
+// clockAnomalies describes the phases with trials of a non-positive duration, e.g. "upload=1".
+func (r Report) clockAnomalies() string {
+	var anomalies []string
+	for _, phase := range r.phases() {
+		if phase.stats.ClockAnomalies > 0 {
+			anomalies = append(anomalies, fmt.Sprintf("%s=%d", phase.name, phase.stats.ClockAnomalies))
+		}
+	}
+	return strings.Join(anomalies, " ")
+}
+
+// clientDelays describes the client delays of the phases, e.g. "upload p50=120µs p90=310µs
+// p99=2.1ms max=4.0ms gc.pause=1.2ms".
+func (r Report) clientDelays() string {
+	var delays []string
+	for _, phase := range r.phases() {
+		if phase.stats.ClientDelay != nil {
+			delays = append(delays, fmt.Sprintf("%s %s", phase.name, phase.stats.ClientDelay))
+		}
+	}
+	return strings.Join(delays, ", ")
+}
+
+// failures describes the phases with failed trials, e.g. "download=10 (1.50 MiB wasted) (aborted
+// after 37 operations)".
+func (r Report) failures() string {
+	var failures []string
+	for _, phase := range r.phases() {
+		if phase.stats.Failed == 0 {
+			continue
+		}
+		failure := fmt.Sprintf("%s=%d", phase.name, phase.stats.Failed)
+		if len(phase.stats.Errors) > 0 {
+			failure += fmt.Sprintf(" (%s)", phase.stats.Errors)
+		}
+		if phase.stats.WastedBytes > 0 {
+			failure += fmt.Sprintf(" (%s wasted)", formatBytes(phase.stats.WastedBytes))
+		}
+		if phase.stats.Aborted {
+			failure += fmt.Sprintf(" (aborted after %d operations)", phase.stats.AbortedAfter)
+		}
+		failures = append(failures, failure)
+	}
+	return strings.Join(failures, " ")
+}
+
+// thresholdColor is the color of the checks on the phase, colorNone without any.
+func (r Report) thresholdColor(phase string) string {
+	color := colorNone
+	for _, t := range r.Thresholds {
+		if !strings.HasPrefix(t.Metric, phase+".") {
+			continue
+		}
+		switch {
+		case !t.Passed:
+			return colorRed
+		case t.Limit != 0 && math.Abs(t.Measured-t.Limit)/math.Abs(t.Limit) < thresholdMargin:
+			color = colorYellow
+		case color == colorNone:
+			color = colorGreen
+		}
+	}
+	return color
+}
+
+func (c Comparison) summaryLine(st styler) string {
+	return fmt.Sprintf("plain: %s | sse: %s", c.Plain.summaryLine(st), c.Encrypted.summaryLine(st))
+}
@@ -0,0 +1,117 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// builtinTemplates are selectable with "-format"; "full" is what Report.String prints.
+var builtinTemplates = map[string]string{
+	"full": `{{.}}`,
+	"brief": `{{with .Label}} Label       : {{.}}
+{{end}} Upload      : p90.time={{duration .Upload.P90Time}} p90.speed={{speed .Upload.P90Speed}} MB/s (n={{.Upload.Count}})
+ Download    : p90.time={{duration .Download.P90Time}} p90.speed={{speed .Download.P90Speed}} MB/s (n={{.Download.Count}})
+ Errors      : {{errors .}}
+`,
+}
+
+// reportFuncs are the functions available to report templates, rendering like the report does.
+var reportFuncs = template.FuncMap{
+	"duration": formatDuration,
+	"speed":    formatSpeed,
+	"bytes":    formatBytes,
+	"count":    formatByteCount,
+	"errors":   func(r Report) int { return r.errors() },
+	"passed":   func(r Report) bool { return r.Passed() },
+}
+
+// newReportTemplate parses "-report-template", or else the built-in template named by "-format".
+// It is executed against a zero Report right away so that a broken template fails the start of
+// the run rather than its end; optional sections are nil there and have to be guarded with
+// "with" or "if".
+func newReportTemplate(format, path string) (*template.Template, error) {
+	var (
+		name   = path
+		source string
+	)
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		source = string(content)
+	} else {
+		var ok bool
+		if source, ok = builtinTemplates[format]; !ok {
+			names := make([]string, 0, len(builtinTemplates))
+			for name := range builtinTemplates {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf(`unknown format %q, expected one of %s`, format, strings.Join(names, ", "))
+		}
+		name = format
+	}
+	tmpl, err := template.New(name).Funcs(reportFuncs).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, Report{}); err != nil {
+		return nil, fmt.Errorf(`executing against an empty report: %w`, err)
+	}
+	return tmpl, nil
+}
+
+func renderReport(tmpl *template.Template, r Report) string {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, r); err != nil {
+		log.Printf(`WARNING: unable to render the report template, printing the full report: %v`, err)
+		return r.String()
+	}
+	return sb.String()
+}
+
+// writeReportFields lists what report templates can refer to: the fields of the Report with
+// their types (nil when a pointer is not set), then the functions.
+func writeReportFields(w io.Writer) {
+	writeFields(w, ".", reflect.TypeOf(Report{}))
+	funcs := make([]string, 0, len(reportFuncs))
+	for name, fn := range reportFuncs {
+		funcs = append(funcs, fmt.Sprintf("%s %s", name, reflect.TypeOf(fn)))
+	}
+	sort.Strings(funcs)
+	fmt.Fprintf(w, "\nFunctions:\n")
+	for _, fn := range funcs {
+		fmt.Fprintf(w, "  %s\n", fn)
+	}
+}
+
+func writeFields(w io.Writer, path string, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fieldPath := path + field.Name
+		fmt.Fprintf(w, "%s %s\n", fieldPath, field.Type)
+
+		inner := field.Type
+		for inner.Kind() == reflect.Pointer || inner.Kind() == reflect.Slice || inner.Kind() == reflect.Map {
+			if inner.Kind() != reflect.Pointer {
+				fieldPath += "[]"
+			}
+			inner = inner.Elem()
+		}
+		if inner.Kind() == reflect.Struct && inner != reflect.TypeOf(time.Time{}) {
+			writeFields(w, fieldPath+".", inner)
+		}
+	}
+}
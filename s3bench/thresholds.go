@@ -0,0 +1,172 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exit codes besides 0 (passed) and 1 (any other failure).
+const (
+	exitAuthFailed       = 2
+	exitThresholdsFailed = 3
+	// exitCleanupIncomplete is a run which produced its results but left objects behind.
+	exitCleanupIncomplete = 4
+	// exitInterrupted is what shells report for SIGINT.
+	exitInterrupted = 130
+)
+
+// threshold is a check like "upload.p90_time<2s" or "download.p90_speed>=100" (MB/s).
+type threshold struct {
+	expr     string
+	metric   string
+	operator string
+	limit    float64
+	isTime   bool
+}
+
+type ThresholdResult struct {
+	Check    string  `json:"check"`
+	Metric   string  `json:"metric"`
+	Measured float64 `json:"measured"`
+	Limit    float64 `json:"limit"`
+	Passed   bool    `json:"passed"`
+}
+
+type thresholdFlags []threshold
+
+func (t *thresholdFlags) String() string {
+	exprs := make([]string, 0, len(*t))
+	for _, th := range *t {
+		exprs = append(exprs, th.expr)
+	}
+	return strings.Join(exprs, ",")
+}
+
+func (t *thresholdFlags) Set(value string) error {
+	th, err := parseThreshold(value)
+	if err != nil {
+		return err
+	}
+	*t = append(*t, th)
+	return nil
+}
+
+func parseThreshold(expr string) (threshold, error) {
+	th := threshold{expr: expr}
+	for _, operator := range []string{"<=", ">=", "<", ">"} {
+		if i := strings.Index(expr, operator); i > 0 {
+			th.metric, th.operator = strings.TrimSpace(expr[:i]), operator
+			expr = strings.TrimSpace(expr[i+len(operator):])
+			break
+		}
+	}
+	if th.operator == "" {
+		return th, fmt.Errorf(`threshold %q has no comparison operator`, th.expr)
+	}
+	if _, err := thresholdMetric(Report{}, th.metric); err != nil {
+		return th, err
+	}
+
+	th.isTime = strings.HasSuffix(th.metric, "_time")
+	if th.isTime {
+		d, err := time.ParseDuration(expr)
+		if err != nil {
+			return th, fmt.Errorf(`threshold %q: %v`, th.expr, err)
+		}
+		th.limit = float64(d)
+		return th, nil
+	}
+
+	limit, err := strconv.ParseFloat(expr, 64)
+	if err != nil {
+		return th, fmt.Errorf(`threshold %q: invalid number %q`, th.expr, expr)
+	}
+	th.limit = limit
+	return th, nil
+}
+
+func thresholdMetric(r Report, metric string) (float64, error) {
+	phase, name, _ := strings.Cut(metric, ".")
+	var stats PhaseStats
+	switch phase {
+	case "upload":
+		stats = r.Upload
+	case "download":
+		stats = r.Download
+	default:
+		return 0, fmt.Errorf(`unknown threshold phase in %q`, metric)
+	}
+
+	switch name {
+	case "avg_time":
+		return float64(stats.AvgTime), nil
+	case "p90_time":
+		return float64(stats.P90Time), nil
+	case "avg_speed":
+		return stats.AvgSpeed, nil
+	case "p90_speed":
+		return stats.P90Speed, nil
+	case "throughput":
+		return stats.Throughput, nil
+	default:
+		return 0, fmt.Errorf(`unknown threshold metric in %q`, metric)
+	}
+}
+
+func evaluateThresholds(r Report, thresholds []threshold) []ThresholdResult {
+	var results []ThresholdResult
+	for _, th := range thresholds {
+		measured, _ := thresholdMetric(r, th.metric)
+		var passed bool
+		switch th.operator {
+		case "<":
+			passed = measured < th.limit
+		case "<=":
+			passed = measured <= th.limit
+		case ">":
+			passed = measured > th.limit
+		case ">=":
+			passed = measured >= th.limit
+		}
+		results = append(results, ThresholdResult{Check: th.expr, Metric: th.metric, Measured: measured, Limit: th.limit, Passed: passed})
+	}
+	return results
+}
+
+// Passed tells whether all threshold checks of the report hold and neither the listing check nor
+// verification found missing or corrupted objects.
+func (r Report) Passed() bool {
+	if r.Listing != nil && r.Listing.failures() > 0 {
+		return false
+	}
+	if r.Verification != nil && len(r.Verification.Failures) > 0 {
+		return false
+	}
+	if r.Blocks != nil && len(r.Blocks.Corrupted) > 0 {
+		return false
+	}
+	for _, result := range r.Thresholds {
+		if !result.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+func (t ThresholdResult) String() string {
+	status := "PASS"
+	if !t.Passed {
+		status = "FAIL"
+	}
+	return fmt.Sprintf("  %s %s (measured %s)\n", status, t.Check, t.measured())
+}
+
+func (t ThresholdResult) measured() string {
+	if strings.HasSuffix(t.Metric, "_time") {
+		return time.Duration(t.Measured).String()
+	}
+	return strconv.FormatFloat(t.Measured, 'f', 2, 64)
+}
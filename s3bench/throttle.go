@@ -0,0 +1,80 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// throttleSlices is how many reads a second of the rate is split into at most, so that the
+// reader keeps to the rate smoothly rather than in bursts.
+const throttleSlices = 50
+
+// throttledReader reads no faster than rate bytes per second on average since its first read.
+// It hides the io.Seeker of the reader, so that the consumer reads it front to back at the pace.
+type throttledReader struct {
+	io.Reader
+	rate  float64
+	start time.Time
+	read  int64
+}
+
+func newThrottledReader(r io.Reader, rate int64) *throttledReader {
+	return &throttledReader{Reader: r, rate: float64(rate)}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+	if slice := int(t.rate / throttleSlices); slice > 0 && len(p) > slice {
+		p = p[:slice]
+	}
+	n, err := t.Reader.Read(p)
+	t.read += int64(n)
+	due := time.Duration(float64(t.read) / t.rate * float64(time.Second))
+	if ahead := due - time.Since(t.start); ahead > 0 {
+		time.Sleep(ahead)
+	}
+	return n, err
+}
+
+// parseRate parses a rate like "20MB" or "20MB/s" into bytes per second.
+func parseRate(value string) (int64, error) {
+	return parseByteSize(strings.TrimSuffix(strings.TrimSpace(value), "/s"))
+}
+
+// payload is the body of an upload try, produced no faster than "-upload-pace".
+func (r runner) payload(data []byte) io.Reader {
+	if r.uploadPace == 0 {
+		return bytes.NewReader(data)
+	}
+	return newThrottledReader(bytes.NewReader(data), r.uploadPace)
+}
+
+// UploadPace compares the uploads to the "-upload-pace" their payload was produced at, in MB/s.
+// Whatever an upload falls short of the pace is spent beyond the producer: on the requests, the
+// network and the server.
+type UploadPace struct {
+	Pace     float64 `json:"pace"`
+	AvgSpeed float64 `json:"avg_speed"`
+	P90Speed float64 `json:"p90_speed"`
+	// Overhead is the share of the average upload time beyond what the pace takes, in percent.
+	Overhead float64 `json:"overhead_pct"`
+}
+
+func newUploadPace(pace int64, upload PhaseStats) *UploadPace {
+	p := &UploadPace{Pace: float64(pace) / 1024 / 1024, AvgSpeed: upload.AvgSpeed, P90Speed: upload.P90Speed}
+	if upload.AvgSpeed > 0 {
+		p.Overhead = (1 - upload.AvgSpeed/p.Pace) * 100
+	}
+	return p
+}
+
+func (p UploadPace) String() string {
+	return fmt.Sprintf(" Pace        : upload.pace=%s MB/s avg.speed=%s MB/s p90.speed=%s MB/s (%.1f%% of the time beyond the producer)\n",
+		formatSpeed(p.Pace), formatSpeed(p.AvgSpeed), formatSpeed(p.P90Speed), p.Overhead)
+}
@@ -0,0 +1,97 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timing is the end-to-end wall-clock time of the parts of a run. WarmUp is the part of the
+// upload and download phases spent in ramp-up; Total is the setup plus everything after it.
+type Timing struct {
+	Setup        time.Duration `json:"setup"`
+	WarmUp       time.Duration `json:"warm_up"`
+	Listing      time.Duration `json:"listing,omitempty"`
+	Upload       time.Duration `json:"upload"`
+	ListingCheck time.Duration `json:"listing_check,omitempty"`
+	Overwrite    time.Duration `json:"overwrite,omitempty"`
+	Staging      time.Duration `json:"staging,omitempty"`
+	Gap          time.Duration `json:"gap,omitempty"`
+	Download     time.Duration `json:"download"`
+	CacheProbe   time.Duration `json:"cache_probe,omitempty"`
+	Transformed  time.Duration `json:"transformed,omitempty"`
+	AltEndpoint  time.Duration `json:"alt_endpoint,omitempty"`
+	Replication  time.Duration `json:"replication,omitempty"`
+	Miss         time.Duration `json:"miss,omitempty"`
+	Verify       time.Duration `json:"verify,omitempty"`
+	Cleanup      time.Duration `json:"cleanup"`
+	Total        time.Duration `json:"total"`
+}
+
+// The trial and phase durations come from the monotonic clock only: they are time.Since of, or
+// the difference between, readings of time.Now in this process, which carry a monotonic reading
+// that clock steps leave alone. Wall-clock times, e.g. the starts in the events, the server
+// Date or those restored from a state file, are never subtracted for a duration.
+
+// transferSpeed is the speed in MB/s of bytes transferred in d, 0 for a non-positive d which
+// only a clock anomaly produces.
+func transferSpeed(bytes int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(bytes) / d.Seconds() / 1024 / 1024
+}
+
+// stopwatch measures consecutive laps.
+type stopwatch struct {
+	start, last time.Time
+}
+
+func startStopwatch() *stopwatch {
+	now := time.Now()
+	return &stopwatch{start: now, last: now}
+}
+
+// lap returns the time since the previous lap.
+func (s *stopwatch) lap() time.Duration {
+	now := time.Now()
+	elapsed := now.Sub(s.last)
+	s.last = now
+	return elapsed
+}
+
+func (s *stopwatch) elapsed() time.Duration {
+	return time.Since(s.start)
+}
+
+// warmUp is how much of a phase was ramp-up.
+func warmUp(rampUp, phase time.Duration) time.Duration {
+	if phase < rampUp {
+		return phase
+	}
+	return rampUp
+}
+
+func (t Timing) String() string {
+	parts := []string{"setup " + seconds(t.Setup), "warm-up " + seconds(t.WarmUp)}
+	if t.Listing > 0 {
+		parts = append(parts, "listing "+seconds(t.Listing))
+	} else {
+		parts = append(parts, "upload "+seconds(t.Upload))
+	}
+	for _, optional := range []struct {
+		name     string
+		duration time.Duration
+	}{{"listing-check", t.ListingCheck}, {"overwrite", t.Overwrite}, {"staging", t.Staging}, {"gap", t.Gap}, {"download", t.Download}, {"cache-probe", t.CacheProbe}, {"transformed", t.Transformed}, {"alt-endpoint", t.AltEndpoint}, {"replication", t.Replication}, {"miss", t.Miss}, {"verify", t.Verify}} {
+		if optional.duration > 0 || optional.name == "download" {
+			parts = append(parts, optional.name+" "+seconds(optional.duration))
+		}
+	}
+	parts = append(parts, "cleanup "+seconds(t.Cleanup), "total "+seconds(t.Total))
+	return fmt.Sprintf(" Timing      : %s\n", strings.Join(parts, ", "))
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.2fs", d.Seconds())
+}
@@ -0,0 +1,109 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracing exports a span per trial over OTLP/gRPC. A nil tracing does nothing, which keeps
+// the OpenTelemetry SDK entirely uninitialized unless "-otel-endpoint" is given.
+type tracing struct {
+	provider   *sdktrace.TracerProvider
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+func newTracing(endpoint string, insecure bool) (*tracing, error) {
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+	if insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+	// The exporter connects lazily, so an unreachable collector never delays the run.
+	exporter, err := otlptracegrpc.New(context.Background(), options...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The batch processor drops spans instead of blocking when its queue is full.
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName("s3-simple-benchmarker"))),
+	)
+	return &tracing{
+		provider:   provider,
+		tracer:     provider.Tracer("github.com/thekondor/s3-simple-benchmarker"),
+		propagator: propagation.TraceContext{},
+	}, nil
+}
+
+func (t *tracing) startTrial(ctx context.Context, name, bucket, key string, size int64) (context.Context, trace.Span) {
+	if t == nil {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return t.tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("bucket", bucket),
+		attribute.String("key", key),
+		attribute.Int64("bytes", size),
+	))
+}
+
+// endTrial ends the span of a trial which took attempt tries, e.g. the retried uploads.
+func endTrial(span trace.Span, attempt int, err error) {
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(attribute.Int("attempt", attempt))
+	if err != nil {
+		span.SetAttributes(attribute.String("result", "error"))
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(attribute.String("result", "ok"))
+	}
+	span.End()
+}
+
+// transport propagates the trial's trace context to the server via request headers.
+func (t *tracing) transport(base http.RoundTripper) http.RoundTripper {
+	if t == nil {
+		return base
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		t.propagator.Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+		return base.RoundTrip(req)
+	})
+}
+
+// shutdown flushes queued spans.
+func (t *tracing) shutdown() error {
+	if t == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return t.provider.Shutdown(ctx)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// traceContinueWait records how long the upload waited for its "100 Continue".
+func traceContinueWait(span trace.Span, wait time.Duration) {
+	if span.IsRecording() {
+		span.SetAttributes(attribute.Int64("http.continue_wait_ns", int64(wait)))
+	}
+}
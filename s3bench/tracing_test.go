@@ -0,0 +1,57 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// spanAttribute is the value of the attribute key of span, if set.
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestTrialSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer provider.Shutdown(context.Background())
+
+	// Every upload fails twice, its retry included, so that there is nothing to download.
+	store, requests := newTestStore(t)
+	r := newTestRunner(t, newFaultInjector([]fault{{operation: "put", probability: 1}}, 1).wrap(store), requests, 3, 100)
+	r.uploadRetries = 1
+	r.tracing = &tracing{provider: provider, tracer: provider.Tracer("test"), propagator: propagation.TraceContext{}}
+	r.run()
+
+	attempts := map[string][]int64{}
+	for _, span := range recorder.Ended() {
+		attempt, ok := spanAttribute(span, "attempt")
+		if !ok {
+			t.Errorf("%s span without an attempt", span.Name())
+		}
+		if result, _ := spanAttribute(span, "result"); result.AsString() != "error" {
+			t.Errorf("%s span of the result %q, want error", span.Name(), result.AsString())
+		}
+		attempts[span.Name()] = append(attempts[span.Name()], attempt.AsInt64())
+	}
+	for name, want := range map[string]int64{"s3bench.upload": 2, "s3bench.download": 1} {
+		if len(attempts[name]) != 3 {
+			t.Errorf("%d %s spans, want 3", len(attempts[name]), name)
+		}
+		for _, attempt := range attempts[name] {
+			if attempt != want {
+				t.Errorf("%s span of attempt %d, want %d", name, attempt, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// resolveOverride pins connections to host:port to a fixed address, like curl's "--resolve".
+// The request keeps the original host, so TLS SNI and request signing are unaffected.
+type resolveOverride struct {
+	hostport string
+	address  string
+}
+
+func (o resolveOverride) String() string {
+	return o.hostport + ":" + o.address
+}
+
+type resolveFlags []resolveOverride
+
+func (r *resolveFlags) String() string {
+	overrides := make([]string, 0, len(*r))
+	for _, o := range *r {
+		overrides = append(overrides, o.String())
+	}
+	return strings.Join(overrides, ",")
+}
+
+func (r *resolveFlags) Set(value string) error {
+	o, err := parseResolve(value)
+	if err != nil {
+		return err
+	}
+	*r = append(*r, o)
+	return nil
+}
+
+func parseResolve(value string) (resolveOverride, error) {
+	parts := strings.SplitN(value, ":", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		return resolveOverride{}, fmt.Errorf(`%q is not of the "host:port:address" form`, value)
+	}
+	address := strings.TrimSuffix(strings.TrimPrefix(parts[2], "["), "]")
+	if net.ParseIP(address) == nil {
+		return resolveOverride{}, fmt.Errorf(`%q: %q is not an IP address`, value, parts[2])
+	}
+	return resolveOverride{hostport: net.JoinHostPort(parts[0], parts[1]), address: address}, nil
+}
+
+func (r resolveFlags) lookup(hostport string) (string, bool) {
+	for _, o := range r {
+		if strings.EqualFold(o.hostport, hostport) {
+			return o.address, true
+		}
+	}
+	return "", false
+}
+
+func (r resolveFlags) strings() []string {
+	if len(r) == 0 {
+		return nil
+	}
+	overrides := make([]string, 0, len(r))
+	for _, o := range r {
+		overrides = append(overrides, o.String())
+	}
+	return overrides
+}
+
+// checkBindable fails when connections cannot originate from ip, e.g. because no local
+// interface has it assigned.
+func checkBindable(ip net.IP) error {
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: ip})
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+// IP versions of "-ip-version".
+const (
+	ipAny = "any"
+	ipV4  = "4"
+	ipV6  = "6"
+)
+
+// needsDialer tells whether the options call for newDialContext instead of minio-go's dialer.
+func needsDialer(resolve resolveFlags, localIP net.IP, ipVersion string) bool {
+	return len(resolve) > 0 || localIP != nil || (ipVersion != "" && ipVersion != ipAny)
+}
+
+// newDialContext mirrors the dialer of minio.DefaultTransport, binds it to localIP (if set),
+// restricts it to ipVersion and applies the overrides. Failures name the IP version attempted.
+func newDialContext(resolve resolveFlags, localIP net.IP, ipVersion string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if localIP != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: localIP}
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if address, ok := resolve.lookup(addr); ok {
+			_, port, _ := net.SplitHostPort(addr)
+			addr = net.JoinHostPort(address, port)
+		}
+		if ipVersion == ipV4 || ipVersion == ipV6 {
+			network = "tcp" + ipVersion
+		}
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, fmt.Errorf(`%w (%s)`, err, attemptedFamily(err, ipVersion))
+		}
+		return conn, nil
+	}
+}
+
+// attemptedFamily names the IP version a failed dial used, as far as it is known.
+func attemptedFamily(err error, ipVersion string) string {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		if addr, ok := opErr.Addr.(*net.TCPAddr); ok {
+			return ipFamily(addr.IP)
+		}
+	}
+	if ipVersion == ipV4 || ipVersion == ipV6 {
+		return "IPv" + ipVersion
+	}
+	return "any IP version"
+}
+
+func ipFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return "IPv4"
+	}
+	return "IPv6"
+}
+
+func validateIPVersion(ipVersion string, localIP net.IP) error {
+	switch ipVersion {
+	case ipAny, ipV4, ipV6:
+	default:
+		return fmt.Errorf(`unknown IP version %q, expected %q, %q or %q`, ipVersion, ipAny, ipV4, ipV6)
+	}
+	if localIP != nil && ipVersion != ipAny && ipFamily(localIP) != "IPv"+ipVersion {
+		return fmt.Errorf(`the local address %s is not IPv%s`, localIP, ipVersion)
+	}
+	return nil
+}
+
+// withExpectContinue makes uploads ask for a "100 Continue" before their body is sent, which
+// minio-go never does by itself, and waits up to timeout for it.
+func withExpectContinue(transport *http.Transport, timeout time.Duration) http.RoundTripper {
+	transport.ExpectContinueTimeout = timeout
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodPut && req.ContentLength != 0 {
+			req = req.Clone(req.Context())
+			req.Header.Set("Expect", "100-continue")
+		}
+		return transport.RoundTrip(req)
+	})
+}
+
+// withHostHeader sends the requests with host as their Host header while they keep going to the
+// endpoint. minio-go signs a request before its transport sees it, so the signature still covers
+// the endpoint host; whatever routes on the Host header has to verify against, or restore, that
+// one. Requests to another host, which a virtual-host-style bucket lookup would make, are refused
+// rather than sent with a Host header they were not meant for.
+func withHostHeader(base http.RoundTripper, host, endpointHost string) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if !strings.EqualFold(req.URL.Hostname(), endpointHost) {
+			return nil, fmt.Errorf(`refusing to send "-host-header" %s to %s, the requests are signed for the endpoint %s`, host, req.URL.Hostname(), endpointHost)
+		}
+		req = req.Clone(req.Context())
+		req.Host = host
+		return base.RoundTrip(req)
+	})
+}
+
+// validateHostOverride checks the "-sni-host" or "-host-header" value, a host name with an
+// optional port for the Host header.
+func validateHostOverride(value string, portAllowed bool) error {
+	host := value
+	if portAllowed {
+		if h, port, err := net.SplitHostPort(value); err == nil {
+			if port == "" {
+				return fmt.Errorf(`%q has an empty port`, value)
+			}
+			host = h
+		}
+	}
+	if host == "" || strings.ContainsAny(host, "/:?#@ \t") {
+		return fmt.Errorf(`%q is not a host name`, value)
+	}
+	return nil
+}
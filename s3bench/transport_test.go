@@ -0,0 +1,110 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// tlsRecorder is a TLS server answering every request, which records the server name of the
+// handshakes and the Host and Authorization headers of the requests.
+type tlsRecorder struct {
+	*httptest.Server
+	mu                        sync.Mutex
+	serverNames, hosts, paths []string
+	authorization             string
+}
+
+func newTLSRecorder(t *testing.T) *tlsRecorder {
+	t.Helper()
+	r := &tlsRecorder{}
+	r.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.hosts, r.paths, r.authorization = append(r.hosts, req.Host), append(r.paths, req.URL.Path), req.Header.Get("Authorization")
+	}))
+	r.Server.TLS = &tls.Config{GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.serverNames = append(r.serverNames, hello.ServerName)
+		return nil, nil
+	}}
+	r.StartTLS()
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestHostOverrides(t *testing.T) {
+	for _, tc := range []struct {
+		name                string
+		sniHost, hostHeader string
+		// serverName and host are what the server sees, the endpoint host for the empty ones.
+		serverName, host string
+	}{
+		// Without SNI for an IP address.
+		{"none", "", "", "", ""},
+		// The certificate of httptest is valid for example.com as well.
+		{"-sni-host", "example.com", "", "example.com", ""},
+		{"-host-header", "", "s3.example.com", "", "s3.example.com"},
+		{"both", "example.com", "s3.example.com:8443", "example.com", "s3.example.com:8443"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTLSRecorder(t)
+			endpoint := server.URL
+			roots := x509.NewCertPool()
+			roots.AddCert(server.Certificate())
+			store, err := newObjectStore(endpoint, clientOptions{
+				accessKey: "access", secretKey: "secret", signature: signatureV4, region: "us-east-1",
+				rootCAs: roots, sniHost: tc.sniHost, hostHeader: tc.hostHeader, requests: &requestCounter{}, wire: newWireCounter(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.BucketExists(context.Background(), testBucket); err != nil {
+				t.Fatalf("the certificate was not verified against the server name: %v", err)
+			}
+
+			host := tc.host
+			if host == "" {
+				host = strings.TrimPrefix(endpoint, "https://")
+			}
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			if len(server.serverNames) != 1 || server.serverNames[0] != tc.serverName {
+				t.Errorf("server names %q, want %q", server.serverNames, tc.serverName)
+			}
+			if len(server.hosts) != 1 || server.hosts[0] != host {
+				t.Errorf("Host headers %q, want %q", server.hosts, host)
+			}
+			// The bucket is addressed by the path whatever the Host header, still signed.
+			if len(server.paths) != 1 || server.paths[0] != "/"+testBucket+"/" || !strings.Contains(server.authorization, "SignedHeaders=host;") {
+				t.Errorf("paths %q with the authorization %q, want the path of the bucket and a signed host", server.paths, server.authorization)
+			}
+		})
+	}
+}
+
+func TestHostHeaderRefusesOtherHosts(t *testing.T) {
+	transport := withHostHeader(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	}), "s3.example.com", "127.0.0.1")
+
+	request, _ := http.NewRequest(http.MethodGet, "https://127.0.0.1:9000/bench/file-1.dat", nil)
+	if _, err := transport.RoundTrip(request); err != nil {
+		t.Errorf("a request to the endpoint: %v", err)
+	}
+	request, _ = http.NewRequest(http.MethodGet, "https://bench.127.0.0.1.nip.io:9000/file-1.dat", nil)
+	if _, err := transport.RoundTrip(request); err == nil || !strings.Contains(err.Error(), "refusing") {
+		t.Errorf("a request to another host: %v", err)
+	}
+
+	if _, err := newObjectStore("http://127.0.0.1:9000", clientOptions{signature: signatureV4, sniHost: "example.com"}); err == nil || !strings.Contains(err.Error(), "needs a TLS endpoint") {
+		t.Errorf(`"-sni-host" of a plain HTTP endpoint: %v`, err)
+	}
+}
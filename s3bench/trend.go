@@ -0,0 +1,152 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// minTrendTrials is the fewest trials a trend is looked for in: each decile needs one.
+	minTrendTrials = 10
+	// trendTStat is the t-statistic of the slope above which a trend counts as significant,
+	// about 95% confidence for all but the smallest phases.
+	trendTStat = 2.0
+	// minTrendChange is how much the last decile has to differ from the first one (in percent)
+	// for a significant trend to be worth a note; large phases make tiny slopes significant.
+	minTrendChange = 10.0
+)
+
+// TrendStats is the least squares fit of the latency against the trial number of a phase,
+// e.g. a backend slowing down as it fills up. Slope is in nanoseconds per trial;
+// LastVsFirst compares the mean latency of the last decile of trials to the first one's, in
+// percent. Significant trends are both statistically so and at least minTrendChange.
+type TrendStats struct {
+	Slope           float64       `json:"slope"`
+	TStat           float64       `json:"t_stat"`
+	Significant     bool          `json:"significant"`
+	FirstDecileMean time.Duration `json:"first_decile_mean"`
+	LastDecileMean  time.Duration `json:"last_decile_mean"`
+	LastVsFirst     float64       `json:"last_vs_first_pct"`
+}
+
+func (t TrendStats) String(phase string) string {
+	direction, comparison := "increased", "slower"
+	if t.Slope < 0 {
+		direction = "decreased"
+	}
+	if t.LastVsFirst < 0 {
+		comparison = "faster"
+	}
+	return fmt.Sprintf(" Trend       : %s latency %s ~%s per trial; last 10%% of trials were %.0f%% %s than the first 10%%\n",
+		phase, direction, formatDuration(time.Duration(math.Abs(t.Slope))), math.Abs(t.LastVsFirst), comparison)
+}
+
+// trendBins bounds the memory of a trend: the latencies are summed in at most that many bins of
+// consecutive trial numbers, which double in width as the trial numbers grow. Below that many
+// trials every bin holds a single trial and the deciles are exact.
+const trendBins = 256
+
+// trendRecorder keeps the running sums of the least squares fit of the latency against the
+// trial number, and the latencies binned by trial number for the deciles. The sums are taken
+// relative to the first point, which keeps the sums of squares from cancelling out.
+type trendRecorder struct {
+	n                     int
+	x0, y0                float64
+	sx, sy, sxx, sxy, syy float64
+	width                 int
+	bins                  []trendBin
+}
+
+type trendBin struct {
+	count int
+	sum   time.Duration
+}
+
+func (t *trendRecorder) add(trial int, duration time.Duration) {
+	if t.n == 0 {
+		t.x0, t.y0, t.width = float64(trial), float64(duration), 1
+	}
+	t.n++
+	x, y := float64(trial)-t.x0, float64(duration)-t.y0
+	t.sx += x
+	t.sy += y
+	t.sxx += x * x
+	t.sxy += x * y
+	t.syy += y * y
+
+	for trial/t.width >= trendBins {
+		for i := 0; i < len(t.bins); i += 2 {
+			merged := t.bins[i]
+			if i+1 < len(t.bins) {
+				merged.count += t.bins[i+1].count
+				merged.sum += t.bins[i+1].sum
+			}
+			t.bins[i/2] = merged
+		}
+		t.bins = t.bins[:(len(t.bins)+1)/2]
+		t.width *= 2
+	}
+	i := trial / t.width
+	for len(t.bins) <= i {
+		t.bins = append(t.bins, trendBin{})
+	}
+	t.bins[i].count++
+	t.bins[i].sum += duration
+}
+
+// stats fits the points, or returns nil when there are too few of them.
+func (t *trendRecorder) stats() *TrendStats {
+	if t.n < minTrendTrials {
+		return nil
+	}
+	n := float64(t.n)
+	sxx := t.sxx - t.sx*t.sx/n
+	sxy := t.sxy - t.sx*t.sy/n
+	syy := t.syy - t.sy*t.sy/n
+	if sxx <= 0 {
+		return nil
+	}
+
+	s := &TrendStats{Slope: sxy / sxx}
+	residuals := math.Max(syy-s.Slope*sxy, 0)
+	if se := math.Sqrt(residuals / (n - 2) / sxx); se > 0 {
+		s.TStat = s.Slope / se
+		s.Significant = math.Abs(s.TStat) > trendTStat
+	} else {
+		// A perfect fit: any slope at all is a trend.
+		s.Significant = s.Slope != 0
+	}
+
+	decile := t.n / 10
+	s.FirstDecileMean, s.LastDecileMean = t.decileMean(decile, false), t.decileMean(decile, true)
+	s.LastVsFirst = percentDelta(float64(s.FirstDecileMean), float64(s.LastDecileMean))
+	s.Significant = s.Significant && math.Abs(s.LastVsFirst) >= minTrendChange
+	return s
+}
+
+// decileMean is the mean latency of the first (or the last) k trials by trial number, taking
+// the mean of the bin the decile ends in for the part of it.
+func (t *trendRecorder) decileMean(k int, last bool) time.Duration {
+	var sum float64
+	need := k
+	for i := range t.bins {
+		bin := t.bins[i]
+		if last {
+			bin = t.bins[len(t.bins)-1-i]
+		}
+		if bin.count == 0 {
+			continue
+		}
+		take := bin.count
+		if take > need {
+			take = need
+		}
+		sum += float64(bin.sum) * float64(take) / float64(bin.count)
+		if need -= take; need == 0 {
+			break
+		}
+	}
+	return time.Duration(sum / float64(k))
+}
@@ -0,0 +1,119 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"math"
+	mathrand "math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// trendOf fits n trials of the latency base + slope*trial, give or take the noise, shuffled
+// like concurrent workers would complete them.
+func trendOf(n int, base, slope, noise time.Duration) (*trendRecorder, []time.Duration) {
+	rng := mathrand.New(mathrand.NewSource(1))
+	durations := make([]time.Duration, n)
+	for i := range durations {
+		durations[i] = base + slope*time.Duration(i) + time.Duration(rng.NormFloat64()*float64(noise))
+	}
+	t := &trendRecorder{}
+	for _, i := range rng.Perm(n) {
+		t.add(i, durations[i])
+	}
+	return t, durations
+}
+
+func meanOf(durations []time.Duration) time.Duration {
+	var sum time.Duration
+	for _, d := range durations {
+		sum += d
+	}
+	return sum / time.Duration(len(durations))
+}
+
+func TestLatencyTrend(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		n                  int
+		base, slope, noise time.Duration
+		// tStat is whether the slope is statistically significant, significant whether it is worth
+		// a note as well.
+		tStat, significant       bool
+		direction                string
+		withinSlope, lastVsFirst float64
+	}{
+		// The decile means are 14.95ms and 104.95ms, or the other way round.
+		{"slowing down", 1000, 10 * time.Millisecond, 100 * time.Microsecond, 5 * time.Millisecond, true, true, "increased ~100µs per trial", 0.02, 602},
+		{"speeding up", 1000, 110 * time.Millisecond, -100 * time.Microsecond, 5 * time.Millisecond, true, true, "decreased ~100µs per trial", 0.02, -85.7},
+		{"flat", 1000, 50 * time.Millisecond, 0, 5 * time.Millisecond, false, false, "", 0, 0},
+		// Some 0.2% slower only, short of minTrendChange.
+		{"negligible", 100000, 50 * time.Millisecond, time.Nanosecond, time.Millisecond, true, false, "", 0.3, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder, durations := trendOf(tc.n, tc.base, tc.slope, tc.noise)
+			trend := recorder.stats()
+			if trend == nil {
+				t.Fatal("no trend fitted")
+			}
+			if statistically := math.Abs(trend.TStat) > trendTStat; statistically != tc.tStat {
+				t.Errorf("t-statistic %v, want it significant=%t", trend.TStat, tc.tStat)
+			}
+			if trend.Significant != tc.significant {
+				t.Errorf("significant %t, want %t: %+v", trend.Significant, tc.significant, trend)
+			}
+			if tc.slope != 0 && math.Abs(trend.Slope-float64(tc.slope)) > math.Abs(float64(tc.slope))*tc.withinSlope {
+				t.Errorf("slope %v ns per trial, want %v", trend.Slope, float64(tc.slope))
+			}
+			if tc.lastVsFirst != 0 && math.Abs(trend.LastVsFirst-tc.lastVsFirst) > math.Abs(tc.lastVsFirst)*0.1 {
+				t.Errorf("last vs first %.1f%%, want about %.0f%%", trend.LastVsFirst, tc.lastVsFirst)
+			}
+			if tc.direction != "" && !strings.Contains(trend.String("upload"), tc.direction) {
+				t.Errorf("%q does not say %q", trend.String("upload"), tc.direction)
+			}
+
+			// The deciles are binned: the bin a decile ends in is taken by its mean for the part of it,
+			// which is off by its slope and its noise at most, for that part.
+			decile := tc.n / 10
+			first, last := meanOf(durations[:decile]), meanOf(durations[tc.n-decile:])
+			if len(recorder.bins) > trendBins {
+				t.Errorf("%d bins kept, want at most %d", len(recorder.bins), trendBins)
+			}
+			width := float64(recorder.width)
+			tolerance := time.Duration(width / float64(decile) * (width*math.Abs(float64(tc.slope)) + 6*float64(tc.noise)))
+			if d := trend.FirstDecileMean - first; d > tolerance || d < -tolerance {
+				t.Errorf("first decile mean %v, want %v±%v", trend.FirstDecileMean, first, tolerance)
+			}
+			if d := trend.LastDecileMean - last; d > tolerance || d < -tolerance {
+				t.Errorf("last decile mean %v, want %v±%v", trend.LastDecileMean, last, tolerance)
+			}
+		})
+	}
+}
+
+func TestLatencyTrendExactDeciles(t *testing.T) {
+	// Fewer trials than bins take a bin each.
+	recorder, durations := trendOf(trendBins, 10*time.Millisecond, time.Millisecond, time.Millisecond)
+	trend := recorder.stats()
+	decile := trendBins / 10
+	if first := meanOf(durations[:decile]); trend.FirstDecileMean != first {
+		t.Errorf("first decile mean %v, want exactly %v", trend.FirstDecileMean, first)
+	}
+	if last := meanOf(durations[trendBins-decile:]); trend.LastDecileMean != last {
+		t.Errorf("last decile mean %v, want exactly %v", trend.LastDecileMean, last)
+	}
+}
+
+func TestLatencyTrendTooFewTrials(t *testing.T) {
+	if recorder, _ := trendOf(minTrendTrials-1, time.Millisecond, time.Millisecond, 0); recorder.stats() != nil {
+		t.Errorf("a trend of %d trials", minTrendTrials-1)
+	}
+	// A single trial number has no slope.
+	recorder := &trendRecorder{}
+	for i := 0; i < minTrendTrials; i++ {
+		recorder.add(7, time.Duration(i)*time.Millisecond)
+	}
+	if recorder.stats() != nil {
+		t.Error("a trend of a single trial number")
+	}
+}
@@ -0,0 +1,58 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// md5ETag is the ETag of a single PUT, the MD5 of the object; multipart uploads have the MD5 of
+// the part MD5s with "-<parts>" appended instead, their checksums as well.
+var md5ETag = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
+func isMultipartETag(etag string) bool {
+	return strings.Contains(etag, "-")
+}
+
+// checkUploadInfo cross-checks what PutObject returned against the body the upload sent: the
+// size always, and with verification the checksums the server computed and an MD5 ETag as well.
+// A mismatch is an integrity error. SSE-KMS and SSE-C ETags are no MD5, only the size and the
+// checksums are checked for them.
+func (r runner) checkUploadInfo(info minio.UploadInfo, body []byte) error {
+	if info.Size != int64(len(body)) {
+		return fmt.Errorf(`the server stored %s of %s (%w)`, formatBytes(info.Size), formatBytes(int64(len(body))), errorIntegrity)
+	}
+	if r.verifySample <= 0 && r.verifyMode != verifyBlocks {
+		return nil
+	}
+	for _, checksum := range []struct {
+		algorithm minio.ChecksumType
+		returned  string
+	}{
+		{minio.ChecksumCRC32, info.ChecksumCRC32},
+		{minio.ChecksumCRC32C, info.ChecksumCRC32C},
+		{minio.ChecksumSHA1, info.ChecksumSHA1},
+		{minio.ChecksumSHA256, info.ChecksumSHA256},
+	} {
+		if checksum.returned == "" || isMultipartETag(checksum.returned) {
+			continue
+		}
+		if local := checksum.algorithm.ChecksumBytes(body).Encoded(); local != checksum.returned {
+			return fmt.Errorf(`the server returned the %s %s, the body has %s (%w)`, checksum.algorithm, checksum.returned, local, errorIntegrity)
+		}
+	}
+	etag := strings.Trim(info.ETag, `"`)
+	if !md5ETag.MatchString(etag) || r.sse != nil && r.sse.Type() != encrypt.S3 {
+		return nil
+	}
+	if sum := md5.Sum(body); !strings.EqualFold(etag, hex.EncodeToString(sum[:])) {
+		return fmt.Errorf(`the server returned the ETag %s, the MD5 of the body is %s (%w)`, etag, hex.EncodeToString(sum[:]), errorIntegrity)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+func TestCheckUploadInfo(t *testing.T) {
+	body := []byte("the body of the upload")
+	sum := md5.Sum(body)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	other := md5.Sum([]byte("another body"))
+	otherETag := hex.EncodeToString(other[:])
+	crc32c := minio.ChecksumCRC32C.ChecksumBytes(body).Encoded()
+	sha256 := minio.ChecksumSHA256.ChecksumBytes(body).Encoded()
+	ssec, err := encrypt.NewSSEC(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kms, err := encrypt.NewSSEKMS("key", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := int64(len(body))
+	for _, tc := range []struct {
+		name   string
+		info   minio.UploadInfo
+		verify bool
+		sse    encrypt.ServerSide
+		// want is part of the error, none if empty.
+		want string
+	}{
+		{"matching", minio.UploadInfo{ETag: etag, Size: size, ChecksumCRC32C: crc32c, ChecksumSHA256: sha256}, true, nil, ""},
+		{"short", minio.UploadInfo{ETag: etag, Size: size - 1}, false, nil, "stored 21 B of 22 B"},
+		{"long", minio.UploadInfo{ETag: etag, Size: size + 1}, true, nil, "stored 23 B of 22 B"},
+		// Without verification only the size is checked.
+		{"other ETag unverified", minio.UploadInfo{ETag: otherETag, Size: size}, false, nil, ""},
+		{"other ETag", minio.UploadInfo{ETag: otherETag, Size: size}, true, nil, "the MD5 of the body is " + strings.Trim(etag, `"`)},
+		{"upper case ETag", minio.UploadInfo{ETag: strings.ToUpper(etag), Size: size}, true, nil, ""},
+		{"multipart ETag", minio.UploadInfo{ETag: otherETag + "-3", Size: size}, true, nil, ""},
+		{"other ETag of SSE-S3", minio.UploadInfo{ETag: otherETag, Size: size}, true, encrypt.NewSSE(), "the MD5 of the body"},
+		{"ETag of SSE-C", minio.UploadInfo{ETag: otherETag, Size: size}, true, ssec, ""},
+		{"ETag of SSE-KMS", minio.UploadInfo{ETag: otherETag, Size: size}, true, kms, ""},
+		{"other CRC32C", minio.UploadInfo{ETag: etag, Size: size, ChecksumCRC32C: minio.ChecksumCRC32C.ChecksumBytes([]byte("x")).Encoded()}, true, nil, "the server returned the CRC32C"},
+		{"other SHA256 of SSE-C", minio.UploadInfo{ETag: otherETag, Size: size, ChecksumSHA256: minio.ChecksumSHA256.ChecksumBytes([]byte("x")).Encoded()}, true, ssec, "the server returned the SHA256"},
+		{"composite checksum", minio.UploadInfo{ETag: otherETag + "-2", Size: size, ChecksumCRC32C: "AAAAAA==-2"}, true, nil, ""},
+		{"other checksum unverified", minio.UploadInfo{ETag: etag, Size: size, ChecksumSHA256: "AAAA"}, false, nil, ""},
+	} {
+		r := runner{sse: tc.sse}
+		if tc.verify {
+			r.verifySample = 1
+		}
+		err := r.checkUploadInfo(tc.info, body)
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("%s: %v", tc.name, err)
+		case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want) || !errors.Is(err, errorIntegrity)):
+			t.Errorf("%s: %v, want an integrity error of %q", tc.name, err, tc.want)
+		}
+	}
+
+	// The payload blocks are verified as well, the upload info with them.
+	r := runner{verifyMode: verifyBlocks}
+	if err := r.checkUploadInfo(minio.UploadInfo{ETag: otherETag, Size: size}, body); !errors.Is(err, errorIntegrity) {
+		t.Errorf(`other ETag with "-verify-mode blocks": %v`, err)
+	}
+}
+
+// truncatingStore reports every other upload it stored as one byte shorter.
+type truncatingStore struct {
+	ObjectStore
+	puts atomic.Int64
+}
+
+func (s *truncatingStore) PutObject(ctx context.Context, bucketName, key string, reader io.Reader, size int64, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	info, err := s.ObjectStore.PutObject(ctx, bucketName, key, reader, size, opts)
+	if err == nil && s.puts.Add(1)%2 == 0 {
+		info.Size--
+	}
+	return info, err
+}
+
+func TestUploadInfoMismatchFailsTheTrial(t *testing.T) {
+	fs, requests := newTestStore(t)
+	report := newTestRunner(t, &truncatingStore{ObjectStore: fs}, requests, 10, 1000).run()
+
+	upload := report.Upload
+	if upload.Count != 5 || upload.Failed != 5 || upload.Errors[errorIntegrity] != 5 {
+		t.Errorf("%d uploads measured and %d failed with the errors %v, want 5 integrity errors", upload.Count, upload.Failed, upload.Errors)
+	}
+}
@@ -0,0 +1,142 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	verifyChunkSize          = 64 * 1024
+	maxReportedMismatchCount = 10
+)
+
+// Verification is the outcome of re-downloading a sample of objects and comparing them
+// byte by byte against their regenerated payloads.
+type Verification struct {
+	Seed     int64                 `json:"seed"`
+	Verified int                   `json:"verified"`
+	Failures []VerificationFailure `json:"failures,omitempty"`
+}
+
+type VerificationFailure struct {
+	Key string `json:"key"`
+	// Offsets lists the first mismatching byte offsets (at most maxReportedMismatchCount).
+	Offsets         []int64 `json:"offsets,omitempty"`
+	MismatchedBytes int64   `json:"mismatched_bytes"`
+	Size            int64   `json:"size"`
+	ExpectedSize    int64   `json:"expected_size"`
+	Error           string  `json:"error,omitempty"`
+}
+
+func (v Verification) String() string {
+	s := fmt.Sprintf(" Verification: verified=%d failed=%d seed=%d\n", v.Verified, len(v.Failures), v.Seed)
+	for _, f := range v.Failures {
+		if f.Error != "" {
+			s += fmt.Sprintf("  %s: %s\n", f.Key, f.Error)
+			continue
+		}
+		s += fmt.Sprintf("  %s: size=%s expected=%s mismatched.bytes=%s offsets=%v\n", f.Key, formatByteCount(f.Size), formatByteCount(f.ExpectedSize), formatByteCount(f.MismatchedBytes), f.Offsets)
+	}
+	return s
+}
+
+// parseFraction accepts "10%" as well as "0.1".
+func parseFraction(value string) (float64, error) {
+	percent := strings.HasSuffix(value, "%")
+	fraction, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf(`invalid fraction %q`, value)
+	}
+	if percent {
+		fraction /= 100
+	}
+	if fraction < 0 || fraction > 1 {
+		return 0, fmt.Errorf(`fraction %q is out of the [0, 1] range`, value)
+	}
+	return fraction, nil
+}
+
+// verifyFiles re-downloads a seeded random sample of the uploaded objects outside of any timed phase.
+func (r runner) verifyFiles() *Verification {
+	count := int(float64(r.storedCount())*r.verifySample + 0.5)
+	if count == 0 && r.verifySample > 0 {
+		count = 1
+	}
+
+	verification := &Verification{Seed: r.seed}
+	trials := mathrand.New(mathrand.NewSource(r.seed)).Perm(r.storedCount())[:count]
+	for _, i := range trials {
+		trial := r.storedTrial(i + 1)
+		if failure := r.verifyFile(trial); failure != nil {
+			verification.Failures = append(verification.Failures, *failure)
+		}
+		verification.Verified++
+	}
+	return verification
+}
+
+func (r runner) verifyFile(trial int) *VerificationFailure {
+	key := r.key(trial)
+	expectedSize := r.sizes.size(trial)
+	failure := &VerificationFailure{Key: key, ExpectedSize: expectedSize}
+
+	object, err := r.client.GetObject(context.Background(), r.bucket(trial), key, minio.GetObjectOptions{})
+	if err != nil {
+		failure.Error = err.Error()
+		return failure
+	}
+	defer object.Close()
+	// The plaintext is compared, not the ciphertext.
+	var body io.Reader = object
+	if r.clientCrypt != nil {
+		body = r.clientCrypt.opener(object)
+	}
+
+	var (
+		expected      = newPayloadReader(r.seed, trial, r.overwriteTrials, expectedSize)
+		actualChunk   = make([]byte, verifyChunkSize)
+		expectedChunk = make([]byte, verifyChunkSize)
+		offset        int64
+	)
+	for {
+		n, readErr := io.ReadFull(body, actualChunk)
+		if n > 0 {
+			m, _ := io.ReadFull(expected, expectedChunk[:n])
+			if r.verifyMode == verifyBlocks {
+				sealBlock(expectedChunk[:m], trial, int(offset/blockSize))
+			}
+			if !bytes.Equal(actualChunk[:m], expectedChunk[:m]) {
+				for j := 0; j < m; j++ {
+					if actualChunk[j] != expectedChunk[j] {
+						failure.MismatchedBytes++
+						if len(failure.Offsets) < maxReportedMismatchCount {
+							failure.Offsets = append(failure.Offsets, offset+int64(j))
+						}
+					}
+				}
+			}
+			offset += int64(n)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			failure.Error = fmt.Sprintf(`unable to receive after %d bytes: %v`, offset, readErr)
+			return failure
+		}
+	}
+
+	failure.Size = offset
+	if failure.MismatchedBytes == 0 && failure.Size == expectedSize {
+		return nil
+	}
+	return failure
+}
@@ -0,0 +1,22 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+const appName = "s3-simple-benchmarker"
+
+// version is set at build time, e.g. with
+// `-ldflags "-X github.com/thekondor/s3-simple-benchmarker/s3bench.version=$(git describe --tags)"`.
+var version = "dev"
+
+// appVersion is the version minio-go puts into the User-Agent after the app name, followed by
+// the "-user-agent-suffix" if any.
+func appVersion(suffix string) string {
+	if suffix == "" {
+		return version
+	}
+	return version + " " + suffix
+}
+
+// userAgent is what the tool appends to minio-go's own User-Agent.
+func userAgent(suffix string) string {
+	return appName + "/" + appVersion(suffix)
+}
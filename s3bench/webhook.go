@@ -0,0 +1,108 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const webhookAttempts = 3
+
+// webhook posts the outcome of a run to a chat or a generic HTTP endpoint. A nil webhook does nothing.
+type webhook struct {
+	url    string
+	format string
+	always bool
+	label  string
+}
+
+func newWebhook(url, format, on, label string) (*webhook, error) {
+	if format != "slack" && format != "generic" {
+		return nil, fmt.Errorf(`unsupported format %q`, format)
+	}
+	if on != "failure" && on != "always" {
+		return nil, fmt.Errorf(`unsupported "-webhook-on" value %q`, on)
+	}
+	return &webhook{url: url, format: format, always: on == "always", label: label}, nil
+}
+
+type webhookPayload struct {
+	Label   string `json:"label,omitempty"`
+	Passed  bool   `json:"passed"`
+	Error   string `json:"error,omitempty"`
+	Summary string `json:"summary"`
+	// SummaryLine is the "-summary-line" rendering of the report, without colors.
+	SummaryLine string `json:"summary_line,omitempty"`
+	Report      any    `json:"report,omitempty"`
+}
+
+// notify sends the report (a Report or a Comparison) of a finished run, or the error which ended it.
+func (w *webhook) notify(report fmt.Stringer, passed bool, failure error) {
+	if w == nil || (passed && failure == nil && !w.always) {
+		return
+	}
+
+	payload := webhookPayload{Label: w.label, Passed: passed && failure == nil}
+	title := "s3-simple-benchmarker"
+	if w.label != "" {
+		title += " [" + w.label + "]"
+	}
+	if failure != nil {
+		payload.Error = failure.Error()
+		payload.Summary = fmt.Sprintf("%s FAILED: %v", title, failure)
+	} else {
+		status := "PASSED"
+		if !passed {
+			status = "FAILED"
+		}
+		payload.Summary = fmt.Sprintf("%s %s\n%s", title, status, report)
+		payload.Report = report
+		if liner, ok := report.(interface{ summaryLine(styler) string }); ok {
+			payload.SummaryLine = liner.summaryLine(styler{})
+			payload.Summary += "\n" + payload.SummaryLine
+		}
+	}
+
+	var envelope any = payload
+	if w.format == "slack" {
+		envelope = struct {
+			Text string `json:"text"`
+		}{"```\n" + payload.Summary + "```"}
+	}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		log.Printf(`WARNING: unable to encode webhook payload: %v`, err)
+		return
+	}
+
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err = w.post(body)
+		if err == nil {
+			return
+		}
+		if attempt == webhookAttempts {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	log.Printf(`WARNING: unable to deliver webhook after %d attempts: %v`, webhookAttempts, err)
+}
+
+func (w *webhook) post(body []byte) error {
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf(`unexpected status %s`, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WindowStats summarizes the trials of a phase which completed within one time window.
+type WindowStats struct {
+	Record  string        `json:"record"`
+	Variant string        `json:"variant,omitempty"`
+	Phase   string        `json:"phase"`
+	Start   time.Time     `json:"start"`
+	Count   int           `json:"count"`
+	Mean    time.Duration `json:"mean"`
+	P90     time.Duration `json:"p90"`
+}
+
+// windowRecorder summarizes each window as soon as it closes, so memory stays bounded by
+// the trials of a single window. A nil windowRecorder does nothing.
+type windowRecorder struct {
+	mu      sync.Mutex
+	variant string
+	phase   string
+	size    time.Duration
+	start   time.Time
+	events  *eventWriter
+
+	current int
+	// open are the samples of the current window, summarized as the Results of a Run are.
+	open   Results
+	closed []WindowStats
+}
+
+func newWindowRecorder(variant, phase string, size time.Duration, events *eventWriter, method percentileMethod) *windowRecorder {
+	if size <= 0 {
+		return nil
+	}
+	return &windowRecorder{variant: variant, phase: phase, size: size, start: time.Now(), events: events, open: newResults(method)}
+}
+
+func (w *windowRecorder) wrap(newOperation operationFactory) operationFactory {
+	if w == nil {
+		return newOperation
+	}
+	return func(worker int) operation {
+		op := newOperation(worker)
+		return func(trial int, stage string) sample {
+			s := op(trial, stage)
+			if s.err != nil || s.skipped {
+				return s
+			}
+			w.record(s)
+			return s
+		}
+	}
+}
+
+func (w *windowRecorder) record(s sample) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Trials finishing slightly out of order around a boundary land in the still open window.
+	for window := int(s.start.Add(s.duration).Sub(w.start) / w.size); window > w.current; {
+		w.closeCurrent()
+	}
+	// The order of the samples does not matter to the summary, they are not sorted.
+	w.open.Samples[w.phase] = append(w.open.Samples[w.phase], resultSample(s))
+}
+
+func (w *windowRecorder) closeCurrent() {
+	stats := WindowStats{
+		Record:  "window",
+		Variant: w.variant,
+		Phase:   w.phase,
+		Start:   w.start.Add(time.Duration(w.current) * w.size),
+		Count:   len(w.open.Samples[w.phase]),
+		Mean:    w.open.Mean(w.phase),
+		P90:     w.open.Percentile(w.phase, 0.9),
+	}
+	w.closed = append(w.closed, stats)
+	w.events.write(stats)
+
+	w.current++
+	w.open = newResults(w.open.method)
+}
+
+// close summarizes the last, partial window and returns all of them.
+func (w *windowRecorder) close() []WindowStats {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.open.Samples[w.phase]) > 0 {
+		w.closeCurrent()
+	}
+	return w.closed
+}
+
+func formatWindows(windows []WindowStats) string {
+	var sb strings.Builder
+	sb.WriteString(" Windows     :\n")
+	fmt.Fprintf(&sb, "  %-9s %-12s %7s %14s %14s\n", "phase", "start", "count", "mean", "p90")
+	for _, w := range windows {
+		fmt.Fprintf(&sb, "  %-9s %-12s %7d %14v %14v\n", w.Phase, w.Start.Format("15:04:05.000"), w.Count, formatDuration(w.Mean), formatDuration(w.P90))
+	}
+	return sb.String()
+}
@@ -0,0 +1,108 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+)
+
+// PhaseWire is what a phase moved over the connections, TLS, HTTP framing and retried attempts
+// included, against Payload, the object bytes its trials transferred successfully. Overhead is
+// how much the wire bytes exceed the payload in percent; phases of no trials have neither.
+type PhaseWire struct {
+	Phase    string  `json:"phase"`
+	Sent     int64   `json:"sent"`
+	Received int64   `json:"received"`
+	Payload  int64   `json:"payload,omitempty"`
+	Overhead float64 `json:"overhead,omitempty"`
+}
+
+func formatWire(phases []PhaseWire) string {
+	var sb strings.Builder
+	sb.WriteString(" Wire        : bytes over the connections, TLS, HTTP and retries included\n")
+	for _, p := range phases {
+		fmt.Fprintf(&sb, "  %s: sent=%s received=%s", strings.ToLower(p.Phase), formatBytes(p.Sent), formatBytes(p.Received))
+		if p.Payload > 0 {
+			fmt.Fprintf(&sb, " payload=%s overhead=%.2f%%", formatBytes(p.Payload), p.Overhead)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+type wireCounts struct {
+	sent, received atomic.Int64
+}
+
+// wireCounter counts the bytes of the connections of all the clients into the counts of the
+// current phase. begin swaps them for fresh ones, so that a connection kept alive across phases
+// is accounted to the phase which used it. A nil wireCounter counts nothing.
+type wireCounter struct {
+	current atomic.Pointer[wireCounts]
+}
+
+func newWireCounter() *wireCounter {
+	c := &wireCounter{}
+	c.current.Store(&wireCounts{})
+	return c
+}
+
+// dialContext wraps dial to count the bytes of the connections it opens.
+func (c *wireCounter) dialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if c == nil || dial == nil {
+		return dial
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &wireConn{Conn: conn, counter: c}, nil
+	}
+}
+
+// wireConn is a connection counting its bytes into the counts current at the time.
+type wireConn struct {
+	net.Conn
+	counter *wireCounter
+}
+
+func (c *wireConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.counter.current.Load().received.Add(int64(n))
+	return n, err
+}
+
+func (c *wireConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.counter.current.Load().sent.Add(int64(n))
+	return n, err
+}
+
+// begin starts a new phase.
+func (c *wireCounter) begin() {
+	if c == nil {
+		return
+	}
+	c.current.Store(&wireCounts{})
+}
+
+// end returns the bytes of the phase since begin along with the payload of its trials, false
+// when the phase did not use the network.
+func (c *wireCounter) end(phase string, payload int64) (PhaseWire, bool) {
+	if c == nil {
+		return PhaseWire{}, false
+	}
+	counts := c.current.Swap(&wireCounts{})
+	p := PhaseWire{Phase: phase, Sent: counts.sent.Load(), Received: counts.received.Load(), Payload: payload}
+	if p.Sent == 0 && p.Received == 0 {
+		return PhaseWire{}, false
+	}
+	if payload > 0 {
+		p.Overhead = float64(p.Sent+p.Received-payload) / float64(payload) * 100
+	}
+	return p, true
+}
@@ -0,0 +1,58 @@
+// This file is a part of `github.com/thekondor/s3-simple-benchmarker`
+package s3bench
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// workerSpreadFactor is the ratio of the slowest to the fastest worker mean latency above
+// which the spread is worth a note.
+const workerSpreadFactor = 2
+
+type PerWorkerStats struct {
+	Upload   []WorkerStats `json:"upload"`
+	Download []WorkerStats `json:"download"`
+}
+
+type WorkerStats struct {
+	Worker  int           `json:"worker"`
+	Count   int           `json:"count"`
+	AvgTime time.Duration `json:"avg_time"`
+	P90Time time.Duration `json:"p90_time"`
+	Bytes   int64         `json:"bytes"`
+}
+
+func (p PerWorkerStats) String() string {
+	var sb strings.Builder
+	sb.WriteString(" Workers     :\n")
+	fmt.Fprintf(&sb, "  %-9s %6s %7s %14s %14s %14s\n", "phase", "worker", "ops", "mean", "p90", "bytes")
+	for _, phase := range []struct {
+		name  string
+		stats []WorkerStats
+	}{{"upload", p.Upload}, {"download", p.Download}} {
+		for _, w := range phase.stats {
+			fmt.Fprintf(&sb, "  %-9s %6d %7d %14v %14v %14s\n", phase.name, w.Worker, w.Count, formatDuration(w.AvgTime), formatDuration(w.P90Time), formatByteCount(w.Bytes))
+		}
+		if fastest, slowest, ok := workerSpread(phase.stats); ok {
+			fmt.Fprintf(&sb, "  NOTE: %s worker %d is %.1fx slower on average than worker %d\n",
+				phase.name, slowest.Worker, float64(slowest.AvgTime)/float64(fastest.AvgTime), fastest.Worker)
+		}
+	}
+	return sb.String()
+}
+
+// workerSpread reports the fastest and the slowest workers when their means differ by more than workerSpreadFactor.
+func workerSpread(stats []WorkerStats) (fastest, slowest WorkerStats, ok bool) {
+	for i, w := range stats {
+		if i == 0 || w.AvgTime < fastest.AvgTime {
+			fastest = w
+		}
+		if i == 0 || w.AvgTime > slowest.AvgTime {
+			slowest = w
+		}
+	}
+	ok = len(stats) > 1 && fastest.AvgTime > 0 && float64(slowest.AvgTime) > workerSpreadFactor*float64(fastest.AvgTime)
+	return fastest, slowest, ok
+}